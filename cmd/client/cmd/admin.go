@@ -0,0 +1,129 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/seeleteam/go-seele/p2p"
+	"github.com/seeleteam/go-seele/seele"
+	"github.com/spf13/cobra"
+)
+
+var nodeURL string
+
+// peersCmd represents the peers command
+var peersCmd = &cobra.Command{
+	Use:   "peers",
+	Short: "get the connected peers",
+	Long: `get the information of the connected peers
+    For example:
+		client.exe peers --ipc ~/.seele/node1/seele.ipc`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var peers []seele.AdminPeerInfo
+		if callAdmin("admin.Peers", nil, &peers) {
+			printJSON(peers)
+		}
+	},
+}
+
+// nodeinfoCmd represents the nodeinfo command
+var nodeinfoCmd = &cobra.Command{
+	Use:   "nodeinfo",
+	Short: "get the local node info",
+	Long: `get the node url, listen addresses and protocols of the local node
+    For example:
+		client.exe nodeinfo --ipc ~/.seele/node1/seele.ipc`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var info p2p.NodeInfo
+		if callAdmin("admin.NodeInfo", nil, &info) {
+			printJSON(info)
+		}
+	},
+}
+
+// addpeerCmd represents the addpeer command
+var addpeerCmd = &cobra.Command{
+	Use:   "addpeer",
+	Short: "connect to a node",
+	Long: `connect to a node with the node url
+    For example:
+		client.exe addpeer --ipc ~/.seele/node1/seele.ipc -n snode://id@127.0.0.1:39007`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var result bool
+		if callAdmin("admin.AddPeer", &nodeURL, &result) {
+			fmt.Printf("adding peer: %t\n", result)
+		}
+	},
+}
+
+// addtrustedpeerCmd represents the addtrustedpeer command
+var addtrustedpeerCmd = &cobra.Command{
+	Use:   "addtrustedpeer",
+	Short: "connect to a trusted node",
+	Long: `connect to a node with the node url, which is always allowed past the max peers
+    For example:
+		client.exe addtrustedpeer --ipc ~/.seele/node1/seele.ipc -n snode://id@127.0.0.1:39007`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var result bool
+		if callAdmin("admin.AddTrustedPeer", &nodeURL, &result) {
+			fmt.Printf("adding trusted peer: %t\n", result)
+		}
+	},
+}
+
+// removepeerCmd represents the removepeer command
+var removepeerCmd = &cobra.Command{
+	Use:   "removepeer",
+	Short: "disconnect a node",
+	Long: `disconnect a node with the node url, and remove it from the trusted nodes
+    For example:
+		client.exe removepeer --ipc ~/.seele/node1/seele.ipc -n snode://id@127.0.0.1:39007`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var result bool
+		if callAdmin("admin.RemovePeer", &nodeURL, &result) {
+			fmt.Printf("peer is connected and removed: %t\n", result)
+		}
+	},
+}
+
+// callAdmin calls the admin rpc method and prints the error if any, returns true on success.
+func callAdmin(method string, args interface{}, reply interface{}) bool {
+	client, err := dialRPC()
+	if err != nil {
+		fmt.Println(err.Error())
+		return false
+	}
+	defer client.Close()
+
+	if err = client.Call(method, args, reply); err != nil {
+		fmt.Printf("calling %s failed: %s\n", method, err.Error())
+		return false
+	}
+
+	return true
+}
+
+func printJSON(v interface{}) {
+	output, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		fmt.Printf("encoding the result failed: %s\n", err.Error())
+		return
+	}
+
+	fmt.Println(string(output))
+}
+
+func init() {
+	rootCmd.AddCommand(peersCmd)
+	rootCmd.AddCommand(nodeinfoCmd)
+
+	for _, cmd := range []*cobra.Command{addpeerCmd, addtrustedpeerCmd, removepeerCmd} {
+		cmd.Flags().StringVarP(&nodeURL, "node", "n", "", "node url, e.g. snode://id@ip:port")
+		rootCmd.AddCommand(cmd)
+	}
+}
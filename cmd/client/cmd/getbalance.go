@@ -8,7 +8,6 @@ package cmd
 import (
 	"fmt"
 	"math/big"
-	"net/rpc/jsonrpc"
 
 	"github.com/seeleteam/go-seele/common"
 	"github.com/spf13/cobra"
@@ -25,7 +24,7 @@ var getbalanceCmd = &cobra.Command{
 	Long: `For example:
 	client.exe getbalance`,
 	Run: func(cmd *cobra.Command, args []string) {
-		client, err := jsonrpc.Dial("tcp", rpcAddr)
+		client, err := dialRPC()
 		if err != nil {
 			fmt.Println(err.Error())
 			return
@@ -7,7 +7,6 @@ package cmd
 
 import (
 	"fmt"
-	"net/rpc/jsonrpc"
 
 	"github.com/spf13/cobra"
 )
@@ -19,7 +18,7 @@ var getblockheightCmd = &cobra.Command{
 	Long: `For example:
 	client.exe getblockheight`,
 	Run: func(cmd *cobra.Command, args []string) {
-		client, err := jsonrpc.Dial("tcp", rpcAddr)
+		client, err := dialRPC()
 		if err != nil {
 			fmt.Println(err)
 			return
@@ -9,11 +9,16 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/seeleteam/go-seele/rpc"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var rpcAddr string
+var (
+	rpcAddr    string
+	ipcPath    string
+	credential string
+)
 
 // rootCmd represents the base command called without any subcommands
 var rootCmd = &cobra.Command{
@@ -37,6 +42,28 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVarP(&rpcAddr, "addr", "a", "127.0.0.1:55027", "rpc address")
+	rootCmd.PersistentFlags().StringVar(&ipcPath, "ipc", "", "ipc socket path of the node, which is used instead of the rpc address if specified")
+	rootCmd.PersistentFlags().StringVar(&credential, "auth", "", "API key or JWT to authenticate with the rpc address")
+}
+
+// dialRPC connects to the ipc socket of the node if specified, otherwise the rpc address
+// which is authenticated with the credential if specified.
+func dialRPC() (*rpc.Client, error) {
+	if len(ipcPath) > 0 {
+		return rpc.Dial("unix", ipcPath)
+	}
+
+	client, err := rpc.Dial("tcp", rpcAddr)
+	if err != nil || len(credential) == 0 {
+		return client, err
+	}
+
+	if err = client.Authenticate(credential); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return client, nil
 }
 
 // initConfig reads in the config file and ENV variables if set.
@@ -35,12 +35,29 @@ type Config struct {
 	// coinbase used by the miner
 	Coinbase string
 
-  // static nodes which will be connected to find more nodes when the node starts
+	// static nodes which will be connected to find more nodes when the node starts
 	StaticNodes []string
 
+	// trusted nodes which are kept connected and always allowed past the peer limits
+	TrustedNodes []string
+
+	// max number of peers, and max number of handshaking connections for each direction. 0 is the default value
+	MaxPeers        int
+	MaxPendingPeers int
+
 	// core msg interaction uses TCP address and Kademila protocol uses UDP address
 	ListenAddr string
 
+	// transport of peer connections, "tcp" or "qvic" (reliable udp on the port next to ListenAddr). default is "tcp"
+	Transport string
+
+	// offset of the qvic udp port to the port of ListenAddr, which must be the same in the network. 0 is the default value 1
+	QvicPortOffset int
+
+	// max upload bandwidth of all peers and of each peer in bytes per second, 0 is unlimited
+	MaxUploadRate     int64
+	MaxPeerUploadRate int64
+
 	// If IsDebug is true, the log level will be DebugLevel, otherwise it is InfoLevel
 	IsDebug bool
 
@@ -74,9 +91,24 @@ func LoadConfigFromFile(configFile string) (*node.Config, error) {
 	nodeConfig.HTTPAddr = config.HTTPAddr
 	nodeConfig.HTTPCors = config.HTTPCors
 	nodeConfig.HTTPWhiteHost = config.HTTPWhiteHost
+	nodeConfig.WSAddr = config.WSAddr
+	nodeConfig.IPCPath = config.IPCPath
+	nodeConfig.DisableIPC = config.DisableIPC
+	nodeConfig.RPCAuth = config.RPCAuth
+	nodeConfig.HTTPAuth = config.HTTPAuth
+	nodeConfig.WSAuth = config.WSAuth
+	nodeConfig.RPCModules = config.RPCModules
+	nodeConfig.HTTPModules = config.HTTPModules
+	nodeConfig.WSModules = config.WSModules
+	nodeConfig.RPCLimits = config.RPCLimits
+	nodeConfig.HTTPLimits = config.HTTPLimits
+	nodeConfig.WSLimits = config.WSLimits
+	nodeConfig.MetricsAddr = config.MetricsAddr
 	nodeConfig.SeeleConfig.Coinbase = common.HexMustToAddres(config.Coinbase)
 	nodeConfig.SeeleConfig.NetworkID = config.SeeleConfig.NetworkID
 	nodeConfig.SeeleConfig.TxConf.Capacity = config.SeeleConfig.TxConf.Capacity
+	nodeConfig.SeeleConfig.SyncMode = config.SeeleConfig.SyncMode
+	nodeConfig.SeeleConfig.KeyStoreDir = config.SeeleConfig.KeyStoreDir
 
 	nodeConfig.P2P, err = GetP2pConfig(config)
 	if err != nil {
@@ -93,15 +125,13 @@ func LoadConfigFromFile(configFile string) (*node.Config, error) {
 func GetP2pConfig(config Config) (p2p.Config, error) {
 	p2pConfig := p2p.Config{}
 
-	if len(config.StaticNodes) != 0 {
-		for _, id := range config.StaticNodes {
-			n, err := discovery.NewNodeFromString(id)
-			if err != nil {
-				return p2p.Config{}, err
-			}
+	var err error
+	if p2pConfig.StaticNodes, err = getNodes(config.StaticNodes); err != nil {
+		return p2p.Config{}, err
+	}
 
-			p2pConfig.StaticNodes = append(p2pConfig.StaticNodes, n)
-		}
+	if p2pConfig.TrustedNodes, err = getNodes(config.TrustedNodes); err != nil {
+		return p2p.Config{}, err
 	}
 
 	key, err := keystore.GetKey(config.KeyFile, "")
@@ -111,5 +141,26 @@ func GetP2pConfig(config Config) (p2p.Config, error) {
 
 	p2pConfig.PrivateKey = key.PrivateKey
 	p2pConfig.ListenAddr = config.ListenAddr
+	p2pConfig.Transport = config.Transport
+	p2pConfig.QvicPortOffset = config.QvicPortOffset
+	p2pConfig.MaxPeers = config.MaxPeers
+	p2pConfig.MaxPendingPeers = config.MaxPendingPeers
+	p2pConfig.MaxUploadRate = config.MaxUploadRate
+	p2pConfig.MaxPeerUploadRate = config.MaxPeerUploadRate
 	return p2pConfig, nil
 }
+
+// getNodes parses the node urls
+func getNodes(ids []string) ([]*discovery.Node, error) {
+	var nodes []*discovery.Node
+	for _, id := range ids {
+		n, err := discovery.NewNodeFromString(id)
+		if err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, n)
+	}
+
+	return nodes, nil
+}
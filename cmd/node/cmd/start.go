@@ -11,6 +11,7 @@ import (
 	"sync"
 
 	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/light"
 	"github.com/seeleteam/go-seele/log"
 	"github.com/seeleteam/go-seele/monitor"
 	"github.com/seeleteam/go-seele/node"
@@ -48,6 +49,25 @@ var startCmd = &cobra.Command{
 
 		// Create seele service and register the service
 		slog := log.GetLogger("seele", common.PrintLog)
+		if nCfg.SeeleConfig.SyncMode == light.SyncMode {
+			// the light node only keeps the header chain, and does not mine
+			lightService, err := light.NewLightService(nCfg.DataDir, nCfg.SeeleConfig.NetworkID, slog)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+
+			if err := seeleNode.Register(lightService); err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+
+			seeleNode.Start()
+			wg.Add(1)
+			wg.Wait()
+			return
+		}
+
 		serviceContext := seele.ServiceContext{
 			DataDir: nCfg.DataDir,
 		}
@@ -0,0 +1,226 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/crypto"
+)
+
+var (
+	// ErrAccountNotFound is returned when the key file of the account is not in the directory.
+	ErrAccountNotFound = errors.New("account not found")
+
+	// ErrAccountExists is returned when the key file of the imported account is already in the directory.
+	ErrAccountExists = errors.New("account already exists")
+
+	// ErrLocked is returned when the account is not unlocked to sign.
+	ErrLocked = errors.New("account is locked")
+)
+
+// unlockedKey is a decrypted key, which is locked when the abort channel is closed.
+type unlockedKey struct {
+	key   *Key
+	abort chan struct{}
+}
+
+// Manager manages the encrypted key files in a directory, one file for each account, and the
+// keys unlocked in memory to sign without the passphrase.
+type Manager struct {
+	dir string
+
+	mutex    sync.Mutex // protects unlocked
+	unlocked map[common.Address]*unlockedKey
+}
+
+// NewManager returns a manager of the key files in the directory, which is created when the
+// first key is stored.
+func NewManager(dir string) *Manager {
+	return &Manager{
+		dir:      dir,
+		unlocked: make(map[common.Address]*unlockedKey),
+	}
+}
+
+// Dir returns the directory of the key files.
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// keyFile returns the file name of the account key.
+func (m *Manager) keyFile(address common.Address) string {
+	return filepath.Join(m.dir, strings.TrimPrefix(address.ToHex(), "0x"))
+}
+
+// Accounts returns the addresses of the key files in the directory. The files which are not
+// keys are ignored.
+func (m *Manager) Accounts() ([]common.Address, error) {
+	files, err := ioutil.ReadDir(m.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []common.Address
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(m.dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var key encryptedKey
+		if json.Unmarshal(content, &key) != nil {
+			continue
+		}
+
+		address, err := common.HexToAddress(key.Address)
+		if err != nil {
+			continue
+		}
+
+		accounts = append(accounts, address)
+	}
+
+	return accounts, nil
+}
+
+// NewAccount generates a new key, and stores it encrypted with the passphrase.
+func (m *Manager) NewAccount(passphrase string) (common.Address, error) {
+	_, privKey, err := crypto.GenerateKeyPair()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return m.Import(privKey, passphrase)
+}
+
+// Import stores the private key encrypted with the passphrase, and returns the account address.
+func (m *Manager) Import(privKey *ecdsa.PrivateKey, passphrase string) (common.Address, error) {
+	address, err := crypto.GetAddress(privKey)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	fileName := m.keyFile(*address)
+	if _, err = os.Stat(fileName); err == nil {
+		return common.Address{}, ErrAccountExists
+	}
+
+	if err = StoreKey(fileName, passphrase, &Key{*address, privKey}); err != nil {
+		return common.Address{}, err
+	}
+
+	return *address, nil
+}
+
+// getKey decrypts the key of the account with the passphrase.
+func (m *Manager) getKey(address common.Address, passphrase string) (*Key, error) {
+	key, err := GetKey(m.keyFile(address), passphrase)
+	if os.IsNotExist(err) {
+		return nil, ErrAccountNotFound
+	}
+
+	return key, err
+}
+
+// Unlock decrypts the key of the account with the passphrase, and keeps it in memory until the
+// timeout expires or it is locked. The key is kept until locked if the timeout is 0.
+func (m *Manager) Unlock(address common.Address, passphrase string, timeout time.Duration) error {
+	key, err := m.getKey(address, passphrase)
+	if err != nil {
+		return err
+	}
+
+	u := &unlockedKey{key, make(chan struct{})}
+
+	m.mutex.Lock()
+	if old := m.unlocked[address]; old != nil {
+		close(old.abort)
+	}
+
+	m.unlocked[address] = u
+	m.mutex.Unlock()
+
+	if timeout > 0 {
+		go m.expire(address, u, timeout)
+	}
+
+	return nil
+}
+
+// expire locks the unlocked key after the timeout, unless it is locked or unlocked again before.
+func (m *Manager) expire(address common.Address, u *unlockedKey, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-u.abort:
+	case <-timer.C:
+		m.mutex.Lock()
+		if m.unlocked[address] == u {
+			delete(m.unlocked, address)
+		}
+		m.mutex.Unlock()
+	}
+}
+
+// Lock removes the unlocked key of the account from memory, and returns false if the account
+// is not unlocked.
+func (m *Manager) Lock(address common.Address) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	u := m.unlocked[address]
+	if u == nil {
+		return false
+	}
+
+	close(u.abort)
+	delete(m.unlocked, address)
+
+	return true
+}
+
+// SignHash signs the hash with the unlocked key of the account.
+func (m *Manager) SignHash(address common.Address, hash []byte) (*crypto.Signature, error) {
+	m.mutex.Lock()
+	u := m.unlocked[address]
+	m.mutex.Unlock()
+
+	if u == nil {
+		return nil, ErrLocked
+	}
+
+	return crypto.NewSignature(u.key.PrivateKey, hash), nil
+}
+
+// SignHashWithPassphrase signs the hash with the key of the account decrypted with the
+// passphrase, which is not kept in memory.
+func (m *Manager) SignHashWithPassphrase(address common.Address, passphrase string, hash []byte) (*crypto.Signature, error) {
+	key, err := m.getKey(address, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.NewSignature(key.PrivateKey, hash), nil
+}
@@ -0,0 +1,71 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties/assert"
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/crypto"
+)
+
+func Test_Manager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := NewManager(filepath.Join(dir, "keys"))
+
+	// the directory is not created yet
+	accounts, err := m.Accounts()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(accounts), 0)
+
+	_, privKey, err := crypto.GenerateKeyPair()
+	if err != nil {
+		panic(err)
+	}
+
+	address, err := m.Import(privKey, "password")
+	assert.Equal(t, err, nil)
+
+	_, err = m.Import(privKey, "password")
+	assert.Equal(t, err, ErrAccountExists)
+
+	// the files which are not keys are ignored
+	assert.Equal(t, ioutil.WriteFile(filepath.Join(m.Dir(), "readme"), []byte("keys"), 0600), nil)
+
+	accounts, err = m.Accounts()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, accounts, []common.Address{address})
+
+	// sign with the unlocked key until it expires
+	hash := crypto.MustHash("data").Bytes()
+	_, err = m.SignHash(address, hash)
+	assert.Equal(t, err, ErrLocked)
+
+	assert.Equal(t, m.Unlock(address, "wrong", 0), ErrDecrypt)
+	assert.Equal(t, m.Unlock(address, "password", 100*time.Millisecond), nil)
+
+	sig, err := m.SignHash(address, hash)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, sig.Verify(&address, hash), true)
+
+	time.Sleep(300 * time.Millisecond)
+	_, err = m.SignHash(address, hash)
+	assert.Equal(t, err, ErrLocked)
+	assert.Equal(t, m.Lock(address), false)
+
+	_, err = m.SignHashWithPassphrase(*crypto.MustGenerateRandomAddress(), "password", hash)
+	assert.Equal(t, err, ErrAccountNotFound)
+}
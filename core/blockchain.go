@@ -10,15 +10,24 @@ import (
 	"errors"
 	"math/big"
 	"sync"
+	"time"
 
 	"github.com/seeleteam/go-seele/common"
 	"github.com/seeleteam/go-seele/core/state"
 	"github.com/seeleteam/go-seele/core/store"
 	"github.com/seeleteam/go-seele/core/types"
 	"github.com/seeleteam/go-seele/database"
+	"github.com/seeleteam/go-seele/event"
+	"github.com/seeleteam/go-seele/metrics"
 	"github.com/seeleteam/go-seele/miner/pow"
 )
 
+var (
+	blockImportTimer = metrics.GetOrRegisterTimer("chain_block_import_seconds", "the time to validate, process and write a block")
+	headHeightGauge  = metrics.GetOrRegisterGauge("chain_head_height", "the height of the HEAD block")
+	reorgDepth       = metrics.GetOrRegisterHistogram("chain_reorg_depth", "the number of the canonical blocks replaced by the new HEAD block", []float64{1, 2, 4, 8, 16, 32, 64, 128})
+)
+
 var (
 	// ErrBlockHashMismatch is returned when the block hash does not match the header hash.
 	ErrBlockHashMismatch = errors.New("block header hash mismatch")
@@ -33,6 +42,9 @@ var (
 	// ErrBlockInvalidHeight is returned when inserting a new header with invalid block height.
 	ErrBlockInvalidHeight = errors.New("invalid block height")
 
+	// ErrBlockInvalidDifficulty is returned when inserting a new header with nil or non-positive difficulty.
+	ErrBlockInvalidDifficulty = errors.New("invalid block difficulty")
+
 	// ErrBlockAlreadyExists is returned when inserted block already exists
 	ErrBlockAlreadyExists = errors.New("block already exists")
 
@@ -40,6 +52,13 @@ var (
 	// does not match the state root hash in block header.
 	ErrBlockStateHashMismatch = errors.New("block state hash mismatch")
 
+	// ErrTxIndexOutOfRange is returned when the tx index is out of the range of the block txs.
+	ErrTxIndexOutOfRange = errors.New("tx index out of range")
+
+	// ErrBlockLogsBloomMismatch is returned when the calculated logs bloom of the block receipts
+	// mismatches the logs bloom in the block header.
+	ErrBlockLogsBloomMismatch = errors.New("block logs bloom mismatch")
+
 	// ErrBlockEmptyTxs is returned when writing a block with empty transactions.
 	ErrBlockEmptyTxs = errors.New("empty transactions in block")
 
@@ -127,6 +146,7 @@ func NewBlockchain(bcStore store.BlockchainStore, accountStateDB database.Databa
 	blockIndex := NewBlockIndex(currentState, currentBlock, td)
 	bc.blockLeaves = NewBlockLeaves()
 	bc.blockLeaves.Add(blockIndex)
+	headHeightGauge.Update(float64(currentBlock.Header.Height))
 
 	return bc, nil
 }
@@ -162,6 +182,20 @@ func (bc *Blockchain) WriteBlock(block *types.Block) error {
 		return ErrBlockAlreadyExists
 	}
 
+	start := time.Now()
+
+	// The events of the new HEAD block are fired after the lock is released.
+	var blockStatedb *state.Statedb
+	headChanged := false
+	defer func() {
+		if headChanged {
+			event.ChainHeaderChangedEventManager.Fire(block)
+			if logs := blockStatedb.GetLogs(); len(logs) > 0 {
+				event.ChainLogsEventManager.Fire(logs)
+			}
+		}
+	}()
+
 	bc.lock.Lock()
 	defer bc.lock.Unlock()
 
@@ -175,12 +209,16 @@ func (bc *Blockchain) WriteBlock(block *types.Block) error {
 		return err
 	}
 
-	// Process the txs in the block and check the state root hash.
-	var blockStatedb *state.Statedb
-	if blockStatedb, err = bc.applyTxs(block, preBlock); err != nil {
+	// Process the txs in the block and check the logs bloom and state root hash.
+	var receipts []*types.Receipt
+	if blockStatedb, receipts, err = bc.applyTxs(block, preBlock); err != nil {
 		return err
 	}
 
+	if types.ReceiptsBloom(receipts) != block.Header.LogsBloom {
+		return ErrBlockLogsBloomMismatch
+	}
+
 	batch := bc.accountStateDB.NewBatch()
 	committed := false
 	defer func() {
@@ -218,12 +256,17 @@ func (bc *Blockchain) WriteBlock(block *types.Block) error {
 
 	// If the new block has larger TD, the canonical chain will be changed.
 	// In this case, need to update the height-to-blockHash mapping for the new canonical chain.
+	var depth int
 	if isHead {
-		if err = bc.updateHashByHeight(block); err != nil {
+		if depth, err = bc.updateHashByHeight(block); err != nil {
 			return err
 		}
 	}
 
+	if err = bc.bcStore.PutReceipts(block.HeaderHash, receipts); err != nil {
+		return err
+	}
+
 	if err = bc.bcStore.PutBlock(block, td, isHead); err != nil {
 		return err
 	}
@@ -235,10 +278,104 @@ func (bc *Blockchain) WriteBlock(block *types.Block) error {
 	}
 
 	committed = true
+	headChanged = isHead
+
+	blockImportTimer.UpdateSince(start)
+	if isHead {
+		headHeightGauge.Update(float64(block.Header.Height))
+		if depth > 0 {
+			reorgDepth.Observe(float64(depth))
+		}
+	}
 
 	return nil
 }
 
+// WriteBlockWithoutState writes the specified block into the canonical chain without processing
+// its txs, which is used by fast sync for the blocks before the pivot block whose state is not
+// downloaded. The HEAD block is not changed.
+func (bc *Blockchain) WriteBlockWithoutState(block *types.Block) error {
+	exist, err := bc.bcStore.HasBlock(block.HeaderHash)
+	if err != nil {
+		return err
+	}
+
+	if exist {
+		return ErrBlockAlreadyExists
+	}
+
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	var preBlock *types.Block
+	if preBlock, err = bc.bcStore.GetBlock(block.Header.PreviousBlockHash); err != nil {
+		return ErrBlockInvalidParentHash
+	}
+
+	if err = bc.validateBlock(block, preBlock); err != nil {
+		return err
+	}
+
+	if _, err = bc.validateMinerRewardTx(block); err != nil {
+		return err
+	}
+
+	var td *big.Int
+	if td, err = bc.bcStore.GetBlockTotalDifficulty(block.Header.PreviousBlockHash); err != nil {
+		return err
+	}
+
+	if err = bc.bcStore.PutBlock(block, td.Add(td, block.Header.Difficulty), false); err != nil {
+		return err
+	}
+
+	return bc.bcStore.PutBlockHash(block.Header.Height, block.HeaderHash)
+}
+
+// CommitFastSyncBlock sets the block of the specified hash as the HEAD block, after its state
+// is downloaded by fast sync. The blocks after it are processed with txs again.
+func (bc *Blockchain) CommitFastSyncBlock(hash common.Hash) error {
+	block, err := bc.bcStore.GetBlock(hash)
+	if err != nil {
+		return err
+	}
+
+	td, err := bc.bcStore.GetBlockTotalDifficulty(hash)
+	if err != nil {
+		return err
+	}
+
+	statedb, err := state.NewStatedb(block.Header.StateHash, bc.accountStateDB)
+	if err != nil {
+		return err
+	}
+
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	if _, err = bc.updateHashByHeight(block); err != nil {
+		return err
+	}
+
+	if err = bc.bcStore.PutBlock(block, td, true); err != nil {
+		return err
+	}
+
+	bc.blockLeaves = NewBlockLeaves()
+	bc.blockLeaves.Add(NewBlockIndex(statedb, block, td))
+	bc.headerChain.WriteHeader(block.Header)
+	headHeightGauge.Update(float64(block.Header.Height))
+
+	event.ChainHeaderChangedEventManager.Fire(block)
+
+	return nil
+}
+
+// AccountStateDB returns the account state database.
+func (bc *Blockchain) AccountStateDB() database.Database {
+	return bc.accountStateDB
+}
+
 func (bc *Blockchain) validateBlock(block, preBlock *types.Block) error {
 	if !block.HeaderHash.Equal(block.Header.Hash()) {
 		return ErrBlockHashMismatch
@@ -249,11 +386,27 @@ func (bc *Blockchain) validateBlock(block, preBlock *types.Block) error {
 		return ErrBlockTxsHashMismatch
 	}
 
-	if block.Header.Height != preBlock.Header.Height+1 {
-		return ErrBlockInvalidHeight
+	return bc.headerChain.ValidateHeader(block.Header, preBlock.Header)
+}
+
+// ValidateBlockHeader validates the header hash, transactions root hash and consensus of the
+// block, regardless of its parent and state, so that the block could be relayed before inserted.
+func (bc *Blockchain) ValidateBlockHeader(block *types.Block) error {
+	if !block.HeaderHash.Equal(block.Header.Hash()) {
+		return ErrBlockHashMismatch
+	}
+
+	txsHash := types.MerkleRootHash(block.Transactions)
+	if !txsHash.Equal(block.Header.TxHash) {
+		return ErrBlockTxsHashMismatch
 	}
 
-	return bc.engine.ValidateHeader(block.Header)
+	return bc.headerChain.validateConsensus(block.Header)
+}
+
+// HeaderChain returns the header chain of the blockchain.
+func (bc *Blockchain) HeaderChain() *HeaderChain {
+	return bc.headerChain
 }
 
 // GetStore returns the blockchain store instance.
@@ -261,24 +414,25 @@ func (bc *Blockchain) GetStore() store.BlockchainStore {
 	return bc.bcStore
 }
 
-// applyTxs processes the txs in the specified block and returns the new state DB of the block.
-// This method supposes the specified block is validated.
-func (bc *Blockchain) applyTxs(block, preBlock *types.Block) (*state.Statedb, error) {
+// applyTxs processes the txs in the specified block and returns the new state DB and
+// the tx receipts of the block. This method supposes the specified block is validated.
+func (bc *Blockchain) applyTxs(block, preBlock *types.Block) (*state.Statedb, []*types.Receipt, error) {
 	minerRewardTx, err := bc.validateMinerRewardTx(block)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	statedb, err := state.NewStatedb(preBlock.Header.StateHash, bc.accountStateDB)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	if err := updateStatedb(statedb, minerRewardTx, block.Transactions[1:]); err != nil {
-		return nil, err
+	receipts, err := updateStatedb(statedb, minerRewardTx, block.Transactions[1:])
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return statedb, nil
+	return statedb, receipts, nil
 }
 
 func (bc *Blockchain) validateMinerRewardTx(block *types.Block) (*types.Transaction, error) {
@@ -310,68 +464,99 @@ func (bc *Blockchain) validateMinerRewardTx(block *types.Block) (*types.Transact
 	return minerRewardTx, nil
 }
 
-func updateStatedb(statedb *state.Statedb, minerRewardTx *types.Transaction, txs []*types.Transaction) error {
+func updateStatedb(statedb *state.Statedb, minerRewardTx *types.Transaction, txs []*types.Transaction) ([]*types.Receipt, error) {
 	// process miner reward
-	stateObj := statedb.GetOrNewStateObject(*minerRewardTx.Data.To)
-	stateObj.AddAmount(minerRewardTx.Data.Amount)
+	ApplyTransaction(statedb, minerRewardTx, 0)
+
+	receipts := []*types.Receipt{NewReceipt(statedb, minerRewardTx, 0, 0)}
 
 	// process other txs
-	for _, tx := range txs {
-		if err := tx.Validate(statedb); err != nil {
-			return err
+	for i, tx := range txs {
+		logIndex := len(statedb.GetLogs())
+		if err := applyTx(statedb, tx); err != nil {
+			return nil, err
 		}
 
-		if tx.Data.To == nil {
-			return errContractCreationNotSupported
-		}
+		receipts = append(receipts, NewReceipt(statedb, tx, i+1, logIndex))
+	}
 
-		fromStateObj := statedb.GetOrNewStateObject(tx.Data.From)
-		fromStateObj.SubAmount(tx.Data.Amount)
-		fromStateObj.SetNonce(tx.Data.AccountNonce + 1)
+	return receipts, nil
+}
 
-		toStateObj := statedb.GetOrNewStateObject(*tx.Data.To)
-		toStateObj.AddAmount(tx.Data.Amount)
+// applyTx validates and processes the tx which is not the miner reward tx.
+func applyTx(statedb *state.Statedb, tx *types.Transaction) error {
+	if err := tx.Validate(statedb); err != nil {
+		return err
 	}
 
+	if tx.Data.To == nil {
+		return errContractCreationNotSupported
+	}
+
+	fromStateObj := statedb.GetOrNewStateObject(tx.Data.From)
+	fromStateObj.SubAmount(tx.Data.Amount)
+	fromStateObj.SetNonce(tx.Data.AccountNonce + 1)
+
+	toStateObj := statedb.GetOrNewStateObject(*tx.Data.To)
+	toStateObj.AddAmount(tx.Data.Amount)
+
 	return nil
 }
 
-// updateHashByHeight updates the height-to-hash mapping for the specified new HEAD block in the canonical chain.
-func (bc *Blockchain) updateHashByHeight(block *types.Block) error {
-	// Delete height-to-hash mappings with the larger height than that of the new HEAD block in the canonical chain.
-	for i := block.Header.Height + 1; ; i++ {
-		deleted, err := bc.bcStore.DeleteBlockHash(i)
-		if err != nil {
-			return err
-		}
+// StateAtTransaction returns the state before the tx of the specified index in the block is
+// processed, i.e. the state of the parent block with the former txs in the block processed.
+// The state is not committed, and the tx could be processed on it with ApplyTransaction.
+func (bc *Blockchain) StateAtTransaction(block *types.Block, txIndex int) (*state.Statedb, error) {
+	if txIndex < 0 || txIndex >= len(block.Transactions) {
+		return nil, ErrTxIndexOutOfRange
+	}
 
-		if !deleted {
-			break
-		}
+	preHeader, err := bc.bcStore.GetBlockHeader(block.Header.PreviousBlockHash)
+	if err != nil {
+		return nil, ErrBlockInvalidParentHash
 	}
 
-	// Overwrite stale canonical height-to-hash mappings
-	for headerHash := block.Header.PreviousBlockHash; !headerHash.Equal(common.EmptyHash); {
-		header, err := bc.bcStore.GetBlockHeader(headerHash)
-		if err != nil {
-			return err
-		}
+	statedb, err := state.NewStatedb(preHeader.StateHash, bc.accountStateDB)
+	if err != nil {
+		return nil, err
+	}
 
-		canonicalHash, err := bc.bcStore.GetBlockHash(header.Height)
-		if err != nil {
-			return err
+	for i := 0; i < txIndex; i++ {
+		if err = ApplyTransaction(statedb, block.Transactions[i], i); err != nil {
+			return nil, err
 		}
+	}
 
-		if headerHash.Equal(canonicalHash) {
-			break
-		}
+	return statedb, nil
+}
 
-		if err = bc.bcStore.PutBlockHash(header.Height, headerHash); err != nil {
-			return err
-		}
+// ApplyTransaction processes the tx of the specified index in the block on the state, where
+// the tx of index 0 is the miner reward tx.
+func ApplyTransaction(statedb *state.Statedb, tx *types.Transaction, txIndex int) error {
+	if txIndex == 0 {
+		statedb.GetOrNewStateObject(*tx.Data.To).AddAmount(tx.Data.Amount)
+		return nil
+	}
+
+	return applyTx(statedb, tx)
+}
 
-		headerHash = header.PreviousBlockHash
+// NewReceipt creates the receipt of the tx at the specified index of the block, whose logs
+// are the ones added into the statedb after the specified log index.
+func NewReceipt(statedb *state.Statedb, tx *types.Transaction, txIndex int, logIndex int) *types.Receipt {
+	logs := statedb.GetLogs()[logIndex:]
+	for _, log := range logs {
+		log.TxIndex = uint(txIndex)
 	}
 
-	return nil
+	return &types.Receipt{
+		TxHash: tx.Hash,
+		Logs:   logs,
+	}
+}
+
+// updateHashByHeight updates the height-to-hash mapping for the specified new HEAD block in the canonical chain,
+// and returns the reorg depth.
+func (bc *Blockchain) updateHashByHeight(block *types.Block) (int, error) {
+	return overwriteCanonicalHashes(bc.bcStore, block.Header)
 }
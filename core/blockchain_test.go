@@ -17,6 +17,7 @@ import (
 	"github.com/seeleteam/go-seele/core/types"
 	"github.com/seeleteam/go-seele/crypto"
 	"github.com/seeleteam/go-seele/database"
+	"github.com/seeleteam/go-seele/event"
 	"github.com/seeleteam/go-seele/miner/pow"
 )
 
@@ -120,6 +121,7 @@ func newTestBlock(bc *Blockchain, parentHash common.Hash, blockHeight, txNum, st
 	}
 
 	stateRootHash := common.EmptyHash
+	var logsBloom types.Bloom
 	parentBlock, err := bc.bcStore.GetBlock(parentHash)
 	if err == nil {
 		statedb, err := state.NewStatedb(parentBlock.Header.StateHash, bc.accountStateDB)
@@ -127,11 +129,13 @@ func newTestBlock(bc *Blockchain, parentHash common.Hash, blockHeight, txNum, st
 			panic(err)
 		}
 
-		if err = updateStatedb(statedb, rewardTx, txs[1:]); err != nil {
+		receipts, err := updateStatedb(statedb, rewardTx, txs[1:])
+		if err != nil {
 			panic(err)
 		}
 
 		stateRootHash = statedb.Commit(nil)
+		logsBloom = types.ReceiptsBloom(receipts)
 	}
 
 	header := &types.BlockHeader{
@@ -143,6 +147,7 @@ func newTestBlock(bc *Blockchain, parentHash common.Hash, blockHeight, txNum, st
 		Difficulty:        big.NewInt(1),
 		CreateTimestamp:   big.NewInt(1),
 		Nonce:             10,
+		LogsBloom:         logsBloom,
 	}
 
 	return &types.Block{
@@ -190,26 +195,143 @@ func Test_Blockchain_WriteBlock_InvalidHeight(t *testing.T) {
 	assert.Equal(t, bc.WriteBlock(newBlock), ErrBlockInvalidHeight)
 }
 
+func Test_Blockchain_WriteBlock_LogsBloomChanged(t *testing.T) {
+	db, dispose := newTestDatabase()
+	defer dispose()
+
+	bc := newTestBlockchain(db)
+
+	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
+	newBlock.Header.LogsBloom.SetBit(1)
+	newBlock.HeaderHash = newBlock.Header.Hash()
+
+	assert.Equal(t, bc.WriteBlock(newBlock), ErrBlockLogsBloomMismatch)
+}
+
 func Test_Blockchain_WriteBlock_ValidBlock(t *testing.T) {
 	db, dispose := newTestDatabase()
 	defer dispose()
 
 	bc := newTestBlockchain(db)
 
+	heads := make(chan event.Event, 1)
+	sub := event.ChainHeaderChangedEventManager.Subscribe(func(e event.Event) { heads <- e })
+	defer sub.Unsubscribe()
+
 	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
 	assert.Equal(t, bc.WriteBlock(newBlock), error(nil))
 
 	currentBlock, _ := bc.CurrentBlock()
 	assert.Equal(t, currentBlock, newBlock)
+	assert.Equal(t, <-heads, event.Event(newBlock))
 
 	storedBlock, err := bc.bcStore.GetBlock(newBlock.HeaderHash)
 	assert.Equal(t, err, error(nil))
 	assert.Equal(t, storedBlock, newBlock)
 
+	receipts, err := bc.bcStore.GetReceiptsByBlockHash(newBlock.HeaderHash)
+	assert.Equal(t, err, error(nil))
+	assert.Equal(t, len(receipts), len(newBlock.Transactions))
+	for i, receipt := range receipts {
+		assert.Equal(t, receipt.TxHash, newBlock.Transactions[i].Hash)
+	}
+
 	_, err = state.NewStatedb(newBlock.Header.StateHash, db)
 	assert.Equal(t, err, error(nil))
 }
 
+func Test_Blockchain_StateAtTransaction(t *testing.T) {
+	db, dispose := newTestDatabase()
+	defer dispose()
+
+	bc := newTestBlockchain(db)
+	block := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
+	assert.Equal(t, bc.WriteBlock(block), error(nil))
+
+	from := testGenesisAccounts[0].addr
+	minerAddr := *block.Transactions[0].Data.To
+
+	// parent state
+	statedb, err := bc.StateAtTransaction(block, 0)
+	assert.Equal(t, err, error(nil))
+	assert.Equal(t, statedb.GetBalance(minerAddr), big.NewInt(0))
+	assert.Equal(t, statedb.GetNonce(from), uint64(0))
+
+	// the reward tx and the first 1 tx are processed
+	statedb, err = bc.StateAtTransaction(block, 2)
+	assert.Equal(t, err, error(nil))
+	assert.Equal(t, statedb.GetBalance(minerAddr), new(big.Int).SetUint64(pow.MinerRewardAmount))
+	assert.Equal(t, statedb.GetNonce(from), uint64(1))
+	assert.Equal(t, statedb.GetBalance(from), big.NewInt(99))
+
+	_, err = bc.StateAtTransaction(block, len(block.Transactions))
+	assert.Equal(t, err, ErrTxIndexOutOfRange)
+}
+
+func Test_Blockchain_ValidateBlockHeader(t *testing.T) {
+	db, dispose := newTestDatabase()
+	defer dispose()
+
+	bc := newTestBlockchain(db)
+
+	// the parent is not required
+	newBlock := newTestBlock(bc, common.StringToHash("unknown parent"), 10, 3, 0)
+	assert.Equal(t, bc.ValidateBlockHeader(newBlock), error(nil))
+
+	newBlock.Transactions = newBlock.Transactions[1:]
+	assert.Equal(t, bc.ValidateBlockHeader(newBlock), ErrBlockTxsHashMismatch)
+
+	newBlock.HeaderHash = common.EmptyHash
+	assert.Equal(t, bc.ValidateBlockHeader(newBlock), ErrBlockHashMismatch)
+}
+
+func Test_Blockchain_FastSync(t *testing.T) {
+	sourceDB, disposeSource := newTestDatabase()
+	defer disposeSource()
+
+	source := newTestBlockchain(sourceDB)
+	block1 := newTestBlock(source, source.genesisBlock.HeaderHash, 1, 3, 0)
+	assert.Equal(t, source.WriteBlock(block1), error(nil))
+	block2 := newTestBlock(source, block1.HeaderHash, 2, 3, 3)
+	assert.Equal(t, source.WriteBlock(block2), error(nil))
+
+	db, dispose := newTestDatabase()
+	defer dispose()
+
+	bc := newTestBlockchain(db)
+	assert.Equal(t, bc.WriteBlockWithoutState(block1), error(nil))
+	assert.Equal(t, bc.WriteBlockWithoutState(block1), ErrBlockAlreadyExists)
+
+	// the HEAD block is not changed without the state
+	currentBlock, _ := bc.CurrentBlock()
+	assert.Equal(t, currentBlock.HeaderHash, bc.genesisBlock.HeaderHash)
+	assert.Equal(t, bc.CommitFastSyncBlock(block1.HeaderHash) != nil, true)
+
+	// download the state of block1
+	stateSync := state.NewStateSync(block1.Header.StateHash, db)
+	for hashes := stateSync.Missing(16); len(hashes) > 0; hashes = stateSync.Missing(16) {
+		for _, hash := range hashes {
+			data, err := state.GetTrieNode(sourceDB, hash)
+			assert.Equal(t, err, error(nil))
+			_, err = stateSync.Process(data)
+			assert.Equal(t, err, error(nil))
+		}
+
+		batch := db.NewBatch()
+		stateSync.Commit(batch)
+		assert.Equal(t, batch.Commit(), error(nil))
+	}
+
+	assert.Equal(t, bc.CommitFastSyncBlock(block1.HeaderHash), error(nil))
+	currentBlock, _ = bc.CurrentBlock()
+	assert.Equal(t, currentBlock.HeaderHash, block1.HeaderHash)
+
+	// the blocks after the pivot block are processed
+	assert.Equal(t, bc.WriteBlock(block2), error(nil))
+	currentBlock, _ = bc.CurrentBlock()
+	assert.Equal(t, currentBlock.HeaderHash, block2.HeaderHash)
+}
+
 func Test_Blockchain_WriteBlock_DupBlocks(t *testing.T) {
 	db, dispose := newTestDatabase()
 	defer dispose()
@@ -323,11 +445,17 @@ func Test_Blockchain_UpdateCanocialHash(t *testing.T) {
 
 	// genesis <- block11 <- block12
 	//         <- block21 <- block22 <- block23 (canonical)
+	reorgs := reorgDepth.Snapshot()
 	block23 := newTestBlock(bc, block22.HeaderHash, 3, 3, 6)
 	assert.Equal(t, bc.WriteBlock(block23), error(nil))
 	assertCanonicalHash(t, bc, 1, block21.HeaderHash)
 	assertCanonicalHash(t, bc, 2, block22.HeaderHash)
 	assertCanonicalHash(t, bc, 3, block23.HeaderHash)
+
+	// block11 and block12 are replaced
+	assert.Equal(t, reorgDepth.Snapshot().Count, reorgs.Count+1)
+	assert.Equal(t, reorgDepth.Snapshot().Sum, reorgs.Sum+2)
+	assert.Equal(t, headHeightGauge.Value(), float64(3))
 }
 
 func assertCanonicalHash(t *testing.T, bc *Blockchain, height uint64, expectedHash common.Hash) {
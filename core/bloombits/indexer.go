@@ -0,0 +1,343 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package bloombits
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/store"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/database"
+	"github.com/seeleteam/go-seele/event"
+	"github.com/seeleteam/go-seele/log"
+	leveldbErrors "github.com/syndtr/goleveldb/leveldb/errors"
+)
+
+const (
+	// DefaultSectionSize is the number of blocks in a section of the bloom index.
+	DefaultSectionSize = 4096
+
+	// DefaultConfirms is the number of blocks after a section that is indexed, so that
+	// the index is hardly affected by the chain reorganization.
+	DefaultConfirms = 64
+)
+
+// There are following mappings in database:
+//  1. keyPrefixBits + bit index + section => bit vector of the section blocks
+//  2. keyPrefixSectionHead + section => hash of the last block of the section
+//  3. keyIndexedSections => number of indexed sections
+var (
+	keyPrefixBits        = []byte("bloomBits")
+	keyPrefixSectionHead = []byte("bloomHead")
+	keyIndexedSections   = []byte("bloomSections")
+
+	errSectionNotIndexed = errors.New("bloombits: section not indexed")
+)
+
+func bitsKey(bit uint, section uint64) []byte {
+	key := make([]byte, len(keyPrefixBits)+10)
+	copy(key, keyPrefixBits)
+	binary.BigEndian.PutUint16(key[len(keyPrefixBits):], uint16(bit))
+	binary.BigEndian.PutUint64(key[len(keyPrefixBits)+2:], section)
+	return key
+}
+
+func sectionHeadKey(section uint64) []byte {
+	key := make([]byte, len(keyPrefixSectionHead)+8)
+	copy(key, keyPrefixSectionHead)
+	binary.BigEndian.PutUint64(key[len(keyPrefixSectionHead):], section)
+	return key
+}
+
+// Indexer indexes the logs bloom of the canonical blocks by sections. For each section, the
+// bits of the same index in the block blooms are rotated into a bit vector, so that the
+// candidate blocks of a range query are found by reading a few vectors, instead of the
+// headers of all blocks in the range.
+type Indexer struct {
+	db          database.Database
+	bcStore     store.BlockchainStore
+	sectionSize uint64
+	confirms    uint64
+	log         *log.SeeleLog
+
+	lock     sync.RWMutex // protects sections
+	sections uint64       // number of indexed sections
+
+	heads chan uint64
+	sub   *event.Subscription
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewIndexer returns an indexer of the canonical blocks in the store, which saves the index
+// in the database. The section size must be a multiple of 8.
+func NewIndexer(db database.Database, bcStore store.BlockchainStore, sectionSize, confirms uint64) *Indexer {
+	if sectionSize == 0 || sectionSize%8 != 0 {
+		panic("bloombits: section size must be a positive multiple of 8")
+	}
+
+	indexer := &Indexer{
+		db:          db,
+		bcStore:     bcStore,
+		sectionSize: sectionSize,
+		confirms:    confirms,
+		log:         log.GetLogger("bloombits", common.PrintLog),
+		heads:       make(chan uint64, 1),
+		quit:        make(chan struct{}),
+	}
+
+	if value, err := db.Get(keyIndexedSections); err == nil && len(value) == 8 {
+		indexer.sections = binary.BigEndian.Uint64(value)
+	}
+
+	return indexer
+}
+
+// SectionSize returns the number of blocks in a section.
+func (indexer *Indexer) SectionSize() uint64 {
+	return indexer.sectionSize
+}
+
+// Sections returns the number of indexed sections.
+func (indexer *Indexer) Sections() uint64 {
+	indexer.lock.RLock()
+	defer indexer.lock.RUnlock()
+
+	return indexer.sections
+}
+
+// Start indexes the sections of the current canonical chain, and the new sections when
+// the HEAD block changes.
+func (indexer *Indexer) Start() {
+	indexer.sub = event.ChainHeaderChangedEventManager.Subscribe(func(e event.Event) {
+		indexer.notify(e.(*types.Block).Header.Height)
+	})
+
+	if hash, err := indexer.bcStore.GetHeadBlockHash(); err == nil {
+		if header, err := indexer.bcStore.GetBlockHeader(hash); err == nil {
+			indexer.notify(header.Height)
+		}
+	}
+
+	indexer.wg.Add(1)
+	go indexer.loop()
+}
+
+// Stop stops indexing the new sections.
+func (indexer *Indexer) Stop() {
+	if indexer.sub != nil {
+		indexer.sub.Unsubscribe()
+	}
+
+	close(indexer.quit)
+	indexer.wg.Wait()
+}
+
+// notify replaces the pending HEAD height with the new one without blocking.
+func (indexer *Indexer) notify(height uint64) {
+	for {
+		select {
+		case indexer.heads <- height:
+			return
+		case <-indexer.heads:
+		}
+	}
+}
+
+func (indexer *Indexer) loop() {
+	defer indexer.wg.Done()
+
+	for {
+		select {
+		case height := <-indexer.heads:
+			indexer.Process(height)
+		case <-indexer.quit:
+			return
+		}
+	}
+}
+
+// Process drops the sections which are not in the canonical chain any more, and indexes the
+// sections which are confirmed by the HEAD block of the specified height.
+func (indexer *Indexer) Process(headHeight uint64) {
+	indexer.lock.Lock()
+	defer indexer.lock.Unlock()
+
+	sections := indexer.sections
+	for sections > 0 && !indexer.isCanonical(sections-1) {
+		sections--
+	}
+
+	for (sections+1)*indexer.sectionSize+indexer.confirms <= headHeight+1 {
+		if err := indexer.indexSection(sections); err != nil {
+			indexer.log.Warn("failed to index bloom section %d, %s", sections, err)
+			break
+		}
+
+		sections++
+
+		select {
+		case <-indexer.quit:
+			indexer.setSections(sections)
+			return
+		default:
+		}
+	}
+
+	indexer.setSections(sections)
+}
+
+func (indexer *Indexer) setSections(sections uint64) {
+	if sections == indexer.sections {
+		return
+	}
+
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, sections)
+	if err := indexer.db.Put(keyIndexedSections, value); err != nil {
+		indexer.log.Warn("failed to save the number of bloom sections, %s", err)
+		return
+	}
+
+	indexer.sections = sections
+}
+
+// isCanonical returns true if the last block of the indexed section is still canonical.
+func (indexer *Indexer) isCanonical(section uint64) bool {
+	headHash, err := indexer.db.Get(sectionHeadKey(section))
+	if err != nil {
+		return false
+	}
+
+	hash, err := indexer.bcStore.GetBlockHash((section+1)*indexer.sectionSize - 1)
+	if err != nil {
+		return false
+	}
+
+	return hash.Equal(common.BytesToHash(headHash))
+}
+
+// indexSection rotates the blooms of the section blocks into the bit vectors.
+func (indexer *Indexer) indexSection(section uint64) error {
+	vectors := make([][]byte, types.BloomBitLength)
+	var hash common.Hash
+
+	for i := uint64(0); i < indexer.sectionSize; i++ {
+		var err error
+		if hash, err = indexer.bcStore.GetBlockHash(section*indexer.sectionSize + i); err != nil {
+			return err
+		}
+
+		header, err := indexer.bcStore.GetBlockHeader(hash)
+		if err != nil {
+			return err
+		}
+
+		for bit := uint(0); bit < types.BloomBitLength; bit++ {
+			if !header.LogsBloom.Bit(bit) {
+				continue
+			}
+
+			if vectors[bit] == nil {
+				vectors[bit] = make([]byte, indexer.sectionSize/8)
+			}
+
+			vectors[bit][i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	batch := indexer.db.NewBatch()
+	for bit, vector := range vectors {
+		if vector == nil {
+			batch.Delete(bitsKey(uint(bit), section))
+		} else {
+			batch.Put(bitsKey(uint(bit), section), vector)
+		}
+	}
+
+	batch.Put(sectionHeadKey(section), hash.Bytes())
+
+	return batch.Commit()
+}
+
+// bitVector returns the bit vector of the bit index in the section.
+func (indexer *Indexer) bitVector(bit uint, section uint64) ([]byte, error) {
+	vector, err := indexer.db.Get(bitsKey(bit, section))
+	if err == leveldbErrors.ErrNotFound {
+		return make([]byte, indexer.sectionSize/8), nil
+	}
+
+	return vector, err
+}
+
+// Candidates returns the heights of the blocks in the section whose blooms may match the
+// groups. A bloom matches the groups if it contains any value of each non-empty group.
+// It returns an error if the section is not indexed or out of the canonical chain, in which
+// case the blooms in the headers should be checked instead.
+func (indexer *Indexer) Candidates(section uint64, groups [][][]byte) ([]uint64, error) {
+	indexer.lock.RLock()
+	defer indexer.lock.RUnlock()
+
+	if section >= indexer.sections || !indexer.isCanonical(section) {
+		return nil, errSectionNotIndexed
+	}
+
+	result := make([]byte, indexer.sectionSize/8)
+	for i := range result {
+		result[i] = 0xFF
+	}
+
+	vectors := make(map[uint][]byte)
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+
+		groupVector := make([]byte, len(result))
+		for _, value := range group {
+			valueVector := make([]byte, len(result))
+			for i := range valueVector {
+				valueVector[i] = 0xFF
+			}
+
+			for _, bit := range types.BloomBitIndexes(value) {
+				vector, ok := vectors[bit]
+				if !ok {
+					var err error
+					if vector, err = indexer.bitVector(bit, section); err != nil {
+						return nil, err
+					}
+
+					vectors[bit] = vector
+				}
+
+				for i := range valueVector {
+					valueVector[i] &= vector[i]
+				}
+			}
+
+			for i := range groupVector {
+				groupVector[i] |= valueVector[i]
+			}
+		}
+
+		for i := range result {
+			result[i] &= groupVector[i]
+		}
+	}
+
+	var heights []uint64
+	for i := uint64(0); i < indexer.sectionSize; i++ {
+		if result[i/8]&(1<<(7-i%8)) != 0 {
+			heights = append(heights, section*indexer.sectionSize+i)
+		}
+	}
+
+	return heights, nil
+}
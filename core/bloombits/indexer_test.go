@@ -0,0 +1,146 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package bloombits
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/store"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/database"
+	"github.com/seeleteam/go-seele/database/leveldb"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testAddress = common.BytesToAddress([]byte("contract"))
+	testTopic   = common.StringToHash("topic")
+)
+
+// newTestChain writes the canonical headers of the heights [0, count), and the logs bloom of
+// the headers in matched contains the test address and topic.
+func newTestChain(t *testing.T, count uint64, matched map[uint64]bool) (database.Database, store.BlockchainStore) {
+	db, err := leveldb.NewMemDatabase()
+	assert.Nil(t, err)
+
+	bcStore := store.NewBlockchainDatabase(db)
+	for height := uint64(0); height < count; height++ {
+		writeTestHeader(t, bcStore, height, 0, matched[height])
+	}
+
+	return db, bcStore
+}
+
+func writeTestHeader(t *testing.T, bcStore store.BlockchainStore, height uint64, nonce uint64, matched bool) {
+	header := &types.BlockHeader{
+		Height:          height,
+		Nonce:           nonce,
+		Difficulty:      big.NewInt(1),
+		CreateTimestamp: big.NewInt(1),
+	}
+
+	if matched {
+		header.LogsBloom = types.LogsBloom([]*types.Log{{Address: testAddress, Topics: []common.Hash{testTopic}}})
+	}
+
+	assert.Nil(t, bcStore.PutBlockHeader(header.Hash(), header, big.NewInt(1), true))
+}
+
+func Test_Indexer_Process(t *testing.T) {
+	db, bcStore := newTestChain(t, 40, nil)
+	defer db.Close()
+
+	indexer := NewIndexer(db, bcStore, 16, 4)
+	indexer.Process(18)
+	assert.Equal(t, indexer.Sections(), uint64(0))
+
+	indexer.Process(19)
+	assert.Equal(t, indexer.Sections(), uint64(1))
+
+	indexer.Process(39)
+	assert.Equal(t, indexer.Sections(), uint64(2))
+
+	// the number of sections is loaded from the database
+	assert.Equal(t, NewIndexer(db, bcStore, 16, 4).Sections(), uint64(2))
+}
+
+func Test_Indexer_Candidates(t *testing.T) {
+	matched := map[uint64]bool{3: true, 17: true, 31: true}
+	db, bcStore := newTestChain(t, 40, matched)
+	defer db.Close()
+
+	indexer := NewIndexer(db, bcStore, 16, 4)
+	indexer.Process(39)
+
+	addrGroup := [][]byte{testAddress.Bytes()}
+	topicGroup := [][]byte{testTopic.Bytes()}
+	otherGroup := [][]byte{common.StringToHash("other").Bytes()}
+
+	heights, err := indexer.Candidates(0, [][][]byte{addrGroup, topicGroup})
+	assert.Nil(t, err)
+	assert.Equal(t, heights, []uint64{3})
+
+	heights, err = indexer.Candidates(1, [][][]byte{addrGroup, nil})
+	assert.Nil(t, err)
+	assert.Equal(t, heights, []uint64{17, 31})
+
+	heights, err = indexer.Candidates(1, [][][]byte{otherGroup})
+	assert.Nil(t, err)
+	assert.Equal(t, len(heights), 0)
+
+	// any value of a group matches
+	heights, err = indexer.Candidates(0, [][][]byte{append(otherGroup, topicGroup...)})
+	assert.Nil(t, err)
+	assert.Equal(t, heights, []uint64{3})
+
+	// no groups matches all blocks
+	heights, err = indexer.Candidates(0, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, len(heights), 16)
+
+	_, err = indexer.Candidates(2, [][][]byte{addrGroup})
+	assert.Equal(t, err, errSectionNotIndexed)
+}
+
+func Test_Indexer_Reorg(t *testing.T) {
+	matched := map[uint64]bool{20: true}
+	db, bcStore := newTestChain(t, 40, matched)
+	defer db.Close()
+
+	indexer := NewIndexer(db, bcStore, 16, 4)
+	indexer.Process(39)
+	assert.Equal(t, indexer.Sections(), uint64(2))
+
+	// the last block of section 1 is replaced in the canonical chain
+	writeTestHeader(t, bcStore, 31, 1, false)
+	_, err := indexer.Candidates(1, nil)
+	assert.Equal(t, err, errSectionNotIndexed)
+
+	indexer.Process(39)
+	assert.Equal(t, indexer.Sections(), uint64(2))
+
+	heights, err := indexer.Candidates(1, [][][]byte{{testAddress.Bytes()}})
+	assert.Nil(t, err)
+	assert.Equal(t, heights, []uint64{20})
+}
+
+func Test_Indexer_Start(t *testing.T) {
+	db, bcStore := newTestChain(t, 40, nil)
+	defer db.Close()
+
+	indexer := NewIndexer(db, bcStore, 16, 4)
+	indexer.Start()
+	defer indexer.Stop()
+
+	for i := 0; i < 100 && indexer.Sections() < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Equal(t, indexer.Sections(), uint64(2))
+}
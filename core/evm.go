@@ -6,6 +6,7 @@
 package core
 
 import (
+	"errors"
 	"math"
 	"math/big"
 
@@ -17,6 +18,60 @@ import (
 	"github.com/seeleteam/go-seele/core/vm"
 )
 
+// ErrIntrinsicGas is returned when the gas of the contract call is less than the intrinsic gas.
+var ErrIntrinsicGas = errors.New("intrinsic gas too low")
+
+// IntrinsicGas returns the gas of the tx before the contract is executed, which depends on
+// the payload and whether the tx creates a contract.
+func IntrinsicGas(payload []byte, contractCreation bool) uint64 {
+	gas := params.TxGas
+	if contractCreation {
+		gas = params.TxGasContractCreation
+	}
+
+	for _, b := range payload {
+		if b == 0 {
+			gas += params.TxDataZeroGas
+		} else {
+			gas += params.TxDataNonZeroGas
+		}
+	}
+
+	return gas
+}
+
+// CallContract executes the contract of the tx with the specified gas limit in the context of
+// the block header, and returns the output and the used gas. The statedb is changed but not
+// committed, so a copy of the state should be passed if the changes are discarded. The output
+// is the revert reason if vm.ErrExecutionReverted is returned. The execution is traced if the
+// tracer of the vm config is set.
+func CallContract(tx *types.Transaction, gas uint64, header *types.BlockHeader, statedb *state.Statedb, bcStore store.BlockchainStore, vmConfig vm.Config) ([]byte, uint64, error) {
+	intrinsicGas := IntrinsicGas(tx.Data.Payload, tx.Data.To == nil)
+	if gas < intrinsicGas {
+		return nil, 0, ErrIntrinsicGas
+	}
+
+	context := newEVMContext(tx, header, header.Creator, bcStore)
+	evm := vm.NewEVM(*context, statedb, params.AllEthashProtocolChanges, vmConfig)
+	caller := vm.AccountRef(tx.Data.From)
+
+	amount := tx.Data.Amount
+	if amount == nil {
+		amount = new(big.Int)
+	}
+
+	var output []byte
+	var leftOverGas uint64
+	var err error
+	if tx.Data.To == nil {
+		output, _, leftOverGas, err = evm.Create(caller, tx.Data.Payload, gas-intrinsicGas, amount)
+	} else {
+		output, leftOverGas, err = evm.Call(caller, *tx.Data.To, tx.Data.Payload, gas-intrinsicGas, amount)
+	}
+
+	return output, gas - leftOverGas, err
+}
+
 // newEVMContext creates a new context for use in the EVM.
 func newEVMContext(tx *types.Transaction, header *types.BlockHeader, minerAddress common.Address, bcStore store.BlockchainStore) *vm.Context {
 	canTransferFunc := func(db vm.StateDB, addr common.Address, amount *big.Int) bool {
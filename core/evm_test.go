@@ -0,0 +1,103 @@
+/**
+* @file
+* @copyright defined in go-seele/LICENSE
+ */
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/magiconair/properties/assert"
+	"github.com/seeleteam/go-seele/core/state"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/core/vm"
+	"github.com/seeleteam/go-seele/crypto"
+)
+
+func Test_IntrinsicGas(t *testing.T) {
+	assert.Equal(t, IntrinsicGas(nil, false), params.TxGas)
+	assert.Equal(t, IntrinsicGas(nil, true), params.TxGasContractCreation)
+	assert.Equal(t, IntrinsicGas([]byte{0, 1, 2}, false), params.TxGas+params.TxDataZeroGas+2*params.TxDataNonZeroGas)
+}
+
+func Test_CallContract(t *testing.T) {
+	db, dispose := newTestDatabase()
+	defer dispose()
+
+	bc := newTestBlockchain(db)
+	header := bc.genesisBlock.Header
+
+	from := testGenesisAccounts[0].addr
+	to := *crypto.MustGenerateRandomAddress()
+	tx := types.NewTransaction(from, to, big.NewInt(10), 0)
+
+	statedb, err := state.NewStatedb(header.StateHash, db)
+	assert.Equal(t, err, nil)
+
+	output, gasUsed, err := CallContract(tx, 100000, header, statedb, bc.bcStore, vm.Config{})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(output), 0)
+	assert.Equal(t, gasUsed, params.TxGas)
+	assert.Equal(t, statedb.GetBalance(to), big.NewInt(10))
+
+	// the intrinsic gas is not enough
+	_, _, err = CallContract(tx, params.TxGas-1, header, statedb, bc.bcStore, vm.Config{})
+	assert.Equal(t, err, ErrIntrinsicGas)
+
+	// the balance is not enough
+	tx = types.NewTransaction(to, from, big.NewInt(11), 0)
+	_, _, err = CallContract(tx, 100000, header, statedb, bc.bcStore, vm.Config{})
+	assert.Equal(t, err, vm.ErrInsufficientBalance)
+
+	// the changes are not committed
+	statedb, err = state.NewStatedb(header.StateHash, db)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, statedb.GetBalance(to), new(big.Int))
+}
+
+// testContractCode returns the word 42 if the first byte of the input is 0, otherwise reverts
+// with the word 42 as the reason.
+var testContractCode = []byte{
+	0x60, 0x2a, 0x60, 0x00, 0x52, // MSTORE(0, 42)
+	0x60, 0x00, 0x35, 0x60, 0x00, 0x1a, // BYTE(0, CALLDATALOAD(0))
+	0x60, 0x13, 0x57, // JUMPI(19, byte)
+	0x60, 0x20, 0x60, 0x00, 0xf3, // RETURN(0, 32)
+	0x5b, 0x60, 0x20, 0x60, 0x00, 0xfd, // JUMPDEST, REVERT(0, 32)
+}
+
+func Test_CallContract_Code(t *testing.T) {
+	db, dispose := newTestDatabase()
+	defer dispose()
+
+	bc := newTestBlockchain(db)
+	header := bc.genesisBlock.Header
+
+	statedb, err := state.NewStatedb(header.StateHash, db)
+	assert.Equal(t, err, nil)
+
+	contract := *crypto.MustGenerateRandomAddress()
+	statedb.CreateAccount(contract)
+	statedb.SetCode(contract, testContractCode)
+
+	expected := make([]byte, 32)
+	expected[31] = 42
+	from := testGenesisAccounts[0].addr
+
+	// returns data
+	tx := types.NewTransaction(from, contract, big.NewInt(0), 0)
+	tx.Data.Payload = []byte{0}
+	output, gasUsed, err := CallContract(tx, 100000, header, statedb, bc.bcStore, vm.Config{})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, output, expected)
+	assert.Equal(t, gasUsed > IntrinsicGas(tx.Data.Payload, false), true)
+
+	// reverts with the reason
+	tx.Data.Payload = []byte{1}
+	output, gasUsed, err = CallContract(tx, 100000, header, statedb, bc.bcStore, vm.Config{})
+	assert.Equal(t, err, vm.ErrExecutionReverted)
+	assert.Equal(t, output, expected)
+	assert.Equal(t, gasUsed < 100000, true)
+}
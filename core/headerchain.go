@@ -6,18 +6,21 @@
 package core
 
 import (
+	"math/big"
 	"sync"
 
 	"github.com/seeleteam/go-seele/common"
 	"github.com/seeleteam/go-seele/core/store"
 	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/miner/pow"
 )
 
 // HeaderChain represents the block header chain that is shared by the archive node and light node.
-// This is a non-thread safe structure.
+// The headers are validated and inserted independently of the block bodies.
 type HeaderChain struct {
 	lock    sync.Mutex
 	bcStore store.BlockchainStore
+	engine  consensusEngine
 
 	genesisHeader     *types.BlockHeader
 	currentHeader     *types.BlockHeader
@@ -28,6 +31,7 @@ type HeaderChain struct {
 func NewHeaderChain(bcStore store.BlockchainStore) (*HeaderChain, error) {
 	hc := HeaderChain{
 		bcStore: bcStore,
+		engine:  &pow.Engine{},
 	}
 
 	// Get the genesis block header from the store.
@@ -69,3 +73,170 @@ func (hc *HeaderChain) WriteHeader(newHeader *types.BlockHeader) error {
 
 	return nil
 }
+
+// CurrentHeader returns the HEAD header of the header chain.
+func (hc *HeaderChain) CurrentHeader() *types.BlockHeader {
+	hc.lock.Lock()
+	defer hc.lock.Unlock()
+
+	return hc.currentHeader.Clone()
+}
+
+// ValidateHeader validates the header against its parent header, including the parent hash,
+// height, difficulty and consensus of the header.
+func (hc *HeaderChain) ValidateHeader(header, parent *types.BlockHeader) error {
+	if !header.PreviousBlockHash.Equal(parent.Hash()) {
+		return ErrBlockInvalidParentHash
+	}
+
+	if header.Height != parent.Height+1 {
+		return ErrBlockInvalidHeight
+	}
+
+	return hc.validateConsensus(header)
+}
+
+// validateConsensus validates the difficulty and consensus of the header regardless of its parent.
+func (hc *HeaderChain) validateConsensus(header *types.BlockHeader) error {
+	if header.Difficulty == nil || header.Difficulty.Sign() <= 0 {
+		return ErrBlockInvalidDifficulty
+	}
+
+	return hc.engine.ValidateHeader(header)
+}
+
+// ValidateHeaderChain validates the headers which are linked one by one. The parent of the
+// first header is not required, so that a batch of headers could be validated before its
+// parent is available.
+func (hc *HeaderChain) ValidateHeaderChain(headers []*types.BlockHeader) error {
+	for i, header := range headers {
+		var err error
+		if i == 0 {
+			err = hc.validateConsensus(header)
+		} else {
+			err = hc.ValidateHeader(header, headers[i-1])
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InsertHeaderChain validates and inserts the headers without block bodies, which are linked one
+// by one and the parent of the first header is in the header chain. The HEAD header is changed
+// if the inserted headers have larger total difficulty. It returns the number of headers inserted.
+func (hc *HeaderChain) InsertHeaderChain(headers []*types.BlockHeader) (int, error) {
+	if len(headers) == 0 {
+		return 0, nil
+	}
+
+	if err := hc.ValidateHeaderChain(headers); err != nil {
+		return 0, err
+	}
+
+	hc.lock.Lock()
+	defer hc.lock.Unlock()
+
+	parent, err := hc.bcStore.GetBlockHeader(headers[0].PreviousBlockHash)
+	if err != nil {
+		return 0, ErrBlockInvalidParentHash
+	}
+
+	if err = hc.ValidateHeader(headers[0], parent); err != nil {
+		return 0, err
+	}
+
+	td, err := hc.bcStore.GetBlockTotalDifficulty(headers[0].PreviousBlockHash)
+	if err != nil {
+		return 0, err
+	}
+
+	currentTD, err := hc.bcStore.GetBlockTotalDifficulty(hc.currentHeaderHash)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, header := range headers {
+		hash := header.Hash()
+		td = new(big.Int).Add(td, header.Difficulty)
+
+		if exist, err := hc.bcStore.HasBlock(hash); err != nil || exist {
+			if err != nil {
+				return i, err
+			}
+
+			continue
+		}
+
+		isHead := td.Cmp(currentTD) > 0
+		if isHead {
+			if _, err = overwriteCanonicalHashes(hc.bcStore, header); err != nil {
+				return i, err
+			}
+		}
+
+		if err = hc.bcStore.PutBlockHeader(hash, header, td, isHead); err != nil {
+			return i, err
+		}
+
+		if isHead {
+			hc.currentHeaderHash, hc.currentHeader, currentTD = hash, header.Clone(), td
+		}
+	}
+
+	return len(headers), nil
+}
+
+// overwriteCanonicalHashes updates the height-to-hash mapping for the new HEAD header in the canonical chain,
+// and returns the number of the canonical blocks replaced, which is the reorg depth.
+func overwriteCanonicalHashes(bcStore store.BlockchainStore, header *types.BlockHeader) (int, error) {
+	replaced := 0
+
+	// The canonical block at the height of the new HEAD header is replaced later.
+	if hash, err := bcStore.GetBlockHash(header.Height); err == nil && !hash.Equal(header.Hash()) {
+		replaced++
+	}
+
+	// Delete height-to-hash mappings with the larger height than that of the new HEAD header in the canonical chain.
+	for i := header.Height + 1; ; i++ {
+		deleted, err := bcStore.DeleteBlockHash(i)
+		if err != nil {
+			return replaced, err
+		}
+
+		if !deleted {
+			break
+		}
+
+		replaced++
+	}
+
+	// Overwrite stale canonical height-to-hash mappings
+	for headerHash := header.PreviousBlockHash; !headerHash.Equal(common.EmptyHash); {
+		parent, err := bcStore.GetBlockHeader(headerHash)
+		if err != nil {
+			return replaced, err
+		}
+
+		canonicalHash, err := bcStore.GetBlockHash(parent.Height)
+		if err != nil {
+			return replaced, err
+		}
+
+		if headerHash.Equal(canonicalHash) {
+			break
+		}
+
+		if err = bcStore.PutBlockHash(parent.Height, headerHash); err != nil {
+			return replaced, err
+		}
+
+		replaced++
+		headerHash = parent.PreviousBlockHash
+	}
+
+	return replaced, nil
+}
@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/magiconair/properties/assert"
+	"github.com/seeleteam/go-seele/common"
 	"github.com/seeleteam/go-seele/core/store"
 	"github.com/seeleteam/go-seele/core/types"
 	"github.com/seeleteam/go-seele/database"
@@ -48,3 +49,88 @@ func Test_HeaderChain_WriteHeader(t *testing.T) {
 	assert.Equal(t, hc.currentHeaderHash, newHeader.Hash())
 	assert.Equal(t, hc.currentHeader, newHeader)
 }
+
+// newTestHeaders creates n linked headers after the parent, the extra is used to create different headers.
+func newTestHeaders(parent *types.BlockHeader, n int, extra int64) []*types.BlockHeader {
+	var headers []*types.BlockHeader
+	for i := 0; i < n; i++ {
+		header := &types.BlockHeader{
+			PreviousBlockHash: parent.Hash(),
+			Height:            parent.Height + 1,
+			Difficulty:        big.NewInt(1),
+			CreateTimestamp:   big.NewInt(extra),
+		}
+
+		headers = append(headers, header)
+		parent = header
+	}
+
+	return headers
+}
+
+func Test_HeaderChain_ValidateHeaderChain(t *testing.T) {
+	db, dispose := newTestDatabase()
+	defer dispose()
+
+	hc := newTestHeaderChain(db)
+	headers := newTestHeaders(hc.genesisHeader, 3, 1)
+	assert.Equal(t, hc.ValidateHeaderChain(headers), nil)
+
+	// the parent of the first header is not required
+	assert.Equal(t, hc.ValidateHeaderChain(headers[1:]), nil)
+
+	headers = newTestHeaders(hc.genesisHeader, 3, 1)
+	headers[1].PreviousBlockHash = common.EmptyHash
+	assert.Equal(t, hc.ValidateHeaderChain(headers), ErrBlockInvalidParentHash)
+
+	headers = newTestHeaders(hc.genesisHeader, 3, 1)
+	headers[2].Height = 5
+	assert.Equal(t, hc.ValidateHeaderChain(headers), ErrBlockInvalidHeight)
+
+	headers = newTestHeaders(hc.genesisHeader, 3, 1)
+	headers[0].Difficulty = big.NewInt(0)
+	assert.Equal(t, hc.ValidateHeaderChain(headers), ErrBlockInvalidDifficulty)
+
+	// the nonce is not mined for the difficulty
+	headers = newTestHeaders(hc.genesisHeader, 1, 1)
+	headers[0].Difficulty = new(big.Int).Lsh(big.NewInt(1), 255)
+	assert.Equal(t, hc.ValidateHeaderChain(headers) != nil, true)
+}
+
+func Test_HeaderChain_InsertHeaderChain(t *testing.T) {
+	db, dispose := newTestDatabase()
+	defer dispose()
+
+	hc := newTestHeaderChain(db)
+	headers := newTestHeaders(hc.genesisHeader, 3, 1)
+
+	// the parent of the first header is not in the chain
+	_, err := hc.InsertHeaderChain(headers[1:])
+	assert.Equal(t, err, ErrBlockInvalidParentHash)
+
+	n, err := hc.InsertHeaderChain(headers)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, 3)
+	assert.Equal(t, hc.CurrentHeader().Hash(), headers[2].Hash())
+
+	// the fork with less total difficulty is inserted without changing the HEAD header
+	fork := newTestHeaders(hc.genesisHeader, 4, 2)
+	_, err = hc.InsertHeaderChain(fork[:2])
+	assert.Equal(t, err, nil)
+	assert.Equal(t, hc.CurrentHeader().Hash(), headers[2].Hash())
+
+	// the fork becomes the canonical chain with larger total difficulty
+	_, err = hc.InsertHeaderChain(fork[2:])
+	assert.Equal(t, err, nil)
+	assert.Equal(t, hc.CurrentHeader().Hash(), fork[3].Hash())
+
+	for _, header := range fork {
+		hash, err := hc.bcStore.GetBlockHash(header.Height)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, hash, header.Hash())
+	}
+
+	headHash, err := hc.bcStore.GetHeadBlockHash()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, headHash, fork[3].Hash())
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/hashicorp/golang-lru"
 	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/types"
 	"github.com/seeleteam/go-seele/database"
 	"github.com/seeleteam/go-seele/trie"
 )
@@ -20,17 +21,29 @@ const StateCacheCapacity = 1000
 
 var (
 	stateBalance0 = big.NewInt(0)
+
+	// TrieDbPrefix is the db prefix of the account state trie nodes
+	TrieDbPrefix = []byte("S")
+
+	// CodeDbPrefix is the db prefix of the contract codes by the code hash
+	CodeDbPrefix = []byte("C")
+
+	// codeHashKeySuffix is appended to the account address as the key of the code hash in the trie
+	codeHashKeySuffix = []byte("code")
 )
 
 // Statedb is used to store accounts into the MPT tree
 type Statedb struct {
+	db           database.Database
 	trie         *trie.Trie
-	stateObjects *lru.Cache // stateObjects maps account addresses of common.Address type to the state objects of *StateObject type
+	stateObjects *lru.Cache             // stateObjects maps account addresses of common.Address type to the state objects of *StateObject type
+	codes        map[common.Hash][]byte // the contract codes set since the statedb is created, which are written by Commit
+	logs         []*types.Log           // the logs added by the contracts since the statedb is created
 }
 
 // NewStatedb constructs and returns a statedb instance
 func NewStatedb(root common.Hash, db database.Database) (*Statedb, error) {
-	trie, err := trie.NewTrie(root, []byte("S"), db)
+	trie, err := trie.NewTrie(root, TrieDbPrefix, db)
 	if err != nil {
 		return nil, err
 	}
@@ -41,16 +54,56 @@ func NewStatedb(root common.Hash, db database.Database) (*Statedb, error) {
 	}
 
 	return &Statedb{
+		db:           db,
 		trie:         trie,
 		stateObjects: stateCache,
+		codes:        make(map[common.Hash][]byte),
 	}, nil
 }
 
+// NewStateSync creates a sync of the account state trie of the root into the db.
+func NewStateSync(root common.Hash, db database.Database) *trie.Sync {
+	return trie.NewSync(root, TrieDbPrefix, db)
+}
+
+// GetTrieNode returns the data of the account state trie node of the hash in the db.
+func GetTrieNode(db database.Database, hash common.Hash) ([]byte, error) {
+	return db.Get(append(append([]byte{}, TrieDbPrefix...), hash.Bytes()...))
+}
+
+// GetAccountProof returns the trie proof of the account in the committed state of the root.
+func GetAccountProof(db database.Database, root common.Hash, addr common.Address) ([][]byte, error) {
+	trie, err := trie.NewTrie(root, TrieDbPrefix, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return trie.GetProof(addr[:])
+}
+
+// VerifyAccountProof verifies the trie proof of the account against the state root, and
+// returns the account. The account is empty if it does not exist in the state.
+func VerifyAccountProof(root common.Hash, addr common.Address, proof [][]byte) (*Account, error) {
+	val, err := trie.VerifyProof(root, addr[:], proof)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &Account{Amount: new(big.Int)}
+	if len(val) > 0 {
+		if err = rlp.DecodeBytes(val, account); err != nil {
+			return nil, err
+		}
+	}
+
+	return account, nil
+}
+
 // GetCopy gets a memory copy of statedb
 func (s *Statedb) GetCopy() *Statedb {
 	copies, err := lru.New(StateCacheCapacity)
 	if err != nil {
-		panic(err) // call panic, in case of the error which happens only when StateCacheCapacity is negative. 
+		panic(err) // call panic, in case of the error which happens only when StateCacheCapacity is negative.
 	}
 
 	for _, k := range s.stateObjects.Keys() {
@@ -60,9 +113,16 @@ func (s *Statedb) GetCopy() *Statedb {
 		}
 	}
 
+	codes := make(map[common.Hash][]byte, len(s.codes))
+	for hash, code := range s.codes {
+		codes[hash] = code
+	}
+
 	return &Statedb{
+		db:           s.db,
 		trie:         s.trie,
 		stateObjects: copies,
+		codes:        codes,
 	}
 }
 
@@ -117,7 +177,8 @@ func (s *Statedb) SetNonce(addr common.Address, nonce uint64) {
 	}
 }
 
-// Commit commits memory state objects to db
+// Commit commits memory state objects to db. The contract codes are written by the code
+// hash if the batch is not nil.
 func (s *Statedb) Commit(batch database.Batch) common.Hash {
 	for _, key := range s.stateObjects.Keys() {
 		value, ok := s.stateObjects.Peek(key)
@@ -130,6 +191,13 @@ func (s *Statedb) Commit(batch database.Batch) common.Hash {
 			}
 		}
 	}
+
+	if batch != nil {
+		for hash, code := range s.codes {
+			batch.Put(codeKey(hash), code)
+		}
+	}
+
 	return s.trie.Commit(batch)
 }
 
@@ -139,6 +207,49 @@ func (s *Statedb) commitOne(addr common.Address, obj *StateObject) {
 		panic(err) // must encode because the account object is a deterministic struct
 	}
 	s.trie.Put(addr[:], data)
+
+	if obj.codeDirty {
+		if obj.codeHash.IsEmpty() {
+			s.trie.Delete(codeHashKey(addr))
+		} else {
+			s.trie.Put(codeHashKey(addr), obj.codeHash.Bytes())
+			s.codes[obj.codeHash] = obj.code
+		}
+
+		obj.codeDirty = false
+	}
+}
+
+// codeHashKey returns the trie key of the code hash of the account.
+func codeHashKey(addr common.Address) []byte {
+	return append(addr.Bytes(), codeHashKeySuffix...)
+}
+
+// codeKey returns the db key of the contract code.
+func codeKey(hash common.Hash) []byte {
+	return append(append([]byte{}, CodeDbPrefix...), hash.Bytes()...)
+}
+
+// loadCode loads the contract code of the account from the trie and db.
+func (s *Statedb) loadCode(addr common.Address, obj *StateObject) error {
+	val, _ := s.trie.Get(codeHashKey(addr))
+	if len(val) == 0 {
+		return nil
+	}
+
+	hash := common.BytesToHash(val)
+	if code, ok := s.codes[hash]; ok {
+		obj.code, obj.codeHash = code, hash
+		return nil
+	}
+
+	code, err := s.db.Get(codeKey(hash))
+	if err != nil {
+		return err
+	}
+
+	obj.code, obj.codeHash = code, hash
+	return nil
 }
 
 func (s *Statedb) cache(addr common.Address, obj *StateObject) {
@@ -182,6 +293,11 @@ func (s *Statedb) getStateObject(addr common.Address) *StateObject {
 	if err := rlp.DecodeBytes(val, &object.account); err != nil {
 		return nil
 	}
+
+	if err := s.loadCode(addr, object); err != nil {
+		return nil
+	}
+
 	s.cache(addr, object)
 	return object
 }
@@ -20,27 +20,36 @@ func (s *Statedb) CreateAccount(address common.Address) {
 // GetCodeHash returns the hash of the contract code associated with the specified address if any.
 // Otherwise, return an empty hash.
 func (s *Statedb) GetCodeHash(address common.Address) common.Hash {
-	// @todo
-	return common.EmptyHash
+	stateObj := s.getStateObject(address)
+	if stateObj == nil {
+		return common.EmptyHash
+	}
+
+	return stateObj.GetCodeHash()
 }
 
 // GetCode returns the contract code associated with the specified address if any.
 // Otherwise, return nil.
 func (s *Statedb) GetCode(address common.Address) []byte {
-	// @todo
-	return nil
+	stateObj := s.getStateObject(address)
+	if stateObj == nil {
+		return nil
+	}
+
+	return stateObj.GetCode()
 }
 
 // SetCode sets the contract code of the specified address if exists.
 func (s *Statedb) SetCode(address common.Address, code []byte) {
-	// @todo
+	if stateObj := s.getStateObject(address); stateObj != nil {
+		stateObj.SetCode(code)
+	}
 }
 
 // GetCodeSize returns the size of the contract code associated with the specified address if any.
 // Otherwise, return 0.
 func (s *Statedb) GetCodeSize(address common.Address) int {
-	// @todo
-	return 0
+	return len(s.GetCode(address))
 }
 
 // AddRefund refunds the specified gas value
@@ -118,7 +127,12 @@ func (s *Statedb) Snapshot() int {
 
 // AddLog adds a log.
 func (s *Statedb) AddLog(log *types.Log) {
-	// @todo
+	s.logs = append(s.logs, log)
+}
+
+// GetLogs returns the logs added since the statedb is created.
+func (s *Statedb) GetLogs() []*types.Log {
+	return s.logs
 }
 
 // AddPreimage records a SHA3 preimage seen by the VM.
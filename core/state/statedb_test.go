@@ -15,6 +15,7 @@ import (
 
 	"github.com/magiconair/properties/assert"
 	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/crypto"
 	"github.com/seeleteam/go-seele/database"
 	"github.com/seeleteam/go-seele/database/leveldb"
 )
@@ -201,3 +202,63 @@ func TestStatedb_Cache(t *testing.T) {
 		t.Error("trie root hash should changed")
 	}
 }
+
+func Test_Statedb_AccountProof(t *testing.T) {
+	db, remove := newTestStateDB()
+	defer remove()
+
+	root := teststatedbaddbalance(common.Hash{}, db)
+
+	addr := BytesToAddressForTest([]byte{10})
+	proof, err := GetAccountProof(db, root, addr)
+	assert.Equal(t, err, nil)
+
+	account, err := VerifyAccountProof(root, addr, proof)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, account.Amount, big.NewInt(40))
+	assert.Equal(t, account.Nonce, uint64(1))
+
+	// the account not in the state is empty
+	addr = BytesToAddressForTest([]byte{1, 2, 3})
+	proof, err = GetAccountProof(db, root, addr)
+	assert.Equal(t, err, nil)
+
+	account, err = VerifyAccountProof(root, addr, proof)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, account.Amount, big.NewInt(0))
+	assert.Equal(t, account.Nonce, uint64(0))
+
+	// the proof is invalid against another state root
+	_, err = VerifyAccountProof(common.StringToHash("root"), addr, proof)
+	assert.Equal(t, err != nil, true)
+}
+
+func Test_Statedb_Code(t *testing.T) {
+	db, remove := newTestStateDB()
+	defer remove()
+
+	statedb, err := NewStatedb(common.Hash{}, db)
+	assert.Equal(t, err, nil)
+
+	addr := BytesToAddressForTest([]byte{1})
+	code := []byte{0x60, 0x00}
+	statedb.CreateAccount(addr)
+	statedb.SetCode(addr, code)
+
+	batch := db.NewBatch()
+	root := statedb.Commit(batch)
+	assert.Equal(t, batch.Commit(), nil)
+
+	// the code is loaded by the code hash after reopened
+	statedb, err = NewStatedb(root, db)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, statedb.GetCode(addr), code)
+	assert.Equal(t, statedb.GetCodeHash(addr), crypto.HashBytes(code))
+
+	// the code hash is in the state, so that the code changes the state root
+	statedb.SetCode(addr, nil)
+	batch = db.NewBatch()
+	assert.Equal(t, statedb.Commit(batch) == root, false)
+	assert.Equal(t, batch.Commit(), nil)
+	assert.Equal(t, statedb.GetCode(addr) == nil, true)
+}
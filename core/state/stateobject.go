@@ -5,7 +5,12 @@
 
 package state
 
-import "math/big"
+import (
+	"math/big"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/crypto"
+)
 
 // Account is a balance model for blockchain
 type Account struct {
@@ -15,8 +20,11 @@ type Account struct {
 
 // StateObject is the state object for statedb
 type StateObject struct {
-	account Account
-	dirty   bool
+	account   Account
+	code      []byte      // the contract code, nil if no code
+	codeHash  common.Hash // the hash of the contract code, empty if no code
+	codeDirty bool        // whether the code is set since the state object is committed
+	dirty     bool
 }
 
 func newStateObject() *StateObject {
@@ -36,7 +44,10 @@ func (s *StateObject) GetCopy() *StateObject {
 			Nonce:  s.account.Nonce,
 			Amount: big.NewInt(0).Set(s.account.Amount),
 		},
-		dirty: s.dirty,
+		code:      s.code,
+		codeHash:  s.codeHash,
+		codeDirty: s.codeDirty,
+		dirty:     s.dirty,
 	}
 }
 
@@ -73,3 +84,25 @@ func (s *StateObject) AddAmount(amount *big.Int) {
 func (s *StateObject) SubAmount(amount *big.Int) {
 	s.SetAmount(new(big.Int).Sub(s.account.Amount, amount))
 }
+
+// GetCode gets the contract code of the account in the state object
+func (s *StateObject) GetCode() []byte {
+	return s.code
+}
+
+// GetCodeHash gets the hash of the contract code of the account in the state object
+func (s *StateObject) GetCodeHash() common.Hash {
+	return s.codeHash
+}
+
+// SetCode sets the contract code of the account in the state object
+func (s *StateObject) SetCode(code []byte) {
+	s.code = code
+	s.codeHash = common.EmptyHash
+	if len(code) > 0 {
+		s.codeHash = crypto.HashBytes(code)
+	}
+
+	s.codeDirty = true
+	s.dirty = true
+}
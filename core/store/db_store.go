@@ -22,6 +22,9 @@ var (
 	keyPrefixHeader = []byte("h")
 	keyPrefixTD     = []byte("t")
 	keyPrefixBody   = []byte("b")
+
+	keyPrefixReceipts = []byte("r")
+	keyPrefixTxIndex  = []byte("l")
 )
 
 // blockBody represents the payload of a block
@@ -36,19 +39,25 @@ type blockchainDatabase struct {
 
 // NewBlockchainDatabase returns a blockchainDatabase instance.
 // There are following mappings in database:
-//   1) keyPrefixHash + height => hash
-//   2) keyHeadBlockHash => HEAD hash
-//   3) keyPrefixHeader + hash => header
-//   4) keyPrefixTD + hash => total difficulty (td for short)
-//   5) keyPrefixBody + hash => block body (transactions)
+//  1. keyPrefixHash + height => hash
+//  2. keyHeadBlockHash => HEAD hash
+//  3. keyPrefixHeader + hash => header
+//  4. keyPrefixTD + hash => total difficulty (td for short)
+//  5. keyPrefixBody + hash => block body (transactions)
+//  6. keyPrefixReceipts + hash => block receipts
+//  7. keyPrefixTxIndex + tx hash => tx index (block hash and index in the block)
 func NewBlockchainDatabase(db database.Database) BlockchainStore {
 	return &blockchainDatabase{db}
 }
 
-func heightToHashKey(height uint64) []byte { return append(keyPrefixHash, encodeBlockHeight(height)...) }
+func heightToHashKey(height uint64) []byte {
+	return append(keyPrefixHash, encodeBlockHeight(height)...)
+}
 func hashToHeaderKey(hash []byte) []byte   { return append(keyPrefixHeader, hash...) }
 func hashToTDKey(hash []byte) []byte       { return append(keyPrefixTD, hash...) }
 func hashToBodyKey(hash []byte) []byte     { return append(keyPrefixBody, hash...) }
+func hashToReceiptsKey(hash []byte) []byte { return append(keyPrefixReceipts, hash...) }
+func hashToTxIndexKey(hash []byte) []byte  { return append(keyPrefixTxIndex, hash...) }
 
 // GetBlockHash gets the hash of the block with the specified height in the blockchain database
 func (store *blockchainDatabase) GetBlockHash(height uint64) (common.Hash, error) {
@@ -60,8 +69,8 @@ func (store *blockchainDatabase) GetBlockHash(height uint64) (common.Hash, error
 	return common.BytesToHash(hashBytes), nil
 }
 
-// PutBlockHash puts the given block height which is encoded as the key 
-// and hash as the value to the blockchain database. 
+// PutBlockHash puts the given block height which is encoded as the key
+// and hash as the value to the blockchain database.
 func (store *blockchainDatabase) PutBlockHash(height uint64, hash common.Hash) error {
 	return store.db.Put(heightToHashKey(height), hash.Bytes())
 }
@@ -155,6 +164,10 @@ func (store *blockchainDatabase) putBlockInternal(hash common.Hash, header *type
 
 	if body != nil {
 		batch.Put(hashToBodyKey(hashBytes), common.SerializePanic(body))
+
+		for i, tx := range body.Txs {
+			batch.Put(hashToTxIndexKey(tx.Hash.Bytes()), common.SerializePanic(&TxIndex{hash, uint64(i)}))
+		}
 	}
 
 	if isHead {
@@ -226,3 +239,43 @@ func (store *blockchainDatabase) GetBlock(hash common.Hash) (*types.Block, error
 		Transactions: body.Txs,
 	}, nil
 }
+
+// PutReceipts serializes the receipts of the txs in the block with the specified hash into the blockchain database.
+func (store *blockchainDatabase) PutReceipts(hash common.Hash, receipts []*types.Receipt) error {
+	receiptsBytes, err := common.Serialize(receipts)
+	if err != nil {
+		return err
+	}
+
+	return store.db.Put(hashToReceiptsKey(hash.Bytes()), receiptsBytes)
+}
+
+// GetReceiptsByBlockHash gets the receipts of the txs in the block with the specified hash in the blockchain database
+func (store *blockchainDatabase) GetReceiptsByBlockHash(hash common.Hash) ([]*types.Receipt, error) {
+	receiptsBytes, err := store.db.Get(hashToReceiptsKey(hash.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+
+	var receipts []*types.Receipt
+	if err = common.Deserialize(receiptsBytes, &receipts); err != nil {
+		return nil, err
+	}
+
+	return receipts, nil
+}
+
+// GetTxIndex gets the index of the tx with the specified hash in the blockchain database
+func (store *blockchainDatabase) GetTxIndex(txHash common.Hash) (*TxIndex, error) {
+	indexBytes, err := store.db.Get(hashToTxIndexKey(txHash.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+
+	index := new(TxIndex)
+	if err = common.Deserialize(indexBytes, index); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
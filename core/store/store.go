@@ -12,6 +12,12 @@ import (
 	"github.com/seeleteam/go-seele/core/types"
 )
 
+// TxIndex is the position of a transaction in the blockchain.
+type TxIndex struct {
+	BlockHash common.Hash // the hash of the block which contains the tx
+	Index     uint64      // the index of the tx in the block
+}
+
 // BlockchainStore is the interface that wraps the atomic CRUD methods of blockchain.
 type BlockchainStore interface {
 	// GetBlockHash retrieves the block hash for the specified canonical block height.
@@ -45,4 +51,15 @@ type BlockchainStore interface {
 
 	// HasBlock checks if the block with the specified hash exists.
 	HasBlock(hash common.Hash) (bool, error)
+
+	// PutReceipts serializes the receipts of the txs in the block with the specified hash into the store.
+	PutReceipts(hash common.Hash, receipts []*types.Receipt) error
+
+	// GetReceiptsByBlockHash retrieves the receipts of the txs in the block with the specified hash.
+	GetReceiptsByBlockHash(hash common.Hash) ([]*types.Receipt, error)
+
+	// GetTxIndex retrieves the index of the tx with the specified hash, which is written with the
+	// body of the block. If the tx is included by the blocks of different branches, the index
+	// is the one in the latest written block.
+	GetTxIndex(txHash common.Hash) (*TxIndex, error)
 }
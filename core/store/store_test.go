@@ -101,6 +101,10 @@ func Test_blockchainDatabase_Block(t *testing.T) {
 		Transactions: []*types.Transaction{newTestTx(), newTestTx(), newTestTx()},
 	}
 
+	for _, tx := range block.Transactions {
+		tx.Hash = crypto.MustHash(tx.Data)
+	}
+
 	testBlockchainDatabase(func(bcStore BlockchainStore) {
 		err := bcStore.PutBlock(block, header.Difficulty, true)
 		assert.Equal(t, err, error(nil))
@@ -108,5 +112,47 @@ func Test_blockchainDatabase_Block(t *testing.T) {
 		storedBlock, err := bcStore.GetBlock(block.HeaderHash)
 		assert.Equal(t, err, error(nil))
 		assert.Equal(t, storedBlock, block)
+
+		for i, tx := range block.Transactions {
+			index, err := bcStore.GetTxIndex(tx.Hash)
+			assert.Equal(t, err, error(nil))
+			assert.Equal(t, *index, TxIndex{block.HeaderHash, uint64(i)})
+		}
+
+		_, err = bcStore.GetTxIndex(common.StringToHash("tx"))
+		assert.Equal(t, err != nil, true)
+	})
+}
+
+func Test_blockchainDatabase_Receipts(t *testing.T) {
+	blockHash := common.StringToHash("block")
+	receipts := []*types.Receipt{
+		{TxHash: common.StringToHash("tx1")},
+		{
+			TxHash: common.StringToHash("tx2"),
+			Logs: []*types.Log{
+				{
+					Address:     *crypto.MustGenerateRandomAddress(),
+					Topics:      []common.Hash{common.StringToHash("topic")},
+					Data:        []byte("data"),
+					BlockNumber: 1,
+					TxIndex:     1,
+				},
+			},
+		},
+	}
+
+	testBlockchainDatabase(func(bcStore BlockchainStore) {
+		_, err := bcStore.GetReceiptsByBlockHash(blockHash)
+		assert.Equal(t, err != nil, true)
+
+		err = bcStore.PutReceipts(blockHash, receipts)
+		assert.Equal(t, err, error(nil))
+
+		storedReceipts, err := bcStore.GetReceiptsByBlockHash(blockHash)
+		assert.Equal(t, err, error(nil))
+		assert.Equal(t, len(storedReceipts), 2)
+		assert.Equal(t, storedReceipts[0].TxHash, receipts[0].TxHash)
+		assert.Equal(t, storedReceipts[1].Logs, receipts[1].Logs)
 	})
 }
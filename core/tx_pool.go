@@ -13,6 +13,7 @@ import (
 	"github.com/seeleteam/go-seele/core/state"
 	"github.com/seeleteam/go-seele/core/types"
 	"github.com/seeleteam/go-seele/event"
+	"github.com/seeleteam/go-seele/metrics"
 )
 
 var (
@@ -20,6 +21,11 @@ var (
 	errTxPoolFull   = errors.New("transaction pool is full")
 )
 
+var (
+	txPoolDropCounter    = metrics.GetOrRegisterCounter("txpool_dropped_total", "the txs dropped as the pool is full")
+	txPoolInvalidCounter = metrics.GetOrRegisterCounter("txpool_invalid_total", "the invalid txs rejected by the pool")
+)
+
 type blockchain interface {
 	CurrentState() *state.Statedb
 }
@@ -52,6 +58,7 @@ func NewTransactionPool(config TransactionPoolConfig, chain blockchain) *Transac
 func (pool *TransactionPool) AddTransaction(tx *types.Transaction) error {
 	statedb := pool.chain.CurrentState()
 	if err := tx.Validate(statedb); err != nil {
+		txPoolInvalidCounter.Inc(1)
 		return err
 	}
 
@@ -63,6 +70,7 @@ func (pool *TransactionPool) AddTransaction(tx *types.Transaction) error {
 	}
 
 	if uint(len(pool.hashToTxMap)) >= pool.config.Capacity {
+		txPoolDropCounter.Inc(1)
 		return errTxPoolFull
 	}
 
@@ -124,6 +132,43 @@ func (pool *TransactionPool) GetProcessableTransactions() map[common.Address][]*
 	return allAccountTxs
 }
 
+// GetPendingNonce returns the next nonce of the account, which follows the largest nonce of the
+// account txs in the pool, or the nonce in the current state if the pool has no larger nonce.
+func (pool *TransactionPool) GetPendingNonce(account common.Address) uint64 {
+	nonce := pool.chain.CurrentState().GetNonce(account)
+
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
+	if collection := pool.accountToTxsMap[account]; collection != nil {
+		for txNonce := range collection.nonceToTxMap {
+			if txNonce >= nonce {
+				nonce = txNonce + 1
+			}
+		}
+	}
+
+	return nonce
+}
+
+// Stats returns the number of the pending txs, whose nonces follow the account nonces in the
+// current state without gaps, and the number of the other queued txs.
+func (pool *TransactionPool) Stats() (int, int) {
+	statedb := pool.chain.CurrentState()
+
+	pool.mutex.RLock()
+	defer pool.mutex.RUnlock()
+
+	pending := 0
+	for account, collection := range pool.accountToTxsMap {
+		for nonce := statedb.GetNonce(account); collection.nonceToTxMap[nonce] != nil; nonce++ {
+			pending++
+		}
+	}
+
+	return pending, len(pool.hashToTxMap) - pending
+}
+
 // Stop terminates the transaction pool.
 func (pool *TransactionPool) Stop() {
 	// TODO remove event listeners
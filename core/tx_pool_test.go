@@ -193,3 +193,34 @@ func Test_TransactionPool_Remove(t *testing.T) {
 	assert.Equal(t, len(pool.hashToTxMap), 0)
 	assert.Equal(t, len(pool.accountToTxsMap), 0)
 }
+
+func Test_TransactionPool_GetPendingNonce(t *testing.T) {
+	chain := newMockBlockchain()
+	pool := NewTransactionPool(*DefaultTxPoolConfig(), chain)
+	account, txs := newTestAccountTxs(t, []int64{1, 2}, []uint64{5, 6})
+	chain.addAccount(account, 10, 5)
+
+	assert.Equal(t, pool.GetPendingNonce(account), uint64(5))
+
+	for _, tx := range txs {
+		pool.AddTransaction(tx)
+	}
+
+	assert.Equal(t, pool.GetPendingNonce(account), uint64(7))
+}
+
+func Test_TransactionPool_Stats(t *testing.T) {
+	chain := newMockBlockchain()
+	pool := NewTransactionPool(*DefaultTxPoolConfig(), chain)
+	account, txs := newTestAccountTxs(t, []int64{1, 2, 3}, []uint64{5, 6, 8})
+	chain.addAccount(account, 10, 5)
+
+	for _, tx := range txs {
+		assert.Equal(t, pool.AddTransaction(tx), error(nil))
+	}
+
+	// the tx of nonce 8 waits for the tx of nonce 7
+	pending, queued := pool.Stats()
+	assert.Equal(t, pending, 2)
+	assert.Equal(t, queued, 1)
+}
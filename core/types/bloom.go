@@ -0,0 +1,102 @@
+/**
+* @file
+* @copyright defined in go-seele/LICENSE
+ */
+
+package types
+
+import (
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/crypto"
+)
+
+const (
+	// BloomByteLength is the number of bytes of the logs bloom in the block header.
+	BloomByteLength = 256
+
+	// BloomBitLength is the number of bits of the logs bloom in the block header.
+	BloomBitLength = 8 * BloomByteLength
+
+	// bloomHashFuncs is the number of bits set in the bloom for each value.
+	bloomHashFuncs = 3
+)
+
+// Bloom is a 2048 bits bloom filter of the contract addresses and topics of the logs.
+type Bloom [BloomByteLength]byte
+
+// BloomBitIndexes returns the indexes of the bits set in the bloom for the value, which
+// are taken from the first 6 bytes of the value hash.
+func BloomBitIndexes(value []byte) [bloomHashFuncs]uint {
+	hash := crypto.HashBytes(value)
+
+	var indexes [bloomHashFuncs]uint
+	for i := range indexes {
+		indexes[i] = (uint(hash[2*i])<<8 | uint(hash[2*i+1])) % BloomBitLength
+	}
+
+	return indexes
+}
+
+// Add adds the value into the bloom.
+func (b *Bloom) Add(value []byte) {
+	for _, index := range BloomBitIndexes(value) {
+		b.SetBit(index)
+	}
+}
+
+// Test returns false if the value is definitely not in the bloom.
+func (b *Bloom) Test(value []byte) bool {
+	for _, index := range BloomBitIndexes(value) {
+		if !b.Bit(index) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SetBit sets the bit of the specified index.
+func (b *Bloom) SetBit(index uint) {
+	b[index/8] |= 1 << (index % 8)
+}
+
+// Bit returns true if the bit of the specified index is set.
+func (b *Bloom) Bit(index uint) bool {
+	return b[index/8]&(1<<(index%8)) != 0
+}
+
+// LogsBloom creates the bloom of the contract addresses and topics of the logs.
+func LogsBloom(logs []*Log) Bloom {
+	var bloom Bloom
+	for _, log := range logs {
+		bloom.Add(log.Address.Bytes())
+		for _, topic := range log.Topics {
+			bloom.Add(topic.Bytes())
+		}
+	}
+
+	return bloom
+}
+
+// ReceiptsBloom creates the bloom of the logs in the receipts.
+func ReceiptsBloom(receipts []*Receipt) Bloom {
+	var bloom Bloom
+	for _, receipt := range receipts {
+		receiptBloom := LogsBloom(receipt.Logs)
+		for i := range bloom {
+			bloom[i] |= receiptBloom[i]
+		}
+	}
+
+	return bloom
+}
+
+// TestAddress returns false if the address is definitely not in the bloom.
+func (b *Bloom) TestAddress(addr common.Address) bool {
+	return b.Test(addr.Bytes())
+}
+
+// TestTopic returns false if the topic is definitely not in the bloom.
+func (b *Bloom) TestTopic(topic common.Hash) bool {
+	return b.Test(topic.Bytes())
+}
@@ -0,0 +1,41 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package types
+
+import (
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+	"github.com/seeleteam/go-seele/common"
+)
+
+func Test_Bloom(t *testing.T) {
+	var bloom Bloom
+	bloom.Add([]byte("value"))
+
+	assert.Equal(t, bloom.Test([]byte("value")), true)
+	assert.Equal(t, bloom.Test([]byte("other")), false)
+
+	for _, index := range BloomBitIndexes([]byte("value")) {
+		assert.Equal(t, bloom.Bit(index), true)
+	}
+}
+
+func Test_ReceiptsBloom(t *testing.T) {
+	addr := randomAddress(t)
+	topic := common.StringToHash("topic")
+	receipts := []*Receipt{
+		{},
+		{Logs: []*Log{{Address: addr, Topics: []common.Hash{topic}}}},
+	}
+
+	bloom := ReceiptsBloom(receipts)
+	assert.Equal(t, bloom.TestAddress(addr), true)
+	assert.Equal(t, bloom.TestTopic(topic), true)
+	assert.Equal(t, bloom.TestTopic(common.StringToHash("other")), false)
+
+	assert.Equal(t, ReceiptsBloom(nil), Bloom{})
+}
@@ -196,3 +196,28 @@ func MerkleRootHash(txs []*Transaction) common.Hash {
 
 	return bmt.MerkleRoot()
 }
+
+// GetTxProof returns the merkle proof of the transaction of the index in the specified transactions.
+func GetTxProof(txs []*Transaction, index int) ([]common.Hash, error) {
+	contents := make([]merkle.Content, len(txs))
+	for i, tx := range txs {
+		contents[i] = tx
+	}
+
+	bmt, err := merkle.NewTree(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	return bmt.GetProof(index)
+}
+
+// VerifyTxProof returns true if the proof shows the transaction of the index is included
+// in the transactions of the merkle root hash.
+func VerifyTxProof(root common.Hash, tx *Transaction, index int, proof []common.Hash) bool {
+	if tx == nil || tx.Data == nil || !tx.CalculateHash().Equal(tx.Hash) {
+		return false
+	}
+
+	return merkle.VerifyProof(root, tx.Hash, index, proof)
+}
@@ -125,6 +125,31 @@ func Test_MerkleRootHash_Empty(t *testing.T) {
 	assert.Equal(t, hash, emptyTxRootHash)
 }
 
+func Test_TxProof(t *testing.T) {
+	var txs []*Transaction
+	for i := 0; i < 5; i++ {
+		txs = append(txs, newTestTx(t, 10, uint64(i), true))
+	}
+
+	root := MerkleRootHash(txs)
+	for i, tx := range txs {
+		proof, err := GetTxProof(txs, i)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, VerifyTxProof(root, tx, i, proof), true)
+	}
+
+	// the transaction data is changed
+	proof, _ := GetTxProof(txs, 0)
+	changed := *txs[0]
+	changed.Data = &TransactionData{}
+	*changed.Data = *txs[0].Data
+	changed.Data.AccountNonce = 10
+	assert.Equal(t, VerifyTxProof(root, &changed, 0, proof), false)
+
+	// the proof of another transaction
+	assert.Equal(t, VerifyTxProof(root, txs[1], 0, proof), false)
+}
+
 func Test_Transaction_Validate_BalanceNotEnough(t *testing.T) {
 	tx := newTestTx(t, 100, 38, true)
 	statedb := newTestStateDB(tx.Data.From, 38, 50)
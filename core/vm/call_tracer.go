@@ -0,0 +1,192 @@
+/**
+* @file
+* @copyright defined in go-seele/LICENSE
+ */
+
+package vm
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/seeleteam/go-seele/common"
+)
+
+// errInternalFailure is the error of the nested calls which failed, whose reason is not
+// exposed to the caller contract.
+const errInternalFailure = "internal failure"
+
+// CallFrame is a call in the call tree captured by the CallTracer.
+type CallFrame struct {
+	Type    string // CALL, CALLCODE, DELEGATECALL, STATICCALL or CREATE
+	From    common.Address
+	To      common.Address
+	Value   *big.Int
+	Gas     uint64
+	GasUsed uint64
+	Input   []byte
+	Output  []byte
+	Error   string
+	Calls   []*CallFrame
+
+	gasIn   uint64 // the gas of the caller before the call op
+	gasCost uint64 // the cost of the call op, including the gas of the call
+}
+
+// CallTracer is an EVM tracer which captures the tree of the nested calls and creations,
+// with the value, input and output of each call.
+type CallTracer struct {
+	callstack []*CallFrame // the frames being executed, the root frame is at depth 1
+}
+
+// NewCallTracer returns a new call tracer.
+func NewCallTracer() *CallTracer {
+	return &CallTracer{}
+}
+
+// CaptureStart starts the root call frame.
+func (t *CallTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	typ := CALL.String()
+	if create {
+		typ = CREATE.String()
+	}
+
+	t.callstack = []*CallFrame{{
+		Type:  typ,
+		From:  from,
+		To:    to,
+		Value: new(big.Int).Set(value),
+		Gas:   gas,
+		Input: copyBytes(input),
+	}}
+
+	return nil
+}
+
+// CaptureState finishes the frames returned to the caller, and starts a frame if the op
+// is a call or creation.
+func (t *CallTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	if err != nil || len(t.callstack) == 0 {
+		return nil
+	}
+
+	for len(t.callstack) > depth {
+		t.exit(env, gas, stack)
+	}
+
+	var frame *CallFrame
+	switch op {
+	case CREATE:
+		available := gas - cost
+		frame = &CallFrame{
+			Value: new(big.Int).Set(stack.Back(0)),
+			Gas:   available - available/64,
+			Input: memory.Get(stack.Back(1).Int64(), stack.Back(2).Int64()),
+		}
+	case CALL, CALLCODE:
+		frame = &CallFrame{
+			To:    common.BigToAddress(stack.Back(1)),
+			Value: new(big.Int).Set(stack.Back(2)),
+			Gas:   env.callGasTemp,
+			Input: memory.Get(stack.Back(3).Int64(), stack.Back(4).Int64()),
+		}
+
+		if frame.Value.Sign() != 0 {
+			frame.Gas += params.CallStipend
+		}
+	case DELEGATECALL, STATICCALL:
+		frame = &CallFrame{
+			To:    common.BigToAddress(stack.Back(1)),
+			Gas:   env.callGasTemp,
+			Input: memory.Get(stack.Back(2).Int64(), stack.Back(3).Int64()),
+		}
+	default:
+		return nil
+	}
+
+	frame.Type = op.String()
+	frame.From = contract.Address()
+	frame.gasIn = gas
+	frame.gasCost = cost
+
+	if op == DELEGATECALL {
+		frame.Value = new(big.Int).Set(contract.value)
+	}
+
+	t.callstack = append(t.callstack, frame)
+	return nil
+}
+
+// exit finishes the innermost frame with the state of the caller after the call op.
+func (t *CallTracer) exit(env *EVM, gas uint64, stack *Stack) {
+	frame := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+
+	// the left gas of the call is refunded to the caller
+	if leftOverGas := gas + frame.gasCost - frame.gasIn; gas+frame.gasCost >= frame.gasIn && leftOverGas <= frame.Gas {
+		frame.GasUsed = frame.Gas - leftOverGas
+	}
+
+	// the call pushes the success flag, and the creation pushes the contract address
+	result := stack.Back(0)
+	if frame.Type == CREATE.String() {
+		frame.To = common.BigToAddress(result)
+	} else {
+		frame.Output = copyBytes(env.interpreter.returnData)
+	}
+
+	if result.Sign() == 0 {
+		frame.Error = errInternalFailure
+	}
+
+	parent := t.callstack[len(t.callstack)-1]
+	parent.Calls = append(parent.Calls, frame)
+}
+
+// CaptureFault implements the Tracer interface.
+func (t *CallTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd finishes the root call frame, and the frames which are not returned.
+func (t *CallTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	if len(t.callstack) == 0 {
+		return nil
+	}
+
+	for len(t.callstack) > 1 {
+		frame := t.callstack[len(t.callstack)-1]
+		t.callstack = t.callstack[:len(t.callstack)-1]
+
+		frame.Error = errInternalFailure
+		parent := t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, frame)
+	}
+
+	root := t.callstack[0]
+	root.Output = copyBytes(output)
+	root.GasUsed = gasUsed
+	if err != nil {
+		root.Error = err.Error()
+	}
+
+	return nil
+}
+
+// Result returns the root call frame, or nil if nothing is captured.
+func (t *CallTracer) Result() *CallFrame {
+	if len(t.callstack) == 0 {
+		return nil
+	}
+
+	return t.callstack[0]
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+
+	return append([]byte{}, b...)
+}
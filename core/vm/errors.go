@@ -25,4 +25,8 @@ var (
 	ErrTraceLimitReached        = errors.New("the number of logs reached the specified limit")
 	ErrInsufficientBalance      = errors.New("insufficient balance for transfer")
 	ErrContractAddressCollision = errors.New("contract address collision")
+
+	// ErrExecutionReverted is returned when the execution is reverted by the REVERT opcode,
+	// and the returned data is the revert reason.
+	ErrExecutionReverted = errExecutionReverted
 )
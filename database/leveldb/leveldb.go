@@ -10,6 +10,7 @@ import (
 
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/storage"
 )
 
 // LevelDB level db struct
@@ -36,6 +37,16 @@ func NewLevelDB(path string) (database.Database, error) {
 	return result, nil
 }
 
+// NewMemDatabase news database interface of level db in memory, which is used in tests and simulations.
+func NewMemDatabase() (database.Database, error) {
+	db, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LevelDB{db: db}, nil
+}
+
 // Close don't forget to close db when not use
 func (db *LevelDB) Close() {
 	db.db.Close()
@@ -121,3 +121,16 @@ func newDbInstance(dbPath string) database.Database {
 
 	return db
 }
+
+func Test_MemDatabase(t *testing.T) {
+	db, err := NewMemDatabase()
+	assert.Equal(t, err, nil)
+	defer db.Close()
+
+	err = db.PutString("1", "2")
+	assert.Equal(t, err, nil)
+
+	value, err := db.GetString("1")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, value, "2")
+}
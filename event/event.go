@@ -21,4 +21,7 @@ type eventListener struct {
 	Callable        EventHandleMethod
 	IsOnceListener  bool
 	IsAsyncListener bool
+
+	// subscription id, 0 if the listener is not added by Subscribe
+	subID uint64
 }
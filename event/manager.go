@@ -15,6 +15,13 @@ import (
 type EventManager struct {
 	lock      sync.RWMutex
 	listeners []eventListener
+	lastSubID uint64
+}
+
+// Subscription is a listener added by Subscribe or SubscribeSync.
+type Subscription struct {
+	manager *EventManager
+	id      uint64
 }
 
 // Fire fires the event and returns it after all listeners have done
@@ -75,6 +82,47 @@ func (h *EventManager) AddAsyncListener(callback EventHandleMethod) {
 	h.addEventListener(listener)
 }
 
+// Subscribe adds an async listener and returns the subscription to remove it.
+// Unlike AddAsyncListener, it is not checked for duplicates, so that method values or
+// closures of different objects, which share the same code pointer, can listen to the same event.
+func (h *EventManager) Subscribe(callback EventHandleMethod) *Subscription {
+	return h.subscribe(callback, true)
+}
+
+// SubscribeSync adds a listener which runs in the goroutine firing the event, so that the events
+// are handled in order. The callback must not block, nor call the methods of the manager.
+func (h *EventManager) SubscribeSync(callback EventHandleMethod) *Subscription {
+	return h.subscribe(callback, false)
+}
+
+func (h *EventManager) subscribe(callback EventHandleMethod, async bool) *Subscription {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.lastSubID++
+	h.listeners = append(h.listeners, eventListener{
+		Callable:        callback,
+		IsAsyncListener: async,
+		subID:           h.lastSubID,
+	})
+
+	return &Subscription{h, h.lastSubID}
+}
+
+// Unsubscribe removes the listener of the subscription.
+func (s *Subscription) Unsubscribe() {
+	h := s.manager
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for i, l := range h.listeners {
+		if l.subID == s.id {
+			h.listeners = append(h.listeners[:i], h.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
 // addEventListener registers a event listener.
 // If there is already a same listener (same method pointer), we will not add it
 func (h *EventManager) addEventListener(listener eventListener) {
@@ -117,6 +165,10 @@ func (h *EventManager) find(callback EventHandleMethod) int {
 	p := reflect.ValueOf(callback).Pointer()
 
 	for i, l := range h.listeners {
+		if l.subID != 0 {
+			continue
+		}
+
 		lp := reflect.ValueOf(l.Callable).Pointer()
 		if lp == p {
 			return i
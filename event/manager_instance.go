@@ -23,3 +23,9 @@ var TransactionInsertedEventManager = NewEventManager()
 
 // BlockInsertedEventManager is event of new block inserted into blockchain
 var BlockInsertedEventManager = NewEventManager()
+
+// ChainHeaderChangedEventManager is event of the HEAD block of the blockchain changed
+var ChainHeaderChangedEventManager = NewEventManager()
+
+// ChainLogsEventManager is event of the logs of the new HEAD block of the blockchain
+var ChainLogsEventManager = NewEventManager()
@@ -108,3 +108,53 @@ func Test_EventOnceAndAsync(t *testing.T) {
 	assert.Equal(t, count, 1)
 	assert.Equal(t, len(manager.listeners), 0)
 }
+
+type testSubscriber struct {
+	count chan int
+}
+
+func (s *testSubscriber) handle(e Event) {
+	s.count <- e.(int)
+}
+
+func Test_EventSubscribe(t *testing.T) {
+	manager := NewEventManager()
+	s1 := &testSubscriber{make(chan int, 1)}
+	s2 := &testSubscriber{make(chan int, 1)}
+
+	// method values of different objects share the same code pointer
+	sub1 := manager.Subscribe(s1.handle)
+	manager.Subscribe(s2.handle)
+	manager.AddAsyncListener(s1.handle)
+	assert.Equal(t, len(manager.listeners), 3)
+
+	manager.Fire(1)
+	assert.Equal(t, <-s1.count+<-s1.count, 2)
+	assert.Equal(t, <-s2.count, 1)
+
+	sub1.Unsubscribe()
+	manager.RemoveListener(s1.handle)
+	assert.Equal(t, len(manager.listeners), 1)
+
+	manager.Fire(2)
+	assert.Equal(t, <-s2.count, 2)
+}
+
+func Test_EventSubscribeSync(t *testing.T) {
+	manager := NewEventManager()
+	var events []int
+	sub := manager.SubscribeSync(func(e Event) {
+		events = append(events, e.(int))
+	})
+
+	// the events are handled in order before Fire returns
+	for i := 0; i < 10; i++ {
+		manager.Fire(i)
+	}
+
+	assert.Equal(t, events, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+
+	sub.Unsubscribe()
+	manager.Fire(10)
+	assert.Equal(t, len(events), 10)
+}
@@ -0,0 +1,101 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package light
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/common/hexutil"
+	"github.com/seeleteam/go-seele/core/types"
+)
+
+var errAccountRequired = errors.New("Account is required by the light node")
+
+// PublicSeeleAPI provides an API to access the account states and transactions on the light node.
+type PublicSeeleAPI struct {
+	s *LightService
+}
+
+// NewPublicSeeleAPI creates a new PublicSeeleAPI object for rpc service.
+func NewPublicSeeleAPI(s *LightService) *PublicSeeleAPI {
+	return &PublicSeeleAPI{s}
+}
+
+// GetBalance gets the balance of the account, which is verified with the proof from the servers.
+func (api *PublicSeeleAPI) GetBalance(account *common.Address, result *big.Int) error {
+	if account == nil || account.Equal(common.Address{}) {
+		return errAccountRequired
+	}
+
+	state, err := api.s.GetAccount(*account)
+	if err != nil {
+		return err
+	}
+
+	result.Set(state.Amount)
+	return nil
+}
+
+// GetAccountNonce gets the account next used nonce, which is verified with the proof from the servers.
+func (api *PublicSeeleAPI) GetAccountNonce(account *common.Address, nonce *uint64) error {
+	if account == nil || account.Equal(common.Address{}) {
+		return errAccountRequired
+	}
+
+	state, err := api.s.GetAccount(*account)
+	if err != nil {
+		return err
+	}
+
+	*nonce = state.Nonce
+	return nil
+}
+
+// AddTx relays the tx to the tx pool of a server
+func (api *PublicSeeleAPI) AddTx(tx *types.Transaction, result *bool) error {
+	if err := api.s.SendTransaction(tx); err != nil {
+		*result = false
+		return err
+	}
+
+	*result = true
+	return nil
+}
+
+// GetBlockHeight gets the block height of the header chain head
+func (api *PublicSeeleAPI) GetBlockHeight(input interface{}, height *uint64) error {
+	*height = api.s.chain.CurrentHeader().Height
+	return nil
+}
+
+// GetTransactionRequest request param for GetTransaction api
+type GetTransactionRequest struct {
+	BlockHashHex string
+	TxHashHex    string
+}
+
+// GetTransaction gets the transaction in the block, which is verified with the inclusion proof from the servers.
+func (api *PublicSeeleAPI) GetTransaction(request *GetTransactionRequest, result *types.Transaction) error {
+	blockHash, err := hexutil.HexToBytes(request.BlockHashHex)
+	if err != nil {
+		return err
+	}
+
+	txHash, err := hexutil.HexToBytes(request.TxHashHex)
+	if err != nil {
+		return err
+	}
+
+	tx, err := api.s.GetTransaction(common.BytesToHash(blockHash), common.BytesToHash(txHash))
+	if err != nil {
+		return err
+	}
+
+	*result = *tx
+	return nil
+}
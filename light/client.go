@@ -0,0 +1,297 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package light
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core"
+	"github.com/seeleteam/go-seele/core/store"
+	"github.com/seeleteam/go-seele/log"
+	"github.com/seeleteam/go-seele/p2p"
+)
+
+var errNoServer = errors.New("No light server available")
+
+// clientProtocol is the light protocol of the light node. It synchronises the header chain
+// from the servers, and retrieves the proofs of the states and transactions from them.
+type clientProtocol struct {
+	p2p.Protocol
+
+	networkID uint64
+	chain     *core.HeaderChain
+	bcStore   store.BlockchainStore
+
+	lock    sync.RWMutex
+	peers   map[common.Address]*peer
+	syncing int32 // 1 if synchronising headers, accessed atomically
+
+	wg     sync.WaitGroup
+	syncCh chan struct{}
+	quitCh chan struct{}
+	log    *log.SeeleLog
+}
+
+func newClientProtocol(networkID uint64, chain *core.HeaderChain, bcStore store.BlockchainStore, log *log.SeeleLog) *clientProtocol {
+	c := &clientProtocol{
+		Protocol: p2p.Protocol{
+			Name:    LightProtoName,
+			Version: LightVersion,
+			Length:  protocolMsgCodeLength,
+		},
+		networkID: networkID,
+		chain:     chain,
+		bcStore:   bcStore,
+		peers:     make(map[common.Address]*peer),
+		syncCh:    make(chan struct{}, 1),
+		quitCh:    make(chan struct{}),
+		log:       log,
+	}
+
+	c.Protocol.AddPeer = c.handleAddPeer
+	c.Protocol.DeletePeer = c.handleDelPeer
+
+	return c
+}
+
+func (c *clientProtocol) Start() {
+	c.wg.Add(1)
+	go c.syncer()
+}
+
+func (c *clientProtocol) Stop() {
+	close(c.quitCh)
+	c.wg.Wait()
+}
+
+// peerCount returns the number of servers which finished the handshake.
+func (c *clientProtocol) peerCount() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return len(c.peers)
+}
+
+// sortedPeers returns the servers in descending order of total difficulty.
+func (c *clientProtocol) sortedPeers() []*peer {
+	c.lock.RLock()
+	peers := make([]*peer, 0, len(c.peers))
+	for _, p := range c.peers {
+		peers = append(peers, p)
+	}
+	c.lock.RUnlock()
+
+	sort.Slice(peers, func(i, j int) bool {
+		_, _, tdi := peers[i].Head()
+		_, _, tdj := peers[j].Head()
+		return tdi.Cmp(tdj) > 0
+	})
+
+	return peers
+}
+
+// retrieve calls the function with the servers in descending order of total difficulty
+// until it succeeds, and returns the error of the last server if all failed.
+func (c *clientProtocol) retrieve(fn func(p *peer) error) error {
+	err := errNoServer
+	for _, p := range c.sortedPeers() {
+		if err = fn(p); err == nil {
+			return nil
+		}
+
+		c.log.Debug("light retrieve from server %s failed, %s", p.peerStrID, err)
+	}
+
+	return err
+}
+
+// notifySync triggers the header synchronisation without blocking.
+func (c *clientProtocol) notifySync() {
+	select {
+	case c.syncCh <- struct{}{}:
+	default:
+	}
+}
+
+// syncer synchronises the headers from the best server periodically or when notified.
+func (c *clientProtocol) syncer() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.syncCh:
+		case <-ticker.C:
+		case <-c.quitCh:
+			return
+		}
+
+		if peers := c.sortedPeers(); len(peers) > 0 {
+			c.synchronise(peers[0])
+		}
+	}
+}
+
+// synchronise inserts the headers of the server into the header chain if the server has
+// larger total difficulty. The headers are fetched again from an earlier height if they
+// are not linked to the local header chain.
+func (c *clientProtocol) synchronise(p *peer) {
+	if !atomic.CompareAndSwapInt32(&c.syncing, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&c.syncing, 0)
+
+	current := c.chain.CurrentHeader()
+	localTD, err := c.bcStore.GetBlockTotalDifficulty(current.Hash())
+	if err != nil {
+		c.log.Error("light synchronise get total difficulty err. %s", err)
+		return
+	}
+
+	if _, _, td := p.Head(); localTD.Cmp(td) >= 0 {
+		return
+	}
+
+	for from := current.Height + 1; ; {
+		headers, err := p.RequestHeaders(from, MaxHeaderFetch)
+		if err != nil {
+			c.log.Warn("light synchronise request headers from %s failed, %s", p.peerStrID, err)
+			return
+		}
+
+		if len(headers) == 0 {
+			break
+		}
+
+		if _, err = c.chain.InsertHeaderChain(headers); err != nil {
+			if err == core.ErrBlockInvalidParentHash && headers[0].Height == from && from > 1 {
+				// the server is on a fork of the local header chain
+				if from > forkRollback {
+					from -= forkRollback
+				} else {
+					from = 1
+				}
+
+				continue
+			}
+
+			c.log.Warn("light synchronise insert headers from %s failed, %s", p.peerStrID, err)
+			p.Disconnect(DiscBadResponse)
+			return
+		}
+
+		if len(headers) < MaxHeaderFetch {
+			break
+		}
+
+		from = headers[len(headers)-1].Height + 1
+	}
+
+	head := c.chain.CurrentHeader()
+	c.log.Debug("light synchronise done, head %d %s", head.Height, head.Hash().ToHex())
+}
+
+func (c *clientProtocol) handleAddPeer(p2pPeer *p2p.Peer, rw p2p.MsgReadWriter) {
+	newPeer := newPeer(p2pPeer, rw)
+
+	current := c.chain.CurrentHeader()
+	td, err := c.bcStore.GetBlockTotalDifficulty(current.Hash())
+	if err != nil {
+		return
+	}
+
+	genesis, err := c.bcStore.GetBlockHash(0)
+	if err != nil {
+		return
+	}
+
+	if err = newPeer.handShake(c.networkID, td, current.Hash(), current.Height, genesis); err != nil {
+		newPeer.Disconnect(DiscHandShakeErr)
+		c.log.Error("light client handshake err. %s", err)
+		return
+	}
+
+	c.lock.Lock()
+	c.peers[newPeer.peerID] = newPeer
+	c.lock.Unlock()
+
+	c.notifySync()
+	go c.handleMsg(newPeer)
+}
+
+func (c *clientProtocol) handleDelPeer(p2pPeer *p2p.Peer) {
+}
+
+func (c *clientProtocol) handleMsg(peer *peer) {
+	for {
+		msg, err := peer.rw.ReadMsg()
+		if err != nil {
+			c.log.Error("get error when read msg from light server %s, %s", peer.peerStrID, err)
+			break
+		}
+
+		var reqID uint64
+		var response interface{}
+		switch msg.Code {
+		case announceMsgCode:
+			var announce announceData
+			if err = common.Deserialize(msg.Payload, &announce); err != nil || announce.TD == nil {
+				c.log.Warn("deserialize announce msg failed %v", err)
+				continue
+			}
+
+			peer.SetHead(announce.Hash, announce.Number, announce.TD)
+			c.notifySync()
+			continue
+
+		case headersMsgCode:
+			var headers headersResponse
+			err = common.Deserialize(msg.Payload, &headers)
+			reqID, response = headers.ReqID, &headers
+
+		case accountProofMsgCode:
+			var proof proofResponse
+			err = common.Deserialize(msg.Payload, &proof)
+			reqID, response = proof.ReqID, &proof
+
+		case txProofMsgCode:
+			var proof txProofResponse
+			err = common.Deserialize(msg.Payload, &proof)
+			reqID, response = proof.ReqID, &proof
+
+		case txStatusMsgCode:
+			var status txStatusResponse
+			err = common.Deserialize(msg.Payload, &status)
+			reqID, response = status.ReqID, &status
+
+		default:
+			c.log.Warn("unknown light msg code %d", msg.Code)
+			continue
+		}
+
+		if err != nil {
+			c.log.Warn("deserialize light msg %d failed %s", msg.Code, err)
+			continue
+		}
+
+		if !peer.deliver(reqID, response) {
+			c.log.Debug("drop unrequested light msg %d from %s", msg.Code, peer.peerStrID)
+		}
+	}
+
+	c.lock.Lock()
+	delete(c.peers, peer.peerID)
+	c.lock.Unlock()
+	peer.close()
+	c.log.Debug("light client peer %s quits", peer.peerStrID)
+}
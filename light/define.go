@@ -0,0 +1,122 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package light
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/types"
+)
+
+const (
+	// LightProtoName is the name of the light protocol served by full nodes to light nodes
+	LightProtoName = "light"
+
+	// LightVersion is the version number of the light protocol
+	LightVersion uint = 1
+
+	// SyncMode is the sync mode of the node config to run a light node
+	SyncMode = "light"
+
+	// BlockChainDir is the header chain data directory based on config.DataRoot
+	BlockChainDir = "/db/lightchain"
+
+	// MaxHeaderFetch is the max number of headers in a response
+	MaxHeaderFetch = 256
+
+	announceInterval = time.Second      // interval time of announcing the chain head to light peers
+	syncInterval     = 5 * time.Second  // interval time of synchronising headers with the best server
+	requestTimeout   = 10 * time.Second // timeout of a request to the server
+	forkRollback     = 64               // headers to step back when the fetched headers are not linked
+)
+
+var (
+	statusMsgCode          uint16 = 0
+	announceMsgCode        uint16 = 1
+	getHeadersMsgCode      uint16 = 2
+	headersMsgCode         uint16 = 3
+	getAccountProofMsgCode uint16 = 4
+	accountProofMsgCode    uint16 = 5
+	getTxProofMsgCode      uint16 = 6
+	txProofMsgCode         uint16 = 7
+	sendTxMsgCode          uint16 = 8
+	txStatusMsgCode        uint16 = 9
+	protocolMsgCodeLength  uint16 = 10
+)
+
+// statusData is exchanged by the server and the light node in handshake.
+type statusData struct {
+	ProtocolVersion uint32
+	NetworkID       uint64
+	TD              *big.Int
+	CurrentBlock    common.Hash
+	CurrentNumber   uint64
+	GenesisBlock    common.Hash
+}
+
+// announceData is sent by the server when its chain head changes.
+type announceData struct {
+	Hash   common.Hash
+	Number uint64
+	TD     *big.Int
+}
+
+// headersQuery requests at most Amount canonical headers from the height Number.
+type headersQuery struct {
+	ReqID  uint64
+	Number uint64
+	Amount uint64
+}
+
+// headersResponse is the response of headersQuery.
+type headersResponse struct {
+	ReqID   uint64
+	Headers []*types.BlockHeader
+}
+
+// accountProofQuery requests the proof of the account in the state of the block.
+type accountProofQuery struct {
+	ReqID     uint64
+	BlockHash common.Hash
+	Address   common.Address
+}
+
+// proofResponse is the response of accountProofQuery, the proof is the trie nodes from the
+// state root to the account. It is empty if the server does not have the state of the block.
+type proofResponse struct {
+	ReqID uint64
+	Proof [][]byte
+}
+
+// txProofQuery requests the inclusion proof of the transaction in the block.
+type txProofQuery struct {
+	ReqID     uint64
+	BlockHash common.Hash
+	TxHash    common.Hash
+}
+
+// txProofResponse is the response of txProofQuery, the proof is the merkle path of the
+// transaction of the index to the block tx hash. Txs is empty if the transaction is not found.
+type txProofResponse struct {
+	ReqID uint64
+	Txs   []*types.Transaction
+	Index uint64
+	Proof []common.Hash
+}
+
+// sendTxRequest relays the transaction to the tx pool of the server.
+type sendTxRequest struct {
+	ReqID uint64
+	Tx    *types.Transaction
+}
+
+// txStatusResponse is the response of sendTxRequest, the error is empty if the transaction is added.
+type txStatusResponse struct {
+	ReqID uint64
+	Error string
+}
@@ -0,0 +1,208 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package light
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core"
+	"github.com/seeleteam/go-seele/core/store"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/crypto"
+	"github.com/seeleteam/go-seele/database/leveldb"
+	"github.com/seeleteam/go-seele/log"
+	"github.com/seeleteam/go-seele/miner/pow"
+	"github.com/seeleteam/go-seele/p2p"
+	"github.com/seeleteam/go-seele/p2p/discovery"
+	"github.com/stretchr/testify/assert"
+)
+
+var testLog = log.GetLogger("light", common.PrintLog)
+
+type testServer struct {
+	*ServerProtocol
+	coinbase common.Address
+	key      *ecdsa.PrivateKey
+}
+
+func newTestServer(t *testing.T) *testServer {
+	chainDB, err := leveldb.NewMemDatabase()
+	assert.Equal(t, err, nil)
+	stateDB, err := leveldb.NewMemDatabase()
+	assert.Equal(t, err, nil)
+
+	bcStore := store.NewBlockchainDatabase(chainDB)
+	assert.Equal(t, core.DefaultGenesis(bcStore).Initialize(stateDB), nil)
+
+	chain, err := core.NewBlockchain(bcStore, stateDB)
+	assert.Equal(t, err, nil)
+
+	coinbase, key, err := crypto.GenerateKeyPair()
+	assert.Equal(t, err, nil)
+
+	txPool := core.NewTransactionPool(*core.DefaultTxPoolConfig(), chain)
+	server := NewServerProtocol(1, chain, txPool, testLog)
+	server.Start()
+
+	return &testServer{server, *coinbase, key}
+}
+
+// mineBlock writes a block with the reward transaction to the coinbase on the current block.
+func (s *testServer) mineBlock(t *testing.T) *types.Block {
+	parent, parentState := s.chain.CurrentBlock()
+	statedb := parentState.GetCopy()
+
+	reward := types.NewTransaction(common.Address{}, s.coinbase, big.NewInt(pow.MinerRewardAmount), 0)
+	reward.Signature = &crypto.Signature{}
+	statedb.GetOrNewStateObject(s.coinbase).AddAmount(reward.Data.Amount)
+
+	header := &types.BlockHeader{
+		PreviousBlockHash: parent.HeaderHash,
+		Creator:           s.coinbase,
+		StateHash:         statedb.Commit(nil),
+		Height:            parent.Header.Height + 1,
+		Difficulty:        big.NewInt(1),
+		CreateTimestamp:   new(big.Int).Add(parent.Header.CreateTimestamp, big.NewInt(1)),
+	}
+
+	block := types.NewBlock(header, []*types.Transaction{reward})
+	target := pow.GetMiningTarget(header.Difficulty)
+	for new(big.Int).SetBytes(block.HeaderHash.Bytes()).Cmp(target) > 0 {
+		block.Header.Nonce++
+		block.HeaderHash = block.Header.Hash()
+	}
+
+	assert.Equal(t, s.chain.WriteBlock(block), nil)
+
+	return block
+}
+
+func newTestLightService(t *testing.T) *LightService {
+	chainDB, err := leveldb.NewMemDatabase()
+	assert.Equal(t, err, nil)
+
+	s, err := NewLightServiceWithDB(1, testLog, chainDB)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, s.Start(nil), nil)
+
+	return s
+}
+
+// connect runs the peers of the server and the light node over an in-memory connection.
+func connect(server *ServerProtocol, client *LightService) func() {
+	c1, c2 := net.Pipe()
+	caps := []p2p.Cap{{Name: LightProtoName, Version: LightVersion}}
+
+	serverNode := discovery.NewNode(*crypto.MustGenerateRandomAddress(), net.IPv4(127, 0, 0, 1), 0)
+	clientNode := discovery.NewNode(*crypto.MustGenerateRandomAddress(), net.IPv4(127, 0, 0, 1), 0)
+	serverPeer := p2p.NewPeerWithConn(c1, []p2p.Protocol{server.Protocol}, caps, testLog, clientNode)
+	clientPeer := p2p.NewPeerWithConn(c2, client.Protocols(), caps, testLog, serverNode)
+
+	go serverPeer.Run()
+	go clientPeer.Run()
+
+	return func() {
+		c1.Close()
+		c2.Close()
+	}
+}
+
+func waitFor(condition func() bool) bool {
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func Test_Light_SyncHeaders(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+
+	var blocks []*types.Block
+	for i := 0; i < 10; i++ {
+		blocks = append(blocks, server.mineBlock(t))
+	}
+
+	client := newTestLightService(t)
+	defer client.Stop()
+
+	disconnect := connect(server.ServerProtocol, client)
+	defer disconnect()
+
+	// the headers are synchronised after handshake
+	assert.Equal(t, waitFor(func() bool { return client.chain.CurrentHeader().Hash() == blocks[9].HeaderHash }), true)
+	assert.Equal(t, client.PeerCount(), 1)
+	assert.Equal(t, server.PeerCount(), 1)
+
+	// the new headers are synchronised after announced
+	block := server.mineBlock(t)
+	assert.Equal(t, waitFor(func() bool { return client.chain.CurrentHeader().Hash() == block.HeaderHash }), true)
+}
+
+func Test_Light_Proofs(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+
+	block := server.mineBlock(t)
+
+	client := newTestLightService(t)
+	defer client.Stop()
+
+	disconnect := connect(server.ServerProtocol, client)
+	defer disconnect()
+
+	assert.Equal(t, waitFor(func() bool { return client.chain.CurrentHeader().Hash() == block.HeaderHash }), true)
+
+	// the account state is verified against the state hash of the head header
+	api := NewPublicSeeleAPI(client)
+	balance := new(big.Int)
+	assert.Equal(t, api.GetBalance(&server.coinbase, balance), nil)
+	assert.Equal(t, balance, big.NewInt(pow.MinerRewardAmount))
+
+	// the account not in the state
+	addr := *crypto.MustGenerateRandomAddress()
+	var nonce uint64
+	assert.Equal(t, api.GetAccountNonce(&addr, &nonce), nil)
+	assert.Equal(t, nonce, uint64(0))
+
+	// the transaction is verified against the tx hash of the header
+	reward := block.Transactions[0]
+	tx, err := client.GetTransaction(block.HeaderHash, reward.Hash)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, tx.Hash, reward.Hash)
+
+	_, err = client.GetTransaction(block.HeaderHash, common.StringToHash("tx"))
+	assert.Equal(t, err, errTxNotFound)
+
+	// the transaction is relayed to the tx pool of the server
+	tx = types.NewTransaction(server.coinbase, addr, big.NewInt(10), 0)
+	tx.Sign(server.key)
+	var added bool
+	assert.Equal(t, api.AddTx(tx, &added), nil)
+	assert.Equal(t, added, true)
+	assert.Equal(t, server.txPool.GetTransaction(tx.Hash) != nil, true)
+
+	// the error of the tx pool is returned
+	assert.Equal(t, api.AddTx(tx, &added) != nil, true)
+	assert.Equal(t, added, false)
+}
+
+func Test_Light_NoServer(t *testing.T) {
+	client := newTestLightService(t)
+	defer client.Stop()
+
+	addr := *crypto.MustGenerateRandomAddress()
+	_, err := client.GetAccount(addr)
+	assert.Equal(t, err, errNoServer)
+}
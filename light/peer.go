@@ -0,0 +1,264 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package light
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/p2p"
+)
+
+const (
+	// DiscHandShakeErr peer handshake error
+	DiscHandShakeErr = 100
+
+	// DiscBadResponse peer sends an invalid response or announcement
+	DiscBadResponse = 102
+)
+
+var (
+	errMsgNotMatch     = errors.New("Message not match")
+	errNetworkNotMatch = errors.New("NetworkID not match")
+	errRequestTimeout  = errors.New("Request timeout")
+	errPeerQuit        = errors.New("Peer quit")
+)
+
+// peer is a light protocol peer, which is a light node for the server, or a server for the light node.
+type peer struct {
+	*p2p.Peer
+	peerID    common.Address
+	peerStrID string
+	rw        p2p.MsgReadWriter
+
+	lock   sync.RWMutex
+	head   common.Hash
+	height uint64
+	td     *big.Int
+
+	// requests sent to the server and waiting for the responses
+	reqLock sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan interface{}
+	quitCh  chan struct{}
+}
+
+func newPeer(p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
+	return &peer{
+		Peer:      p,
+		peerID:    p.Node.ID,
+		peerStrID: fmt.Sprintf("%x", p.Node.ID[:8]),
+		rw:        rw,
+		td:        big.NewInt(0),
+		pending:   make(map[uint64]chan interface{}),
+		quitCh:    make(chan struct{}),
+	}
+}
+
+// Head retrieves a copy of the current head hash, height and total difficulty.
+func (p *peer) Head() (hash common.Hash, height uint64, td *big.Int) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.head, p.height, new(big.Int).Set(p.td)
+}
+
+// SetHead updates the head hash, height and total difficulty of the peer.
+func (p *peer) SetHead(hash common.Hash, height uint64, td *big.Int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.head, p.height = hash, height
+	p.td.Set(td)
+}
+
+// handShake exchanges the status between the server and the light node.
+func (p *peer) handShake(networkID uint64, td *big.Int, head common.Hash, height uint64, genesis common.Hash) error {
+	msg := &statusData{
+		ProtocolVersion: uint32(LightVersion),
+		NetworkID:       networkID,
+		TD:              td,
+		CurrentBlock:    head,
+		CurrentNumber:   height,
+		GenesisBlock:    genesis,
+	}
+
+	if err := p2p.SendMessage(p.rw, statusMsgCode, common.SerializePanic(msg)); err != nil {
+		return err
+	}
+
+	retMsg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+
+	if retMsg.Code != statusMsgCode {
+		return errMsgNotMatch
+	}
+
+	var retStatusMsg statusData
+	if err = common.Deserialize(retMsg.Payload, &retStatusMsg); err != nil {
+		return err
+	}
+
+	if retStatusMsg.NetworkID != networkID || retStatusMsg.GenesisBlock != genesis {
+		return errNetworkNotMatch
+	}
+
+	p.head = retStatusMsg.CurrentBlock
+	p.height = retStatusMsg.CurrentNumber
+	p.td = retStatusMsg.TD
+	return nil
+}
+
+// close cancels the pending requests after the peer quits.
+func (p *peer) close() {
+	close(p.quitCh)
+}
+
+func (p *peer) sendAnnounce(announce *announceData) error {
+	return p2p.SendMessage(p.rw, announceMsgCode, common.SerializePanic(announce))
+}
+
+func (p *peer) sendHeaders(reqID uint64, headers []*types.BlockHeader) error {
+	return p2p.SendMessage(p.rw, headersMsgCode, common.SerializePanic(&headersResponse{reqID, headers}))
+}
+
+func (p *peer) sendAccountProof(reqID uint64, proof [][]byte) error {
+	return p2p.SendMessage(p.rw, accountProofMsgCode, common.SerializePanic(&proofResponse{reqID, proof}))
+}
+
+func (p *peer) sendTxProof(response *txProofResponse) error {
+	return p2p.SendMessage(p.rw, txProofMsgCode, common.SerializePanic(response))
+}
+
+func (p *peer) sendTxStatus(reqID uint64, errMsg string) error {
+	return p2p.SendMessage(p.rw, txStatusMsgCode, common.SerializePanic(&txStatusResponse{reqID, errMsg}))
+}
+
+// request sends the request of the code to the server, and waits for the response. The
+// function newRequest creates the request payload with the request id.
+func (p *peer) request(code uint16, newRequest func(reqID uint64) interface{}) (interface{}, error) {
+	ch := make(chan interface{}, 1)
+
+	p.reqLock.Lock()
+	p.nextID++
+	reqID := p.nextID
+	p.pending[reqID] = ch
+	p.reqLock.Unlock()
+
+	defer func() {
+		p.reqLock.Lock()
+		delete(p.pending, reqID)
+		p.reqLock.Unlock()
+	}()
+
+	if err := p2p.SendMessage(p.rw, code, common.SerializePanic(newRequest(reqID))); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(requestTimeout)
+	defer timer.Stop()
+
+	select {
+	case response := <-ch:
+		return response, nil
+	case <-timer.C:
+		return nil, errRequestTimeout
+	case <-p.quitCh:
+		return nil, errPeerQuit
+	}
+}
+
+// deliver delivers the response to the pending request, and returns false if not requested.
+func (p *peer) deliver(reqID uint64, response interface{}) bool {
+	p.reqLock.Lock()
+	defer p.reqLock.Unlock()
+
+	ch, ok := p.pending[reqID]
+	if !ok {
+		return false
+	}
+
+	delete(p.pending, reqID)
+	ch <- response
+
+	return true
+}
+
+// RequestHeaders fetches at most amount canonical headers from the height.
+func (p *peer) RequestHeaders(from uint64, amount int) ([]*types.BlockHeader, error) {
+	response, err := p.request(getHeadersMsgCode, func(reqID uint64) interface{} {
+		return &headersQuery{reqID, from, uint64(amount)}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	headers, ok := response.(*headersResponse)
+	if !ok {
+		return nil, errMsgNotMatch
+	}
+
+	return headers.Headers, nil
+}
+
+// RequestAccountProof fetches the proof of the account in the state of the block.
+func (p *peer) RequestAccountProof(blockHash common.Hash, addr common.Address) ([][]byte, error) {
+	response, err := p.request(getAccountProofMsgCode, func(reqID uint64) interface{} {
+		return &accountProofQuery{reqID, blockHash, addr}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	proof, ok := response.(*proofResponse)
+	if !ok {
+		return nil, errMsgNotMatch
+	}
+
+	return proof.Proof, nil
+}
+
+// RequestTxProof fetches the inclusion proof of the transaction in the block.
+func (p *peer) RequestTxProof(blockHash common.Hash, txHash common.Hash) (*txProofResponse, error) {
+	response, err := p.request(getTxProofMsgCode, func(reqID uint64) interface{} {
+		return &txProofQuery{reqID, blockHash, txHash}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	proof, ok := response.(*txProofResponse)
+	if !ok {
+		return nil, errMsgNotMatch
+	}
+
+	return proof, nil
+}
+
+// SendTransaction relays the transaction to the server, and returns the error message of
+// adding it to the tx pool of the server, which is empty if added.
+func (p *peer) SendTransaction(tx *types.Transaction) (string, error) {
+	response, err := p.request(sendTxMsgCode, func(reqID uint64) interface{} {
+		return &sendTxRequest{reqID, tx}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	status, ok := response.(*txStatusResponse)
+	if !ok {
+		return "", errMsgNotMatch
+	}
+
+	return status.Error, nil
+}
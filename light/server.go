@@ -0,0 +1,291 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package light
+
+import (
+	"sync"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core"
+	"github.com/seeleteam/go-seele/core/state"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/log"
+	"github.com/seeleteam/go-seele/p2p"
+)
+
+// ServerProtocol serves the headers, account state proofs and transaction inclusion proofs
+// of the full node to light nodes, and relays the transactions from light nodes to the tx pool.
+// It runs alongside the seele protocol of the full node.
+type ServerProtocol struct {
+	p2p.Protocol
+
+	networkID uint64
+	chain     *core.Blockchain
+	txPool    *core.TransactionPool
+
+	lock     sync.RWMutex
+	peers    map[common.Address]*peer
+	lastHead common.Hash // the chain head last announced to peers
+
+	wg     sync.WaitGroup
+	quitCh chan struct{}
+	log    *log.SeeleLog
+}
+
+// NewServerProtocol creates the light server protocol of the chain and tx pool.
+func NewServerProtocol(networkID uint64, chain *core.Blockchain, txPool *core.TransactionPool, log *log.SeeleLog) *ServerProtocol {
+	s := &ServerProtocol{
+		Protocol: p2p.Protocol{
+			Name:    LightProtoName,
+			Version: LightVersion,
+			Length:  protocolMsgCodeLength,
+		},
+		networkID: networkID,
+		chain:     chain,
+		txPool:    txPool,
+		peers:     make(map[common.Address]*peer),
+		quitCh:    make(chan struct{}),
+		log:       log,
+	}
+
+	s.Protocol.AddPeer = s.handleAddPeer
+	s.Protocol.DeletePeer = s.handleDelPeer
+
+	return s
+}
+
+// Start starts to announce the chain head to the light peers.
+func (s *ServerProtocol) Start() {
+	s.wg.Add(1)
+	go s.announcer()
+}
+
+// Stop stops the protocol, called when the seele service quits.
+func (s *ServerProtocol) Stop() {
+	close(s.quitCh)
+	s.wg.Wait()
+}
+
+// PeerCount returns the number of light peers which finished the handshake.
+func (s *ServerProtocol) PeerCount() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return len(s.peers)
+}
+
+// head returns the announcement of the current chain head.
+func (s *ServerProtocol) head() (*announceData, error) {
+	block, _ := s.chain.CurrentBlock()
+	td, err := s.chain.GetStore().GetBlockTotalDifficulty(block.HeaderHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &announceData{block.HeaderHash, block.Header.Height, td}, nil
+}
+
+// announcer announces the chain head to the light peers when it changes.
+func (s *ServerProtocol) announcer() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(announceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			head, err := s.head()
+			if err != nil {
+				s.log.Error("light server get chain head err. %s", err)
+				continue
+			}
+
+			s.lock.Lock()
+			if head.Hash == s.lastHead {
+				s.lock.Unlock()
+				continue
+			}
+
+			s.lastHead = head.Hash
+			peers := make([]*peer, 0, len(s.peers))
+			for _, p := range s.peers {
+				peers = append(peers, p)
+			}
+			s.lock.Unlock()
+
+			for _, p := range peers {
+				if err = p.sendAnnounce(head); err != nil {
+					s.log.Warn("announce chain head to light peer %s failed %s", p.peerStrID, err)
+				}
+			}
+		case <-s.quitCh:
+			return
+		}
+	}
+}
+
+func (s *ServerProtocol) handleAddPeer(p2pPeer *p2p.Peer, rw p2p.MsgReadWriter) {
+	newPeer := newPeer(p2pPeer, rw)
+
+	head, err := s.head()
+	if err != nil {
+		return
+	}
+
+	genesis, err := s.chain.GetStore().GetBlockHash(0)
+	if err != nil {
+		return
+	}
+
+	if err = newPeer.handShake(s.networkID, head.TD, head.Hash, head.Number, genesis); err != nil {
+		newPeer.Disconnect(DiscHandShakeErr)
+		s.log.Error("light server handshake err. %s", err)
+		return
+	}
+
+	s.lock.Lock()
+	s.peers[newPeer.peerID] = newPeer
+	s.lock.Unlock()
+
+	go s.handleMsg(newPeer)
+}
+
+func (s *ServerProtocol) handleDelPeer(p2pPeer *p2p.Peer) {
+}
+
+func (s *ServerProtocol) handleMsg(peer *peer) {
+handler:
+	for {
+		msg, err := peer.rw.ReadMsg()
+		if err != nil {
+			s.log.Error("get error when read msg from light peer %s, %s", peer.peerStrID, err)
+			break
+		}
+
+		switch msg.Code {
+		case getHeadersMsgCode:
+			var query headersQuery
+			if err = common.Deserialize(msg.Payload, &query); err != nil {
+				s.log.Warn("deserialize get headers msg failed %s", err)
+				break handler
+			}
+
+			if err = peer.sendHeaders(query.ReqID, s.getHeaders(query.Number, query.Amount)); err != nil {
+				s.log.Warn("send headers msg failed %s", err)
+				break handler
+			}
+
+		case getAccountProofMsgCode:
+			var query accountProofQuery
+			if err = common.Deserialize(msg.Payload, &query); err != nil {
+				s.log.Warn("deserialize get account proof msg failed %s", err)
+				break handler
+			}
+
+			// the empty proof is sent if the state of the block is not available
+			var proof [][]byte
+			if header, err := s.chain.GetStore().GetBlockHeader(query.BlockHash); err == nil {
+				proof, _ = state.GetAccountProof(s.chain.AccountStateDB(), header.StateHash, query.Address)
+			}
+
+			if err = peer.sendAccountProof(query.ReqID, proof); err != nil {
+				s.log.Warn("send account proof msg failed %s", err)
+				break handler
+			}
+
+		case getTxProofMsgCode:
+			var query txProofQuery
+			if err = common.Deserialize(msg.Payload, &query); err != nil {
+				s.log.Warn("deserialize get tx proof msg failed %s", err)
+				break handler
+			}
+
+			if err = peer.sendTxProof(s.getTxProof(&query)); err != nil {
+				s.log.Warn("send tx proof msg failed %s", err)
+				break handler
+			}
+
+		case sendTxMsgCode:
+			var request sendTxRequest
+			if err = common.Deserialize(msg.Payload, &request); err != nil {
+				s.log.Warn("deserialize send tx msg failed %s", err)
+				break handler
+			}
+
+			errMsg := ""
+			if err = s.txPool.AddTransaction(request.Tx); err != nil {
+				errMsg = err.Error()
+			}
+
+			if err = peer.sendTxStatus(request.ReqID, errMsg); err != nil {
+				s.log.Warn("send tx status msg failed %s", err)
+				break handler
+			}
+
+		default:
+			s.log.Warn("unknown light msg code %d", msg.Code)
+		}
+	}
+
+	s.lock.Lock()
+	delete(s.peers, peer.peerID)
+	s.lock.Unlock()
+	peer.close()
+	s.log.Debug("light server peer %s quits", peer.peerStrID)
+}
+
+// getHeaders returns at most amount canonical headers from the height, the headers not
+// found are not returned.
+func (s *ServerProtocol) getHeaders(from uint64, amount uint64) []*types.BlockHeader {
+	if amount > MaxHeaderFetch {
+		amount = MaxHeaderFetch
+	}
+
+	var headers []*types.BlockHeader
+	for height := from; height < from+amount; height++ {
+		hash, err := s.chain.GetStore().GetBlockHash(height)
+		if err != nil {
+			break
+		}
+
+		header, err := s.chain.GetStore().GetBlockHeader(hash)
+		if err != nil {
+			break
+		}
+
+		headers = append(headers, header)
+	}
+
+	return headers
+}
+
+// getTxProof returns the transaction and its inclusion proof in the block, the transaction
+// is not returned if not found.
+func (s *ServerProtocol) getTxProof(query *txProofQuery) *txProofResponse {
+	response := &txProofResponse{ReqID: query.ReqID}
+
+	block, err := s.chain.GetStore().GetBlock(query.BlockHash)
+	if err != nil {
+		return response
+	}
+
+	for i, tx := range block.Transactions {
+		if !tx.Hash.Equal(query.TxHash) {
+			continue
+		}
+
+		if response.Proof, err = types.GetTxProof(block.Transactions, i); err == nil {
+			response.Txs = []*types.Transaction{tx}
+			response.Index = uint64(i)
+		}
+
+		break
+	}
+
+	return response
+}
@@ -0,0 +1,197 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package light
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core"
+	"github.com/seeleteam/go-seele/core/state"
+	"github.com/seeleteam/go-seele/core/store"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/database"
+	"github.com/seeleteam/go-seele/database/leveldb"
+	"github.com/seeleteam/go-seele/log"
+	"github.com/seeleteam/go-seele/p2p"
+	"github.com/seeleteam/go-seele/rpc"
+)
+
+var (
+	errTxNotFound = errors.New("Transaction not found in the block")
+	errInvalidTx  = errors.New("Invalid transaction")
+)
+
+// LightService implements the light node service, which keeps only the header chain, and
+// retrieves the account states and transactions with proofs from the full nodes.
+type LightService struct {
+	networkID uint64
+	p2pServer *p2p.Server
+	protocol  *clientProtocol
+	log       *log.SeeleLog
+
+	chain   *core.HeaderChain
+	bcStore store.BlockchainStore
+	chainDB database.Database // database used to store block headers.
+}
+
+// NewLightService creates the light service with the header chain database in the data directory.
+func NewLightService(dataDir string, networkID uint64, log *log.SeeleLog) (*LightService, error) {
+	chainDBPath := filepath.Join(dataDir, BlockChainDir)
+	log.Info("NewLightService header chain datadir is %s", chainDBPath)
+	chainDB, err := leveldb.NewLevelDB(chainDBPath)
+	if err != nil {
+		log.Error("NewLightService Create header chain err. %s", err)
+		return nil, err
+	}
+
+	return NewLightServiceWithDB(networkID, log, chainDB)
+}
+
+// NewLightServiceWithDB creates the light service with the opened header chain database, which
+// is closed when the service stops, or it fails to create.
+func NewLightServiceWithDB(networkID uint64, log *log.SeeleLog, chainDB database.Database) (*LightService, error) {
+	s := &LightService{
+		networkID: networkID,
+		log:       log,
+		chainDB:   chainDB,
+		bcStore:   store.NewBlockchainDatabase(chainDB),
+	}
+
+	// the genesis state is not kept, as the account states are retrieved from servers.
+	stateDB, err := leveldb.NewMemDatabase()
+	if err != nil {
+		chainDB.Close()
+		return nil, err
+	}
+	defer stateDB.Close()
+
+	if err = core.DefaultGenesis(s.bcStore).Initialize(stateDB); err != nil {
+		chainDB.Close()
+		log.Error("NewLightService genesis.Initialize err. %s", err)
+		return nil, err
+	}
+
+	if s.chain, err = core.NewHeaderChain(s.bcStore); err != nil {
+		chainDB.Close()
+		log.Error("NewLightService init header chain failed. %s", err)
+		return nil, err
+	}
+
+	s.protocol = newClientProtocol(networkID, s.chain, s.bcStore, log)
+
+	return s, nil
+}
+
+// HeaderChain returns the header chain of the light node.
+func (s *LightService) HeaderChain() *core.HeaderChain { return s.chain }
+
+// NetVersion returns the network id.
+func (s *LightService) NetVersion() uint64 { return s.networkID }
+
+// PeerCount returns the number of servers which finished the light protocol handshake.
+func (s *LightService) PeerCount() int { return s.protocol.peerCount() }
+
+// Protocols implements node.Service, returning the light protocol.
+func (s *LightService) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{s.protocol.Protocol}
+}
+
+// Start implements node.Service, starting to synchronise the header chain.
+func (s *LightService) Start(srvr *p2p.Server) error {
+	s.p2pServer = srvr
+	s.protocol.Start()
+	return nil
+}
+
+// Stop implements node.Service, terminating all internal goroutines.
+func (s *LightService) Stop() error {
+	s.protocol.Stop()
+	s.chainDB.Close()
+	return nil
+}
+
+// APIs implements node.Service, returning the collection of RPC services the light node offers.
+func (s *LightService) APIs() (apis []rpc.API) {
+	return append(apis, []rpc.API{
+		{
+			Namespace: "seele",
+			Version:   "1.0",
+			Service:   NewPublicSeeleAPI(s),
+			Public:    true,
+		},
+	}...)
+}
+
+// GetAccount retrieves the account in the state of the HEAD header from the servers,
+// and verifies it with the proof against the state hash of the header.
+func (s *LightService) GetAccount(addr common.Address) (*state.Account, error) {
+	header := s.chain.CurrentHeader()
+
+	var account *state.Account
+	err := s.protocol.retrieve(func(p *peer) error {
+		proof, err := p.RequestAccountProof(header.Hash(), addr)
+		if err != nil {
+			return err
+		}
+
+		account, err = state.VerifyAccountProof(header.StateHash, addr, proof)
+		return err
+	})
+
+	return account, err
+}
+
+// GetTransaction retrieves the transaction in the block from the servers, and verifies its
+// inclusion proof against the tx hash of the block header in the header chain.
+func (s *LightService) GetTransaction(blockHash common.Hash, txHash common.Hash) (*types.Transaction, error) {
+	header, err := s.bcStore.GetBlockHeader(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx *types.Transaction
+	err = s.protocol.retrieve(func(p *peer) error {
+		response, err := p.RequestTxProof(blockHash, txHash)
+		if err != nil {
+			return err
+		}
+
+		if len(response.Txs) != 1 || !response.Txs[0].Hash.Equal(txHash) {
+			return errTxNotFound
+		}
+
+		if !types.VerifyTxProof(header.TxHash, response.Txs[0], int(response.Index), response.Proof) {
+			return errInvalidTx
+		}
+
+		tx = response.Txs[0]
+		return nil
+	})
+
+	return tx, err
+}
+
+// SendTransaction relays the transaction to a server. The error of adding the transaction
+// to the tx pool of the server is returned, and the other servers are not tried.
+func (s *LightService) SendTransaction(tx *types.Transaction) error {
+	var poolErr string
+	err := s.protocol.retrieve(func(p *peer) (err error) {
+		poolErr, err = p.SendTransaction(tx)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if len(poolErr) > 0 {
+		return errors.New(poolErr)
+	}
+
+	return nil
+}
@@ -15,7 +15,8 @@ import (
 )
 
 var (
-	errNoContent = errors.New("Error: cannot construct tree with no content.")
+	errNoContent  = errors.New("Error: cannot construct tree with no content.")
+	errNoSuchLeaf = errors.New("Error: leaf index out of range.")
 )
 
 // Content represents the data that is stored and verified by the tree. A type that
@@ -199,6 +200,44 @@ func (m *MerkleTree) VerifyContent(expectedMerkleRoot []byte, content Content) b
 	return false
 }
 
+// GetProof returns the hashes of the sibling nodes on the path from the leaf of the index to
+// the root, which proves the content of the leaf is in the tree.
+func (m *MerkleTree) GetProof(index int) ([]common.Hash, error) {
+	if index < 0 || index >= len(m.Leafs) {
+		return nil, errNoSuchLeaf
+	}
+
+	var proof []common.Hash
+	for n := m.Leafs[index]; n.Parent != nil; n = n.Parent {
+		if n.Parent.Left == n {
+			proof = append(proof, n.Parent.Right.Hash)
+		} else {
+			proof = append(proof, n.Parent.Left.Hash)
+		}
+	}
+
+	return proof, nil
+}
+
+// VerifyProof returns true if the proof shows the content hash is the leaf of the index
+// in the tree of the expected merkle root.
+func VerifyProof(expectedMerkleRoot common.Hash, hash common.Hash, index int, proof []common.Hash) bool {
+	if index < 0 {
+		return false
+	}
+
+	for _, sibling := range proof {
+		if index%2 == 0 {
+			hash = crypto.HashBytes(append(hash.Bytes(), sibling.Bytes()...))
+		} else {
+			hash = crypto.HashBytes(append(sibling.Bytes(), hash.Bytes()...))
+		}
+		index = index / 2
+	}
+
+	return index == 0 && hash.Equal(expectedMerkleRoot)
+}
+
 // String returns a string representation of the tree. Only leaf nodes are included
 // in the output.
 func (m *MerkleTree) String() string {
@@ -181,6 +181,33 @@ func Test_MerkleTree_VerifyContent(t *testing.T) {
 	}
 }
 
+func Test_MerkleTree_Proof(t *testing.T) {
+	for i := 0; i < len(table); i++ {
+		tree, err := NewTree(table[i].contents)
+		if err != nil {
+			t.Fatalf("error: unexpected error:  %s", err)
+		}
+		for j, c := range table[i].contents {
+			proof, err := tree.GetProof(j)
+			if err != nil {
+				t.Fatalf("error: unexpected error:  %s", err)
+			}
+			if !VerifyProof(tree.MerkleRoot(), c.CalculateHash(), j, proof) {
+				t.Error("error: expected valid proof")
+			}
+			if VerifyProof(tree.MerkleRoot(), c.CalculateHash(), j+len(tree.Leafs), proof) {
+				t.Error("error: expected invalid proof of another index")
+			}
+			if VerifyProof(tree.MerkleRoot(), hash("NotInTestTable"), j, proof) {
+				t.Error("error: expected invalid proof of another content")
+			}
+		}
+		if _, err := tree.GetProof(len(tree.Leafs)); err == nil {
+			t.Error("error: expected error of the index out of range")
+		}
+	}
+}
+
 func Test_MerkleTree_String(t *testing.T) {
 	for i := 0; i < len(table); i++ {
 		tree, err := NewTree(table[i].contents)
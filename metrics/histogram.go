@@ -0,0 +1,111 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultTimeBuckets are the upper bounds in seconds of the timer buckets.
+	DefaultTimeBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+)
+
+// Histogram samples the observed values into the buckets by their upper bounds, and keeps
+// the sum and count of the values.
+type Histogram struct {
+	mutex   sync.Mutex
+	bounds  []float64 // the sorted upper bounds of the buckets
+	buckets []uint64  // the number of values in each bucket, not cumulative
+	sum     float64
+	count   uint64
+}
+
+// HistogramSnapshot is the state of a histogram at a moment.
+type HistogramSnapshot struct {
+	Bounds  []float64 // the upper bounds of the buckets
+	Buckets []uint64  // the cumulative number of values less than or equal to the bounds
+	Sum     float64
+	Count   uint64
+}
+
+// NewHistogram returns a histogram with the upper bounds of the buckets, which are sorted
+// if not yet. The values larger than all bounds are only counted in the sum and count.
+func NewHistogram(bounds []float64) *Histogram {
+	sorted := make([]float64, len(bounds))
+	copy(sorted, bounds)
+	sort.Float64s(sorted)
+
+	return &Histogram{
+		bounds:  sorted,
+		buckets: make([]uint64, len(sorted)),
+	}
+}
+
+// Observe records a value.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if i < len(h.buckets) {
+		h.buckets[i]++
+	}
+
+	h.sum += v
+	h.count++
+}
+
+// Snapshot returns the cumulative buckets, sum and count of the histogram.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s := HistogramSnapshot{
+		Bounds:  h.bounds,
+		Buckets: make([]uint64, len(h.buckets)),
+		Sum:     h.sum,
+		Count:   h.count,
+	}
+
+	var cumulative uint64
+	for i, n := range h.buckets {
+		cumulative += n
+		s.Buckets[i] = cumulative
+	}
+
+	return s
+}
+
+// Timer is a histogram of durations in seconds.
+type Timer struct {
+	*Histogram
+}
+
+// NewTimer returns a timer with the DefaultTimeBuckets.
+func NewTimer() *Timer {
+	return &Timer{NewHistogram(DefaultTimeBuckets)}
+}
+
+// Update records a duration.
+func (t *Timer) Update(d time.Duration) {
+	t.Observe(d.Seconds())
+}
+
+// UpdateSince records the duration since the start time.
+func (t *Timer) UpdateSince(start time.Time) {
+	t.Update(time.Since(start))
+}
+
+// Time records the duration of the function.
+func (t *Timer) Time(f func()) {
+	start := time.Now()
+	f()
+	t.UpdateSince(start)
+}
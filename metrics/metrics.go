@@ -0,0 +1,162 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+// Package metrics provides the counters, gauges, meters, histograms and timers of the node,
+// which are exported in the Prometheus text format.
+package metrics
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	meterTickInterval = 5 * time.Second // interval to update the moving average rates of meters
+)
+
+// Counter is a monotonically increasing count, e.g. the number of dropped txs.
+type Counter struct {
+	count int64
+}
+
+// Inc increases the counter by n, which must not be negative.
+func (c *Counter) Inc(n int64) {
+	atomic.AddInt64(&c.count, n)
+}
+
+// Count returns the current count.
+func (c *Counter) Count() int64 {
+	return atomic.LoadInt64(&c.count)
+}
+
+// Gauge is a value that goes up and down, e.g. the number of peers.
+type Gauge struct {
+	bits uint64 // float64 bits
+}
+
+// Update sets the value of the gauge.
+func (g *Gauge) Update(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Value returns the current value.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// funcMetric is a counter or gauge whose value is read by the function when exported,
+// e.g. the size of the tx pool.
+type funcMetric struct {
+	typ string // "counter" or "gauge"
+	fn  func() float64
+}
+
+// ewma is the exponentially weighted moving average of a rate per second.
+type ewma struct {
+	alpha float64
+	rate  float64
+	init  bool
+}
+
+func newEWMA(minutes float64) ewma {
+	return ewma{alpha: 1 - math.Exp(-meterTickInterval.Seconds()/60/minutes)}
+}
+
+// tick updates the rate with the instant rate of an interval, and decays it for the
+// following idle intervals.
+func (e *ewma) tick(instantRate float64, idleTicks int) {
+	if e.init {
+		e.rate += e.alpha * (instantRate - e.rate)
+	} else {
+		e.rate, e.init = instantRate, true
+	}
+
+	if idleTicks > 0 {
+		e.rate *= math.Pow(1-e.alpha, float64(idleTicks))
+	}
+}
+
+// Meter counts the events and measures their rates per second, which are the 1-minute,
+// 5-minute and 15-minute moving averages, and the mean rate since the meter is created.
+// The moving averages are updated lazily when the meter is marked or read.
+type Meter struct {
+	mutex     sync.Mutex
+	count     int64
+	uncounted int64 // the events in the current tick interval
+	start     time.Time
+	lastTick  time.Time
+	m1, m5    ewma
+	m15       ewma
+}
+
+// NewMeter returns a new meter.
+func NewMeter() *Meter {
+	now := time.Now()
+	return &Meter{
+		start:    now,
+		lastTick: now,
+		m1:       newEWMA(1),
+		m5:       newEWMA(5),
+		m15:      newEWMA(15),
+	}
+}
+
+// tickTo updates the moving averages for the tick intervals elapsed until now.
+func (m *Meter) tickTo(now time.Time) {
+	ticks := int(now.Sub(m.lastTick) / meterTickInterval)
+	if ticks <= 0 {
+		return
+	}
+
+	instantRate := float64(m.uncounted) / meterTickInterval.Seconds()
+	m.m1.tick(instantRate, ticks-1)
+	m.m5.tick(instantRate, ticks-1)
+	m.m15.tick(instantRate, ticks-1)
+
+	m.uncounted = 0
+	m.lastTick = m.lastTick.Add(time.Duration(ticks) * meterTickInterval)
+}
+
+// Mark records n events.
+func (m *Meter) Mark(n int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.tickTo(time.Now())
+	m.count += n
+	m.uncounted += n
+}
+
+// Count returns the number of the events.
+func (m *Meter) Count() int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.count
+}
+
+// Rates returns the 1-minute, 5-minute and 15-minute moving average rates per second.
+func (m *Meter) Rates() (float64, float64, float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.tickTo(time.Now())
+	return m.m1.rate, m.m5.rate, m.m15.rate
+}
+
+// RateMean returns the mean rate per second since the meter is created.
+func (m *Meter) RateMean() float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	elapsed := time.Since(m.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(m.count) / elapsed
+}
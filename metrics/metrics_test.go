@@ -0,0 +1,93 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func Test_Counter_Gauge(t *testing.T) {
+	var c Counter
+	c.Inc(2)
+	c.Inc(3)
+	assert.Equal(t, c.Count(), int64(5))
+
+	var g Gauge
+	assert.Equal(t, g.Value(), float64(0))
+	g.Update(-1.5)
+	assert.Equal(t, g.Value(), -1.5)
+}
+
+func Test_Meter(t *testing.T) {
+	m := NewMeter()
+	m.Mark(50)
+	assert.Equal(t, m.Count(), int64(50))
+
+	// no tick yet
+	m1, m5, m15 := m.Rates()
+	assert.Equal(t, m1, float64(0))
+
+	// the first tick sets the rates to the instant rate
+	m.lastTick = m.lastTick.Add(-meterTickInterval)
+	m1, m5, m15 = m.Rates()
+	assert.Equal(t, m1, float64(10))
+	assert.Equal(t, m5, float64(10))
+	assert.Equal(t, m15, float64(10))
+
+	// the rates decay in the idle intervals
+	m.lastTick = m.lastTick.Add(-12 * meterTickInterval)
+	m1, m5, m15 = m.Rates()
+	assert.Equal(t, math.Abs(m1-10*math.Exp(-1)) < 1e-9, true)
+	assert.Equal(t, m1 < m5 && m5 < m15, true)
+	assert.Equal(t, m.uncounted, int64(0))
+}
+
+func Test_Histogram_Timer(t *testing.T) {
+	h := NewHistogram([]float64{10, 1, 5})
+	for _, v := range []float64{0.5, 1, 3, 7, 20} {
+		h.Observe(v)
+	}
+
+	s := h.Snapshot()
+	assert.Equal(t, s.Bounds, []float64{1, 5, 10})
+	assert.Equal(t, s.Buckets, []uint64{2, 3, 4})
+	assert.Equal(t, s.Sum, 31.5)
+	assert.Equal(t, s.Count, uint64(5))
+
+	timer := NewTimer()
+	timer.Update(20 * time.Millisecond)
+	timer.Time(func() {})
+
+	s = timer.Snapshot()
+	assert.Equal(t, s.Count, uint64(2))
+	assert.Equal(t, s.Buckets[len(s.Buckets)-1], uint64(2))
+}
+
+func Test_Registry(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("test_total", "the test counter")
+	c.Inc(1)
+	assert.Equal(t, r.Counter("test_total", "").Count(), int64(1))
+
+	assert.Equal(t, Name("bytes", "code", "8", "dir", `in"out`), `bytes{code="8",dir="in\"out"}`)
+	assert.Equal(t, Name("bytes"), "bytes")
+
+	family, labels := splitName(`bytes{code="8"}`)
+	assert.Equal(t, family, "bytes")
+	assert.Equal(t, labels, `code="8"`)
+
+	// the function metric is replaced
+	r.RegisterGaugeFunc("size", "", func() float64 { return 1 })
+	r.RegisterGaugeFunc("size", "", func() float64 { return 2 })
+	assert.Equal(t, r.entries["size"].metric.(*funcMetric).fn(), float64(2))
+
+	r.Unregister("size")
+	assert.Equal(t, len(r.sortedEntries()), 1)
+}
@@ -0,0 +1,135 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// prometheusContentType is the content type of the Prometheus text format.
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// family is the metrics of a family in the Prometheus text format.
+type family struct {
+	name    string
+	help    string
+	typ     string
+	samples []string
+}
+
+// familyWriter collects the samples by the families in the order of the first samples.
+type familyWriter struct {
+	families []*family
+	index    map[string]*family
+}
+
+func (w *familyWriter) add(name, help, typ, labels string, value float64) {
+	f := w.index[name]
+	if f == nil {
+		f = &family{name: name, help: help, typ: typ}
+		w.index[name] = f
+		w.families = append(w.families, f)
+	}
+
+	f.samples = append(f.samples, sampleLine(name, labels, value))
+}
+
+// sampleLine returns the sample line of the metric with the labels.
+func sampleLine(name, labels string, value float64) string {
+	if len(labels) > 0 {
+		name += "{" + labels + "}"
+	}
+
+	return name + " " + strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// joinLabels returns the labels with the extra label pair.
+func joinLabels(labels string, key string, value string) string {
+	pair := key + `="` + value + `"`
+	if len(labels) == 0 {
+		return pair
+	}
+
+	return labels + "," + pair
+}
+
+// WritePrometheus writes the metrics of the registry in the Prometheus text format. The
+// meters are written as counters, with their rates as the gauges of the family "<name>_rate"
+// labeled by the window. The timers are written as histograms in seconds.
+func WritePrometheus(w io.Writer, r *Registry) error {
+	fw := &familyWriter{index: make(map[string]*family)}
+
+	for _, e := range r.sortedEntries() {
+		switch m := e.metric.(type) {
+		case *Counter:
+			fw.add(e.family, e.help, "counter", e.labels, float64(m.Count()))
+		case *Gauge:
+			fw.add(e.family, e.help, "gauge", e.labels, m.Value())
+		case *funcMetric:
+			fw.add(e.family, e.help, m.typ, e.labels, m.fn())
+		case *Meter:
+			fw.add(e.family, e.help, "counter", e.labels, float64(m.Count()))
+
+			m1, m5, m15 := m.Rates()
+			rateHelp := "the rates per second of " + e.family
+			fw.add(e.family+"_rate", rateHelp, "gauge", joinLabels(e.labels, "window", "1m"), m1)
+			fw.add(e.family+"_rate", rateHelp, "gauge", joinLabels(e.labels, "window", "5m"), m5)
+			fw.add(e.family+"_rate", rateHelp, "gauge", joinLabels(e.labels, "window", "15m"), m15)
+			fw.add(e.family+"_rate", rateHelp, "gauge", joinLabels(e.labels, "window", "mean"), m.RateMean())
+		case *Histogram:
+			writeHistogram(fw, e, m.Snapshot())
+		case *Timer:
+			writeHistogram(fw, e, m.Snapshot())
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, f := range fw.families {
+		if len(f.help) > 0 {
+			bw.WriteString("# HELP " + f.name + " " + f.help + "\n")
+		}
+
+		bw.WriteString("# TYPE " + f.name + " " + f.typ + "\n")
+		for _, sample := range f.samples {
+			bw.WriteString(sample + "\n")
+		}
+	}
+
+	return bw.Flush()
+}
+
+// writeHistogram adds the cumulative buckets, sum and count of the histogram.
+func writeHistogram(fw *familyWriter, e *entry, s HistogramSnapshot) {
+	f := fw.index[e.family]
+	if f == nil {
+		f = &family{name: e.family, help: e.help, typ: "histogram"}
+		fw.index[e.family] = f
+		fw.families = append(fw.families, f)
+	}
+
+	for i, bound := range s.Bounds {
+		le := strconv.FormatFloat(bound, 'g', -1, 64)
+		f.samples = append(f.samples, sampleLine(e.family+"_bucket", joinLabels(e.labels, "le", le), float64(s.Buckets[i])))
+	}
+
+	f.samples = append(f.samples,
+		sampleLine(e.family+"_bucket", joinLabels(e.labels, "le", "+Inf"), float64(s.Count)),
+		sampleLine(e.family+"_sum", e.labels, s.Sum),
+		sampleLine(e.family+"_count", e.labels, float64(s.Count)),
+	)
+}
+
+// Handler returns the http handler which responds the metrics of the registry in the
+// Prometheus text format.
+func Handler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", prometheusContentType)
+		WritePrometheus(w, r)
+	})
+}
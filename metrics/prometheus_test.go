@@ -0,0 +1,89 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func Test_WritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	r.Counter(Name("p2p_in_bytes_total", "protocol", "seele", "code", "9"), "the received bytes").Inc(20)
+	r.Counter(Name("p2p_in_bytes_total", "protocol", "seele", "code", "10"), "the received bytes").Inc(10)
+	r.Gauge("chain_head_height", "the height of the HEAD block").Update(7)
+	r.RegisterGaugeFunc("txpool_pending", "", func() float64 { return 3 })
+	r.Histogram("chain_reorg_depth", "the depth of reorgs", []float64{1, 4}).Observe(2)
+	r.Timer("chain_block_import_seconds", "the time to import a block").Update(2 * time.Second)
+	r.Meter("downloader_blocks", "the downloaded blocks").Mark(5)
+
+	var buf bytes.Buffer
+	assert.Equal(t, WritePrometheus(&buf, r), nil)
+
+	expected := `# HELP chain_block_import_seconds the time to import a block
+# TYPE chain_block_import_seconds histogram
+chain_block_import_seconds_bucket{le="0.005"} 0
+chain_block_import_seconds_bucket{le="0.01"} 0
+chain_block_import_seconds_bucket{le="0.025"} 0
+chain_block_import_seconds_bucket{le="0.05"} 0
+chain_block_import_seconds_bucket{le="0.1"} 0
+chain_block_import_seconds_bucket{le="0.25"} 0
+chain_block_import_seconds_bucket{le="0.5"} 0
+chain_block_import_seconds_bucket{le="1"} 0
+chain_block_import_seconds_bucket{le="2.5"} 1
+chain_block_import_seconds_bucket{le="5"} 1
+chain_block_import_seconds_bucket{le="10"} 1
+chain_block_import_seconds_bucket{le="30"} 1
+chain_block_import_seconds_bucket{le="60"} 1
+chain_block_import_seconds_bucket{le="+Inf"} 1
+chain_block_import_seconds_sum 2
+chain_block_import_seconds_count 1
+# HELP chain_head_height the height of the HEAD block
+# TYPE chain_head_height gauge
+chain_head_height 7
+# HELP chain_reorg_depth the depth of reorgs
+# TYPE chain_reorg_depth histogram
+chain_reorg_depth_bucket{le="1"} 0
+chain_reorg_depth_bucket{le="4"} 1
+chain_reorg_depth_bucket{le="+Inf"} 1
+chain_reorg_depth_sum 2
+chain_reorg_depth_count 1
+# HELP downloader_blocks the downloaded blocks
+# TYPE downloader_blocks counter
+downloader_blocks 5
+# HELP downloader_blocks_rate the rates per second of downloader_blocks
+# TYPE downloader_blocks_rate gauge
+`
+	output := buf.String()
+	assert.Equal(t, strings.HasPrefix(output, expected), true)
+
+	// the samples of a family are grouped, and the family without help has no help line
+	assert.Equal(t, strings.HasSuffix(output, `# HELP p2p_in_bytes_total the received bytes
+# TYPE p2p_in_bytes_total counter
+p2p_in_bytes_total{protocol="seele",code="10"} 10
+p2p_in_bytes_total{protocol="seele",code="9"} 20
+# TYPE txpool_pending gauge
+txpool_pending 3
+`), true)
+}
+
+func Test_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("test_total", "").Inc(1)
+
+	w := httptest.NewRecorder()
+	Handler(r).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get("Content-Type"), prometheusContentType)
+	assert.Equal(t, w.Body.String(), "# TYPE test_total counter\ntest_total 1\n")
+}
@@ -0,0 +1,199 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultRegistry is the registry of the metrics of the node.
+var DefaultRegistry = NewRegistry()
+
+// labelEscaper escapes the label values in the Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Name returns the metric name of the family with the label pairs, e.g.
+// Name("p2p_in_bytes_total", "protocol", "seele", "code", "8") returns
+// `p2p_in_bytes_total{protocol="seele",code="8"}`.
+func Name(family string, labels ...string) string {
+	if len(labels) < 2 {
+		return family
+	}
+
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+labelEscaper.Replace(labels[i+1])+`"`)
+	}
+
+	return family + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// splitName returns the family and the labels without braces of the metric name.
+func splitName(name string) (string, string) {
+	if i := strings.IndexByte(name, '{'); i >= 0 && strings.HasSuffix(name, "}") {
+		return name[:i], name[i+1 : len(name)-1]
+	}
+
+	return name, ""
+}
+
+// entry is a registered metric.
+type entry struct {
+	name   string
+	family string
+	labels string
+	help   string
+	metric interface{}
+}
+
+// Registry keeps the metrics by their names. The metrics of a family share the help of
+// the first registered one.
+type Registry struct {
+	mutex   sync.RWMutex
+	entries map[string]*entry
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[string]*entry)}
+}
+
+// getOrRegister returns the metric of the name, or registers the one created by the function.
+func (r *Registry) getOrRegister(name string, help string, create func() interface{}) interface{} {
+	r.mutex.RLock()
+	e := r.entries[name]
+	r.mutex.RUnlock()
+
+	if e != nil {
+		return e.metric
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if e = r.entries[name]; e == nil {
+		family, labels := splitName(name)
+		e = &entry{name, family, labels, help, create()}
+		r.entries[name] = e
+	}
+
+	return e.metric
+}
+
+// Counter returns the counter of the name, which is registered if not yet.
+func (r *Registry) Counter(name string, help string) *Counter {
+	return r.getOrRegister(name, help, func() interface{} { return new(Counter) }).(*Counter)
+}
+
+// Gauge returns the gauge of the name, which is registered if not yet.
+func (r *Registry) Gauge(name string, help string) *Gauge {
+	return r.getOrRegister(name, help, func() interface{} { return new(Gauge) }).(*Gauge)
+}
+
+// Meter returns the meter of the name, which is registered if not yet.
+func (r *Registry) Meter(name string, help string) *Meter {
+	return r.getOrRegister(name, help, func() interface{} { return NewMeter() }).(*Meter)
+}
+
+// Histogram returns the histogram of the name, which is registered with the bucket bounds
+// if not yet.
+func (r *Registry) Histogram(name string, help string, bounds []float64) *Histogram {
+	return r.getOrRegister(name, help, func() interface{} { return NewHistogram(bounds) }).(*Histogram)
+}
+
+// Timer returns the timer of the name, which is registered if not yet.
+func (r *Registry) Timer(name string, help string) *Timer {
+	return r.getOrRegister(name, help, func() interface{} { return NewTimer() }).(*Timer)
+}
+
+// registerFunc registers the function metric, which replaces the registered one, so that
+// the value is read from the latest instance, e.g. the tx pool of the restarted service.
+func (r *Registry) registerFunc(name string, help string, typ string, fn func() float64) {
+	family, labels := splitName(name)
+
+	r.mutex.Lock()
+	r.entries[name] = &entry{name, family, labels, help, &funcMetric{typ, fn}}
+	r.mutex.Unlock()
+}
+
+// RegisterCounterFunc registers the counter whose value is read by the function.
+func (r *Registry) RegisterCounterFunc(name string, help string, fn func() float64) {
+	r.registerFunc(name, help, "counter", fn)
+}
+
+// RegisterGaugeFunc registers the gauge whose value is read by the function.
+func (r *Registry) RegisterGaugeFunc(name string, help string, fn func() float64) {
+	r.registerFunc(name, help, "gauge", fn)
+}
+
+// Unregister removes the metric of the name.
+func (r *Registry) Unregister(name string) {
+	r.mutex.Lock()
+	delete(r.entries, name)
+	r.mutex.Unlock()
+}
+
+// sortedEntries returns the registered metrics sorted by the names.
+func (r *Registry) sortedEntries() []*entry {
+	r.mutex.RLock()
+	entries := make([]*entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, e)
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].family != entries[j].family {
+			return entries[i].family < entries[j].family
+		}
+
+		return entries[i].labels < entries[j].labels
+	})
+
+	return entries
+}
+
+// GetOrRegisterCounter returns the counter of the name in the DefaultRegistry.
+func GetOrRegisterCounter(name string, help string) *Counter {
+	return DefaultRegistry.Counter(name, help)
+}
+
+// GetOrRegisterGauge returns the gauge of the name in the DefaultRegistry.
+func GetOrRegisterGauge(name string, help string) *Gauge {
+	return DefaultRegistry.Gauge(name, help)
+}
+
+// GetOrRegisterMeter returns the meter of the name in the DefaultRegistry.
+func GetOrRegisterMeter(name string, help string) *Meter {
+	return DefaultRegistry.Meter(name, help)
+}
+
+// GetOrRegisterHistogram returns the histogram of the name in the DefaultRegistry.
+func GetOrRegisterHistogram(name string, help string, bounds []float64) *Histogram {
+	return DefaultRegistry.Histogram(name, help, bounds)
+}
+
+// GetOrRegisterTimer returns the timer of the name in the DefaultRegistry.
+func GetOrRegisterTimer(name string, help string) *Timer {
+	return DefaultRegistry.Timer(name, help)
+}
+
+// RegisterCounterFunc registers the counter function in the DefaultRegistry.
+func RegisterCounterFunc(name string, help string, fn func() float64) {
+	DefaultRegistry.RegisterCounterFunc(name, help, fn)
+}
+
+// RegisterGaugeFunc registers the gauge function in the DefaultRegistry.
+func RegisterGaugeFunc(name string, help string, fn func() float64) {
+	DefaultRegistry.RegisterGaugeFunc(name, help, fn)
+}
+
+// Unregister removes the metric of the name from the DefaultRegistry.
+func Unregister(name string) {
+	DefaultRegistry.Unregister(name)
+}
@@ -15,9 +15,17 @@ import (
 	"github.com/seeleteam/go-seele/core/types"
 	"github.com/seeleteam/go-seele/event"
 	"github.com/seeleteam/go-seele/log"
+	"github.com/seeleteam/go-seele/metrics"
 	"github.com/seeleteam/go-seele/seele"
 )
 
+var (
+	minedBlockCounter  = metrics.GetOrRegisterCounter("miner_blocks_mined_total", "the blocks mined and written to the chain")
+	saveFailureCounter = metrics.GetOrRegisterCounter("miner_save_failures_total", "the mined blocks failed to write to the chain")
+	blockMiningTimer   = metrics.GetOrRegisterTimer("miner_block_seconds", "the time from preparing a block to writing it to the chain")
+	minedBlockTxsGauge = metrics.GetOrRegisterGauge("miner_block_txs", "the number of the txs in the last mined block, including the reward tx")
+)
+
 // Miner defines base elements of the miner
 type Miner struct {
 	coinbase common.Address
@@ -129,10 +137,15 @@ out:
 
 			ret := miner.saveBlock(result)
 			if ret != nil {
+				saveFailureCounter.Inc(1)
 				miner.log.Error("saving the block failed, for %s", ret.Error())
 				continue
 			}
 
+			minedBlockCounter.Inc(1)
+			blockMiningTimer.UpdateSince(result.task.createdAt)
+			minedBlockTxsGauge.Update(float64(len(result.block.Transactions)))
+
 			miner.log.Info("found a new mined block and notify p2p")
 			event.BlockMinedEventManager.Fire(result.block) // notify p2p to broadcast the block
 			atomic.StoreInt32(&miner.mining, 0)
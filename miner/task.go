@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core"
 	"github.com/seeleteam/go-seele/core/state"
 	"github.com/seeleteam/go-seele/core/types"
 	"github.com/seeleteam/go-seele/crypto"
@@ -20,8 +21,9 @@ import (
 
 // Task is a mining work for engine, containing block header, transactions, and transaction receipts.
 type Task struct {
-	header *types.BlockHeader
-	txs    []*types.Transaction
+	header   *types.BlockHeader
+	txs      []*types.Transaction
+	receipts []*types.Receipt
 
 	createdAt time.Time
 }
@@ -35,6 +37,7 @@ func (task *Task) applyTransactions(seele *seele.SeeleService, statedb *state.St
 	stateObj := statedb.GetOrNewStateObject(seele.Coinbase)
 	stateObj.AddAmount(rewardValue)
 	task.txs = append(task.txs, reward)
+	task.receipts = append(task.receipts, core.NewReceipt(statedb, reward, 0, 0))
 
 	for _, tx := range txs {
 		seele.TxPool().RemoveTransaction(tx.Hash)
@@ -45,6 +48,8 @@ func (task *Task) applyTransactions(seele *seele.SeeleService, statedb *state.St
 			continue
 		}
 
+		logIndex := len(statedb.GetLogs())
+
 		fromStateObj := statedb.GetOrNewStateObject(tx.Data.From)
 		fromStateObj.SubAmount(tx.Data.Amount)
 		fromStateObj.SetNonce(tx.Data.AccountNonce + 1)
@@ -52,6 +57,7 @@ func (task *Task) applyTransactions(seele *seele.SeeleService, statedb *state.St
 		toStateObj := statedb.GetOrNewStateObject(*tx.Data.To)
 		toStateObj.AddAmount(tx.Data.Amount)
 
+		task.receipts = append(task.receipts, core.NewReceipt(statedb, tx, len(task.txs), logIndex))
 		task.txs = append(task.txs, tx)
 	}
 
@@ -59,6 +65,7 @@ func (task *Task) applyTransactions(seele *seele.SeeleService, statedb *state.St
 
 	root := statedb.Commit(nil)
 	task.header.StateHash = root
+	task.header.LogsBloom = types.ReceiptsBloom(task.receipts)
 
 	return nil
 }
@@ -8,6 +8,8 @@ package monitor
 import (
 	"errors"
 	"runtime"
+
+	"github.com/seeleteam/go-seele/p2p"
 )
 
 // error infos
@@ -61,13 +63,29 @@ func (api *PublicMonitorAPI) NodeStats(arg int, result *NodeStats) error {
 	}
 
 	mining := api.s.seeleNode.Miner().IsMining()
+	traffic := api.s.p2pServer.Traffic()
 
 	*result = NodeStats{
-		Active:  true,
-		Syncing: true,
-		Mining:  mining,
-		Peers:   api.s.p2pServer.PeerCount(),
+		Active:   true,
+		Syncing:  true,
+		Mining:   mining,
+		Peers:    api.s.p2pServer.PeerCount(),
+		InBytes:  traffic.InBytes,
+		OutBytes: traffic.OutBytes,
+		InRate:   traffic.InRate,
+		OutRate:  traffic.OutRate,
 	}
 
 	return nil
 }
+
+// PeersTraffic return the traffic statistics of the connected peers.
+func (api *PublicMonitorAPI) PeersTraffic(arg int, result *[]p2p.PeerTraffic) error {
+	if api.s.p2pServer == nil {
+		return ErrP2PServerInfoFailed
+	}
+
+	*result = api.s.p2pServer.PeersTraffic()
+
+	return nil
+}
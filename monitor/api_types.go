@@ -22,8 +22,12 @@ type NodeInfo struct {
 
 // NodeStats is the information about the local node.
 type NodeStats struct {
-	Active  bool `json:"active"`
-	Syncing bool `json:"syncing"`
-	Mining  bool `json:"mining"`
-	Peers   int  `json:"peers"`
+	Active   bool   `json:"active"`
+	Syncing  bool   `json:"syncing"`
+	Mining   bool   `json:"mining"`
+	Peers    int    `json:"peers"`
+	InBytes  uint64 `json:"inBytes"`
+	OutBytes uint64 `json:"outBytes"`
+	InRate   uint   `json:"inRate"`
+	OutRate  uint   `json:"outRate"`
 }
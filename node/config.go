@@ -6,10 +6,16 @@
 package node
 
 import (
+	"path/filepath"
+
 	"github.com/seeleteam/go-seele/p2p"
+	"github.com/seeleteam/go-seele/rpc"
 	"github.com/seeleteam/go-seele/seele"
 )
 
+// DefaultIPCName is the name of the unix socket of IPC rpc service in the data folder.
+const DefaultIPCName = "seele.ipc"
+
 // Config holds Node options.
 type Config struct {
 	// The name of the node
@@ -38,6 +44,58 @@ type Config struct {
 	// HTTPHostFilter is the whitelist of hostnames which are allowed on incoming requests.
 	HTTPWhiteHost []string
 
+	// The WSAddr is the address of WebSocket rpc service, which is disabled if empty.
+	// The origins of WebSocket requests are checked with HTTPCors.
+	WSAddr string
+
+	// The IPCPath is the path of the unix socket of IPC rpc service, which exposes all
+	// APIs including the non-public ones. It is relative to DataDir if not absolute,
+	// and DefaultIPCName is used if empty.
+	IPCPath string
+
+	// DisableIPC disables the IPC rpc service.
+	DisableIPC bool
+
+	// The RPCAuth, HTTPAuth and WSAuth are the authentication configs of the TCP, HTTP and
+	// WebSocket rpc services, which accept any client if disabled.
+	RPCAuth  rpc.AuthConfig
+	HTTPAuth rpc.AuthConfig
+	WSAuth   rpc.AuthConfig
+
+	// The RPCModules, HTTPModules and WSModules are the allow-lists of the API namespaces of
+	// the TCP, HTTP and WebSocket rpc services, which expose all public APIs if empty. The
+	// non-public APIs are exposed only if listed and the service requires authentication.
+	// The subscription topics are exposed if their namespaces are allowed.
+	RPCModules  []string
+	HTTPModules []string
+	WSModules   []string
+
+	// The RPCLimits, HTTPLimits and WSLimits are the rate limits, size limits and method
+	// timeouts of the TCP, HTTP and WebSocket rpc services. The rates are rpc.DefaultIPRate
+	// and rpc.DefaultKeyRate if 0 and unlimited if negative, the other limits are unlimited
+	// if 0.
+	RPCLimits  rpc.LimitConfig
+	HTTPLimits rpc.LimitConfig
+	WSLimits   rpc.LimitConfig
+
+	// The MetricsAddr is the HTTP address to export the metrics in the Prometheus text format
+	// on the path /metrics, which is disabled if empty.
+	MetricsAddr string
+
 	// The SeeleConfig is the configuration to create seele service.
 	SeeleConfig seele.Config
 }
+
+// IPCEndpoint returns the path of the unix socket of IPC rpc service.
+func (c *Config) IPCEndpoint() string {
+	path := c.IPCPath
+	if len(path) == 0 {
+		path = DefaultIPCName
+	}
+
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(c.DataDir, path)
+}
@@ -10,12 +10,14 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	netrpc "net/rpc"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sync"
 
 	"github.com/seeleteam/go-seele/common"
 	"github.com/seeleteam/go-seele/log"
+	"github.com/seeleteam/go-seele/metrics"
 	"github.com/seeleteam/go-seele/miner"
 	"github.com/seeleteam/go-seele/p2p"
 	"github.com/seeleteam/go-seele/rpc"
@@ -51,7 +53,12 @@ type Node struct {
 
 	services []Service
 
-	rpcAPIs []rpc.API
+	rpcAPIs     []rpc.API
+	rpcServers  map[string]*rpc.Server // the network rpc servers by the endpoint name
+	ipcListener net.Listener           // nil if the IPC rpc service is disabled
+	wsListener  net.Listener           // nil if the WebSocket rpc service is disabled
+
+	metricsListener net.Listener // nil if the metrics are not exported
 
 	log  *log.SeeleLog
 	lock sync.RWMutex
@@ -135,10 +142,10 @@ func (n *Node) Start() error {
 			for j := 0; j < i; j++ {
 				n.services[j].Stop()
 			}
-			
+
 			// stop the p2p server
 			running.Stop()
-			
+
 			return err
 		}
 	}
@@ -148,49 +155,206 @@ func (n *Node) Start() error {
 		for _, service := range n.services {
 			service.Stop()
 		}
-		
+
 		// stop the p2p server
 		running.Stop()
-		
+
 		return err
 	}
 
+	// Start the metrics exporter
+	if len(n.config.MetricsAddr) > 0 {
+		if err := n.startMetrics(n.config.MetricsAddr); err != nil {
+			for _, service := range n.services {
+				service.Stop()
+			}
+
+			// stop the p2p server
+			running.Stop()
+
+			return err
+		}
+	}
+
 	n.server = running
 
 	return nil
 }
 
-// startRPC starts all RPC
+// startRPC starts all RPC. The network endpoints expose the public APIs in their allow-lists,
+// and the listed non-public APIs if they require authentication. All APIs are exposed on the
+// IPC endpoint.
 func (n *Node) startRPC(services []Service, conf *Config) error {
 	apis := []rpc.API{}
+	topics := []rpc.Topic{}
 	for _, service := range services {
 		apis = append(apis, service.APIs()...)
+
+		if topicService, ok := service.(TopicService); ok {
+			topics = append(topics, topicService.Topics()...)
+		}
 	}
 
-	if err := n.startJSONRPC(apis); err != nil {
+	n.rpcServers = make(map[string]*rpc.Server)
+	if err := n.startJSONRPC(endpointAPIs(apis, conf.RPCModules, conf.RPCAuth.Enabled()), endpointTopics(topics, conf.RPCModules)); err != nil {
 		n.log.Error("startProc err", err)
 		return err
 	}
 
-	if err := n.startHTTPRPC(apis, conf.HTTPWhiteHost, conf.HTTPCors); err != nil {
+	if err := n.startHTTPRPC(endpointAPIs(apis, conf.HTTPModules, conf.HTTPAuth.Enabled()), conf.HTTPWhiteHost, conf.HTTPCors); err != nil {
 		n.log.Error("start http rpc err", err)
 		return err
 	}
 
+	if len(conf.WSAddr) > 0 {
+		if err := n.startWSRPC(endpointAPIs(apis, conf.WSModules, conf.WSAuth.Enabled()), endpointTopics(topics, conf.WSModules), conf.HTTPCors); err != nil {
+			n.log.Error("start websocket rpc err. %s", err)
+			return err
+		}
+	}
+
+	if !conf.DisableIPC {
+		if err := n.startIPC(apis, topics, conf.IPCEndpoint()); err != nil {
+			n.log.Error("start ipc rpc err. %s", err)
+			return err
+		}
+	}
+
+	n.registerRPCMetrics()
+
 	return nil
 }
 
-// startJSONRPC starts JSONRPC server
-func (n *Node) startJSONRPC(apis []rpc.API) error {
-	handler := rpc.NewServer()
+// registerRPCMetrics registers the counters of the requests rejected by the limits of the
+// network rpc servers, which are labeled by the endpoint name and the reason.
+func (n *Node) registerRPCMetrics() {
+	const help = "the rpc requests rejected by the limits"
+
+	for name, server := range n.rpcServers {
+		server := server
+		reasons := map[string]func() uint64{
+			"rate_limited":    func() uint64 { return server.Rejections().RateLimited },
+			"body_too_large":  func() uint64 { return server.Rejections().BodyTooLarge },
+			"batch_too_large": func() uint64 { return server.Rejections().BatchTooLarge },
+			"timeout":         func() uint64 { return server.Rejections().Timeout },
+		}
+
+		for reason, count := range reasons {
+			count := count
+			metrics.RegisterCounterFunc(metrics.Name("rpc_rejected_total", "endpoint", name, "reason", reason), help, func() float64 {
+				return float64(count())
+			})
+		}
+	}
+}
+
+// startMetrics starts the http server which exports the metrics in the Prometheus text format.
+func (n *Node) startMetrics(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler(metrics.DefaultRegistry))
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		n.log.Error("metrics listen failed, %s", err)
+		return err
+	}
+
+	n.metricsListener = listener
+	go http.Serve(listener, mux)
+
+	return nil
+}
+
+// endpointAPIs returns the APIs exposed on the endpoint with the namespace allow-list, whose
+// clients are authenticated or not.
+func endpointAPIs(apis []rpc.API, modules []string, authenticated bool) []rpc.API {
+	allowed := make(map[string]bool)
+	for _, module := range modules {
+		allowed[module] = true
+	}
+
+	var result []rpc.API
 	for _, api := range apis {
-		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+		if len(modules) > 0 && !allowed[api.Namespace] {
+			continue
+		}
+
+		if !api.Public && (!authenticated || !allowed[api.Namespace]) {
+			continue
+		}
+
+		result = append(result, api)
+	}
+
+	return result
+}
+
+// endpointTopics returns the topics exposed on the endpoint with the namespace allow-list,
+// which exposes all topics if empty.
+func endpointTopics(topics []rpc.Topic, modules []string) []rpc.Topic {
+	if len(modules) == 0 {
+		return topics
+	}
+
+	allowed := make(map[string]bool)
+	for _, module := range modules {
+		allowed[module] = true
+	}
+
+	var result []rpc.Topic
+	for _, topic := range topics {
+		if allowed[topic.Namespace] {
+			result = append(result, topic)
+		}
+	}
+
+	return result
+}
+
+// registerRPC registers the APIs and topics to the rpc server
+func (n *Node) registerRPC(server *rpc.Server, apis []rpc.API, topics []rpc.Topic) error {
+	for _, api := range apis {
+		if err := server.RegisterName(api.Namespace, api.Service); err != nil {
 			n.log.Error("Api registered failed", "service", api.Service, "namespace", api.Namespace)
 			return err
 		}
 		n.log.Debug("Proc registered service namespace %s", api.Namespace)
 	}
 
+	for _, topic := range topics {
+		if err := server.RegisterTopic(topic); err != nil {
+			n.log.Error("Topic registered failed, topic %s", topic.Name)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RPCRejections returns the number of the requests rejected by the limits of the network
+// rpc services by the endpoint name, i.e. "rpc", "http" and "ws".
+func (n *Node) RPCRejections() map[string]rpc.RejectionStats {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	stats := make(map[string]rpc.RejectionStats, len(n.rpcServers))
+	for name, server := range n.rpcServers {
+		stats[name] = server.Rejections()
+	}
+
+	return stats
+}
+
+// startJSONRPC starts JSONRPC server
+func (n *Node) startJSONRPC(apis []rpc.API, topics []rpc.Topic) error {
+	handler := rpc.NewServer()
+	handler.SetAuth(&n.config.RPCAuth)
+	handler.SetLimits(n.config.RPCLimits.WithDefaultRates())
+	if err := n.registerRPC(handler, apis, topics); err != nil {
+		return err
+	}
+	n.rpcServers["rpc"] = handler
+
 	var (
 		listerner net.Listener
 		err       error
@@ -209,7 +373,7 @@ func (n *Node) startJSONRPC(apis []rpc.API) error {
 				n.log.Error("RPC accept failed", "err", err)
 				continue
 			}
-			go handler.ServeCodec(rpc.NewJsonCodec(conn))
+			go handler.ServeConn(conn)
 		}
 	}()
 
@@ -219,19 +383,17 @@ func (n *Node) startJSONRPC(apis []rpc.API) error {
 // startHTTPRPC starts http rpc server
 func (n *Node) startHTTPRPC(apis []rpc.API, whitehosts []string, corsList []string) error {
 	httpServer, httpHandler := rpc.NewHTTPServer(whitehosts, corsList)
-	for _, api := range apis {
-		if err := httpServer.RegisterName(api.Namespace, api.Service); err != nil {
-			n.log.Error("Api registered failed", "service", api.Service, "namespace", api.Namespace)
-			return err
-		}
-		n.log.Debug("Proc registered service namespace %s", api.Namespace)
+	httpServer.SetAuth(&n.config.HTTPAuth)
+	httpServer.SetLimits(n.config.HTTPLimits.WithDefaultRates())
+	if err := n.registerRPC(httpServer.Server, apis, nil); err != nil {
+		return err
 	}
+	n.rpcServers["http"] = httpServer.Server
 
 	var (
 		listerner net.Listener
 		err       error
 	)
-	httpServer.HandleHTTP(netrpc.DefaultRPCPath, netrpc.DefaultDebugPath)
 	if listerner, err = net.Listen("tcp", n.config.HTTPAddr); err != nil {
 		n.log.Error("HTTP listen failed", "err", err)
 		return err
@@ -242,6 +404,73 @@ func (n *Node) startHTTPRPC(apis []rpc.API, whitehosts []string, corsList []stri
 	return nil
 }
 
+// startWSRPC starts websocket rpc server
+func (n *Node) startWSRPC(apis []rpc.API, topics []rpc.Topic, corsList []string) error {
+	wsServer := rpc.NewWSServer(corsList)
+	wsServer.SetAuth(&n.config.WSAuth)
+	wsServer.SetLimits(n.config.WSLimits.WithDefaultRates())
+	if err := n.registerRPC(wsServer.Server, apis, topics); err != nil {
+		return err
+	}
+	n.rpcServers["ws"] = wsServer.Server
+
+	listerner, err := net.Listen("tcp", n.config.WSAddr)
+	if err != nil {
+		n.log.Error("WebSocket listen failed, %s", err)
+		return err
+	}
+
+	n.wsListener = listerner
+	go http.Serve(listerner, wsServer)
+
+	return nil
+}
+
+// startIPC starts IPC rpc server on the unix socket, which is accessible by the owner only.
+func (n *Node) startIPC(apis []rpc.API, topics []rpc.Topic, endpoint string) error {
+	handler := rpc.NewServer()
+	if err := n.registerRPC(handler, apis, topics); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(endpoint), 0700); err != nil {
+		return err
+	}
+
+	// remove the socket file left by the last run
+	if err := os.Remove(endpoint); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	listener, err := net.Listen("unix", endpoint)
+	if err != nil {
+		n.log.Error("IPC listen failed, %s", err)
+		return err
+	}
+
+	if err = os.Chmod(endpoint, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+
+	n.ipcListener = listener
+	n.log.Info("IPC endpoint opened: %s", endpoint)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				n.log.Debug("IPC accept stopped: %s", err)
+				return
+			}
+
+			go handler.ServeConn(conn)
+		}
+	}()
+
+	return nil
+}
+
 // Stop terminates the running the node and the services registered.
 func (n *Node) Stop() error {
 	n.lock.Lock()
@@ -250,29 +479,45 @@ func (n *Node) Stop() error {
 	if n.server == nil {
 		return ErrNodeStopped
 	}
-	
+
 	// stopErr is intended for possible stop errors
 	stopErr := &StopError{
 		Services: make(map[reflect.Type]error),
 	}
-	
+
 	for _, service := range n.services {
 		if err := service.Stop(); err != nil {
 			stopErr.Services[reflect.TypeOf(service)] = err
 		}
 	}
-    
+
 	// stop the p2p server
 	n.server.Stop()
-	
+
+	if n.wsListener != nil {
+		n.wsListener.Close()
+		n.wsListener = nil
+	}
+
+	// the socket file is removed when the listener is closed
+	if n.ipcListener != nil {
+		n.ipcListener.Close()
+		n.ipcListener = nil
+	}
+
+	if n.metricsListener != nil {
+		n.metricsListener.Close()
+		n.metricsListener = nil
+	}
+
 	n.services = nil
 	n.server = nil
-    
+
 	// return the stop errors if any
 	if len(stopErr.Services) > 0 {
-	    return stopErr
+		return stopErr
 	}
-	
+
 	return nil
 }
 
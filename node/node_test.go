@@ -6,11 +6,17 @@
 package node
 
 import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/seeleteam/go-seele/crypto"
 	"github.com/seeleteam/go-seele/p2p"
 	"github.com/seeleteam/go-seele/rpc"
+	"github.com/stretchr/testify/assert"
 )
 
 var (
@@ -19,9 +25,10 @@ var (
 
 func testNodeConfig() *Config {
 	return &Config{
-		Name:    "test node",
-		Version: "test version",
-		P2P:     p2p.Config{PrivateKey: testNodeKey},
+		Name:       "test node",
+		Version:    "test version",
+		P2P:        p2p.Config{PrivateKey: testNodeKey},
+		DisableIPC: true,
 	}
 }
 
@@ -72,3 +79,178 @@ func Test_ServiceRegistry(t *testing.T) {
 		t.Fatalf("failed to stop service stack: %v", err)
 	}
 }
+
+// TestAPI is a test rpc service.
+type TestAPI struct{}
+
+func (api *TestAPI) Echo(input *string, result *string) error {
+	*result = *input
+	return nil
+}
+
+// TestServiceRPC is a test implementation of the Service interface with a public and a private API.
+type TestServiceRPC struct{ TestServiceA }
+
+func (s TestServiceRPC) APIs() []rpc.API {
+	return []rpc.API{
+		{Namespace: "public", Version: "1.0", Service: new(TestAPI), Public: true},
+		{Namespace: "private", Version: "1.0", Service: new(TestAPI), Public: false},
+	}
+}
+
+func Test_WSRestart(t *testing.T) {
+	conf := testNodeConfig()
+	conf.WSAddr = "127.0.0.1:55041"
+	stack, err := New(conf)
+	assert.Equal(t, err, nil)
+
+	// the WebSocket address is released by Stop
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, stack.Start(), nil)
+		assert.Equal(t, stack.Stop(), nil)
+	}
+}
+
+func Test_IPC(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "node_test")
+	assert.Equal(t, err, nil)
+	defer os.RemoveAll(dataDir)
+
+	conf := testNodeConfig()
+	conf.DataDir = dataDir
+	conf.DisableIPC = false
+	conf.RPCAddr = "127.0.0.1:55037"
+	stack, err := New(conf)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, stack.Register(TestServiceRPC{}), nil)
+	assert.Equal(t, stack.Start(), nil)
+
+	endpoint := filepath.Join(dataDir, DefaultIPCName)
+	info, err := os.Stat(endpoint)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, info.Mode()&os.ModeSocket != 0, true)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0600))
+
+	// all APIs are exposed on IPC
+	client, err := rpc.Dial("unix", endpoint)
+	assert.Equal(t, err, nil)
+
+	var result string
+	input := "hello"
+	assert.Equal(t, client.Call("public.Echo", &input, &result), nil)
+	assert.Equal(t, result, input)
+	assert.Equal(t, client.Call("private.Echo", &input, &result), nil)
+	client.Close()
+
+	// only the public APIs are exposed on TCP
+	client, err = rpc.Dial("tcp", conf.RPCAddr)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, client.Call("public.Echo", &input, &result), nil)
+	err = client.Call("private.Echo", &input, &result)
+	assert.Equal(t, err.(*rpc.Error).Code, rpc.ErrCodeMethodNotFound)
+	client.Close()
+
+	// the socket file is removed after the node stops
+	assert.Equal(t, stack.Stop(), nil)
+	_, err = os.Stat(endpoint)
+	assert.Equal(t, os.IsNotExist(err), true)
+}
+
+func Test_IPCEndpoint(t *testing.T) {
+	conf := &Config{DataDir: "/data"}
+	assert.Equal(t, conf.IPCEndpoint(), filepath.Join("/data", DefaultIPCName))
+
+	conf.IPCPath = "node.ipc"
+	assert.Equal(t, conf.IPCEndpoint(), filepath.Join("/data", "node.ipc"))
+
+	conf.IPCPath = "/tmp/node.ipc"
+	assert.Equal(t, conf.IPCEndpoint(), "/tmp/node.ipc")
+}
+
+func Test_EndpointAPIs(t *testing.T) {
+	apis := []rpc.API{
+		{Namespace: "seele", Public: true},
+		{Namespace: "network", Public: true},
+		{Namespace: "admin", Public: false},
+		{Namespace: "personal", Public: false},
+	}
+
+	namespaces := func(modules []string, authenticated bool) []string {
+		var result []string
+		for _, api := range endpointAPIs(apis, modules, authenticated) {
+			result = append(result, api.Namespace)
+		}
+
+		return result
+	}
+
+	assert.Equal(t, namespaces(nil, false), []string{"seele", "network"})
+	assert.Equal(t, namespaces(nil, true), []string{"seele", "network"})
+	assert.Equal(t, namespaces([]string{"seele", "admin"}, false), []string{"seele"})
+	assert.Equal(t, namespaces([]string{"seele", "admin"}, true), []string{"seele", "admin"})
+}
+
+func Test_EndpointTopics(t *testing.T) {
+	topics := []rpc.Topic{
+		{Name: "newHeads", Namespace: "seele"},
+		{Name: "peers", Namespace: "network"},
+	}
+
+	names := func(modules []string) []string {
+		var result []string
+		for _, topic := range endpointTopics(topics, modules) {
+			result = append(result, topic.Name)
+		}
+
+		return result
+	}
+
+	assert.Equal(t, names(nil), []string{"newHeads", "peers"})
+	assert.Equal(t, names([]string{"network"}), []string{"peers"})
+	assert.Equal(t, len(names([]string{"admin"})), 0)
+}
+
+func Test_RPCRejections(t *testing.T) {
+	conf := testNodeConfig()
+	conf.RPCAddr = "127.0.0.1:55038"
+	conf.RPCLimits = rpc.LimitConfig{IPRate: 1}
+	stack, err := New(conf)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, stack.Register(TestServiceRPC{}), nil)
+	assert.Equal(t, stack.Start(), nil)
+	defer stack.Stop()
+
+	client, err := rpc.Dial("tcp", conf.RPCAddr)
+	assert.Equal(t, err, nil)
+	defer client.Close()
+
+	var result string
+	input := "hello"
+	assert.Equal(t, client.Call("public.Echo", &input, &result), nil)
+	err = client.Call("public.Echo", &input, &result)
+	assert.Equal(t, err.(*rpc.Error).Code, rpc.ErrCodeLimitExceeded)
+
+	stats := stack.RPCRejections()
+	assert.Equal(t, stats["rpc"], rpc.RejectionStats{RateLimited: 1})
+	assert.Equal(t, stats["http"], rpc.RejectionStats{})
+}
+
+func Test_Metrics(t *testing.T) {
+	conf := testNodeConfig()
+	conf.RPCAddr = "127.0.0.1:55039"
+	conf.MetricsAddr = "127.0.0.1:55040"
+	stack, err := New(conf)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, stack.Register(TestServiceRPC{}), nil)
+	assert.Equal(t, stack.Start(), nil)
+	defer stack.Stop()
+
+	resp, err := http.Get("http://" + conf.MetricsAddr + "/metrics")
+	assert.Equal(t, err, nil)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	assert.Equal(t, strings.Contains(string(body), `rpc_rejected_total{endpoint="rpc",reason="rate_limited"} 0`), true)
+}
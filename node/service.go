@@ -21,3 +21,9 @@ type Service interface {
 
 	Stop() error
 }
+
+// TopicService is implemented by the services which offer the topics of the rpc
+// subscriptions on the persistent connections, e.g. TCP and WebSocket.
+type TopicService interface {
+	Topics() []rpc.Topic
+}
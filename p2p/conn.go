@@ -11,6 +11,8 @@ import (
 	"net"
 	"sync"
 	"time"
+
+	"github.com/golang/snappy"
 )
 
 const (
@@ -19,18 +21,38 @@ const (
 	headBuffSizeEnd   = 4
 	headBuffCodeStart = 4
 	headBuffCodeEnd   = 6
+
+	// maxMsgSize is the maximum allowed size of a message payload, it is checked
+	// against the decompressed size when snappy compression is enabled.
+	maxMsgSize = 16 * 1024 * 1024
 )
 
 var (
 	errConnWriteTimeout = errors.New("Connection writes timeout")
+	errMsgTooLarge      = errors.New("Message payload is too large")
 )
 
-// connection TODO add bandwidth meter for connection
+// connection is the message read writer over a tcp or qvic connection, with bandwidth meter and limiters.
 type connection struct {
-	fd net.Conn // tcp connection
+	fd net.Conn // tcp or qvic connection
 
 	rmutux sync.Mutex // read msg lock
 	wmutux sync.Mutex // write msg lock
+
+	snappy bool // whether the payload is compressed with snappy, negotiated in handshake
+
+	meter    *trafficMeter  // counts the messages, nil for not metered
+	limiters []*rateLimiter // upload limiters of the peer and the server, nil for unlimited
+}
+
+// setSnappy enables or disables the snappy compression of message payloads.
+// It should only be called after handshake and before any other message is transferred.
+func (c *connection) setSnappy(enabled bool) {
+	c.rmutux.Lock()
+	c.wmutux.Lock()
+	c.snappy = enabled
+	c.wmutux.Unlock()
+	c.rmutux.Unlock()
 }
 
 // readFull receive from fd till outBuf is full
@@ -98,36 +120,72 @@ func (c *connection) ReadMsg() (msgRecv Message, err error) {
 	}
 
 	size := binary.BigEndian.Uint32(headbuff[headBuffSizeStart:headBuffSizeEnd])
+	if size > maxMsgSize {
+		return Message{}, errMsgTooLarge
+	}
+
 	if size > 0 {
 		msgRecv.Payload = make([]byte, size)
 		if err = c.readFull(msgRecv.Payload); err != nil {
 			return Message{}, err
 		}
+
+		if c.snappy {
+			if msgRecv.Payload, err = decompress(msgRecv.Payload); err != nil {
+				return Message{}, err
+			}
+		}
 	}
 
+	c.meter.markIn(headBuffLegth + int(size))
+
 	return msgRecv, nil
 }
 
+// decompress decodes the snappy compressed payload. The decoded length is checked
+// before decoding to avoid allocating a huge buffer for a malicious payload.
+func decompress(payload []byte) ([]byte, error) {
+	size, err := snappy.DecodedLen(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if size > maxMsgSize {
+		return nil, errMsgTooLarge
+	}
+
+	return snappy.Decode(nil, payload)
+}
+
 // WriteMsg message can be any data type
 func (c *connection) WriteMsg(msg Message) error {
 	c.wmutux.Lock()
 	defer c.wmutux.Unlock()
 
-	b := make([]byte, headBuffLegth)
+	if len(msg.Payload) > maxMsgSize {
+		return errMsgTooLarge
+	}
+
+	if c.snappy && len(msg.Payload) > 0 {
+		msg.Payload = snappy.Encode(nil, msg.Payload)
+	}
+
+	// write head and payload together, so that packet based transports like qvic
+	// will not send the head in a separate packet.
+	b := make([]byte, headBuffLegth+len(msg.Payload))
 	binary.BigEndian.PutUint32(b[headBuffSizeStart:headBuffSizeEnd], uint32(len(msg.Payload)))
 	binary.BigEndian.PutUint16(b[headBuffCodeStart:headBuffCodeEnd], msg.Code)
+	copy(b[headBuffLegth:], msg.Payload)
 
-	err := c.writeFull(b)
-	if err != nil {
-		return err
+	for _, l := range c.limiters {
+		l.wait(len(b))
 	}
 
-	if len(msg.Payload) > 0 {
-		_, err = c.fd.Write(msg.Payload)
-		if err != nil {
-			return err
-		}
+	if err := c.writeFull(b); err != nil {
+		return err
 	}
 
+	c.meter.markOut(len(b))
+
 	return nil
 }
@@ -0,0 +1,141 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package p2p
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/seeleteam/go-seele/p2p/qvic"
+)
+
+func newTestConnPair(snappy bool) (*connection, *connection) {
+	fd1, fd2 := net.Pipe()
+	c1, c2 := &connection{fd: fd1}, &connection{fd: fd2}
+	c1.setSnappy(snappy)
+	c2.setSnappy(snappy)
+
+	return c1, c2
+}
+
+func testConnReadWrite(t *testing.T, snappy bool) {
+	c1, c2 := newTestConnPair(snappy)
+	defer c1.close()
+	defer c2.close()
+
+	payload := bytes.Repeat([]byte("seele"), 1024)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c1.WriteMsg(Message{Code: 20, Payload: payload})
+	}()
+
+	msg, err := c2.ReadMsg()
+	if err != nil {
+		t.Fatalf("failed to read msg, %s", err)
+	}
+
+	if err = <-errCh; err != nil {
+		t.Fatalf("failed to write msg, %s", err)
+	}
+
+	if msg.Code != 20 || !bytes.Equal(msg.Payload, payload) {
+		t.Fatal("received msg mismatch")
+	}
+}
+
+func Test_Connection_ReadWrite(t *testing.T) {
+	testConnReadWrite(t, false)
+}
+
+func Test_Connection_ReadWriteSnappy(t *testing.T) {
+	testConnReadWrite(t, true)
+}
+
+func Test_Connection_Qvic(t *testing.T) {
+	listener, err := qvic.Listen("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	fd1, err := qvic.Dial(listener.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd1.Close()
+
+	fd2, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c1, c2 := &connection{fd: fd1}, &connection{fd: fd2}
+	c1.setSnappy(true)
+	c2.setSnappy(true)
+
+	payload := bytes.Repeat([]byte("seele"), 100*1024)
+	for i := 0; i < 3; i++ {
+		if err = c1.WriteMsg(Message{Code: uint16(20 + i), Payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		msg, err := c2.ReadMsg()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if msg.Code != uint16(20+i) || !bytes.Equal(msg.Payload, payload) {
+			t.Fatal("received msg mismatch")
+		}
+	}
+}
+
+func Test_Connection_DecompressionBomb(t *testing.T) {
+	c1, c2 := newTestConnPair(true)
+	defer c1.close()
+	defer c2.close()
+
+	// payload is compressed well below the limit, but the decoded size exceeds maxMsgSize
+	payload := snappy.Encode(nil, make([]byte, maxMsgSize+1))
+	go func() {
+		head := make([]byte, headBuffLegth)
+		binary.BigEndian.PutUint32(head[headBuffSizeStart:headBuffSizeEnd], uint32(len(payload)))
+		binary.BigEndian.PutUint16(head[headBuffCodeStart:headBuffCodeEnd], 20)
+		c1.writeFull(head)
+		c1.writeFull(payload)
+	}()
+
+	if _, err := c2.ReadMsg(); err != errMsgTooLarge {
+		t.Fatalf("expected errMsgTooLarge, got %v", err)
+	}
+}
+
+func Test_Connection_WriteTooLarge(t *testing.T) {
+	c1, c2 := newTestConnPair(false)
+	defer c1.close()
+	defer c2.close()
+
+	if err := c1.WriteMsg(Message{Code: 20, Payload: make([]byte, maxMsgSize+1)}); err != errMsgTooLarge {
+		t.Fatalf("expected errMsgTooLarge, got %v", err)
+	}
+}
+
+func Test_HasCap(t *testing.T) {
+	caps := []Cap{{"seele", 1}, snappyCap}
+	if !hasCap(caps, snappyCap) {
+		t.Fatal("snappy cap should be found")
+	}
+
+	if hasCap(caps[:1], snappyCap) {
+		t.Fatal("snappy cap should not be found")
+	}
+}
@@ -0,0 +1,201 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package p2p
+
+import (
+	"sync"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/p2p/discovery"
+)
+
+const (
+	// dialInterval is the interval to schedule new dials.
+	dialInterval = 1 * time.Second
+
+	// initialDialBackoff is the delay before re-dialing a node after the first failure.
+	// The delay doubles on every failure up to maxDialBackoff.
+	initialDialBackoff = 5 * time.Second
+	maxDialBackoff     = 5 * time.Minute
+
+	// stableConnection is the duration a peer must stay connected to reset the dial backoff,
+	// so that a peer dropping the connection right after handshake is not re-dialed too often.
+	stableConnection = 1 * time.Minute
+
+	// dialRatio one in dialRatio of MaxPeers slots is for outbound connections,
+	// and the others are for inbound connections.
+	dialRatio = 3
+)
+
+// dialTask is the dial state of a node.
+type dialTask struct {
+	node     *discovery.Node
+	static   bool          // static nodes are kept connected, even if removed from discovery
+	trusted  bool          // trusted nodes are dialed regardless of the limits
+	backoff  time.Duration // delay before the next dial after failure
+	nextDial time.Time     // earliest time of the next dial
+	dialing  bool
+	slot     bool // whether the dial in progress takes a pending slot
+	removed  bool // the node is removed while dialing, and the task is deleted when done
+}
+
+// dialScheduler decides which nodes to dial. The candidates are the nodes found by
+// discovery, and the static and trusted nodes which are re-dialed when disconnected.
+type dialScheduler struct {
+	lock        sync.Mutex
+	tasks       map[common.Address]*dialTask
+	maxPending  int // max number of concurrent dials
+	maxOutbound int // max number of outbound peers
+	pending     int // number of dials in progress, except for trusted nodes
+}
+
+func newDialScheduler(maxPending, maxOutbound int) *dialScheduler {
+	return &dialScheduler{
+		tasks:       make(map[common.Address]*dialTask),
+		maxPending:  maxPending,
+		maxOutbound: maxOutbound,
+	}
+}
+
+// maxOutboundPeers returns the number of outbound slots of maxPeers, at least 1.
+func maxOutboundPeers(maxPeers int) int {
+	n := maxPeers / dialRatio
+	if n < 1 {
+		n = 1
+	}
+
+	return n
+}
+
+// addNode adds the node as a dial candidate. A static or trusted node is dialed as soon as possible.
+func (d *dialScheduler) addNode(node *discovery.Node, static, trusted bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	task, ok := d.tasks[node.ID]
+	if !ok {
+		task = &dialTask{node: node, backoff: initialDialBackoff}
+		d.tasks[node.ID] = task
+	}
+
+	if static || trusted {
+		task.node = node
+		task.static = task.static || static
+		task.trusted = task.trusted || trusted
+		task.removed = false
+		task.nextDial = time.Time{}
+	}
+}
+
+// removeNode removes the node found by discovery, static and trusted nodes are kept.
+func (d *dialScheduler) removeNode(id common.Address) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if task, ok := d.tasks[id]; ok && !task.static && !task.trusted && !task.dialing {
+		delete(d.tasks, id)
+	}
+}
+
+// removeStatic stops keeping the node connected, and the node is not dialed anymore
+// unless it is added again.
+func (d *dialScheduler) removeStatic(id common.Address) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	task, ok := d.tasks[id]
+	if !ok {
+		return
+	}
+
+	task.static, task.trusted = false, false
+	if task.dialing {
+		task.removed = true
+	} else {
+		delete(d.tasks, id)
+	}
+}
+
+// next returns the nodes to dial now. The trusted nodes are returned first regardless of the
+// limits, then the static nodes and discovered nodes while there are free outbound slots.
+func (d *dialScheduler) next(now time.Time, connected func(common.Address) bool, outbound int) []*dialTask {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	var trusted, static, others []*dialTask
+	for id, task := range d.tasks {
+		if task.dialing || now.Before(task.nextDial) || connected(id) {
+			continue
+		}
+
+		switch {
+		case task.trusted:
+			trusted = append(trusted, task)
+		case task.static:
+			static = append(static, task)
+		default:
+			others = append(others, task)
+		}
+	}
+
+	tasks := trusted
+	for _, task := range append(static, others...) {
+		if d.pending >= d.maxPending || outbound+d.pending >= d.maxOutbound {
+			break
+		}
+
+		tasks = append(tasks, task)
+		task.slot = true
+		d.pending++
+	}
+
+	for _, task := range tasks {
+		task.dialing = true
+	}
+
+	return tasks
+}
+
+// done records the dial result. The next dial is delayed by the backoff, which doubles after
+// every dial until the peer stays connected long enough.
+func (d *dialScheduler) done(task *dialTask, now time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if task.slot {
+		d.pending--
+	}
+
+	task.dialing, task.slot = false, false
+	if task.removed {
+		delete(d.tasks, task.node.ID)
+		return
+	}
+
+	task.nextDial = now.Add(task.backoff)
+	task.backoff *= 2
+	if task.backoff > maxDialBackoff {
+		task.backoff = maxDialBackoff
+	}
+}
+
+// peerRemoved schedules a re-dial of the disconnected node. The backoff is reset if the peer
+// stayed connected long enough.
+func (d *dialScheduler) peerRemoved(id common.Address, connected time.Duration, now time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	task, ok := d.tasks[id]
+	if !ok || task.dialing {
+		return
+	}
+
+	if connected >= stableConnection {
+		task.backoff = initialDialBackoff
+		task.nextDial = now
+	}
+}
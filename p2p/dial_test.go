@@ -0,0 +1,175 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/crypto"
+	"github.com/seeleteam/go-seele/p2p/discovery"
+)
+
+func newTestNode() *discovery.Node {
+	return discovery.NewNode(*crypto.MustGenerateRandomAddress(), net.ParseIP("127.0.0.1"), 39007)
+}
+
+func notConnected(common.Address) bool { return false }
+
+func Test_DialScheduler_Limits(t *testing.T) {
+	d := newDialScheduler(2, 3)
+	trusted := newTestNode()
+	d.addNode(trusted, false, true)
+	for i := 0; i < 5; i++ {
+		d.addNode(newTestNode(), false, false)
+	}
+
+	// the trusted node and 2 nodes limited by max pending
+	tasks := d.next(time.Now(), notConnected, 0)
+	if len(tasks) != 3 || tasks[0].node != trusted {
+		t.Fatalf("expected 3 tasks with the trusted node first, got %d", len(tasks))
+	}
+
+	// no free pending slots
+	if tasks := d.next(time.Now(), notConnected, 0); len(tasks) != 0 {
+		t.Fatalf("expected no tasks, got %d", len(tasks))
+	}
+
+	// 1 pending dial is done, and 2 outbound peers are connected
+	d.done(tasks[1], time.Now())
+	if tasks := d.next(time.Now(), notConnected, 2); len(tasks) != 0 {
+		t.Fatalf("expected no tasks when outbound slots are full, got %d", len(tasks))
+	}
+
+	if tasks := d.next(time.Now(), notConnected, 1); len(tasks) != 1 {
+		t.Fatalf("expected 1 task, got %d", len(tasks))
+	}
+}
+
+func Test_DialScheduler_Backoff(t *testing.T) {
+	d := newDialScheduler(10, 10)
+	node := newTestNode()
+	d.addNode(node, true, false)
+
+	now := time.Now()
+	backoff := initialDialBackoff
+	for i := 0; i < 10; i++ {
+		tasks := d.next(now, notConnected, 0)
+		if len(tasks) != 1 {
+			t.Fatalf("expected the static node to dial, got %d tasks", len(tasks))
+		}
+
+		d.done(tasks[0], now)
+		if tasks := d.next(now.Add(backoff-time.Millisecond), notConnected, 0); len(tasks) != 0 {
+			t.Fatal("static node should not be dialed before backoff")
+		}
+
+		now = now.Add(backoff)
+		if backoff *= 2; backoff > maxDialBackoff {
+			backoff = maxDialBackoff
+		}
+	}
+
+	// a stable connection resets the backoff
+	tasks := d.next(now, notConnected, 0)
+	d.done(tasks[0], now)
+	d.peerRemoved(node.ID, stableConnection, now)
+	if tasks := d.next(now, notConnected, 0); len(tasks) != 1 {
+		t.Fatal("static node should be re-dialed after a stable connection dropped")
+	}
+}
+
+func Test_DialScheduler_Remove(t *testing.T) {
+	d := newDialScheduler(10, 10)
+	static, discovered := newTestNode(), newTestNode()
+	d.addNode(static, true, false)
+	d.addNode(discovered, false, false)
+
+	d.removeNode(static.ID)
+	d.removeNode(discovered.ID)
+	if len(d.tasks) != 1 || d.tasks[static.ID] == nil {
+		t.Fatal("only the discovered node should be removed")
+	}
+
+	d.removeStatic(static.ID)
+	if len(d.tasks) != 0 {
+		t.Fatal("the node should be removed after it is not static")
+	}
+
+	connected := func(id common.Address) bool { return id == static.ID }
+	d.addNode(static, true, false)
+	if tasks := d.next(time.Now(), connected, 0); len(tasks) != 0 {
+		t.Fatal("connected node should not be dialed")
+	}
+}
+
+func Test_DialScheduler_RemoveStatic(t *testing.T) {
+	d := newDialScheduler(10, 10)
+	node := newTestNode()
+	d.addNode(node, true, false)
+
+	// the node connected long enough is not re-dialed after it is removed and disconnected
+	now := time.Now()
+	d.done(d.next(now, notConnected, 0)[0], now)
+	d.removeStatic(node.ID)
+	d.peerRemoved(node.ID, stableConnection, now)
+	if tasks := d.next(now.Add(maxDialBackoff), notConnected, 0); len(tasks) != 0 {
+		t.Fatal("removed static node should not be re-dialed")
+	}
+
+	// the node removed while dialing is not re-dialed after the dial is done
+	d.addNode(node, false, true)
+	tasks := d.next(now, notConnected, 0)
+	d.removeStatic(node.ID)
+	d.done(tasks[0], now)
+	d.peerRemoved(node.ID, stableConnection, now)
+	if tasks := d.next(now.Add(maxDialBackoff), notConnected, 0); len(tasks) != 0 || len(d.tasks) != 0 {
+		t.Fatal("removed trusted node should not be re-dialed")
+	}
+}
+
+func Test_Server_CheckPeer(t *testing.T) {
+	srv := &Server{Config: Config{MaxPeers: 3}, trusted: make(map[common.Address]bool)}
+	peers := make(map[common.Address]*Peer)
+	newPeer := func(inbound bool) *Peer {
+		return &Peer{Node: newTestNode(), inbound: inbound}
+	}
+
+	// 1 outbound slot and 2 inbound slots
+	p := newPeer(false)
+	if _, ok := srv.checkPeer(p, peers); !ok {
+		t.Fatal("outbound peer should be allowed")
+	}
+	peers[p.Node.ID] = p
+
+	if reason, ok := srv.checkPeer(p, peers); ok || reason != discAlreadyConnected {
+		t.Fatal("connected peer should not be allowed")
+	}
+
+	if reason, ok := srv.checkPeer(newPeer(false), peers); ok || reason != discTooManyPeers {
+		t.Fatal("outbound peer should not be allowed past the outbound slots")
+	}
+
+	for i := 0; i < 2; i++ {
+		p = newPeer(true)
+		if _, ok := srv.checkPeer(p, peers); !ok {
+			t.Fatal("inbound peer should be allowed")
+		}
+		peers[p.Node.ID] = p
+	}
+
+	if _, ok := srv.checkPeer(newPeer(true), peers); ok {
+		t.Fatal("inbound peer should not be allowed past MaxPeers")
+	}
+
+	p = newPeer(true)
+	srv.trusted[p.Node.ID] = true
+	if _, ok := srv.checkPeer(p, peers); !ok || !p.isTrusted() {
+		t.Fatal("trusted peer should be allowed past MaxPeers")
+	}
+}
@@ -14,12 +14,15 @@ import (
 )
 
 const (
-	bucketSize = 16 // Kademlia bucket size
+	bucketSize      = 16 // Kademlia bucket size
+	maxReplacements = 10 // max number of nodes in the replacement cache of a bucket
 )
 
 type bucket struct {
-	peers []*Node
-	lock  sync.RWMutex //used for peers change
+	peers        []*Node     // live nodes ordered by last seen time, the least recently seen first
+	replacements []*Node     // candidates to replace the dead nodes, the most recently seen first
+	ips          subnetCount // subnets of the live nodes
+	lock         sync.RWMutex
 }
 
 func newBuckets() *bucket {
@@ -29,24 +32,37 @@ func newBuckets() *bucket {
 	}
 }
 
-// addNode add node to bucket, if bucket is full, will remove an old one
-func (b *bucket) addNode(node *Node) {
-	index := b.findNode(node)
-
-	if index != -1 {
-		// do nothing for now
-		// TODO lru
-	} else {
-		b.lock.Lock()
-		defer b.lock.Unlock()
-
-		log.Info("add node: %s", hexutil.BytesToHex(node.ID.Bytes()))
-		if len(b.peers) < bucketSize {
-			b.peers = append(b.peers, node)
-		} else {
-			copy(b.peers[:], b.peers[1:])
-			b.peers[len(b.peers)-1] = node
-		}
+// addNode add node to bucket and returns true if it is a new live node. A known node is moved to
+// the end as the most recently seen. If bucket is full, the node is added to the replacement cache
+// instead, the old nodes are only replaced when they fail the revalidation.
+func (b *bucket) addNode(node *Node) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if index := indexOfNode(b.peers, node.ID); index != -1 {
+		b.peers = append(b.peers[:index], b.peers[index+1:]...)
+		b.peers = append(b.peers, node)
+		return false
+	}
+
+	if len(b.peers) >= bucketSize {
+		b.addReplacement(node)
+		return false
+	}
+
+	log.Info("add node: %s", hexutil.BytesToHex(node.ID.Bytes()))
+	b.peers = append(b.peers, node)
+	b.replacements = deleteNode(b.replacements, node.ID)
+
+	return true
+}
+
+// addReplacement adds the node to the front of the replacement cache
+func (b *bucket) addReplacement(node *Node) {
+	b.replacements = deleteNode(b.replacements, node.ID)
+	b.replacements = append([]*Node{node}, b.replacements...)
+	if len(b.replacements) > maxReplacements {
+		b.replacements = b.replacements[:maxReplacements]
 	}
 }
 
@@ -54,19 +70,31 @@ func (b *bucket) addNode(node *Node) {
 func (b *bucket) findNode(node *Node) int {
 	b.lock.RLock()
 	defer b.lock.RUnlock()
-	for index, n := range b.peers {
-		if n.ID == node.ID {
-			return index
-		}
-	}
 
-	return -1
+	return indexOfNode(b.peers, node.ID)
+}
+
+// full returns true if there is no room for new live nodes
+func (b *bucket) full() bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return len(b.peers) >= bucketSize
 }
 
-func (b *bucket) deleteNode(target common.Hash) {
+// deleteNode deletes the node from the live nodes and the replacement cache,
+// and returns the deleted live node, or nil if not found.
+func (b *bucket) deleteNode(target common.Hash) *Node {
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
+	for i, n := range b.replacements {
+		if n.getSha() == target {
+			b.replacements = append(b.replacements[:i], b.replacements[i+1:]...)
+			break
+		}
+	}
+
 	index := -1
 	for i, n := range b.peers {
 		sha := crypto.HashBytes(n.ID.Bytes())
@@ -77,13 +105,43 @@ func (b *bucket) deleteNode(target common.Hash) {
 	}
 
 	if index == -1 {
-		log.Error("Failed to find the node to delete\n")
-		return
+		log.Debug("Failed to find the node to delete")
+		return nil
 	}
 
-	log.Info("delete node: %s", hexutil.BytesToHex(b.peers[index].ID.Bytes()))
+	node := b.peers[index]
+	log.Info("delete node: %s", hexutil.BytesToHex(node.ID.Bytes()))
 
 	b.peers = append(b.peers[:index], b.peers[index+1:]...)
+
+	return node
+}
+
+// popReplacement removes and returns the most recently seen node in the replacement cache
+func (b *bucket) popReplacement() *Node {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if len(b.replacements) == 0 {
+		return nil
+	}
+
+	node := b.replacements[0]
+	b.replacements = b.replacements[1:]
+
+	return node
+}
+
+// oldest returns the least recently seen live node, or nil if the bucket is empty
+func (b *bucket) oldest() *Node {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	if len(b.peers) == 0 {
+		return nil
+	}
+
+	return b.peers[0]
 }
 
 func (b *bucket) size() int {
@@ -101,3 +159,22 @@ func (b *bucket) printNodeList() {
 		log.Debug("%s", hexutil.BytesToHex(n.ID.Bytes()))
 	}
 }
+
+func indexOfNode(nodes []*Node, id common.Address) int {
+	for index, n := range nodes {
+		if n.ID == id {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// deleteNode returns the nodes without the node of id
+func deleteNode(nodes []*Node, id common.Address) []*Node {
+	if index := indexOfNode(nodes, id); index != -1 {
+		return append(nodes[:index], nodes[index+1:]...)
+	}
+
+	return nodes
+}
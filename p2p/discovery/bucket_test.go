@@ -44,7 +44,7 @@ func Test_Bucket(t *testing.T) {
 func Test_AddNode(t *testing.T) {
 	b := bucket{}
 
-	var n1 *Node
+	var n1, n17 *Node
 	for i := 0; i < 17; i++ {
 		port := i + 9000
 		n := getNode(strconv.Itoa(port))
@@ -53,10 +53,24 @@ func Test_AddNode(t *testing.T) {
 		if i == 0 {
 			n1 = n
 		}
+		n17 = n
 	}
 
+	// the full bucket keeps the old nodes, and caches the new one
 	assert.Equal(t, b.size(), bucketSize)
-	assert.Equal(t, b.findNode(n1), -1)
+	assert.Equal(t, b.findNode(n1), 0)
+	assert.Equal(t, b.findNode(n17), -1)
+	assert.Equal(t, len(b.replacements), 1)
+
+	// the seen node is moved to the end
+	b.addNode(n1)
+	assert.Equal(t, b.findNode(n1), bucketSize-1)
+
+	// the replacement cache is bounded
+	for i := 0; i < maxReplacements+1; i++ {
+		b.addNode(getNode(strconv.Itoa(i + 9100)))
+	}
+	assert.Equal(t, len(b.replacements), maxReplacements)
 }
 
 func getNode(port string) *Node {
@@ -100,19 +100,19 @@ func (m *ping) send(t *udp) {
 		callback: func(resp interface{}, addr *net.UDPAddr) (done bool) {
 			r := resp.(*pong)
 			n := NewNodeWithAddr(r.SelfID, addr)
-			t.table.updateNode(n)
+			t.addNode(n)
 
 			//log.Debug("received pong msg: %s", hexutil.BytesToHex(r.SelfID.Bytes()))
 
 			return true
 		},
-		errorCallBack: func() { // delete this node when ping timeout, TODO add time limit
+		errorCallBack: func() { // replace this node when ping timeout
 			sha := crypto.HashBytes(m.to.ID.Bytes())
 			t.deleteNode(sha)
 		},
 	}
 
-	t.addPending <- p
+	t.pend(p)
 	t.sendMsg(pingMsgType, m, m.to)
 }
 
@@ -171,10 +171,11 @@ func (m *findNode) send(t *udp) {
 				t.addNode(node)
 			}
 
-			// if not found, will find the node that is more closer than last one
+			// if not found, will find the node that is more closer than last one.
+			// the callback runs in the reply loop, so requests are sent in another routine to add pending.
 			if !found {
 				nodes := t.table.findNodeWithTarget(crypto.HashBytes(m.QueryID.Bytes()), crypto.HashBytes(m.SelfID.Bytes()))
-				sendFindNodeRequest(t, nodes, m.QueryID)
+				go sendFindNodeRequest(t, nodes, m.QueryID)
 			}
 
 			return true
@@ -184,7 +185,7 @@ func (m *findNode) send(t *udp) {
 		},
 	}
 
-	t.addPending <- p
+	t.pend(p)
 	t.sendMsg(findNodeMsgType, m, m.to)
 }
 
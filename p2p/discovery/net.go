@@ -9,6 +9,13 @@ import (
 	"net"
 )
 
+// udpConn is the connection of discovery, which is *net.UDPConn except in tests
+type udpConn interface {
+	ReadFromUDP(b []byte) (int, *net.UDPAddr, error)
+	WriteToUDP(b []byte, addr *net.UDPAddr) (int, error)
+	Close() error
+}
+
 func getUDPConn(addr *net.UDPAddr) *net.UDPConn {
 	conn, err := net.ListenUDP("udp", addr)
 	if err != nil {
@@ -14,10 +14,9 @@ import (
 func StartService(myId common.Address, myAddr *net.UDPAddr, bootstrap []*Node) *Database {
 	udp := newUDP(myId, myAddr)
 
-	if bootstrap != nil {
-		for _, bn := range bootstrap {
-			udp.addNode(bn)
-		}
+	udp.bootstrap = bootstrap
+	for _, bn := range bootstrap {
+		udp.addNode(bn)
 	}
 
 	udp.StartServe()
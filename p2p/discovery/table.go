@@ -6,8 +6,10 @@
 package discovery
 
 import (
+	"math/rand"
 	"net"
 	"sort"
+	"sync"
 
 	"github.com/seeleteam/go-seele/common"
 )
@@ -17,12 +19,21 @@ const (
 	responseNodeNumber = 5 // TODO with this number for test
 	hashBits           = len(common.Hash{}) * 8
 	nBuckets           = hashBits + 1 // Number of buckets
+
+	// IP diversity limits to resist eclipse attacks, nodes in the same subnet
+	// are limited per bucket and in the whole table. Nodes in LAN are not limited.
+	ipv4SubnetBits = 24
+	ipv6SubnetBits = 64
+	bucketIPLimit  = 2
+	tableIPLimit   = 10
 )
 
 type Table struct {
 	buckets  [nBuckets]*bucket
-	count    int   //total number of nodes
-	selfNode *Node //info of local node
+	count    int         //total number of live nodes
+	ips      subnetCount // subnets of the live nodes
+	selfNode *Node       //info of local node
+	lock     sync.Mutex  // protects buckets, count and ips
 }
 
 func newTable(id common.Address, addr *net.UDPAddr) *Table {
@@ -40,14 +51,37 @@ func newTable(id common.Address, addr *net.UDPAddr) *Table {
 	return table
 }
 
-func (t *Table) addNode(node *Node) {
-	dis := logDist(t.selfNode.getSha(), node.getSha())
+func (t *Table) bucket(sha common.Hash) *bucket {
+	return t.buckets[logDist(t.selfNode.getSha(), sha)]
+}
+
+// addNode adds the node to the table and returns true if it is a new live node.
+// A known node is marked as the most recently seen.
+func (t *Table) addNode(node *Node) bool {
+	if node.ID == t.selfNode.ID {
+		return false
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	b := t.bucket(node.getSha())
+	if b.findNode(node) == -1 && !b.full() && !t.addIP(b, node.IP) {
+		log.Debug("node %s is rejected by the subnet limits", node.IP)
+		return false
+	}
+
+	if !b.addNode(node) {
+		return false
+	}
 
-	t.buckets[dis].addNode(node)
+	t.count++
+	return true
 }
 
-func (t *Table) updateNode(node *Node) {
-	t.addNode(node)
+// updateNode marks the node as the most recently seen, it is added if unknown.
+func (t *Table) updateNode(node *Node) bool {
+	return t.addNode(node)
 }
 
 // findNodeWithTarget find nodes that distance of target is less than measure with target
@@ -67,10 +101,76 @@ func (t *Table) findNodeWithTarget(target common.Hash, measure common.Hash) []*N
 	return minDis
 }
 
-func (t *Table) deleteNode(target common.Hash) {
-	dis := logDist(t.selfNode.getSha(), target)
+// deleteNode deletes the node from the table, and replaces it with the most recently seen
+// node in the replacement cache. It returns the node which replaces the deleted one, or nil.
+func (t *Table) deleteNode(target common.Hash) *Node {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	b := t.bucket(target)
+	deleted := b.deleteNode(target)
+	if deleted == nil {
+		return nil
+	}
 
-	t.buckets[dis].deleteNode(target)
+	t.count--
+	t.removeIP(b, deleted.IP)
+
+	for node := b.popReplacement(); node != nil; node = b.popReplacement() {
+		if t.addIP(b, node.IP) && b.addNode(node) {
+			t.count++
+			return node
+		}
+	}
+
+	return nil
+}
+
+// nodeToRevalidate returns the least recently seen node of a random non-empty bucket,
+// or nil if the table is empty.
+func (t *Table) nodeToRevalidate() *Node {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, i := range rand.Perm(nBuckets) {
+		if node := t.buckets[i].oldest(); node != nil {
+			return node
+		}
+	}
+
+	return nil
+}
+
+func (t *Table) size() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.count
+}
+
+// addIP checks the subnet limits of the table and bucket, and counts the ip if allowed
+func (t *Table) addIP(b *bucket, ip net.IP) bool {
+	if !limitedIP(ip) {
+		return true
+	}
+
+	if !t.ips.add(ip, tableIPLimit) {
+		return false
+	}
+
+	if !b.ips.add(ip, bucketIPLimit) {
+		t.ips.remove(ip)
+		return false
+	}
+
+	return true
+}
+
+func (t *Table) removeIP(b *bucket, ip net.IP) {
+	if limitedIP(ip) {
+		t.ips.remove(ip)
+		b.ips.remove(ip)
+	}
 }
 
 // findNodeForRequest calls when start find node, find the initialize nodes
@@ -79,6 +179,9 @@ func (t *Table) findNodeForRequest(target common.Hash) []*Node {
 }
 
 func (t *Table) findMinDisNodes(target common.Hash, number int) []*Node {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
 	result := nodesByDistance{
 		target:   target,
 		maxElems: number,
@@ -120,3 +223,44 @@ func (h *nodesByDistance) push(n *Node) {
 		h.entries[ix] = n
 	}
 }
+
+// subnetCount counts the nodes in every subnet
+type subnetCount map[string]int
+
+// limitedIP returns true if the ip is limited by subnet, the LAN ips are not limited.
+func limitedIP(ip net.IP) bool {
+	return ip != nil && !ip.IsLoopback() && !ip.IsUnspecified() && !ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast()
+}
+
+func subnetOf(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(ipv4SubnetBits, 32)).String()
+	}
+
+	return ip.Mask(net.CIDRMask(ipv6SubnetBits, 128)).String()
+}
+
+// add counts the ip, and returns false if its subnet already has limit nodes
+func (s *subnetCount) add(ip net.IP, limit int) bool {
+	if *s == nil {
+		*s = make(subnetCount)
+	}
+
+	subnet := subnetOf(ip)
+	if (*s)[subnet] >= limit {
+		return false
+	}
+
+	(*s)[subnet]++
+	return true
+}
+
+func (s subnetCount) remove(ip net.IP) {
+	subnet := subnetOf(ip)
+	if s[subnet] <= 1 {
+		delete(s, subnet)
+	} else {
+		s[subnet]--
+	}
+}
@@ -0,0 +1,113 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package discovery
+
+import (
+	"net"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+	"github.com/seeleteam/go-seele/crypto"
+)
+
+// newTableNode returns a node with the ip in the farthest bucket of the table,
+// which half of the random nodes fall in.
+func newTableNode(table *Table, ip string) *Node {
+	for {
+		n := NewNode(*crypto.MustGenerateRandomAddress(), net.ParseIP(ip), 9000)
+		if logDist(table.selfNode.getSha(), n.getSha()) == hashBits {
+			return n
+		}
+	}
+}
+
+func newTestTable() *Table {
+	return newTable(*crypto.MustGenerateRandomAddress(), getAddr("9000"))
+}
+
+func Test_Table_BucketIPLimit(t *testing.T) {
+	table := newTestTable()
+
+	for i := 0; i < bucketIPLimit; i++ {
+		assert.Equal(t, table.addNode(newTableNode(table, "1.2.3.4")), true)
+	}
+
+	// the subnet is full in the bucket
+	assert.Equal(t, table.addNode(newTableNode(table, "1.2.3.5")), false)
+	assert.Equal(t, table.addNode(newTableNode(table, "1.2.4.5")), true)
+
+	// LAN nodes are not limited
+	for i := 0; i < bucketIPLimit+1; i++ {
+		assert.Equal(t, table.addNode(newTableNode(table, "127.0.0.1")), true)
+		assert.Equal(t, table.addNode(newTableNode(table, "192.168.1.1")), true)
+	}
+
+	assert.Equal(t, table.size(), 3+2*(bucketIPLimit+1))
+}
+
+func Test_Table_TableIPLimit(t *testing.T) {
+	table := newTestTable()
+
+	// newNode returns a node in the subnet, whose bucket is not full of the subnet
+	newNode := func() *Node {
+		for {
+			n := NewNode(*crypto.MustGenerateRandomAddress(), net.ParseIP("1.2.3.4"), 9000)
+			if table.bucket(n.getSha()).ips["1.2.3.0"] < bucketIPLimit {
+				return n
+			}
+		}
+	}
+
+	// nodes in different buckets, limited by the table
+	for i := 0; i < tableIPLimit; i++ {
+		assert.Equal(t, table.addNode(newNode()), true)
+	}
+
+	assert.Equal(t, table.addNode(newNode()), false)
+
+	// the subnet is available after a node is deleted
+	var deleted *Node
+	for _, b := range table.buckets {
+		if len(b.peers) > 0 {
+			deleted = b.peers[0]
+			break
+		}
+	}
+
+	table.deleteNode(deleted.getSha())
+	assert.Equal(t, table.addNode(deleted), true)
+}
+
+func Test_Table_Replacement(t *testing.T) {
+	table := newTestTable()
+
+	nodes := make([]*Node, bucketSize)
+	for i := range nodes {
+		nodes[i] = newTableNode(table, "127.0.0.1")
+		table.addNode(nodes[i])
+	}
+
+	replacement := newTableNode(table, "127.0.0.1")
+	assert.Equal(t, table.addNode(replacement), false)
+	assert.Equal(t, table.size(), bucketSize)
+
+	// the least recently seen node is revalidated
+	assert.Equal(t, table.nodeToRevalidate(), nodes[0])
+	table.updateNode(nodes[0])
+	assert.Equal(t, table.nodeToRevalidate(), nodes[1])
+
+	// the dead node is replaced
+	assert.Equal(t, table.deleteNode(nodes[1].getSha()), replacement)
+	assert.Equal(t, table.size(), bucketSize)
+	assert.Equal(t, table.deleteNode(nodes[2].getSha()) == nil, true)
+	assert.Equal(t, table.size(), bucketSize-1)
+}
+
+func Test_Table_Self(t *testing.T) {
+	table := newTestTable()
+	assert.Equal(t, table.addNode(table.selfNode), false)
+	assert.Equal(t, table.size(), 0)
+}
@@ -17,21 +17,24 @@ import (
 const (
 	responseTimeout = 10 * time.Second
 
-	pingpongInterval  = 15 * time.Second // sleep between ping pong, must big than response time out
-	discoveryInterval = 20 * time.Second // sleep between discovery, must big than response time out
+	revalidateInterval = 10 * time.Second // interval to ping the least recently seen node of a random bucket
+	discoveryInterval  = 20 * time.Second // sleep between discovery, must big than response time out
 )
 
 type udp struct {
-	conn  *net.UDPConn
-	self  *Node
-	table *Table
+	conn      udpConn
+	self      *Node
+	table     *Table
+	bootstrap []*Node // nodes to refill the table when it is empty
 
 	db        *Database
 	localAddr *net.UDPAddr
+	timeout   time.Duration // timeout of requests
 
 	gotReply   chan *reply
 	addPending chan *pending
 	writer     chan *send
+	quit       chan struct{}
 }
 
 type pending struct {
@@ -61,17 +64,23 @@ type reply struct {
 }
 
 func newUDP(id common.Address, addr *net.UDPAddr) *udp {
+	return newUDPWithConn(id, addr, getUDPConn(addr))
+}
+
+func newUDPWithConn(id common.Address, addr *net.UDPAddr, conn udpConn) *udp {
 	transport := &udp{
-		conn:      getUDPConn(addr),
+		conn:      conn,
 		table:     newTable(id, addr),
 		self:      NewNodeWithAddr(id, addr),
 		localAddr: addr,
+		timeout:   responseTimeout,
 
 		db: NewDatabase(),
 
 		gotReply:   make(chan *reply, 1),
 		addPending: make(chan *pending, 1),
 		writer:     make(chan *send, 1),
+		quit:       make(chan struct{}),
 	}
 
 	return transport
@@ -90,10 +99,30 @@ func (u *udp) sendMsg(t msgType, msg interface{}, to *Node) {
 		to:   to,
 		code: t,
 	}
-	u.writer <- s
+
+	select {
+	case u.writer <- s:
+	case <-u.quit:
+	}
 }
 
-func sendMsg(buff []byte, conn *net.UDPConn, to *net.UDPAddr) bool {
+// reply delivers the response or send error to the pending request
+func (u *udp) reply(r *reply) {
+	select {
+	case u.gotReply <- r:
+	case <-u.quit:
+	}
+}
+
+// pend adds the pending request which waits for response
+func (u *udp) pend(p *pending) {
+	select {
+	case u.addPending <- p:
+	case <-u.quit:
+	}
+}
+
+func sendMsg(buff []byte, conn udpConn, to *net.UDPAddr) bool {
 	//log.Debug("buff length:", len(buff))
 	n, err := conn.WriteToUDP(buff, to)
 	if err != nil {
@@ -122,8 +151,10 @@ func (u *udp) sendLoop() {
 					err:  true,
 				}
 
-				u.gotReply <- r
+				u.reply(r)
 			}
+		case <-u.quit:
+			return
 		}
 	}
 }
@@ -159,7 +190,7 @@ func (u *udp) handleMsg(from *net.UDPAddr, data []byte) {
 				err:  false,
 			}
 
-			u.gotReply <- r
+			u.reply(r)
 		case findNodeMsgType:
 			msg := &findNode{}
 
@@ -186,7 +217,7 @@ func (u *udp) handleMsg(from *net.UDPAddr, data []byte) {
 				err:  false,
 			}
 
-			u.gotReply <- r
+			u.reply(r)
 		default:
 			log.Error("unknown code %d", code)
 		}
@@ -200,7 +231,14 @@ func (u *udp) readLoop() {
 		data := make([]byte, 1024)
 		n, remoteAddr, err := u.conn.ReadFromUDP(data)
 		if err != nil {
+			select {
+			case <-u.quit:
+				return
+			default:
+			}
+
 			log.Info(err.Error())
+			continue
 		}
 
 		//log.Info("get msg from: %d", remoteAddr.Port)
@@ -261,73 +299,118 @@ func (u *udp) loopReply() {
 				}
 			}
 		case p := <-u.addPending:
-			p.deadline = time.Now().Add(responseTimeout)
+			p.deadline = time.Now().Add(u.timeout)
 			pendingList.PushBack(p)
 		case <-timeout.C:
-			for el := pendingList.Front(); el != nil; el = el.Next() {
-				p := el.Value.(*pending)
+			for el := pendingList.Front(); el != nil; {
+				// save the next element, as it is cleared when the element is removed
+				p, next := el.Value.(*pending), el.Next()
 				if p.deadline.Sub(time.Now()) <= 0 {
 					log.Debug("time out %d", p.code)
 					p.errorCallBack()
 					pendingList.Remove(el)
 				}
+
+				el = next
 			}
 
 			resetTimer()
+		case <-u.quit:
+			return
 		}
 	}
 }
 
 func (u *udp) discovery() {
+	ticker := time.NewTicker(discoveryInterval)
+	defer ticker.Stop()
+
 	for {
-		id, err := crypto.GenerateRandomAddress()
-		if err != nil {
-			log.Error(err.Error())
-			continue
+		u.refresh()
+
+		select {
+		case <-ticker.C:
+		case <-u.quit:
+			return
 		}
+	}
+}
 
-		nodes := u.table.findNodeForRequest(crypto.HashBytes(id.Bytes()))
+// refresh looks up the local node and a random target to fill the buckets. The bootstrap
+// nodes are added again if the table is empty, e.g. all nodes are dead after network down.
+func (u *udp) refresh() {
+	if u.table.size() == 0 {
+		for _, n := range u.bootstrap {
+			u.addNode(n)
+		}
+	}
 
-		//log.Debug("query id: %s", hexutil.BytesToHex(id.Bytes()))
-		sendFindNodeRequest(u, nodes, *id)
+	id, err := crypto.GenerateRandomAddress()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
 
-		time.Sleep(discoveryInterval)
+	for _, target := range []common.Address{u.self.ID, *id} {
+		nodes := u.table.findNodeForRequest(crypto.HashBytes(target.Bytes()))
+		sendFindNodeRequest(u, nodes, target)
 	}
 }
 
-func (u *udp) pingPongService() {
+func (u *udp) revalidateLoop() {
+	ticker := time.NewTicker(revalidateInterval)
+	defer ticker.Stop()
+
 	for {
-		copyMap := u.db.GetCopy()
+		select {
+		case <-ticker.C:
+			u.revalidate()
+		case <-u.quit:
+			return
+		}
+	}
+}
 
-		for _, value := range copyMap {
-			p := &ping{
-				Version: discoveryProtocolVersion,
-				SelfID:  u.self.ID,
+// revalidate pings the least recently seen node of a random bucket. The node is marked as
+// the most recently seen if it responds, otherwise it is replaced by the replacement cache.
+func (u *udp) revalidate() {
+	node := u.table.nodeToRevalidate()
+	if node == nil {
+		return
+	}
 
-				to: value,
-			}
+	p := &ping{
+		Version: discoveryProtocolVersion,
+		SelfID:  u.self.ID,
 
-			p.send(u)
-			time.Sleep(pingpongInterval)
-		}
+		to: node,
 	}
+
+	p.send(u)
 }
 
 func (u *udp) StartServe() {
 	go u.readLoop()
 	go u.loopReply()
 	go u.discovery()
-	go u.pingPongService()
+	go u.revalidateLoop()
 	go u.sendLoop()
 }
 
+// close stops the service and closes the connection
+func (u *udp) close() {
+	close(u.quit)
+	u.conn.Close()
+}
+
 func (u *udp) addNode(n *Node) {
 	if n == nil || n.ID == u.self.ID {
 		return
 	}
 
-	u.table.addNode(n)
-	u.db.add(n)
+	if u.table.addNode(n) {
+		u.db.add(n)
+	}
 	//log.Info("add node, total nodes:%d", u.db.size())
 }
 
@@ -337,7 +420,11 @@ func (u *udp) deleteNode(sha common.Hash) {
 		return
 	}
 
-	u.table.deleteNode(sha)
+	replacement := u.table.deleteNode(sha)
 	u.db.delete(sha)
+	if replacement != nil {
+		u.db.add(replacement)
+	}
+
 	log.Info("delete node, total nodes:%d", u.db.size())
 }
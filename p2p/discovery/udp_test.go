@@ -0,0 +1,190 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package discovery
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/crypto"
+)
+
+var errFakeConnClosed = errors.New("fake connection closed")
+
+type fakePacket struct {
+	data []byte
+	from *net.UDPAddr
+}
+
+// fakeNet is an in-memory UDP network, packets to unknown addresses are dropped.
+type fakeNet struct {
+	lock  sync.Mutex
+	conns map[string]*fakeConn
+}
+
+func newFakeNet() *fakeNet {
+	return &fakeNet{conns: make(map[string]*fakeConn)}
+}
+
+func (n *fakeNet) listen(addr *net.UDPAddr) *fakeConn {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	conn := &fakeConn{
+		net:    n,
+		addr:   addr,
+		in:     make(chan fakePacket, 64),
+		closed: make(chan struct{}),
+	}
+	n.conns[addr.String()] = conn
+
+	return conn
+}
+
+type fakeConn struct {
+	net    *fakeNet
+	addr   *net.UDPAddr
+	in     chan fakePacket
+	closed chan struct{}
+}
+
+func (c *fakeConn) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	select {
+	case p := <-c.in:
+		return copy(b, p.data), p.from, nil
+	case <-c.closed:
+		return 0, nil, errFakeConnClosed
+	}
+}
+
+func (c *fakeConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	c.net.lock.Lock()
+	to := c.net.conns[addr.String()]
+	c.net.lock.Unlock()
+
+	if to != nil {
+		data := make([]byte, len(b))
+		copy(data, b)
+
+		select {
+		case to.in <- fakePacket{data, c.addr}:
+		default: // dropped like UDP
+		}
+	}
+
+	return len(b), nil
+}
+
+func (c *fakeConn) Close() error {
+	c.net.lock.Lock()
+	delete(c.net.conns, c.addr.String())
+	c.net.lock.Unlock()
+
+	close(c.closed)
+	return nil
+}
+
+// newTestUDP starts the transport without the periodic discovery and revalidation,
+// the tests run them step by step.
+func newTestUDP(network *fakeNet, id common.Address, port int) *udp {
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: port}
+	u := newUDPWithConn(id, addr, network.listen(addr))
+	u.timeout = 100 * time.Millisecond
+
+	go u.readLoop()
+	go u.loopReply()
+	go u.sendLoop()
+
+	return u
+}
+
+func waitFor(t *testing.T, condition func() bool, msg string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_UDP_Revalidate(t *testing.T) {
+	network := newFakeNet()
+	u := newTestUDP(network, *crypto.MustGenerateRandomAddress(), 10000)
+	defer u.close()
+
+	// a full bucket with a dead node as the least recently seen, and an alive node next to it
+	dead := newTableNode(u.table, "127.0.0.1")
+	dead.UDPPort = 10001
+	u.addNode(dead)
+
+	alive := newTableNode(u.table, "127.0.0.1")
+	alive.UDPPort = 10002
+	u.addNode(alive)
+	aliveUDP := newTestUDP(network, alive.ID, alive.UDPPort)
+	defer aliveUDP.close()
+
+	for i := 2; i < bucketSize; i++ {
+		u.addNode(newTableNode(u.table, "127.0.0.1"))
+	}
+
+	replacement := newTableNode(u.table, "127.0.0.1")
+	u.addNode(replacement)
+	if _, ok := u.db.FindByNodeID(replacement.ID); ok {
+		t.Fatal("replacement node should not be published")
+	}
+
+	// the dead node is replaced after ping timeout
+	u.revalidate()
+	waitFor(t, func() bool {
+		_, ok := u.db.FindByNodeID(replacement.ID)
+		return ok
+	}, "dead node is not replaced")
+
+	if _, ok := u.db.FindByNodeID(dead.ID); ok {
+		t.Fatal("dead node should be deleted")
+	}
+
+	// the alive node responds and becomes the most recently seen
+	if u.table.nodeToRevalidate() != alive {
+		t.Fatal("alive node should be the least recently seen")
+	}
+
+	u.revalidate()
+	b := u.table.bucket(alive.getSha())
+	waitFor(t, func() bool { return b.findNode(alive) == bucketSize-1 }, "alive node is not marked as seen")
+
+	if b.size() != bucketSize {
+		t.Fatalf("expected full bucket, got %d", b.size())
+	}
+}
+
+func Test_UDP_Refresh(t *testing.T) {
+	network := newFakeNet()
+	bootstrap := newTestUDP(network, *crypto.MustGenerateRandomAddress(), 10000)
+	defer bootstrap.close()
+
+	u := newTestUDP(network, *crypto.MustGenerateRandomAddress(), 10001)
+	defer u.close()
+	u.bootstrap = []*Node{bootstrap.self}
+
+	// the bootstrap node is added again when the table is empty, and the
+	// lookups make the local node known to the bootstrap node
+	u.refresh()
+	if _, ok := u.db.FindByNodeID(bootstrap.self.ID); !ok {
+		t.Fatal("bootstrap node should be added")
+	}
+
+	waitFor(t, func() bool {
+		_, ok := bootstrap.db.FindByNodeID(u.self.ID)
+		return ok
+	}, "local node is not found by the bootstrap node")
+}
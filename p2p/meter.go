@@ -0,0 +1,291 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package p2p
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/seeleteam/go-seele/metrics"
+	"github.com/seeleteam/go-seele/p2p/qvic"
+)
+
+const (
+	meterStep  = 100 // step of speed meters in milliseconds
+	meterSteps = 10  // steps of speed meters, step * steps = 1 second
+
+	// minUploadRate is the minimum upload rate limit in bytes per second,
+	// as the token bucket produces tokens by milliseconds.
+	minUploadRate = 1024
+
+	// limiterWaitInterval is the interval to check the tokens again when the token bucket is empty.
+	limiterWaitInterval = 10 * time.Millisecond
+
+	// ctlProtocolName is the protocol name of control messages in traffic statistics.
+	ctlProtocolName = "p2p"
+)
+
+// MsgTraffic is the traffic statistics of a message code.
+type MsgTraffic struct {
+	Protocol string `json:"protocol"` // protocol name, p2p for control messages
+	Code     uint16 `json:"code"`     // message code relative to the protocol
+	InMsgs   uint64 `json:"inMsgs"`
+	InBytes  uint64 `json:"inBytes"`
+	OutMsgs  uint64 `json:"outMsgs"`
+	OutBytes uint64 `json:"outBytes"`
+}
+
+// Traffic is the traffic statistics of a peer or the whole server. The total bytes are
+// counted on the wire, including the message head and compressed payload, while the
+// bytes per message code are the message head and uncompressed payload.
+type Traffic struct {
+	InMsgs   uint64       `json:"inMsgs"`
+	InBytes  uint64       `json:"inBytes"`
+	OutMsgs  uint64       `json:"outMsgs"`
+	OutBytes uint64       `json:"outBytes"`
+	InRate   uint         `json:"inRate"`  // received bytes in the last second
+	OutRate  uint         `json:"outRate"` // sent bytes in the last second
+	Msgs     []MsgTraffic `json:"msgs"`    // statistics per message code
+}
+
+// PeerTraffic is the traffic statistics of a connected peer.
+type PeerTraffic struct {
+	ID         string `json:"id"`
+	RemoteAddr string `json:"remoteAddr"`
+	Traffic
+}
+
+// msgKey is the protocol name and the message code relative to the protocol. The
+// absolute message codes are not used, as they depend on the protocols matched by the peer.
+type msgKey struct {
+	protocol string
+	code     uint16
+}
+
+type codeCounter struct {
+	inMsgs, inBytes, outMsgs, outBytes uint64
+}
+
+// The metric families of the bytes per message code.
+const (
+	inBytesMetric  = "p2p_in_bytes_total"
+	inBytesHelp    = "the bytes received by protocol and message code"
+	outBytesMetric = "p2p_out_bytes_total"
+	outBytesHelp   = "the bytes sent by protocol and message code"
+)
+
+// msgMetrics is the byte counters per protocol and message code exported as metrics.
+// The counters of the control messages and the codes of the protocols are registered
+// in advance, and the messages of other codes are not exported, so that the peers
+// could not register metrics.
+type msgMetrics struct {
+	in, out map[msgKey]*metrics.Counter
+}
+
+func newMsgMetrics(protocols []Protocol) *msgMetrics {
+	m := &msgMetrics{
+		in:  make(map[msgKey]*metrics.Counter),
+		out: make(map[msgKey]*metrics.Counter),
+	}
+
+	register := func(protocol string, length uint16) {
+		for code := uint16(0); code < length; code++ {
+			key := msgKey{protocol, code}
+			m.in[key] = msgBytesCounter(inBytesMetric, inBytesHelp, key)
+			m.out[key] = msgBytesCounter(outBytesMetric, outBytesHelp, key)
+		}
+	}
+
+	register(ctlProtocolName, baseProtoCode)
+	for _, p := range protocols {
+		register(p.Name, p.Length)
+	}
+
+	return m
+}
+
+// msgBytesCounter returns the counter of the metric family labeled by the protocol and message code.
+func msgBytesCounter(family string, help string, key msgKey) *metrics.Counter {
+	name := metrics.Name(family, "protocol", key.protocol, "code", strconv.Itoa(int(key.code)))
+	return metrics.GetOrRegisterCounter(name, help)
+}
+
+// trafficMeter counts the messages and bytes on the wire, and the messages and bytes
+// per message code. The counts are also added to the parent meter if any, e.g. the
+// meter of the server.
+type trafficMeter struct {
+	lock     sync.Mutex
+	total    codeCounter // messages and bytes on the wire
+	msgs     map[msgKey]*codeCounter
+	inRate   *qvic.SpeedMeter
+	outRate  *qvic.SpeedMeter
+	parent   *trafficMeter
+	exported *msgMetrics // the bytes per message code exported as metrics, nil if not exported
+}
+
+func newTrafficMeter(parent *trafficMeter) *trafficMeter {
+	return &trafficMeter{
+		msgs:    make(map[msgKey]*codeCounter),
+		inRate:  qvic.NewSpeedMeter(meterStep, meterSteps),
+		outRate: qvic.NewSpeedMeter(meterStep, meterSteps),
+		parent:  parent,
+	}
+}
+
+func (m *trafficMeter) counter(key msgKey) *codeCounter {
+	c := m.msgs[key]
+	if c == nil {
+		c = &codeCounter{}
+		m.msgs[key] = c
+	}
+
+	return c
+}
+
+// markIn records a message received on the wire, it does nothing on a nil meter.
+func (m *trafficMeter) markIn(size int) {
+	for ; m != nil; m = m.parent {
+		m.lock.Lock()
+		m.total.inMsgs++
+		m.total.inBytes += uint64(size)
+		m.lock.Unlock()
+
+		m.inRate.Feed(uint(size))
+	}
+}
+
+// markOut records a message sent on the wire, it does nothing on a nil meter.
+func (m *trafficMeter) markOut(size int) {
+	for ; m != nil; m = m.parent {
+		m.lock.Lock()
+		m.total.outMsgs++
+		m.total.outBytes += uint64(size)
+		m.lock.Unlock()
+
+		m.outRate.Feed(uint(size))
+	}
+}
+
+// markMsgIn records a received message of the protocol, it does nothing on a nil meter.
+// The bytes are exported as metrics by the meter of the server.
+func (m *trafficMeter) markMsgIn(protocol string, code uint16, size int) {
+	key := msgKey{protocol, code}
+	for ; m != nil; m = m.parent {
+		m.lock.Lock()
+		c := m.counter(key)
+		c.inMsgs++
+		c.inBytes += uint64(size)
+		m.lock.Unlock()
+
+		if m.exported != nil {
+			if counter := m.exported.in[key]; counter != nil {
+				counter.Inc(int64(size))
+			}
+		}
+	}
+}
+
+// markMsgOut records a sent message of the protocol, it does nothing on a nil meter.
+// The bytes are exported as metrics by the meter of the server.
+func (m *trafficMeter) markMsgOut(protocol string, code uint16, size int) {
+	key := msgKey{protocol, code}
+	for ; m != nil; m = m.parent {
+		m.lock.Lock()
+		c := m.counter(key)
+		c.outMsgs++
+		c.outBytes += uint64(size)
+		m.lock.Unlock()
+
+		if m.exported != nil {
+			if counter := m.exported.out[key]; counter != nil {
+				counter.Inc(int64(size))
+			}
+		}
+	}
+}
+
+// traffic returns the statistics, the messages are sorted by protocol name and code.
+func (m *trafficMeter) traffic() Traffic {
+	var t Traffic
+	if m == nil {
+		return t
+	}
+
+	m.lock.Lock()
+	t.InMsgs, t.InBytes = m.total.inMsgs, m.total.inBytes
+	t.OutMsgs, t.OutBytes = m.total.outMsgs, m.total.outBytes
+
+	for key, c := range m.msgs {
+		t.Msgs = append(t.Msgs, MsgTraffic{
+			Protocol: key.protocol,
+			Code:     key.code,
+			InMsgs:   c.inMsgs,
+			InBytes:  c.inBytes,
+			OutMsgs:  c.outMsgs,
+			OutBytes: c.outBytes,
+		})
+	}
+	m.lock.Unlock()
+
+	sort.Slice(t.Msgs, func(i, j int) bool {
+		if t.Msgs[i].Protocol != t.Msgs[j].Protocol {
+			return t.Msgs[i].Protocol < t.Msgs[j].Protocol
+		}
+
+		return t.Msgs[i].Code < t.Msgs[j].Code
+	})
+
+	t.InRate = m.inRate.GetRate()
+	t.OutRate = m.outRate.GetRate()
+
+	return t
+}
+
+// rateLimiter limits the bandwidth with a token bucket, it is safe for concurrent use.
+type rateLimiter struct {
+	lock   sync.Mutex
+	bucket qvic.TokenBucket
+}
+
+// newRateLimiter returns a rate limiter, or nil if bytesPerSecond is not positive.
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+
+	if bytesPerSecond < minUploadRate {
+		bytesPerSecond = minUploadRate
+	}
+
+	l := &rateLimiter{}
+	l.bucket.Init(bytesPerSecond)
+
+	return l
+}
+
+// wait blocks until there are tokens in the bucket, then consumes size tokens. The bucket
+// is allowed to go into debt, so that a message larger than the bucket is not starved.
+// It returns immediately on a nil limiter.
+func (l *rateLimiter) wait(size int) {
+	if l == nil {
+		return
+	}
+
+	for {
+		l.lock.Lock()
+		l.bucket.PeriodicFeed()
+		if l.bucket.GetCurTokens() > 0 {
+			l.bucket.Consume(int64(size))
+			l.lock.Unlock()
+			return
+		}
+		l.lock.Unlock()
+
+		time.Sleep(limiterWaitInterval)
+	}
+}
@@ -0,0 +1,146 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package p2p
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seeleteam/go-seele/metrics"
+)
+
+func Test_TrafficMeter(t *testing.T) {
+	parent := newTrafficMeter(nil)
+	c1, c2 := newTestConnPair(false)
+	defer c1.close()
+	defer c2.close()
+	c1.meter, c2.meter = newTrafficMeter(parent), newTrafficMeter(parent)
+
+	done := make(chan struct{})
+	go func() {
+		c1.WriteMsg(Message{Code: ctlMsgPingCode})
+		c1.WriteMsg(Message{Code: baseProtoCode + 2, Payload: make([]byte, 10)})
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c2.ReadMsg(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+
+	sent := c1.meter.traffic()
+	if sent.OutMsgs != 2 || sent.OutBytes != 2*headBuffLegth+10 || sent.InMsgs != 0 {
+		t.Fatalf("invalid sent traffic %+v", sent)
+	}
+
+	recved := c2.meter.traffic()
+	if recved.InMsgs != 2 || recved.InBytes != sent.OutBytes || recved.OutMsgs != 0 {
+		t.Fatalf("invalid recved traffic %+v", recved)
+	}
+
+	total := parent.traffic()
+	if total.InBytes != sent.OutBytes || total.OutBytes != sent.OutBytes {
+		t.Fatalf("invalid total traffic %+v", total)
+	}
+}
+
+func Test_TrafficMeter_ProtocolRW(t *testing.T) {
+	parent := newTrafficMeter(nil)
+	proto := Protocol{Name: "b", Length: 3}
+
+	// the protocol is at different offsets of the message codes on the two connections
+	for _, offset := range []uint16{baseProtoCode, baseProtoCode + 5} {
+		c1, c2 := newTestConnPair(false)
+		meter := newTrafficMeter(parent)
+		c1.meter = meter
+
+		rw := &protocolRW{Protocol: proto, offset: offset, rw: c1, in: make(chan Message, 1), meter: meter}
+		go c2.ReadMsg()
+		if err := rw.WriteMsg(Message{Code: 1, Payload: make([]byte, 10)}); err != nil {
+			t.Fatal(err)
+		}
+
+		rw.in <- Message{Code: offset + 2}
+		if _, err := rw.ReadMsg(); err != nil {
+			t.Fatal(err)
+		}
+
+		msgs := meter.traffic().Msgs
+		if len(msgs) != 2 || msgs[0] != (MsgTraffic{"b", 1, 0, 0, 1, headBuffLegth + 10}) || msgs[1] != (MsgTraffic{"b", 2, 1, headBuffLegth, 0, 0}) {
+			t.Fatalf("invalid msg traffic %+v", msgs)
+		}
+
+		c1.close()
+		c2.close()
+	}
+
+	// the messages of the protocol are counted by the relative code regardless of the offsets
+	msgs := parent.traffic().Msgs
+	if len(msgs) != 2 || msgs[0].Code != 1 || msgs[0].OutMsgs != 2 || msgs[1].Code != 2 || msgs[1].InMsgs != 2 {
+		t.Fatalf("invalid total msg traffic %+v", msgs)
+	}
+}
+
+func Test_MsgMetrics(t *testing.T) {
+	protocols := []Protocol{{Name: "metrics_test", Length: 3}}
+	parent := newTrafficMeter(nil)
+	parent.exported = newMsgMetrics(protocols)
+	meter := newTrafficMeter(parent)
+
+	// the counters of the control messages and the protocol codes are registered in advance
+	exported := func() string {
+		var buf bytes.Buffer
+		if err := metrics.WritePrometheus(&buf, metrics.DefaultRegistry); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	output := exported()
+	for _, name := range []string{
+		`p2p_in_bytes_total{protocol="p2p",code="0"}`,
+		`p2p_out_bytes_total{protocol="metrics_test",code="2"}`,
+	} {
+		if !strings.Contains(output, name) {
+			t.Fatalf("metric %s is not registered", name)
+		}
+	}
+
+	inBytes := parent.exported.in[msgKey{"metrics_test", 1}].Count()
+	meter.markMsgIn("metrics_test", 1, 5)
+	if n := parent.exported.in[msgKey{"metrics_test", 1}].Count() - inBytes; n != 5 {
+		t.Fatalf("invalid exported bytes %d", n)
+	}
+
+	// the codes out of the protocols are not exported
+	meter.markMsgIn("metrics_test", 3, 5)
+	meter.markMsgIn("unknown", 0, 5)
+	if output = exported(); strings.Contains(output, `protocol="metrics_test",code="3"`) || strings.Contains(output, `"unknown"`) {
+		t.Fatal("the codes out of the protocols should not be exported")
+	}
+}
+
+func Test_RateLimiter(t *testing.T) {
+	if newRateLimiter(0) != nil {
+		t.Fatal("limiter should be nil for unlimited rate")
+	}
+
+	l := newRateLimiter(100 * 1024)
+	start := time.Now()
+
+	// the first 100KB is in the bucket, the next 50KB takes about half a second.
+	for i := 0; i < 150; i++ {
+		l.wait(1024)
+	}
+
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond || elapsed > 3*time.Second {
+		t.Fatalf("unexpected elapsed time %v", elapsed)
+	}
+}
@@ -8,7 +8,9 @@ package p2p
 import (
 	"errors"
 	"fmt"
+	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/seeleteam/go-seele/log"
@@ -19,8 +21,21 @@ const (
 	pingInterval         = 15 * time.Second // ping interval for peer tcp connection. Should be 15
 	discAlreadyConnected = 10               // node already has connection
 	discServerQuit       = 11               // p2p.server need quit, all peers should quit as it can
+	discTooManyPeers     = 12               // the number of peers reaches MaxPeers
+	discRequested        = 13               // disconnection requested by admin
 )
 
+// PeerInfo is the connection information of a peer.
+type PeerInfo struct {
+	ID         string   `json:"id"`
+	RemoteAddr string   `json:"remoteAddr"`
+	Inbound    bool     `json:"inbound"`
+	Trusted    bool     `json:"trusted"`
+	Caps       []string `json:"caps"`
+	Connected  int64    `json:"connected"` // connected duration in seconds
+	Latency    int64    `json:"latency"`   // round trip time of the last ping in milliseconds, 0 if not measured
+}
+
 // Peer represents a connected remote node.
 type Peer struct {
 	protocolErr   chan error
@@ -28,38 +43,74 @@ type Peer struct {
 	Node          *discovery.Node // remote peer that this peer connects
 	disconnection chan uint
 	protocolMap   map[string]protocolRW // protocol cap => protocol read write wrapper
+	protocols     []Protocol            // protocols in the order of message code offsets
 	rw            *connection
 
+	inbound bool      // whether the connection is accepted from remote
+	caps    []Cap     // caps of the remote peer in handshake
+	created time.Time // time the peer is created
+
+	trusted  int32 // 1 if the peer is trusted, accessed atomically
+	pingSent int64 // unix nano time of the last ping sent, accessed atomically
+	latency  int64 // round trip time of the last ping in nanoseconds, accessed atomically
+
 	wg  sync.WaitGroup
 	log *log.SeeleLog
 }
 
 func NewPeer(conn *connection, protocols []Protocol, log *log.SeeleLog, node *discovery.Node) *Peer {
-	closed := make(chan struct{})
+	p := &Peer{
+		rw:            conn,
+		disconnection: make(chan uint),
+		closed:        make(chan struct{}),
+		log:           log,
+		protocolErr:   make(chan error),
+		Node:          node,
+		created:       time.Now(),
+	}
+
+	p.setProtocols(protocols)
+
+	return p
+}
+
+// NewPeerWithConn creates a peer over the established connection, skipping the handshake
+// and node discovery of Server. It is used to simulate networks over in-memory connections,
+// the protocols are matched with the caps of the remote node.
+func NewPeerWithConn(fd net.Conn, protocols []Protocol, caps []Cap, log *log.SeeleLog, node *discovery.Node) *Peer {
+	p := NewPeer(&connection{fd: fd}, protocols, log, node)
+	p.caps = caps
+	p.setProtocols(matchProtocols(protocols, caps))
+
+	return p
+}
+
+// setProtocols assigns the message code offsets to the protocols in order, it must be
+// called before the peer runs.
+func (p *Peer) setProtocols(protocols []Protocol) {
 	offset := baseProtoCode
 	protoMap := make(map[string]protocolRW)
-	for _, p := range protocols {
+	for _, proto := range protocols {
 		protoRW := protocolRW{
-			rw:       conn,
+			rw:       p.rw,
 			offset:   offset,
-			Protocol: p,
+			Protocol: proto,
 			in:       make(chan Message, 1),
-			close:closed,
+			close:    p.closed,
+			meter:    p.rw.meter,
 		}
 
-		protoMap[p.cap().String()] = protoRW
-		offset += p.Length
+		protoMap[proto.cap().String()] = protoRW
+		offset += proto.Length
 	}
 
-	return &Peer{
-		rw:            conn,
-		protocolMap:   protoMap,
-		disconnection: make(chan uint),
-		closed:        closed,
-		log:           log,
-		protocolErr:   make(chan error),
-		Node:          node,
-	}
+	p.protocolMap = protoMap
+	p.protocols = protocols
+}
+
+// Run runs the peer until it is disconnected, and returns the error.
+func (p *Peer) Run() error {
+	return p.run()
 }
 
 // run assumes that SubProtocol will never quit, otherwise proto.DelPeerCh may be closed before peer.run quits?
@@ -95,8 +146,12 @@ errLoop:
 }
 
 func (p *Peer) close() {
+	// p.disconnection is not closed, as Disconnect may send on it concurrently,
+	// the senders are unblocked by p.closed.
 	close(p.closed)
-	close(p.disconnection)
+
+	// unblock the read loop, and notify the remote side
+	p.rw.close()
 }
 
 func (p *Peer) pingLoop() {
@@ -106,6 +161,7 @@ func (p *Peer) pingLoop() {
 	for {
 		select {
 		case <-ping.C:
+			atomic.StoreInt64(&p.pingSent, time.Now().UnixNano())
 			p.sendCtlMsg(ctlMsgPingCode)
 			ping.Reset(pingInterval)
 		case <-p.closed:
@@ -145,11 +201,15 @@ func (p *Peer) notifyProtocols() {
 func (p *Peer) handle(msgRecv Message) error {
 	// control msg
 	if msgRecv.Code < baseProtoCode {
+		p.rw.meter.markMsgIn(ctlProtocolName, msgRecv.Code, msgSize(msgRecv))
+
 		switch {
 		case msgRecv.Code == ctlMsgPingCode:
 			go p.sendCtlMsg(ctlMsgPongCode)
 		case msgRecv.Code == ctlMsgPongCode:
-			//p.log.Debug("peer handle Ping msg.")
+			if sent := atomic.LoadInt64(&p.pingSent); sent > 0 {
+				atomic.StoreInt64(&p.latency, time.Now().UnixNano()-sent)
+			}
 			return nil
 		case msgRecv.Code == ctlMsgDiscCode:
 			return fmt.Errorf("error=%d", ctlMsgDiscCode)
@@ -182,11 +242,58 @@ func (p *Peer) sendCtlMsg(msgCode uint16) error {
 		Code: msgCode,
 	}
 
-	p.rw.WriteMsg(hsMsg)
+	if err := p.rw.WriteMsg(hsMsg); err != nil {
+		return err
+	}
+
+	p.rw.meter.markMsgOut(ctlProtocolName, msgCode, msgSize(hsMsg))
 
 	return nil
 }
 
+// Info returns the connection information of the peer.
+func (p *Peer) Info() PeerInfo {
+	info := PeerInfo{
+		RemoteAddr: p.rw.fd.RemoteAddr().String(),
+		Inbound:    p.inbound,
+		Trusted:    p.isTrusted(),
+		Connected:  int64(time.Since(p.created) / time.Second),
+		Latency:    atomic.LoadInt64(&p.latency) / int64(time.Millisecond),
+	}
+
+	if p.Node != nil {
+		info.ID = p.Node.ID.ToHex()
+	}
+
+	for _, cap := range p.caps {
+		info.Caps = append(info.Caps, cap.String())
+	}
+
+	return info
+}
+
+func (p *Peer) setTrusted() {
+	atomic.StoreInt32(&p.trusted, 1)
+}
+
+func (p *Peer) isTrusted() bool {
+	return atomic.LoadInt32(&p.trusted) == 1
+}
+
+// Traffic returns the traffic statistics of the peer.
+func (p *Peer) Traffic() PeerTraffic {
+	t := PeerTraffic{
+		RemoteAddr: p.rw.fd.RemoteAddr().String(),
+		Traffic:    p.rw.meter.traffic(),
+	}
+
+	if p.Node != nil {
+		t.ID = p.Node.ID.ToHex()
+	}
+
+	return t
+}
+
 // Disconnect terminates the peer connection with the given reason.
 // It returns immediately and does not wait until the connection is closed.
 func (p *Peer) Disconnect(reason uint) {
@@ -201,7 +308,8 @@ type protocolRW struct {
 	offset uint16
 	in     chan Message // read message channel, message will be transferred here when it is a protocol message
 	rw     MsgReadWriter
-	close chan struct{}
+	close  chan struct{}
+	meter  *trafficMeter // counts the messages by the relative code, nil for not metered
 }
 
 func (rw *protocolRW) WriteMsg(msg Message) (err error) {
@@ -209,18 +317,31 @@ func (rw *protocolRW) WriteMsg(msg Message) (err error) {
 		return errors.New("invalid msg code")
 	}
 
+	code := msg.Code
 	msg.Code += rw.offset
 
-	return rw.rw.WriteMsg(msg)
+	if err = rw.rw.WriteMsg(msg); err != nil {
+		return err
+	}
+
+	rw.meter.markMsgOut(rw.Name, code, msgSize(msg))
+
+	return nil
 }
 
 func (rw *protocolRW) ReadMsg() (Message, error) {
 	select {
 	case msg := <-rw.in:
 		msg.Code -= rw.offset
+		rw.meter.markMsgIn(rw.Name, msg.Code, msgSize(msg))
 
 		return msg, nil
 	case <-rw.close:
 		return Message{}, errors.New("peer connection closed")
 	}
 }
+
+// msgSize returns the size of the message head and uncompressed payload.
+func msgSize(msg Message) int {
+	return headBuffLegth + len(msg.Payload)
+}
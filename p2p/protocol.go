@@ -7,6 +7,7 @@ package p2p
 
 import (
 	"fmt"
+	"sort"
 )
 
 const (
@@ -14,6 +15,9 @@ const (
 	ctlProtoCode  uint16 = 1  //control protoCode. For example, handshake ping pong message etc
 )
 
+// snappyCap is advertised in handshake if the node supports snappy compression of message payloads.
+var snappyCap = Cap{"snappy", 1}
+
 //Protocol base class for high level transfer protocol.
 type Protocol struct {
 	// Name should contain the official protocol name,
@@ -46,3 +50,31 @@ type Cap struct {
 func (cap Cap) String() string {
 	return fmt.Sprintf("%s/%d", cap.Name, cap.Version)
 }
+
+// hasCap returns true if the specified cap is in the cap list.
+func hasCap(caps []Cap, cap Cap) bool {
+	for _, c := range caps {
+		if c == cap {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchProtocols returns the protocols whose caps are supported by the remote peer. They are
+// sorted by the cap names, so that both sides assign the same message code offsets.
+func matchProtocols(protocols []Protocol, caps []Cap) []Protocol {
+	var matched []Protocol
+	for _, proto := range protocols {
+		if hasCap(caps, proto.cap()) {
+			matched = append(matched, proto)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].cap().String() < matched[j].cap().String()
+	})
+
+	return matched
+}
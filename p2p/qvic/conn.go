@@ -0,0 +1,938 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package qvic
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aristanetworks/goarista/monotime"
+)
+
+const (
+	packTypeData   byte = 0 // stream data, acked and retransmitted
+	packTypeFEC    byte = 1 // xor of data packets in a bundle, seq is the first seq of the bundle
+	packTypeAck    byte = 2 // seq is the next expected seq, data contains sack bitmap and receive window
+	packTypeSyn    byte = 3 // sent by the dialing side to open a connection
+	packTypeSynAck byte = 4 // reply of packTypeSyn
+	packTypeFin    byte = 5 // connection is closed, seq is the final seq of the stream
+	packTypePing   byte = 6 // keeps the connection alive
+
+	maxPacketSize  = 1500                   // max size of an udp package
+	maxSegmentSize = 1200                   // max stream data in a data packet
+	fecBundleLen   = 16                     // number of data packets in a FEC bundle
+	fecSourceLen   = maxSegmentSize + 2     // data packet with 2 bytes length prefix for xor
+	fecFlagFirst   = byte(0x80)             // fec flag bit of the first fec packet in a bundle
+	ackDataLen     = 12                     // sack bitmap (8 bytes) and receive window (4 bytes)
+	sackBits       = 64                     // number of packets after the acked seq in sack bitmap
+	maxWindow      = 1024                   // max packets in flight, and the size of reorder window
+	maxSendQueue   = 1024                   // max segments waiting to be sent
+	initCwnd       = 16                     // initial congestion window in packets
+	minCwnd        = 4                      // min congestion window in packets
+	dupThresh      = 3                      // packets sacked after a lost one before fast retransmit
+	tickInterval   = 10 * time.Millisecond  // interval of the timer loop of a connection
+	initRTO        = 500 * time.Millisecond // retransmission timeout before rtt is measured
+	minRTO         = 100 * time.Millisecond // lower bound of retransmission timeout
+	maxRTO         = 3 * time.Second        // upper bound of retransmission timeout
+	synInterval    = 200 * time.Millisecond // interval to resend syn when dialing
+	keepalive      = 5 * time.Second        // ping is sent if nothing is sent in the duration
+	idleTimeout    = 30 * time.Second       // connection is closed if nothing is received in the duration
+	lingerTimeout  = 3 * time.Second        // max time to wait for data being acked when closing
+	speedMeterStep = 100                    // step of speed meters in milliseconds
+	speedMeterNum  = 10                     // steps of speed meters, step * num = 1 second
+
+	networkName = "qvic" // network name used in errors
+	opRead      = "read"
+	opWrite     = "write"
+)
+
+var (
+	errConnClosed     = errors.New("qvic connection closed")
+	errConnReset      = errors.New("qvic connection reset by peer")
+	errConnIdle       = errors.New("qvic connection idle timeout")
+	errDialTimeout    = errors.New("qvic dial timeout")
+	errListenerClosed = errors.New("qvic listener closed")
+
+	// fecHelper is shared by all connections, it is not modified after initialized.
+	fecHelper = newFECHelper()
+)
+
+// Config holds the options of qvic connections.
+type Config struct {
+	// FECPackets is the number of FEC packets sent per bundle of 16 data packets.
+	// Zero disables FEC. The max value is 8.
+	FECPackets int
+
+	// MaxBandwidth is the max bytes per second sent by a connection. Zero means unlimited.
+	MaxBandwidth int64
+}
+
+// DefaultConfig returns the default configuration of qvic connections.
+func DefaultConfig() *Config {
+	return &Config{
+		FECPackets: 2,
+	}
+}
+
+// Stats is the statistic information of a connection
+type Stats struct {
+	SendRate     uint          // bytes sent in the last second
+	RecvRate     uint          // bytes received in the last second
+	RTT          time.Duration // smoothed round trip time
+	Cwnd         int           // congestion window in packets
+	Retransmits  uint64        // number of retransmitted data packets
+	FECRecovered uint64        // number of data packets recovered by FEC
+}
+
+// sendItem is a data packet that is sent but not acked yet
+type sendItem struct {
+	seq       uint32
+	data      []byte
+	lastSend  time.Time
+	sendTimes int
+}
+
+// fecGroup records the received packets of a bundle to recover the lost ones.
+type fecGroup struct {
+	info *FECInfo
+	data [fecBundleLen][]byte
+}
+
+// timeoutError is returned when a deadline is exceeded
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// Conn is a reliable, congestion-controlled and FEC-protected stream connection over UDP.
+// It implements net.Conn, so that p2p connections can use it the same way as tcp connections.
+// Data is split into data packets with sequence numbers, which are acked with cumulative acks
+// and sack bitmaps. Lost packets are recovered by FEC packets sent after each bundle of data
+// packets, or retransmitted on fast retransmit and retransmission timeout.
+type Conn struct {
+	ep     *endpoint
+	raddr  net.Addr
+	magic  uint16 // connection id, chosen by the dialing side
+	config *Config
+
+	mutex         sync.Mutex
+	established   bool
+	establishedCh chan struct{}
+	closed        bool
+	closeErr      error
+	closeCh       chan struct{}
+	readNotify    chan struct{}
+	writeNotify   chan struct{}
+	readDeadline  time.Time
+	writeDeadline time.Time
+	lastSend      time.Time
+	lastRecv      time.Time
+
+	// send side
+	sndNext    uint32 // next seq to send
+	sndUna     uint32 // first seq not acked
+	inflight   map[uint32]*sendItem
+	sendQueue  [][]byte
+	peerWnd    uint32
+	cwnd       float64
+	ssthresh   float64
+	inRecovery bool
+	recoverSeq uint32
+	srtt       time.Duration
+	rttvar     time.Duration
+	rto        time.Duration
+	fecBundle  [fecBundleLen][]byte
+	bucket     *TokenBucket
+
+	// receive side
+	rcvNext    uint32
+	rcvBuf     map[uint32][]byte // out of order packets
+	readBuf    []byte
+	fecGroups  map[uint32]*fecGroup // bundle number => group
+	ackPending int
+	echoTick   uint16
+	finRecved  bool
+	finSeq     uint32
+
+	sendMeter    *SpeedMeter
+	recvMeter    *SpeedMeter
+	retransmits  uint64
+	fecRecovered uint64
+}
+
+func newConn(ep *endpoint, raddr net.Addr, magic uint16, established bool) *Conn {
+	now := time.Now()
+	c := &Conn{
+		ep:            ep,
+		raddr:         raddr,
+		magic:         magic,
+		config:        ep.config,
+		established:   established,
+		establishedCh: make(chan struct{}),
+		closeCh:       make(chan struct{}),
+		readNotify:    make(chan struct{}, 1),
+		writeNotify:   make(chan struct{}, 1),
+		lastSend:      now,
+		lastRecv:      now,
+		inflight:      make(map[uint32]*sendItem),
+		peerWnd:       maxWindow,
+		cwnd:          initCwnd,
+		ssthresh:      maxWindow,
+		rto:           initRTO,
+		rcvBuf:        make(map[uint32][]byte),
+		fecGroups:     make(map[uint32]*fecGroup),
+		sendMeter:     NewSpeedMeter(speedMeterStep, speedMeterNum),
+		recvMeter:     NewSpeedMeter(speedMeterStep, speedMeterNum),
+	}
+
+	if established {
+		close(c.establishedCh)
+	}
+
+	if c.config.MaxBandwidth > 0 {
+		c.bucket = new(TokenBucket)
+		c.bucket.Init(c.config.MaxBandwidth)
+	}
+
+	return c
+}
+
+func newFECHelper() *FECHelper {
+	h := new(FECHelper)
+	h.Init(fecBundleLen)
+	return h
+}
+
+// tickNow returns the current tick in milliseconds, used for rtt measurement.
+func tickNow() uint16 {
+	return uint16(monotime.Now() / uint64(time.Millisecond))
+}
+
+// checksum returns the checksum of the packet on the net, which covers both the head and
+// the data. The crc field of the head is taken as zero.
+func checksum(packet []byte) uint16 {
+	h := crc32.NewIEEE()
+	h.Write(packet[:5])
+	h.Write([]byte{0, 0})
+	h.Write(packet[7:])
+	return uint16(h.Sum32())
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// start runs the timer loop of the connection
+func (c *Conn) start() {
+	go c.loop()
+}
+
+func (c *Conn) loop() {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.onTick()
+		case <-c.closeCh:
+			return
+		}
+	}
+}
+
+// handshake sends syn until the syn-ack is received or timeout.
+func (c *Conn) handshake(timeout time.Duration) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(synInterval)
+	defer ticker.Stop()
+
+	for {
+		c.mutex.Lock()
+		c.output(packTypeSyn, 0, 0, nil)
+		c.mutex.Unlock()
+
+		select {
+		case <-c.establishedCh:
+			return nil
+		case <-c.closeCh:
+			return errConnClosed
+		case <-deadline.C:
+			return errDialTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+// output sends a packet to the remote side. It should be called with c.mutex locked.
+func (c *Conn) output(packType byte, seq uint32, fecIdx int, data []byte) {
+	p := &VPacket{
+		seq:             seq,
+		packType:        packType,
+		fecIdx:          fecIdx,
+		magic:           c.magic,
+		lastSeqSendTick: c.echoTick,
+		createTick:      tickNow(),
+		data:            data,
+		dataLen:         uint(len(data)),
+	}
+	p.MarshalData()
+
+	size := int(VPacketHeadLen) + len(data)
+	binary.BigEndian.PutUint16(p.dataNet[5:7], checksum(p.dataNet[:size]))
+	c.ep.pc.WriteTo(p.dataNet[:size], c.raddr)
+	c.lastSend = time.Now()
+	c.sendMeter.Feed(uint(size))
+	if c.bucket != nil {
+		c.bucket.Consume(int64(size))
+	}
+}
+
+// handlePacket handles a packet received from the remote side.
+func (c *Conn) handlePacket(p *VPacket) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	c.lastRecv = time.Now()
+	c.recvMeter.Feed(p.dataLen + VPacketHeadLen)
+
+	if !c.established && p.packType != packTypeSyn {
+		// any packet of the connection means the syn is accepted, even if the syn-ack is lost.
+		c.established = true
+		close(c.establishedCh)
+	}
+
+	switch p.packType {
+	case packTypeData:
+		c.onData(p.seq, p.data, p.createTick)
+	case packTypeFEC:
+		c.onFEC(p)
+	case packTypeAck:
+		c.onAck(p)
+	case packTypeSyn:
+		// the syn-ack is lost, resend it
+		c.output(packTypeSynAck, 0, 0, nil)
+	case packTypeFin:
+		c.finRecved, c.finSeq = true, p.seq
+		notify(c.readNotify)
+		notify(c.writeNotify)
+	}
+}
+
+func (c *Conn) onData(seq uint32, data []byte, tick uint16) {
+	c.echoTick = tick
+	c.ackPending++
+
+	if seq < c.rcvNext || c.rcvBuf[seq] != nil {
+		// duplicated packet, the ack may be lost.
+		c.sendAck()
+		return
+	}
+
+	if seq >= c.rcvNext+maxWindow {
+		return
+	}
+
+	inOrder := seq == c.rcvNext
+	c.receive(seq, data)
+	c.tryRecover(seq / fecBundleLen)
+
+	if !inOrder || c.ackPending >= 2 {
+		// ack immediately if out of order, so that the sender can fast retransmit
+		c.sendAck()
+	}
+}
+
+// receive stores the data packet and delivers in order data to the read buffer
+func (c *Conn) receive(seq uint32, data []byte) {
+	c.rcvBuf[seq] = data
+
+	group := c.getFECGroup(seq / fecBundleLen)
+	group.data[seq%fecBundleLen] = data
+
+	delivered := false
+	for data, ok := c.rcvBuf[c.rcvNext]; ok; data, ok = c.rcvBuf[c.rcvNext] {
+		c.readBuf = append(c.readBuf, data...)
+		delete(c.rcvBuf, c.rcvNext)
+		c.rcvNext++
+		delivered = true
+	}
+
+	if delivered {
+		for bundle := range c.fecGroups {
+			if (bundle+1)*fecBundleLen <= c.rcvNext {
+				delete(c.fecGroups, bundle)
+			}
+		}
+
+		notify(c.readNotify)
+	}
+}
+
+func (c *Conn) getFECGroup(bundle uint32) *fecGroup {
+	group, ok := c.fecGroups[bundle]
+	if !ok {
+		group = &fecGroup{info: NewFECInfo()}
+		group.info.seq = bundle * fecBundleLen
+		c.fecGroups[bundle] = group
+	}
+
+	return group
+}
+
+func (c *Conn) onFEC(p *VPacket) {
+	bundle := p.seq / fecBundleLen
+	if (bundle+1)*fecBundleLen <= c.rcvNext || p.seq >= c.rcvNext+maxWindow || p.fecIdx >= 8 || len(p.data) > fecSourceLen {
+		return
+	}
+
+	group := c.getFECGroup(bundle)
+	group.info.fecPackets[p.fecIdx] = p
+	group.info.fecFlag |= fecFlagFirst >> uint(p.fecIdx)
+	c.tryRecover(bundle)
+}
+
+// tryRecover recovers the lost data packets in the bundle with the received FEC packets.
+func (c *Conn) tryRecover(bundle uint32) {
+	group, ok := c.fecGroups[bundle]
+	if !ok || group.info.fecFlag == 0 {
+		return
+	}
+
+	bits := new(VBitVec)
+	bits.Init(fecBundleLen)
+	bits.ExtFlag = group.info.fecFlag
+	for i := uint(0); i < fecBundleLen; i++ {
+		bits.SetBit(i, group.data[i] != nil)
+	}
+
+	base := bundle * fecBundleLen
+	for vec := fecHelper.GetRecoverInfo(bits); vec != nil; vec = fecHelper.GetRecoverInfo(bits) {
+		buf := make([]byte, fecSourceLen)
+		missing := uint(0)
+		for i := uint(0); i < fecBundleLen; i++ {
+			if !vec.GetBit(i) {
+				continue
+			}
+
+			if !bits.GetBit(i) {
+				missing = i
+				continue
+			}
+
+			xorBytes(buf, encodeFECSource(group.data[i]))
+		}
+
+		for i := uint(0); i < 8; i++ {
+			if vec.GetFlagBit(i) {
+				xorBytes(buf, group.info.fecPackets[i].data)
+			}
+		}
+
+		bits.SetBit(missing, true)
+		size := int(binary.BigEndian.Uint16(buf))
+		if size == 0 || size > maxSegmentSize {
+			// corrupted FEC packets, leave it to retransmission
+			return
+		}
+
+		seq := base + uint32(missing)
+		if seq < c.rcvNext || c.rcvBuf[seq] != nil {
+			continue
+		}
+
+		c.fecRecovered++
+		c.ackPending++
+		c.receive(seq, buf[2:2+size])
+		if _, ok := c.fecGroups[bundle]; !ok {
+			// all packets of the bundle are delivered
+			return
+		}
+	}
+}
+
+// encodeFECSource prefixes the data with its length, so that the recovered data can be truncated.
+func encodeFECSource(data []byte) []byte {
+	buf := make([]byte, 2+len(data))
+	binary.BigEndian.PutUint16(buf, uint16(len(data)))
+	copy(buf[2:], data)
+	return buf
+}
+
+func xorBytes(dst, src []byte) {
+	for i := range src {
+		dst[i] ^= src[i]
+	}
+}
+
+// rcvWindow returns the number of packets can be received.
+func (c *Conn) rcvWindow() uint32 {
+	used := len(c.rcvBuf) + len(c.readBuf)/maxSegmentSize
+	if used >= maxWindow {
+		return 0
+	}
+
+	return uint32(maxWindow - used)
+}
+
+func (c *Conn) sendAck() {
+	data := make([]byte, ackDataLen)
+	var sack uint64
+	for i := uint32(0); i < sackBits; i++ {
+		if _, ok := c.rcvBuf[c.rcvNext+1+i]; ok {
+			sack |= 1 << i
+		}
+	}
+
+	binary.BigEndian.PutUint64(data, sack)
+	binary.BigEndian.PutUint32(data[8:], c.rcvWindow())
+	c.output(packTypeAck, c.rcvNext, 0, data)
+	c.ackPending = 0
+}
+
+func (c *Conn) onAck(p *VPacket) {
+	if len(p.data) < ackDataLen {
+		return
+	}
+
+	ack := p.seq
+	if ack > c.sndNext {
+		return
+	}
+
+	sack := binary.BigEndian.Uint64(p.data)
+	c.peerWnd = binary.BigEndian.Uint32(p.data[8:])
+
+	acked := 0
+	for seq := c.sndUna; seq < ack; seq++ {
+		if _, ok := c.inflight[seq]; ok {
+			delete(c.inflight, seq)
+			acked++
+		}
+	}
+
+	highest := ack
+	for i := uint32(0); i < sackBits; i++ {
+		if sack&(1<<i) == 0 {
+			continue
+		}
+
+		seq := ack + 1 + i
+		if _, ok := c.inflight[seq]; ok {
+			delete(c.inflight, seq)
+			acked++
+		}
+		highest = seq
+	}
+
+	for c.sndUna < c.sndNext {
+		if _, ok := c.inflight[c.sndUna]; ok {
+			break
+		}
+		c.sndUna++
+	}
+
+	if c.inRecovery && c.sndUna >= c.recoverSeq {
+		c.inRecovery = false
+	}
+
+	if acked > 0 {
+		c.updateRTT(time.Duration(tickNow()-p.lastSeqSendTick) * time.Millisecond)
+		c.increaseCwnd(acked)
+	}
+
+	// fast retransmit the packets that are not acked while the later ones are acked
+	now := time.Now()
+	for seq := c.sndUna; seq+dupThresh <= highest; seq++ {
+		item, ok := c.inflight[seq]
+		if !ok || now.Sub(item.lastSend) < c.srtt {
+			continue
+		}
+
+		c.onLoss()
+		c.retransmit(item)
+	}
+
+	c.flush()
+}
+
+func (c *Conn) updateRTT(rtt time.Duration) {
+	if c.srtt == 0 {
+		c.srtt, c.rttvar = rtt, rtt/2
+	} else {
+		delta := c.srtt - rtt
+		if delta < 0 {
+			delta = -delta
+		}
+		c.rttvar = (3*c.rttvar + delta) / 4
+		c.srtt = (7*c.srtt + rtt) / 8
+	}
+
+	c.rto = c.srtt + 4*c.rttvar
+	if c.rto < minRTO {
+		c.rto = minRTO
+	} else if c.rto > maxRTO {
+		c.rto = maxRTO
+	}
+}
+
+// increaseCwnd grows the congestion window with slow start and congestion avoidance.
+func (c *Conn) increaseCwnd(acked int) {
+	if c.cwnd < c.ssthresh {
+		c.cwnd += float64(acked)
+	} else {
+		c.cwnd += float64(acked) / c.cwnd
+	}
+
+	if c.cwnd > maxWindow {
+		c.cwnd = maxWindow
+	}
+}
+
+// onLoss halves the congestion window at most once per window of data.
+func (c *Conn) onLoss() {
+	if c.inRecovery {
+		return
+	}
+
+	c.inRecovery, c.recoverSeq = true, c.sndNext
+	c.ssthresh = c.cwnd / 2
+	if c.ssthresh < minCwnd {
+		c.ssthresh = minCwnd
+	}
+	c.cwnd = c.ssthresh
+}
+
+func (c *Conn) retransmit(item *sendItem) {
+	c.retransmits++
+	c.sendData(item)
+}
+
+func (c *Conn) sendData(item *sendItem) {
+	item.lastSend = time.Now()
+	item.sendTimes++
+	c.output(packTypeData, item.seq, 0, item.data)
+}
+
+// flush sends the queued segments if allowed by congestion window, receive window and bandwidth.
+func (c *Conn) flush() {
+	if !c.established {
+		return
+	}
+
+	for len(c.sendQueue) > 0 {
+		if len(c.inflight) >= int(c.cwnd) || c.sndNext-c.sndUna >= c.peerWnd || c.sndNext-c.sndUna >= maxWindow {
+			break
+		}
+
+		data := c.sendQueue[0]
+		if c.bucket != nil && c.bucket.GetCurTokens() < int64(len(data)) {
+			break
+		}
+
+		c.sendQueue = c.sendQueue[1:]
+		item := &sendItem{seq: c.sndNext, data: data}
+		c.inflight[item.seq] = item
+		c.sndNext++
+		c.sendData(item)
+
+		if c.config.FECPackets > 0 {
+			idx := item.seq % fecBundleLen
+			c.fecBundle[idx] = data
+			if idx == fecBundleLen-1 {
+				c.sendFEC(item.seq - idx)
+			}
+		}
+	}
+
+	if len(c.sendQueue) < maxSendQueue {
+		notify(c.writeNotify)
+	}
+}
+
+// sendFEC sends FEC packets of the bundle which starts from seq.
+func (c *Conn) sendFEC(seq uint32) {
+	num := c.config.FECPackets
+	if num > 8 {
+		num = 8
+	}
+
+	for idx := 0; idx < num; idx++ {
+		vec := fecHelper.canVec[idx]
+		buf := make([]byte, 0, fecSourceLen)
+		for i := uint(0); i < fecBundleLen; i++ {
+			if !vec.GetBit(i) {
+				continue
+			}
+
+			src := encodeFECSource(c.fecBundle[i])
+			if len(src) > len(buf) {
+				buf = buf[:len(src)]
+			}
+			xorBytes(buf, src)
+		}
+
+		c.output(packTypeFEC, seq, idx, buf)
+	}
+}
+
+func (c *Conn) onTick() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(c.lastRecv) > idleTimeout {
+		c.closeLocked(errConnIdle)
+		return
+	}
+
+	// retransmission timeout
+	timeout := false
+	for _, item := range c.inflight {
+		if now.Sub(item.lastSend) >= c.rto {
+			c.retransmit(item)
+			timeout = true
+		}
+	}
+
+	if timeout {
+		c.ssthresh = c.cwnd / 2
+		if c.ssthresh < minCwnd {
+			c.ssthresh = minCwnd
+		}
+		c.cwnd = minCwnd
+		c.rto *= 2
+		if c.rto > maxRTO {
+			c.rto = maxRTO
+		}
+	}
+
+	if c.ackPending > 0 {
+		c.sendAck()
+	}
+
+	if c.bucket != nil {
+		c.bucket.PeriodicFeed()
+	}
+
+	c.flush()
+
+	if now.Sub(c.lastSend) > keepalive {
+		c.output(packTypePing, 0, 0, nil)
+	}
+}
+
+// closeLocked closes the connection with c.mutex locked.
+func (c *Conn) closeLocked(err error) {
+	if c.closed {
+		return
+	}
+
+	c.closed, c.closeErr = true, err
+	close(c.closeCh)
+	go c.ep.remove(c)
+}
+
+// abort closes the connection without notifying the remote side.
+func (c *Conn) abort(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.closeLocked(err)
+}
+
+// wait waits for the notify channel, the connection closed or the deadline exceeded.
+func (c *Conn) wait(ch chan struct{}, deadline time.Time, op string) error {
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return c.opError(op, timeoutError{})
+		}
+
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ch:
+	case <-c.closeCh:
+	case <-timeout:
+		return c.opError(op, timeoutError{})
+	}
+
+	return nil
+}
+
+func (c *Conn) opError(op string, err error) error {
+	return &net.OpError{Op: op, Net: networkName, Source: c.LocalAddr(), Addr: c.raddr, Err: err}
+}
+
+// Read reads data from the connection.
+func (c *Conn) Read(b []byte) (int, error) {
+	for {
+		c.mutex.Lock()
+		if len(c.readBuf) > 0 {
+			wnd := c.rcvWindow()
+			n := copy(b, c.readBuf)
+			c.readBuf = c.readBuf[n:]
+			if wnd < maxWindow/4 && c.rcvWindow() >= maxWindow/4 {
+				// window update, the sender may be blocked by the receive window
+				c.sendAck()
+			}
+			c.mutex.Unlock()
+			return n, nil
+		}
+
+		if c.finRecved && c.rcvNext >= c.finSeq {
+			c.mutex.Unlock()
+			return 0, io.EOF
+		}
+
+		if c.closed {
+			err := c.closeErr
+			if err == nil {
+				err = errConnClosed
+			}
+			c.mutex.Unlock()
+			return 0, c.opError(opRead, err)
+		}
+
+		deadline := c.readDeadline
+		c.mutex.Unlock()
+
+		if err := c.wait(c.readNotify, deadline, opRead); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Write writes data to the connection. It blocks when the send queue is full.
+func (c *Conn) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		c.mutex.Lock()
+		if c.closed || c.finRecved {
+			c.mutex.Unlock()
+			return written, c.opError(opWrite, errConnClosed)
+		}
+
+		for len(c.sendQueue) < maxSendQueue && written < len(b) {
+			n := len(b) - written
+			if n > maxSegmentSize {
+				n = maxSegmentSize
+			}
+
+			seg := make([]byte, n)
+			copy(seg, b[written:written+n])
+			c.sendQueue = append(c.sendQueue, seg)
+			written += n
+		}
+
+		c.flush()
+		deadline := c.writeDeadline
+		c.mutex.Unlock()
+
+		if written < len(b) {
+			if err := c.wait(c.writeNotify, deadline, opWrite); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// Close closes the connection. It waits the sent data being acked for at most lingerTimeout.
+func (c *Conn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	linger := time.Now().Add(lingerTimeout)
+	for !c.closed && !c.finRecved && (len(c.sendQueue) > 0 || len(c.inflight) > 0) && time.Now().Before(linger) {
+		c.mutex.Unlock()
+		time.Sleep(tickInterval)
+		c.mutex.Lock()
+	}
+
+	if c.closed {
+		return nil
+	}
+
+	c.output(packTypeFin, c.sndNext, 0, nil)
+	c.output(packTypeFin, c.sndNext, 0, nil)
+	c.closeLocked(nil)
+	return nil
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.ep.pc.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.raddr
+}
+
+// SetDeadline sets the read and write deadlines.
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	c.SetWriteDeadline(t)
+	return nil
+}
+
+// SetReadDeadline sets the deadline for future Read calls and any currently-blocked Read call.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mutex.Lock()
+	c.readDeadline = t
+	c.mutex.Unlock()
+	notify(c.readNotify)
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for future Write calls and any currently-blocked Write call.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.mutex.Lock()
+	c.writeDeadline = t
+	c.mutex.Unlock()
+	notify(c.writeNotify)
+	return nil
+}
+
+// Stats returns the statistic information of the connection.
+func (c *Conn) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return Stats{
+		SendRate:     c.sendMeter.GetRate(),
+		RecvRate:     c.recvMeter.GetRate(),
+		RTT:          c.srtt,
+		Cwnd:         int(c.cwnd),
+		Retransmits:  c.retransmits,
+		FECRecovered: c.fecRecovered,
+	}
+}
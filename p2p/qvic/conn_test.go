@@ -0,0 +1,192 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package qvic
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lossyPacketConn drops the sent packets with the loss rate
+type lossyPacketConn struct {
+	net.PacketConn
+	lossRate float64
+
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+func newLossyPacketConn(t *testing.T, lossRate float64) *lossyPacketConn {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &lossyPacketConn{
+		PacketConn: pc,
+		lossRate:   lossRate,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (pc *lossyPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	pc.lock.Lock()
+	drop := pc.rand.Float64() < pc.lossRate
+	pc.lock.Unlock()
+
+	if drop {
+		return len(b), nil
+	}
+
+	return pc.PacketConn.WriteTo(b, addr)
+}
+
+func newTestConnPair(t *testing.T, lossRate float64) (*Conn, *Conn, *Listener) {
+	listener := NewListener(newLossyPacketConn(t, lossRate), DefaultConfig())
+	client, err := DialWith(newLossyPacketConn(t, lossRate), listener.Addr(), DefaultConfig(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, server.(*Conn), listener
+}
+
+func getRandomBytes(size int) []byte {
+	buf := make([]byte, size)
+	rand.Read(buf)
+	return buf
+}
+
+func testTransfer(t *testing.T, sender *Conn, recver *Conn, size int) {
+	data := getRandomBytes(size)
+	go func() {
+		if _, err := sender.Write(data); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	recver.SetReadDeadline(time.Now().Add(30 * time.Second))
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(recver, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf, data) {
+		t.Fatal("received data mismatch")
+	}
+}
+
+func Test_Conn_ReadWrite(t *testing.T) {
+	client, server, listener := newTestConnPair(t, 0)
+	defer listener.Close()
+	defer client.Close()
+
+	testTransfer(t, client, server, 1024*1024)
+	testTransfer(t, server, client, 1024*1024)
+}
+
+func Test_Conn_PacketLoss(t *testing.T) {
+	client, server, listener := newTestConnPair(t, 0.1)
+	defer listener.Close()
+	defer client.Close()
+
+	testTransfer(t, client, server, 512*1024)
+	testTransfer(t, server, client, 512*1024)
+
+	if client.Stats().Retransmits == 0 || server.Stats().Retransmits == 0 {
+		t.Fatal("lost packets should be retransmitted")
+	}
+
+	if client.Stats().FECRecovered == 0 || server.Stats().FECRecovered == 0 {
+		t.Fatal("lost packets should be recovered by FEC")
+	}
+}
+
+func Test_Conn_Close(t *testing.T) {
+	client, server, listener := newTestConnPair(t, 0)
+	defer listener.Close()
+
+	data := getRandomBytes(100 * 1024)
+	go func() {
+		client.Write(data)
+		client.Close()
+	}()
+
+	server.SetReadDeadline(time.Now().Add(10 * time.Second))
+	buf := make([]byte, len(data))
+	if _, err := io.ReadFull(server, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := server.Read(buf); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+
+	if _, err := server.Write(buf); err == nil {
+		t.Fatal("expected error when writing to closed connection")
+	}
+}
+
+func Test_Conn_ReadDeadline(t *testing.T) {
+	client, server, listener := newTestConnPair(t, 0)
+	defer listener.Close()
+	defer client.Close()
+
+	server.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, err := server.Read(make([]byte, 1))
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+}
+
+func Test_Dial_Timeout(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	// nobody accepts connections on pc
+	if _, err = Dial(pc.LocalAddr().String(), 300*time.Millisecond); err != errDialTimeout {
+		t.Fatalf("expected errDialTimeout, got %v", err)
+	}
+}
+
+func Test_Checksum(t *testing.T) {
+	p := &VPacket{seq: 101, packType: packTypeData, magic: 120, data: []byte("payload")}
+	p.MarshalData()
+
+	packet := p.dataNet[:int(VPacketHeadLen)+len(p.data)]
+	crc := checksum(packet)
+
+	// the crc field is not covered
+	packet[5], packet[6] = byte(crc>>8), byte(crc)
+	if checksum(packet) != crc {
+		t.Fatal("checksum changed by the crc field")
+	}
+
+	// the head is covered as well as the data
+	packet[0]++
+	if checksum(packet) == crc {
+		t.Fatal("checksum not changed by the head")
+	}
+
+	packet[0]--
+	packet[len(packet)-1]++
+	if checksum(packet) == crc {
+		t.Fatal("checksum not changed by the data")
+	}
+}
@@ -0,0 +1,226 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package qvic
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+// acceptBacklog is the max number of connections waiting to be accepted
+const acceptBacklog = 128
+
+// endpoint dispatches the packets received from an udp socket to connections by the remote address.
+type endpoint struct {
+	pc     net.PacketConn
+	config *Config
+
+	lock     sync.Mutex
+	conns    map[string]*Conn // remote address => connection
+	acceptCh chan *Conn       // nil for the dialing side, which owns the socket with a single connection
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newEndpoint(pc net.PacketConn, config *Config, accept bool) *endpoint {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	ep := &endpoint{
+		pc:     pc,
+		config: config,
+		conns:  make(map[string]*Conn),
+		closed: make(chan struct{}),
+	}
+
+	if accept {
+		ep.acceptCh = make(chan *Conn, acceptBacklog)
+	}
+
+	go ep.readLoop()
+	return ep
+}
+
+func (ep *endpoint) readLoop() {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := ep.pc.ReadFrom(buf)
+		if err != nil {
+			if tempErr, ok := err.(interface{ Temporary() bool }); ok && tempErr.Temporary() {
+				continue
+			}
+
+			ep.close()
+			return
+		}
+
+		if n < int(VPacketHeadLen) {
+			continue
+		}
+
+		p := new(VPacket)
+		p.ParseData(buf[:n])
+		if p.crc != checksum(buf[:n]) {
+			continue
+		}
+
+		ep.dispatch(p, addr)
+	}
+}
+
+func (ep *endpoint) dispatch(p *VPacket, addr net.Addr) {
+	key := addr.String()
+	ep.lock.Lock()
+	c := ep.conns[key]
+	if p.packType == packTypeSyn && ep.acceptCh != nil && (c == nil || c.magic != p.magic) {
+		stale := c
+		c = newConn(ep, addr, p.magic, true)
+		select {
+		case ep.acceptCh <- c:
+			ep.conns[key] = c
+		default:
+			// backlog is full, the dialing side will retry
+			ep.lock.Unlock()
+			return
+		}
+		ep.lock.Unlock()
+
+		if stale != nil {
+			// the remote side reconnects with a new connection
+			stale.abort(errConnReset)
+		}
+
+		c.start()
+		c.mutex.Lock()
+		c.output(packTypeSynAck, 0, 0, nil)
+		c.mutex.Unlock()
+		return
+	}
+	ep.lock.Unlock()
+
+	if c != nil && c.magic == p.magic {
+		c.handlePacket(p)
+	}
+}
+
+// remove removes the closed connection. The socket is closed if it is owned by the connection.
+func (ep *endpoint) remove(c *Conn) {
+	ep.lock.Lock()
+	key := c.raddr.String()
+	if ep.conns[key] == c {
+		delete(ep.conns, key)
+	}
+	ep.lock.Unlock()
+
+	if ep.acceptCh == nil {
+		ep.close()
+	}
+}
+
+func (ep *endpoint) close() {
+	ep.closeOnce.Do(func() {
+		close(ep.closed)
+		ep.pc.Close()
+
+		ep.lock.Lock()
+		conns := make([]*Conn, 0, len(ep.conns))
+		for _, c := range ep.conns {
+			conns = append(conns, c)
+		}
+		ep.lock.Unlock()
+
+		for _, c := range conns {
+			c.abort(errConnClosed)
+		}
+	})
+}
+
+// Listener accepts qvic connections on an udp socket. It implements net.Listener.
+type Listener struct {
+	ep *endpoint
+}
+
+// Listen announces on the local udp address.
+func Listen(network, address string) (*Listener, error) {
+	pc, err := net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewListener(pc, DefaultConfig()), nil
+}
+
+// NewListener creates a Listener on the packet connection.
+func NewListener(pc net.PacketConn, config *Config) *Listener {
+	return &Listener{newEndpoint(pc, config, true)}
+}
+
+// Accept waits for and returns the next connection to the listener.
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.ep.acceptCh:
+		return c, nil
+	case <-l.ep.closed:
+		return nil, errListenerClosed
+	}
+}
+
+// Close closes the listener and all the accepted connections.
+func (l *Listener) Close() error {
+	l.ep.close()
+	return nil
+}
+
+// Addr returns the listener's network address.
+func (l *Listener) Addr() net.Addr {
+	return l.ep.pc.LocalAddr()
+}
+
+// Dial connects to the qvic listener on the udp address.
+func Dial(address string, timeout time.Duration) (*Conn, error) {
+	raddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	pc, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		return nil, err
+	}
+
+	return DialWith(pc, raddr, DefaultConfig(), timeout)
+}
+
+// DialWith connects to the qvic listener with the packet connection, which is owned
+// by the returned connection and closed when the connection is closed.
+func DialWith(pc net.PacketConn, raddr net.Addr, config *Config, timeout time.Duration) (*Conn, error) {
+	var magic uint16
+	for magic == 0 {
+		if err := binary.Read(rand.Reader, binary.BigEndian, &magic); err != nil {
+			pc.Close()
+			return nil, err
+		}
+	}
+
+	ep := newEndpoint(pc, config, false)
+	c := newConn(ep, raddr, magic, false)
+	ep.lock.Lock()
+	ep.conns[raddr.String()] = c
+	ep.lock.Unlock()
+	c.start()
+
+	if err := c.handshake(timeout); err != nil {
+		c.abort(err)
+		return nil, err
+	}
+
+	return c, nil
+}
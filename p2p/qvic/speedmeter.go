@@ -7,6 +7,7 @@ package qvic
 
 import (
 	"sync"
+	"time"
 
 	"github.com/aristanetworks/goarista/monotime"
 )
@@ -16,6 +17,11 @@ const (
 	MilliInSec uint64 = 1000
 )
 
+// nowMillis returns the monotonic time in milliseconds
+func nowMillis() uint64 {
+	return monotime.Now() / uint64(time.Millisecond)
+}
+
 // speedMeterSubItem records amount in a step
 type speedMeterSubItem struct {
 	tick   uint64
@@ -43,7 +49,7 @@ func NewSpeedMeter(step uint64, items uint) (s *SpeedMeter) {
 
 // Feed called when bytes received from network
 func (s *SpeedMeter) Feed(num uint) {
-	cur := monotime.Now() / MilliInSec
+	cur := nowMillis()
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	s.paveToTick(cur)
@@ -54,7 +60,7 @@ func (s *SpeedMeter) Feed(num uint) {
 
 // GetRate gets rate
 func (s *SpeedMeter) GetRate() uint {
-	cur := monotime.Now() / MilliInSec
+	cur := nowMillis()
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	s.paveToTick(cur)
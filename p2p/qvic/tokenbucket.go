@@ -7,8 +7,6 @@ package qvic
 
 import (
 	"sync"
-
-	"github.com/aristanetworks/goarista/monotime"
 )
 
 // TokenBucket for bucket limit rate
@@ -26,7 +24,7 @@ type TokenBucket struct {
 func (t *TokenBucket) Init(bytesPerSecond int64) {
 	t.initCommon(bytesPerSecond)
 	t.curTokens = t.maxTokens
-	t.preTick = monotime.Now() / 1000
+	t.preTick = nowMillis()
 }
 
 // AdjustBW adjusts bandwidth anytime
@@ -42,7 +40,7 @@ func (t *TokenBucket) PeriodicFeed() {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
-	cur := monotime.Now() / 1000
+	cur := nowMillis()
 	i := t.curTokens + int64(cur-t.preTick)*t.tokensPerMS
 	if i > t.maxTokens {
 		t.curTokens = t.maxTokens
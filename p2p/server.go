@@ -24,7 +24,9 @@ import (
 	"github.com/seeleteam/go-seele/crypto/ecies"
 	"github.com/seeleteam/go-seele/crypto/secp256k1"
 	"github.com/seeleteam/go-seele/log"
+	"github.com/seeleteam/go-seele/metrics"
 	"github.com/seeleteam/go-seele/p2p/discovery"
+	"github.com/seeleteam/go-seele/p2p/qvic"
 )
 
 const (
@@ -50,8 +52,22 @@ const (
 
 	// In transfering handshake msg, length of extra data
 	hsExtraDataLen = 32
+
+	// TransportTCP uses tcp connections between peers, it is the default transport.
+	TransportTCP = "tcp"
+
+	// TransportQvic uses qvic connections, the reliable udp transport, between peers.
+	TransportQvic = "qvic"
+
+	// defaultQvicPortOffset the qvic transport listens on the udp port next to ListenAddr
+	// by default, as the udp port of ListenAddr is used by node discovery.
+	defaultQvicPortOffset = 1
 )
 
+var errServerNotRunning = errors.New("p2p server is not running")
+
+var peerGauge = metrics.GetOrRegisterGauge("p2p_peers", "the number of the connected peers")
+
 // Config holds Server options.
 type Config struct {
 	// Name node's name
@@ -71,14 +87,35 @@ type Config struct {
 	// Zero defaults to preset values.
 	MaxPendingPeers int `toml:",omitempty"`
 
-	// pre-configured nodes.
+	// pre-configured nodes, which are kept connected.
 	StaticNodes []*discovery.Node
 
+	// TrustedNodes are kept connected and always allowed past the peer limits.
+	TrustedNodes []*discovery.Node `toml:",omitempty"`
+
 	// Protocols should contain the protocols supported by the server.
 	Protocols []Protocol `toml:"-"`
 
 	// p2p.server will listen for incoming tcp connections. And it is for udp address used for Kad protocol
 	ListenAddr string
+
+	// Transport is the transport of peer connections, TransportTCP or TransportQvic.
+	// Empty defaults to TransportTCP.
+	Transport string `toml:",omitempty"`
+
+	// QvicPortOffset is the offset of the udp port of the qvic transport to the port of
+	// ListenAddr, which must not be 0 as the port of ListenAddr is used by node discovery.
+	// The peers dial the discovery port plus the offset, so it must be the same in the network.
+	// Zero defaults to 1.
+	QvicPortOffset int `toml:",omitempty"`
+
+	// MaxUploadRate is the maximum upload bandwidth of all peers in bytes per second.
+	// Zero means unlimited.
+	MaxUploadRate int64 `toml:",omitempty"`
+
+	// MaxPeerUploadRate is the maximum upload bandwidth of each peer in bytes per second.
+	// Zero means unlimited.
+	MaxPeerUploadRate int64 `toml:",omitempty"`
 }
 
 // Server manages all p2p peer connections.
@@ -98,16 +135,150 @@ type Server struct {
 	delpeer chan *Peer
 	loopWG  sync.WaitGroup // loop, listenLoop
 
-	peers map[common.Address]*Peer
-	log   *log.SeeleLog
+	peerLock sync.RWMutex // protects peers and trusted
+	peers    map[common.Address]*Peer
+	trusted  map[common.Address]bool // trusted nodes added by admin, which are always allowed past MaxPeers
+	log      *log.SeeleLog
+
+	dialer        *dialScheduler
+	meter         *trafficMeter // traffic of all peers
+	uploadLimiter *rateLimiter  // upload limiter of all peers, nil for unlimited
+}
+
+// NodeInfo is the information of the local node.
+type NodeInfo struct {
+	ID         string   `json:"id"`
+	URL        string   `json:"url"`        // node url with the discovery address
+	ListenAddr string   `json:"listenAddr"` // udp address of discovery
+	PeerAddr   string   `json:"peerAddr"`   // address accepting peer connections
+	Transport  string   `json:"transport"`
+	Protocols  []string `json:"protocols"`
 }
 
 // PeerCount return the count of peers
 func (srv *Server) PeerCount() int {
-	if srv.peers != nil {
-		return len(srv.peers)
+	srv.peerLock.RLock()
+	defer srv.peerLock.RUnlock()
+
+	return len(srv.peers)
+}
+
+// Peers returns the connected peers.
+func (srv *Server) Peers() []*Peer {
+	srv.peerLock.RLock()
+	defer srv.peerLock.RUnlock()
+
+	peers := make([]*Peer, 0, len(srv.peers))
+	for _, p := range srv.peers {
+		peers = append(peers, p)
+	}
+
+	return peers
+}
+
+// AddPeer adds the node as a static node, which is connected in background and kept connected.
+func (srv *Server) AddPeer(node *discovery.Node) error {
+	if !srv.isRunning() {
+		return errServerNotRunning
+	}
+
+	srv.dialer.addNode(node, true, false)
+	return nil
+}
+
+// AddTrustedPeer marks the node as trusted, and keeps it connected in background.
+// Trusted peers are always allowed past the peer limits.
+func (srv *Server) AddTrustedPeer(node *discovery.Node) error {
+	if !srv.isRunning() {
+		return errServerNotRunning
+	}
+
+	srv.peerLock.Lock()
+	srv.trusted[node.ID] = true
+	if p, ok := srv.peers[node.ID]; ok {
+		p.setTrusted()
+	}
+	srv.peerLock.Unlock()
+
+	srv.dialer.addNode(node, false, true)
+	return nil
+}
+
+// RemovePeer disconnects the node and removes it from the static and trusted nodes, so that
+// it is not re-dialed.
+// It returns true if the node is connected.
+func (srv *Server) RemovePeer(node *discovery.Node) (bool, error) {
+	if !srv.isRunning() {
+		return false, errServerNotRunning
+	}
+
+	srv.dialer.removeStatic(node.ID)
+
+	srv.peerLock.Lock()
+	delete(srv.trusted, node.ID)
+	p, ok := srv.peers[node.ID]
+	srv.peerLock.Unlock()
+
+	if ok {
+		p.Disconnect(discRequested)
 	}
-	return 0
+
+	return ok, nil
+}
+
+// NodeInfo returns the information of the local node.
+func (srv *Server) NodeInfo() NodeInfo {
+	info := NodeInfo{
+		ID:         srv.MyNodeID,
+		ListenAddr: srv.ListenAddr,
+		Transport:  srv.Transport,
+	}
+
+	if addr, err := net.ResolveUDPAddr("udp", srv.ListenAddr); err == nil {
+		info.URL = discovery.NewNodeWithAddr(common.HexMustToAddres(srv.MyNodeID), addr).String()
+	}
+
+	if srv.listener != nil {
+		info.PeerAddr = srv.listener.Addr().String()
+	}
+
+	for _, proto := range srv.Protocols {
+		info.Protocols = append(info.Protocols, proto.cap().String())
+	}
+
+	return info
+}
+
+func (srv *Server) isRunning() bool {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	return srv.running
+}
+
+func (srv *Server) isTrusted(id common.Address) bool {
+	srv.peerLock.RLock()
+	defer srv.peerLock.RUnlock()
+
+	return srv.trusted[id]
+}
+
+// Traffic returns the traffic statistics of all peers since the server started.
+func (srv *Server) Traffic() Traffic {
+	return srv.meter.traffic()
+}
+
+// PeersTraffic returns the traffic statistics of the connected peers.
+func (srv *Server) PeersTraffic() []PeerTraffic {
+	srv.peerLock.RLock()
+	defer srv.peerLock.RUnlock()
+
+	traffics := make([]PeerTraffic, 0, len(srv.peers))
+	for _, p := range srv.peers {
+		traffics = append(traffics, p.Traffic())
+	}
+
+	return traffics
 }
 
 // Start starts running the server.
@@ -126,8 +297,24 @@ func (srv *Server) Start() (err error) {
 		srv.MaxPeers = defaultMaxPeers
 	}
 
+	if srv.Transport == "" {
+		srv.Transport = TransportTCP
+	}
+
+	if srv.Transport != TransportTCP && srv.Transport != TransportQvic {
+		return fmt.Errorf("unsupported p2p transport %s", srv.Transport)
+	}
+
+	if srv.QvicPortOffset == 0 {
+		srv.QvicPortOffset = defaultQvicPortOffset
+	}
+
 	srv.running = true
 	srv.peers = make(map[common.Address]*Peer)
+	srv.trusted = make(map[common.Address]bool)
+	srv.meter = newTrafficMeter(nil)
+	srv.meter.exported = newMsgMetrics(srv.Protocols)
+	srv.uploadLimiter = newRateLimiter(srv.MaxUploadRate)
 
 	srv.log.Info("Starting P2P networking...")
 	srv.quit = make(chan struct{})
@@ -141,7 +328,22 @@ func (srv *Server) Start() (err error) {
 	}
 	srv.log.Info("p2p.Server.Start: MyNodeID [%s][%s]", srv.MyNodeID, addr)
 	srv.kadDB = discovery.StartService(common.HexMustToAddres(srv.MyNodeID), addr, srv.StaticNodes)
-	srv.kadDB.SetHookForNewNode(srv.addNode)
+	srv.dialer = newDialScheduler(srv.maxPendingPeers(), maxOutboundPeers(srv.MaxPeers))
+	for _, node := range srv.StaticNodes {
+		srv.dialer.addNode(node, true, false)
+	}
+
+	for _, node := range srv.TrustedNodes {
+		srv.trusted[node.ID] = true
+		srv.dialer.addNode(node, false, true)
+	}
+
+	srv.kadDB.SetHookForNewNode(func(node *discovery.Node) {
+		srv.dialer.addNode(node, false, false)
+	})
+	srv.kadDB.SetHookForDeleteNode(func(node *discovery.Node) {
+		srv.dialer.removeNode(node.ID)
+	})
 
 	if err := srv.startListening(); err != nil {
 		return err
@@ -153,21 +355,32 @@ func (srv *Server) Start() (err error) {
 	return nil
 }
 
+// maxPendingPeers returns the max number of handshaking connections for each direction.
+func (srv *Server) maxPendingPeers() int {
+	if srv.MaxPendingPeers > 0 {
+		return srv.MaxPendingPeers
+	}
+
+	return maxAcceptConns
+}
+
+// runDialTask connects to the node of the dial task, and reports the result to the dial scheduler.
+func (srv *Server) runDialTask(task *dialTask) {
+	srv.addNode(task.node)
+	srv.dialer.done(task, time.Now())
+}
+
 func (srv *Server) addNode(node *discovery.Node) {
+	srv.peerLock.RLock()
 	_, ok := srv.peers[node.ID]
+	srv.peerLock.RUnlock()
 	if ok {
 		return
 	}
 
-	//TODO UDPPort==> TCPPort
-	addr, _ := net.ResolveTCPAddr("tcp4", fmt.Sprintf("%s:%d", node.IP.String(), node.UDPPort))
-	srv.log.Info("connecting to a new node... %s", addr.String())
-	conn, err := net.DialTimeout("tcp", addr.String(), defaultDialTimeout)
+	conn, err := srv.dial(node)
 	if err != nil {
-		if conn != nil {
-			conn.Close()
-		}
-
+		srv.log.Info("dial to node %s failed. %s", node.ID.ToHex(), err)
 		return
 	}
 
@@ -176,33 +389,112 @@ func (srv *Server) addNode(node *discovery.Node) {
 	}
 }
 
+// checkPeer returns the disconnection reason and false if the new peer is not allowed.
+// Trusted peers are always allowed past the limits of MaxPeers and inbound/outbound slots.
+func (srv *Server) checkPeer(p *Peer, peers map[common.Address]*Peer) (uint, bool) {
+	if _, ok := peers[p.Node.ID]; ok {
+		return discAlreadyConnected, false
+	}
+
+	if srv.isTrusted(p.Node.ID) {
+		p.setTrusted()
+		return 0, true
+	}
+
+	if len(peers) >= srv.MaxPeers {
+		return discTooManyPeers, false
+	}
+
+	inbound, outbound := countPeers(peers)
+	maxOutbound := maxOutboundPeers(srv.MaxPeers)
+	maxInbound := srv.MaxPeers - maxOutbound
+	if maxInbound < 1 {
+		maxInbound = 1
+	}
+
+	if p.inbound && inbound >= maxInbound || !p.inbound && outbound >= maxOutbound {
+		return discTooManyPeers, false
+	}
+
+	return 0, true
+}
+
+// countPeers returns the number of inbound and outbound peers, trusted peers are not counted.
+func countPeers(peers map[common.Address]*Peer) (inbound int, outbound int) {
+	for _, p := range peers {
+		if p.isTrusted() {
+			continue
+		}
+
+		if p.inbound {
+			inbound++
+		} else {
+			outbound++
+		}
+	}
+
+	return inbound, outbound
+}
+
+// dial connects to the node with the configured transport.
+func (srv *Server) dial(node *discovery.Node) (net.Conn, error) {
+	if srv.Transport == TransportQvic {
+		addr := fmt.Sprintf("%s:%d", node.IP.String(), int(node.UDPPort)+srv.QvicPortOffset)
+		srv.log.Info("connecting to a new node with qvic... %s", addr)
+		return qvic.Dial(addr, defaultDialTimeout)
+	}
+
+	//TODO UDPPort==> TCPPort
+	addr, _ := net.ResolveTCPAddr("tcp4", fmt.Sprintf("%s:%d", node.IP.String(), node.UDPPort))
+	srv.log.Info("connecting to a new node... %s", addr.String())
+	return net.DialTimeout("tcp", addr.String(), defaultDialTimeout)
+}
+
 func (srv *Server) run() {
 	defer srv.loopWG.Done()
 	peers := srv.peers
 	srv.log.Info("p2p start running...")
 
+	dialTicker := time.NewTicker(dialInterval)
+	defer dialTicker.Stop()
+
+	connected := func(id common.Address) bool {
+		_, ok := peers[id]
+		return ok
+	}
+
 running:
 	for {
 		select {
 		case <-srv.quit:
 			// The server was stopped. Run the cleanup logic.
 			break running
+		case <-dialTicker.C:
+			_, outbound := countPeers(peers)
+			for _, task := range srv.dialer.next(time.Now(), connected, outbound) {
+				go srv.runDialTask(task)
+			}
 		case c := <-srv.addpeer:
-			_, ok := peers[c.Node.ID]
-
-			if ok {
-				// node already connected, need close this connection
-				srv.log.Info("server.run  <-srv.addpeer, len(peers)=%d. nodeid already connected", len(peers))
-				c.Disconnect(discAlreadyConnected)
+			if reason, ok := srv.checkPeer(c, peers); !ok {
+				// node already connected or too many peers, need close this connection
+				srv.log.Info("server.run  <-srv.addpeer, len(peers)=%d. peer is not allowed, reason %d", len(peers), reason)
+				c.Disconnect(reason)
 			} else {
+				srv.peerLock.Lock()
 				peers[c.Node.ID] = c
+				srv.peerLock.Unlock()
+				peerGauge.Update(float64(len(peers)))
 				//srv.log.Info("server.run  <-srv.addpeer, len(peers)=%d, len(srv.peers)=%d", len(peers), len(srv.peers))
 				srv.log.Info("server.run  <-srv.addpeer %s", c.Node.ID.ToHex())
 			}
 		case pd := <-srv.delpeer:
 			curPeer, ok := peers[pd.Node.ID]
 			if ok && curPeer == pd {
+				srv.peerLock.Lock()
 				delete(peers, pd.Node.ID)
+				srv.peerLock.Unlock()
+				peerGauge.Update(float64(len(peers)))
+				srv.dialer.peerRemoved(pd.Node.ID, time.Since(pd.created), time.Now())
 				srv.log.Info("server.run delpeer recved. peer match. remove peer. peers num=%d", len(peers))
 			} else {
 				srv.log.Info("server.run delpeer recved. peer not match")
@@ -217,18 +509,53 @@ running:
 
 	for len(peers) > 0 {
 		p := <-srv.delpeer
+		srv.peerLock.Lock()
 		delete(peers, p.Node.ID)
+		srv.peerLock.Unlock()
 	}
+	peerGauge.Update(0)
 }
 
-func (srv *Server) startListening() error {
-	// Launch the TCP listener.
-	listener, err := net.Listen("tcp", srv.ListenAddr)
+// qvicListenAddr returns the udp address of the qvic transport, whose port is of the offset
+// to the discovery port of the listen address.
+func qvicListenAddr(listenAddr string, offset int) (string, error) {
+	addr, err := net.ResolveUDPAddr("udp", listenAddr)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	port := addr.Port + offset
+	if offset == 0 || port <= 0 || port > math.MaxUint16 {
+		return "", fmt.Errorf("invalid qvic port offset %d to the port %d", offset, addr.Port)
 	}
-	laddr := listener.Addr().(*net.TCPAddr)
-	srv.ListenAddr = laddr.String()
+
+	addr.Port = port
+	return addr.String(), nil
+}
+
+func (srv *Server) startListening() error {
+	var listener net.Listener
+	if srv.Transport == TransportQvic {
+		// Launch the qvic listener on the port of the offset to the discovery port.
+		addr, err := qvicListenAddr(srv.ListenAddr, srv.QvicPortOffset)
+		if err != nil {
+			return err
+		}
+
+		if listener, err = qvic.Listen("udp", addr); err != nil {
+			return fmt.Errorf("qvic listen on %s failed, the port may be used by other services, configure another QvicPortOffset, %s", addr, err)
+		}
+	} else {
+		// Launch the TCP listener.
+		var err error
+		if listener, err = net.Listen("tcp", srv.ListenAddr); err != nil {
+			return err
+		}
+
+		laddr := listener.Addr().(*net.TCPAddr)
+		srv.ListenAddr = laddr.String()
+	}
+
 	srv.listener = listener
 	srv.loopWG.Add(1)
 	go srv.listenLoop()
@@ -248,10 +575,7 @@ func (srv *Server) Wait() {
 func (srv *Server) listenLoop() {
 	defer srv.loopWG.Done()
 	// If all slots are taken, no further connections are accepted.
-	tokens := maxAcceptConns
-	if srv.MaxPendingPeers > 0 {
-		tokens = srv.MaxPendingPeers
-	}
+	tokens := srv.maxPendingPeers()
 	slots := make(chan struct{}, tokens)
 	for i := 0; i < tokens; i++ {
 		slots <- struct{}{}
@@ -289,12 +613,25 @@ func (srv *Server) listenLoop() {
 // setupConn Confirm both side are valid peers, have sub-protocols supported by each other
 // Assume the inbound side is server side; outbound side is client side.
 func (srv *Server) setupConn(fd net.Conn, flags int, dialDest *discovery.Node) error {
-	peer := NewPeer(&connection{fd: fd}, srv.Protocols, srv.log, dialDest)
+	conn := &connection{
+		fd:    fd,
+		meter: newTrafficMeter(srv.meter),
+	}
+
+	for _, l := range []*rateLimiter{newRateLimiter(srv.MaxPeerUploadRate), srv.uploadLimiter} {
+		if l != nil {
+			conn.limiters = append(conn.limiters, l)
+		}
+	}
+
+	peer := NewPeer(conn, srv.Protocols, srv.log, dialDest)
+	peer.inbound = flags == inboundConn
 
 	var caps []Cap
 	for _, proto := range srv.Protocols {
 		caps = append(caps, proto.cap())
 	}
+	caps = append(caps, snappyCap)
 
 	recvMsg, nounceCnt, nounceSvr, err := srv.doHandShake(caps, peer, flags, dialDest)
 	if err != nil {
@@ -303,16 +640,14 @@ func (srv *Server) setupConn(fd net.Conn, flags int, dialDest *discovery.Node) e
 	}
 
 	peerCaps, peerNodeID := recvMsg.Caps, recvMsg.NodeID
-	if flags == inboundConn {
-		peerNode, ok := srv.kadDB.FindByNodeID(peerNodeID)
-		if !ok {
-			srv.log.Info("p2p.setupConn conn handshaked, not found nodeID")
-			peer.close()
-			return errors.New("not found nodeID in discovery database!")
-		}
+	peer.caps = peerCaps
+	peer.setProtocols(matchProtocols(srv.Protocols, peerCaps))
 
-		srv.log.Info("p2p.setupConn peerNodeID found in nodeMap. %s", peerNode.ID.ToHex())
-		peer.Node = peerNode
+	// compress message payloads only if both sides support it
+	peer.rw.setSnappy(hasCap(peerCaps, snappyCap))
+	if flags == inboundConn {
+		peer.Node = srv.inboundNode(peerNodeID, fd.RemoteAddr())
+		srv.log.Info("p2p.setupConn inbound peer handshaked. %s", peer.Node.ID.ToHex())
 	}
 
 	srv.log.Debug("p2p.setupConn conn handshaked. nounceCnt=%d nounceSvr=%d peerCaps=%s", nounceCnt, nounceSvr, peerCaps)
@@ -327,6 +662,18 @@ func (srv *Server) setupConn(fd net.Conn, flags int, dialDest *discovery.Node) e
 	return nil
 }
 
+// inboundNode returns the node of the inbound peer, whose node ID is verified by the handshake.
+// The node found by discovery is preferred, otherwise the node is of the remote address, as the
+// verified nodes are not kept by discovery if the buckets of the table are full.
+func (srv *Server) inboundNode(id common.Address, remoteAddr net.Addr) *discovery.Node {
+	if node, ok := srv.kadDB.FindByNodeID(id); ok {
+		return node
+	}
+
+	host, _, _ := net.SplitHostPort(remoteAddr.String())
+	return discovery.NewNode(id, net.ParseIP(host), 0)
+}
+
 // doHandShake Communicate each other
 func (srv *Server) doHandShake(caps []Cap, peer *Peer, flags int, dialDest *discovery.Node) (recvMsg *ProtoHandShake, nounceCnt uint64, nounceSvr uint64, err error) {
 	handshakeMsg := &ProtoHandShake{Caps: caps}
@@ -0,0 +1,85 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package p2p
+
+import (
+	"net"
+	"testing"
+
+	"github.com/seeleteam/go-seele/crypto"
+	"github.com/seeleteam/go-seele/p2p/discovery"
+)
+
+func Test_Server_NotRunning(t *testing.T) {
+	srv := &Server{}
+	node := discovery.NewNode(*crypto.MustGenerateRandomAddress(), net.ParseIP("127.0.0.1"), 39007)
+
+	if err := srv.AddPeer(node); err != errServerNotRunning {
+		t.Fatalf("expected errServerNotRunning, got %v", err)
+	}
+
+	if err := srv.AddTrustedPeer(node); err != errServerNotRunning {
+		t.Fatalf("expected errServerNotRunning, got %v", err)
+	}
+
+	if _, err := srv.RemovePeer(node); err != errServerNotRunning {
+		t.Fatalf("expected errServerNotRunning, got %v", err)
+	}
+
+	if srv.PeerCount() != 0 || len(srv.Peers()) != 0 {
+		t.Fatal("server should have no peers")
+	}
+}
+
+func Test_Peer_Info(t *testing.T) {
+	c1, c2 := newTestConnPair(false)
+	defer c1.close()
+	defer c2.close()
+
+	node := discovery.NewNode(*crypto.MustGenerateRandomAddress(), net.ParseIP("127.0.0.1"), 39007)
+	p := NewPeer(c1, nil, nil, node)
+	p.inbound = true
+	p.caps = []Cap{{"seele", 1}, snappyCap}
+	p.setTrusted()
+
+	info := p.Info()
+	if info.ID != node.ID.ToHex() || !info.Inbound || !info.Trusted {
+		t.Fatalf("invalid peer info %+v", info)
+	}
+
+	if len(info.Caps) != 2 || info.Caps[0] != "seele/1" || info.Caps[1] != "snappy/1" {
+		t.Fatalf("invalid peer caps %v", info.Caps)
+	}
+}
+
+func Test_QvicListenAddr(t *testing.T) {
+	if addr, err := qvicListenAddr("127.0.0.1:39007", 1); err != nil || addr != "127.0.0.1:39008" {
+		t.Fatalf("invalid qvic listen addr %s, %v", addr, err)
+	}
+
+	if addr, err := qvicListenAddr("127.0.0.1:39007", -2); err != nil || addr != "127.0.0.1:39005" {
+		t.Fatalf("invalid qvic listen addr %s, %v", addr, err)
+	}
+
+	// the discovery port is not shared, and the port must be valid
+	for _, offset := range []int{0, 65535, -39007} {
+		if _, err := qvicListenAddr("127.0.0.1:39007", offset); err == nil {
+			t.Fatalf("offset %d should be invalid", offset)
+		}
+	}
+}
+
+func Test_Server_InboundNode(t *testing.T) {
+	srv := &Server{kadDB: discovery.NewDatabase()}
+	id := *crypto.MustGenerateRandomAddress()
+	remoteAddr := &net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: 40001}
+
+	// the verified node not kept by discovery is admitted
+	node := srv.inboundNode(id, remoteAddr)
+	if node.ID != id || !node.IP.Equal(remoteAddr.IP) {
+		t.Fatalf("invalid inbound node %+v", node)
+	}
+}
@@ -0,0 +1,342 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package simulations
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/crypto"
+	"github.com/seeleteam/go-seele/database/leveldb"
+	"github.com/seeleteam/go-seele/event"
+	"github.com/seeleteam/go-seele/log"
+	"github.com/seeleteam/go-seele/miner/pow"
+	"github.com/seeleteam/go-seele/p2p"
+	"github.com/seeleteam/go-seele/p2p/discovery"
+	"github.com/seeleteam/go-seele/seele"
+)
+
+// waitInterval is the interval to check the condition in Network.WaitFor.
+const waitInterval = 10 * time.Millisecond
+
+var (
+	errInvalidNode  = errors.New("invalid node index")
+	errLinkExists   = errors.New("the nodes are already connected")
+	errLinkNotFound = errors.New("the nodes are not connected")
+)
+
+// Node is a simulated node running a full SeeleService on memory databases.
+type Node struct {
+	Name     string
+	ID       common.Address // node id of p2p
+	Coinbase common.Address
+	Key      *ecdsa.PrivateKey // private key of the coinbase to spend the mining rewards
+	Service  *seele.SeeleService
+}
+
+// Network is a simulated network of nodes connected by in-memory links.
+type Network struct {
+	Nodes []*Node
+
+	lock  sync.Mutex
+	links map[[2]int]*link // node indexes in ascending order => link
+	seed  int64            // seed of the next link
+	wg    sync.WaitGroup   // running peers
+	log   *log.SeeleLog
+}
+
+// NewNetwork creates a network of n nodes which are not connected. The seed decides the
+// dropped messages of links, so that a simulation with message loss is reproducible.
+func NewNetwork(n int, seed int64) (*Network, error) {
+	network := &Network{
+		links: make(map[[2]int]*link),
+		seed:  seed,
+		log:   log.GetLogger("simulation", common.PrintLog),
+	}
+
+	for i := 0; i < n; i++ {
+		if _, err := network.AddNode(""); err != nil {
+			network.Close()
+			return nil, err
+		}
+	}
+
+	return network, nil
+}
+
+// AddNode creates a node with the sync mode and adds it into the network, the node is not connected.
+func (network *Network) AddNode(syncMode string) (*Node, error) {
+	network.lock.Lock()
+	defer network.lock.Unlock()
+
+	node, err := newNode(fmt.Sprintf("node%d", len(network.Nodes)), syncMode, network.log)
+	if err != nil {
+		return nil, err
+	}
+
+	network.Nodes = append(network.Nodes, node)
+
+	return node, nil
+}
+
+func newNode(name string, syncMode string, log *log.SeeleLog) (*Node, error) {
+	id := crypto.MustGenerateRandomAddress()
+	coinbase, key, err := crypto.GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+
+	conf := &seele.Config{
+		TxConf:    *core.DefaultTxPoolConfig(),
+		NetworkID: 1,
+		Coinbase:  *coinbase,
+		SyncMode:  syncMode,
+	}
+
+	chainDB, err := leveldb.NewMemDatabase()
+	if err != nil {
+		return nil, err
+	}
+
+	accountStateDB, err := leveldb.NewMemDatabase()
+	if err != nil {
+		chainDB.Close()
+		return nil, err
+	}
+
+	service, err := seele.NewSeeleServiceWithDB(conf, log, chainDB, accountStateDB)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = service.Start(nil); err != nil {
+		service.Stop()
+		return nil, err
+	}
+
+	return &Node{
+		Name:     name,
+		ID:       *id,
+		Coinbase: *coinbase,
+		Key:      key,
+		Service:  service,
+	}, nil
+}
+
+// linkKey returns the key of the link between node i and j, and false if the indexes are invalid.
+func (network *Network) linkKey(i, j int) ([2]int, bool) {
+	if i < 0 || j < 0 || i >= len(network.Nodes) || j >= len(network.Nodes) || i == j {
+		return [2]int{}, false
+	}
+
+	if i > j {
+		i, j = j, i
+	}
+
+	return [2]int{i, j}, true
+}
+
+// Connect connects node i and j with the link quality, and runs the peers of both sides.
+func (network *Network) Connect(i, j int, config LinkConfig) error {
+	key, ok := network.linkKey(i, j)
+	if !ok {
+		return errInvalidNode
+	}
+
+	network.lock.Lock()
+	defer network.lock.Unlock()
+
+	if _, ok := network.links[key]; ok {
+		return errLinkExists
+	}
+
+	n1, n2 := network.Nodes[key[0]], network.Nodes[key[1]]
+	l, c1, c2 := newLink(config, network.seed, n1.Name, n2.Name)
+	network.seed++
+	network.links[key] = l
+
+	network.runPeer(key, l, c1, n1, n2)
+	network.runPeer(key, l, c2, n2, n1)
+
+	return nil
+}
+
+// runPeer runs the peer of the remote node on the local node, the link is closed when the peer quits.
+func (network *Network) runPeer(key [2]int, l *link, conn net.Conn, local, remote *Node) {
+	node := discovery.NewNode(remote.ID, net.IPv4(127, 0, 0, 1), 0)
+	var caps []p2p.Cap
+	for _, proto := range remote.Service.Protocols() {
+		caps = append(caps, p2p.Cap{Name: proto.Name, Version: proto.Version})
+	}
+
+	peer := p2p.NewPeerWithConn(conn, local.Service.Protocols(), caps, network.log, node)
+
+	network.wg.Add(1)
+	go func() {
+		defer network.wg.Done()
+
+		err := peer.Run()
+		network.log.Debug("simulated peer %s of %s quits, %s", remote.Name, local.Name, err)
+
+		l.close()
+		network.lock.Lock()
+		if network.links[key] == l {
+			delete(network.links, key)
+		}
+		network.lock.Unlock()
+	}()
+}
+
+// Disconnect disconnects node i and j.
+func (network *Network) Disconnect(i, j int) error {
+	key, ok := network.linkKey(i, j)
+	if !ok {
+		return errInvalidNode
+	}
+
+	network.lock.Lock()
+	l, ok := network.links[key]
+	delete(network.links, key)
+	network.lock.Unlock()
+
+	if !ok {
+		return errLinkNotFound
+	}
+
+	l.close()
+	return nil
+}
+
+// SetLinkConfig changes the quality of the link between node i and j.
+func (network *Network) SetLinkConfig(i, j int, config LinkConfig) error {
+	key, ok := network.linkKey(i, j)
+	if !ok {
+		return errInvalidNode
+	}
+
+	network.lock.Lock()
+	l, ok := network.links[key]
+	network.lock.Unlock()
+
+	if !ok {
+		return errLinkNotFound
+	}
+
+	l.setConfig(config)
+	return nil
+}
+
+// Connected returns true if node i and j are connected.
+func (network *Network) Connected(i, j int) bool {
+	key, ok := network.linkKey(i, j)
+	if !ok {
+		return false
+	}
+
+	network.lock.Lock()
+	defer network.lock.Unlock()
+
+	_, ok = network.links[key]
+	return ok
+}
+
+// WaitFor checks the condition periodically until it is true or timeout, and returns the last result.
+func (network *Network) WaitFor(condition func() bool, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			return false
+		}
+
+		time.Sleep(waitInterval)
+	}
+
+	return true
+}
+
+// Close disconnects all the nodes and stops the services.
+func (network *Network) Close() {
+	network.lock.Lock()
+	for key, l := range network.links {
+		l.close()
+		delete(network.links, key)
+	}
+	network.lock.Unlock()
+
+	network.wg.Wait()
+
+	for _, node := range network.Nodes {
+		node.Service.Stop()
+	}
+}
+
+// Head returns the current block of the node.
+func (n *Node) Head() *types.Block {
+	block, _ := n.Service.BlockChain().CurrentBlock()
+	return block
+}
+
+// MineBlock creates a block on the current block with the processable transactions in the pool,
+// writes it into the chain and fires the block mined event like the miner. The block difficulty
+// should be small, as the nonce is searched like the miner.
+func (n *Node) MineBlock(difficulty int64) (*types.Block, error) {
+	chain := n.Service.BlockChain()
+	parent, parentState := chain.CurrentBlock()
+	statedb := parentState.GetCopy()
+
+	reward := types.NewTransaction(common.Address{}, n.Coinbase, big.NewInt(pow.MinerRewardAmount), 0)
+	reward.Signature = &crypto.Signature{}
+	statedb.GetOrNewStateObject(n.Coinbase).AddAmount(reward.Data.Amount)
+	txs := []*types.Transaction{reward}
+
+	for _, pending := range n.Service.TxPool().GetProcessableTransactions() {
+		for _, tx := range pending {
+			n.Service.TxPool().RemoveTransaction(tx.Hash)
+			if err := tx.Validate(statedb); err != nil {
+				continue
+			}
+
+			fromStateObj := statedb.GetOrNewStateObject(tx.Data.From)
+			fromStateObj.SubAmount(tx.Data.Amount)
+			fromStateObj.SetNonce(tx.Data.AccountNonce + 1)
+			statedb.GetOrNewStateObject(*tx.Data.To).AddAmount(tx.Data.Amount)
+
+			txs = append(txs, tx)
+		}
+	}
+
+	header := &types.BlockHeader{
+		PreviousBlockHash: parent.HeaderHash,
+		Creator:           n.Coinbase,
+		StateHash:         statedb.Commit(nil),
+		Height:            parent.Header.Height + 1,
+		Difficulty:        big.NewInt(difficulty),
+		CreateTimestamp:   new(big.Int).Add(parent.Header.CreateTimestamp, big.NewInt(1)),
+	}
+
+	block := types.NewBlock(header, txs)
+	target := pow.GetMiningTarget(header.Difficulty)
+	for new(big.Int).SetBytes(block.HeaderHash.Bytes()).Cmp(target) > 0 {
+		block.Header.Nonce++
+		block.HeaderHash = block.Header.Hash()
+	}
+
+	if err := chain.WriteBlock(block); err != nil {
+		return nil, err
+	}
+
+	event.BlockMinedEventManager.Fire(block)
+
+	return block, nil
+}
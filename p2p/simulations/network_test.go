@@ -0,0 +1,430 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package simulations
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/seeleteam/go-seele/core/state"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/crypto"
+	"github.com/seeleteam/go-seele/seele/download"
+)
+
+// syncTimeout is long enough for the forced synchronisation of the protocol.
+const syncTimeout = 20 * time.Second
+
+func newTestNetwork(t *testing.T, n int) *Network {
+	network, err := NewNetwork(n, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return network
+}
+
+func sameHead(nodes ...*Node) func() bool {
+	return func() bool {
+		head := nodes[0].Head().HeaderHash
+		for _, node := range nodes[1:] {
+			if node.Head().HeaderHash != head {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+func peerCount(network *Network, counts ...int) func() bool {
+	return func() bool {
+		for i, count := range counts {
+			if network.Nodes[i].Service.PeerCount() != count {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+func Test_Network_Connect(t *testing.T) {
+	network := newTestNetwork(t, 2)
+	defer network.Close()
+
+	if err := network.Connect(0, 0, LinkConfig{}); err != errInvalidNode {
+		t.Fatalf("expected errInvalidNode, got %v", err)
+	}
+
+	if err := network.Connect(0, 1, LinkConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := network.Connect(1, 0, LinkConfig{}); err != errLinkExists {
+		t.Fatalf("expected errLinkExists, got %v", err)
+	}
+
+	if err := network.Disconnect(0, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := network.Disconnect(0, 1); err != errLinkNotFound {
+		t.Fatalf("expected errLinkNotFound, got %v", err)
+	}
+
+	// the link can be created again after disconnected
+	if err := network.Connect(0, 1, LinkConfig{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Network_BlockPropagation(t *testing.T) {
+	network := newTestNetwork(t, 3)
+	defer network.Close()
+
+	config := LinkConfig{Latency: 10 * time.Millisecond}
+	network.Connect(0, 1, config)
+	network.Connect(0, 2, config)
+	if !network.WaitFor(peerCount(network, 2, 1, 1), syncTimeout) {
+		t.Fatal("nodes are not connected")
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := network.Nodes[0].MineBlock(1); err != nil {
+			t.Fatal(err)
+		}
+
+		// blocks are propagated to peers, instead of the forced synchronisation
+		if !network.WaitFor(sameHead(network.Nodes...), time.Second) {
+			t.Fatalf("block %d is not propagated", i+1)
+		}
+	}
+
+	if height := network.Nodes[2].Head().Header.Height; height != 3 {
+		t.Fatalf("expected height 3, got %d", height)
+	}
+}
+
+func Test_Network_BlockRelay(t *testing.T) {
+	network := newTestNetwork(t, 4)
+	defer network.Close()
+
+	// nodes in a line, the blocks are relayed by the nodes in the middle
+	config := LinkConfig{Latency: 10 * time.Millisecond}
+	for i := 0; i < 3; i++ {
+		network.Connect(i, i+1, config)
+	}
+
+	if !network.WaitFor(peerCount(network, 1, 2, 2, 1), syncTimeout) {
+		t.Fatal("nodes are not connected")
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := network.Nodes[0].MineBlock(1); err != nil {
+			t.Fatal(err)
+		}
+
+		if !network.WaitFor(sameHead(network.Nodes...), time.Second) {
+			t.Fatalf("block %d is not relayed", i+1)
+		}
+	}
+}
+
+func Test_Network_SyncAfterPartition(t *testing.T) {
+	network := newTestNetwork(t, 2)
+	defer network.Close()
+
+	// node 0 mines blocks while it is partitioned from node 1
+	for i := 0; i < 3; i++ {
+		if _, err := network.Nodes[0].MineBlock(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if network.Nodes[1].Head().Header.Height != 0 {
+		t.Fatal("partitioned node should not receive blocks")
+	}
+
+	network.Connect(0, 1, LinkConfig{Latency: 5 * time.Millisecond})
+	if !network.WaitFor(sameHead(network.Nodes...), syncTimeout) {
+		t.Fatal("partitioned node is not synchronised after connected")
+	}
+}
+
+func Test_Network_ForkResolution(t *testing.T) {
+	network := newTestNetwork(t, 2)
+	defer network.Close()
+
+	// both nodes mine blocks while partitioned, and node 1 has the higher total difficulty
+	for i := 0; i < 2; i++ {
+		if _, err := network.Nodes[0].MineBlock(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := network.Nodes[1].MineBlock(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	head := network.Nodes[1].Head().HeaderHash
+	network.Connect(0, 1, LinkConfig{Latency: 5 * time.Millisecond})
+	if !network.WaitFor(sameHead(network.Nodes...), syncTimeout) {
+		t.Fatal("fork is not resolved")
+	}
+
+	if network.Nodes[0].Head().HeaderHash != head {
+		t.Fatal("the chain with the higher total difficulty should win")
+	}
+}
+
+func Test_Network_Disconnect(t *testing.T) {
+	network := newTestNetwork(t, 2)
+	defer network.Close()
+
+	network.Connect(0, 1, LinkConfig{})
+	if !network.WaitFor(peerCount(network, 1, 1), syncTimeout) {
+		t.Fatal("nodes are not connected")
+	}
+
+	network.Disconnect(0, 1)
+	if !network.WaitFor(peerCount(network, 0, 0), syncTimeout) {
+		t.Fatal("peers are not removed after disconnected")
+	}
+
+	// blocks are not propagated after disconnected
+	if _, err := network.Nodes[0].MineBlock(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if network.WaitFor(sameHead(network.Nodes...), 100*time.Millisecond) {
+		t.Fatal("block should not be propagated after disconnected")
+	}
+}
+
+func Test_Network_TxPropagation(t *testing.T) {
+	network := newTestNetwork(t, 4)
+	defer network.Close()
+
+	// fully connected, the transactions are pushed to a peer and announced to the others
+	for i := 0; i < 4; i++ {
+		for j := i + 1; j < 4; j++ {
+			network.Connect(i, j, LinkConfig{Latency: 10 * time.Millisecond})
+		}
+	}
+
+	if !network.WaitFor(peerCount(network, 3, 3, 3, 3), syncTimeout) {
+		t.Fatal("nodes are not connected")
+	}
+
+	sender := network.Nodes[0]
+	if _, err := sender.MineBlock(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if !network.WaitFor(sameHead(network.Nodes...), time.Second) {
+		t.Fatal("block is not relayed")
+	}
+
+	var txs []*types.Transaction
+	for nonce := uint64(0); nonce < 5; nonce++ {
+		tx := types.NewTransaction(sender.Coinbase, *crypto.MustGenerateRandomAddress(), big.NewInt(1), nonce)
+		tx.Sign(sender.Key)
+		if err := sender.Service.TxPool().AddTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+
+		txs = append(txs, tx)
+	}
+
+	inPools := network.WaitFor(func() bool {
+		for _, node := range network.Nodes {
+			for _, tx := range txs {
+				if node.Service.TxPool().GetTransaction(tx.Hash) == nil {
+					return false
+				}
+			}
+		}
+
+		return true
+	}, 2*time.Second)
+
+	if !inPools {
+		t.Fatal("transactions are not propagated")
+	}
+}
+
+func Test_Network_CompactBlockRelay(t *testing.T) {
+	network := newTestNetwork(t, 3)
+	defer network.Close()
+
+	config := LinkConfig{Latency: 10 * time.Millisecond}
+	network.Connect(0, 1, config)
+	network.Connect(1, 2, config)
+
+	if !network.WaitFor(peerCount(network, 1, 2, 1), syncTimeout) {
+		t.Fatal("nodes are not connected")
+	}
+
+	// the mining reward is spent in the next blocks
+	miner := network.Nodes[0]
+	if _, err := miner.MineBlock(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if !network.WaitFor(sameHead(network.Nodes...), time.Second) {
+		t.Fatal("block is not relayed")
+	}
+
+	newTx := func(nonce uint64) *types.Transaction {
+		tx := types.NewTransaction(miner.Coinbase, *crypto.MustGenerateRandomAddress(), big.NewInt(1), nonce)
+		tx.Sign(miner.Key)
+
+		if err := miner.Service.TxPool().AddTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+
+		return tx
+	}
+
+	// the transactions are in the pools of all nodes
+	txs := []*types.Transaction{newTx(0), newTx(1), newTx(2)}
+	inPools := network.WaitFor(func() bool {
+		for _, node := range network.Nodes {
+			for _, tx := range txs {
+				if node.Service.TxPool().GetTransaction(tx.Hash) == nil {
+					return false
+				}
+			}
+		}
+
+		return true
+	}, syncTimeout)
+
+	if !inPools {
+		t.Fatal("transactions are not propagated")
+	}
+
+	block, err := miner.MineBlock(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !network.WaitFor(sameHead(network.Nodes...), time.Second) {
+		t.Fatal("block is not rebuilt")
+	}
+
+	if count := len(network.Nodes[2].Head().Transactions); count != len(block.Transactions) {
+		t.Fatalf("expected %d transactions, got %d", len(block.Transactions), count)
+	}
+
+	// the transaction not propagated yet is requested from the peer
+	newTx(3)
+	if _, err := miner.MineBlock(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if !network.WaitFor(sameHead(network.Nodes...), time.Second) {
+		t.Fatal("block with missing transaction is not relayed")
+	}
+}
+
+func Test_Network_FastSync(t *testing.T) {
+	network := newTestNetwork(t, 1)
+	defer network.Close()
+
+	// the blocks before the pivot block are not processed by the fast sync node
+	miner := network.Nodes[0]
+	to := *crypto.MustGenerateRandomAddress()
+	for i := 0; i < downloader.PivotDistance+16; i++ {
+		if i > 0 {
+			tx := types.NewTransaction(miner.Coinbase, to, big.NewInt(1), uint64(i-1))
+			tx.Sign(miner.Key)
+			if err := miner.Service.TxPool().AddTransaction(tx); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := miner.MineBlock(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := network.AddNode("fast"); err != nil {
+		t.Fatal(err)
+	}
+
+	network.Connect(0, 1, LinkConfig{Latency: 5 * time.Millisecond})
+	if !network.WaitFor(sameHead(network.Nodes...), syncTimeout) {
+		t.Fatal("fast sync node is not synchronised")
+	}
+
+	// the state of the head block is the same as the miner
+	balance := network.Nodes[1].Service.BlockChain().CurrentState().GetBalance(to)
+	expected := miner.Service.BlockChain().CurrentState().GetBalance(to)
+	if balance.Cmp(expected) != 0 || balance.Sign() == 0 {
+		t.Fatalf("invalid balance %v after fast sync, expected %v", balance, expected)
+	}
+
+	// the state of the blocks before the pivot block is not downloaded
+	chain := network.Nodes[1].Service.BlockChain()
+	hash, err := chain.GetStore().GetBlockHash(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := chain.GetStore().GetBlockHeader(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = state.NewStatedb(header.StateHash, chain.AccountStateDB()); err == nil {
+		t.Fatal("the blocks before the pivot block should not be processed")
+	}
+}
+
+func Test_Network_SyncFromPeers(t *testing.T) {
+	network := newTestNetwork(t, 3)
+	defer network.Close()
+
+	// node 1 has the blocks of node 0 except the head
+	miner := network.Nodes[0]
+	for i := 0; i < 2*downloader.MaxHeaderFetch; i++ {
+		if _, err := miner.MineBlock(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	network.Connect(0, 1, LinkConfig{Latency: 5 * time.Millisecond})
+	if !network.WaitFor(sameHead(network.Nodes[0], network.Nodes[1]), syncTimeout) {
+		t.Fatal("node 1 is not synchronised")
+	}
+
+	network.Disconnect(0, 1)
+	if _, err := miner.MineBlock(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// node 2 downloads from both nodes, and goes on with node 1 after node 0 quits
+	config := LinkConfig{Latency: 20 * time.Millisecond}
+	network.Connect(0, 2, config)
+	network.Connect(1, 2, config)
+
+	downloading := network.WaitFor(func() bool {
+		return network.Nodes[2].Head().Header.Height > 50
+	}, syncTimeout)
+	if !downloading {
+		t.Fatal("node 2 does not download blocks")
+	}
+
+	network.Disconnect(0, 2)
+	if !network.WaitFor(sameHead(network.Nodes[1], network.Nodes[2]), syncTimeout) {
+		t.Fatal("node 2 is not synchronised after the sync peer quits")
+	}
+}
@@ -0,0 +1,206 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package simulations
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// frameQueueSize is the max number of messages in flight of a link direction.
+const frameQueueSize = 4096
+
+var errTimeout = &timeoutError{}
+
+// timeoutError is returned when the read deadline exceeds, it implements net.Error.
+type timeoutError struct{}
+
+func (e *timeoutError) Error() string   { return "i/o timeout" }
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
+// LinkConfig is the quality of a simulated link.
+type LinkConfig struct {
+	Latency  time.Duration // one way delay of messages
+	LossRate float64       // probability to drop a message, in [0, 1)
+}
+
+// frame is a message written to the link.
+type frame struct {
+	data      []byte
+	deliverAt time.Time
+}
+
+// link is an in-memory connection between two nodes. Every write of a connection end is
+// delivered to the other end as a whole after the latency, or dropped by the loss rate.
+// p2p connections write the message head and payload together, so a dropped write is
+// a dropped message, and the following messages are still framed correctly.
+type link struct {
+	lock   sync.Mutex
+	config LinkConfig
+	rand   *rand.Rand
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newLink creates a link and returns the connection ends. The seed of the link decides
+// which messages are dropped.
+func newLink(config LinkConfig, seed int64, name1, name2 string) (*link, net.Conn, net.Conn) {
+	l := &link{
+		config: config,
+		rand:   rand.New(rand.NewSource(seed)),
+		closed: make(chan struct{}),
+	}
+
+	q1, q2 := make(chan frame, frameQueueSize), make(chan frame, frameQueueSize)
+	c1 := &pipeConn{link: l, in: q1, out: q2, local: pipeAddr(name1), remote: pipeAddr(name2)}
+	c2 := &pipeConn{link: l, in: q2, out: q1, local: pipeAddr(name2), remote: pipeAddr(name1)}
+
+	return l, c1, c2
+}
+
+func (l *link) setConfig(config LinkConfig) {
+	l.lock.Lock()
+	l.config = config
+	l.lock.Unlock()
+}
+
+// schedule returns the delivery time of a message written now, and false if it is dropped.
+func (l *link) schedule() (time.Time, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.config.LossRate > 0 && l.rand.Float64() < l.config.LossRate {
+		return time.Time{}, false
+	}
+
+	return time.Now().Add(l.config.Latency), true
+}
+
+// close disconnects both ends of the link.
+func (l *link) close() {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+	})
+}
+
+// pipeAddr is the address of a connection end, which is the node name.
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// pipeConn is a connection end of a link, it implements net.Conn.
+type pipeConn struct {
+	link          *link
+	in, out       chan frame
+	local, remote pipeAddr
+
+	rlock   sync.Mutex // read lock
+	pending []byte     // the rest of the frame partly read
+
+	dlock        sync.Mutex // protects readDeadline
+	readDeadline time.Time
+}
+
+func (c *pipeConn) Read(b []byte) (int, error) {
+	c.rlock.Lock()
+	defer c.rlock.Unlock()
+
+	if len(c.pending) == 0 {
+		c.dlock.Lock()
+		deadline := c.readDeadline
+		c.dlock.Unlock()
+
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			timer := time.NewTimer(time.Until(deadline))
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		var f frame
+		select {
+		case f = <-c.in:
+		case <-c.link.closed:
+			return 0, io.EOF
+		case <-timeout:
+			return 0, errTimeout
+		}
+
+		if wait := time.Until(f.deliverAt); wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+
+			select {
+			case <-timer.C:
+			case <-c.link.closed:
+				return 0, io.EOF
+			}
+		}
+
+		c.pending = f.data
+	}
+
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+
+	return n, nil
+}
+
+func (c *pipeConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.link.closed:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+
+	deliverAt, ok := c.link.schedule()
+	if !ok {
+		return len(b), nil
+	}
+
+	f := frame{make([]byte, len(b)), deliverAt}
+	copy(f.data, b)
+
+	select {
+	case c.out <- f:
+		return len(b), nil
+	case <-c.link.closed:
+		return 0, io.ErrClosedPipe
+	}
+}
+
+// Close disconnects the link.
+func (c *pipeConn) Close() error {
+	c.link.close()
+	return nil
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return c.local }
+func (c *pipeConn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *pipeConn) SetDeadline(t time.Time) error {
+	c.SetWriteDeadline(t)
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline, it takes effect on the next Read.
+func (c *pipeConn) SetReadDeadline(t time.Time) error {
+	c.dlock.Lock()
+	c.readDeadline = t
+	c.dlock.Unlock()
+	return nil
+}
+
+// SetWriteDeadline is not supported, as writes only block when the link is congested.
+func (c *pipeConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,84 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package simulations
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func Test_Link_Latency(t *testing.T) {
+	latency := 50 * time.Millisecond
+	_, c1, c2 := newLink(LinkConfig{Latency: latency}, 1, "a", "b")
+	defer c1.Close()
+
+	start := time.Now()
+	if _, err := c1.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	// read the frame in 2 parts
+	buff := make([]byte, 3)
+	if n, err := c2.Read(buff); err != nil || n != 3 || time.Since(start) < latency {
+		t.Fatalf("invalid read, n = %d, err = %v, elapsed = %v", n, err, time.Since(start))
+	}
+
+	if n, err := c2.Read(buff); err != nil || !bytes.Equal(buff[:n], []byte("lo")) {
+		t.Fatalf("invalid read, n = %d, err = %v", n, err)
+	}
+}
+
+func Test_Link_Loss(t *testing.T) {
+	received := func(seed int64) []byte {
+		_, c1, c2 := newLink(LinkConfig{LossRate: 0.5}, seed, "a", "b")
+		defer c1.Close()
+
+		for i := 0; i < 100; i++ {
+			c1.Write([]byte{byte(i)})
+		}
+
+		var result []byte
+		buff := make([]byte, 1)
+		for {
+			c2.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+			if _, err := c2.Read(buff); err != nil {
+				return result
+			}
+
+			result = append(result, buff[0])
+		}
+	}
+
+	r1, r2 := received(7), received(7)
+	if len(r1) == 0 || len(r1) == 100 {
+		t.Fatalf("expected some messages dropped, received %d", len(r1))
+	}
+
+	if !bytes.Equal(r1, r2) {
+		t.Fatal("the same seed should drop the same messages")
+	}
+}
+
+func Test_Link_Close(t *testing.T) {
+	l, c1, c2 := newLink(LinkConfig{}, 1, "a", "b")
+
+	done := make(chan error)
+	go func() {
+		_, err := c2.Read(make([]byte, 1))
+		done <- err
+	}()
+
+	l.close()
+	if err := <-done; err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	if _, err := c1.Write([]byte{1}); err != io.ErrClosedPipe {
+		t.Fatalf("expected ErrClosedPipe, got %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package rpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// authenticateMethod is the built-in method to authenticate the persistent connections,
+// e.g. TCP, whose only param is the API key or JWT.
+const authenticateMethod = "authenticate"
+
+var (
+	errMissingCredential = errors.New("missing credential")
+	errInvalidCredential = errors.New("invalid credential")
+	errInvalidJWT        = errors.New("invalid token")
+	errUnsupportedJWTAlg = errors.New("unsupported token algorithm")
+	errJWTExpired        = errors.New("token expired")
+	errJWTNotValidYet    = errors.New("token not valid yet")
+)
+
+// jwtHashes are the HMAC hash functions of the supported JWT algorithms.
+var jwtHashes = map[string]func() hash.Hash{
+	"HS256": sha256.New,
+	"HS384": sha512.New384,
+	"HS512": sha512.New,
+}
+
+// AuthConfig is the authentication config of an rpc endpoint. The clients are authenticated
+// with any of the API keys, or a JWT signed by HMAC with the secret. The authentication is
+// disabled if both are empty.
+type AuthConfig struct {
+	APIKeys   []string
+	JWTSecret string
+}
+
+// Enabled returns true if the clients must be authenticated.
+func (c *AuthConfig) Enabled() bool {
+	return c != nil && (len(c.APIKeys) > 0 || len(c.JWTSecret) > 0)
+}
+
+// Authenticate checks the credential, which is an API key or a JWT.
+func (c *AuthConfig) Authenticate(credential string) error {
+	if len(credential) == 0 {
+		return errMissingCredential
+	}
+
+	for _, key := range c.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(credential)) == 1 {
+			return nil
+		}
+	}
+
+	if len(c.JWTSecret) > 0 && strings.Count(credential, ".") == 2 {
+		return verifyJWT(credential, []byte(c.JWTSecret), time.Now())
+	}
+
+	return errInvalidCredential
+}
+
+// unauthorizedError returns the rpc error of the failed authentication.
+func unauthorizedError(err error) *Error {
+	return newError(ErrCodeUnauthorized, "unauthorized", err.Error())
+}
+
+// requestCredential returns the credential of the http request, which is the bearer token
+// of the Authorization header, or the X-API-Key header.
+func requestCredential(req *http.Request) string {
+	if auth := req.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+
+	return req.Header.Get("X-API-Key")
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+type jwtClaims struct {
+	IssuedAt  int64 `json:"iat,omitempty"`
+	ExpiresAt int64 `json:"exp,omitempty"`
+	NotBefore int64 `json:"nbf,omitempty"`
+}
+
+func jwtSign(alg string, signingInput string, secret []byte) []byte {
+	mac := hmac.New(jwtHashes[alg], secret)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
+
+// NewJWT returns a JWT signed by HS256 with the secret, which expires after the duration.
+// The token never expires if the duration is 0.
+func NewJWT(secret string, expiry time.Duration) string {
+	now := time.Now()
+	claims := jwtClaims{IssuedAt: now.Unix()}
+	if expiry > 0 {
+		claims.ExpiresAt = now.Add(expiry).Unix()
+	}
+
+	header, _ := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature := jwtSign("HS256", signingInput, []byte(secret))
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// verifyJWT checks the HMAC signature of the token, and the expiry and not-before times
+// if they are present.
+func verifyJWT(token string, secret []byte, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errInvalidJWT
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return errInvalidJWT
+	}
+
+	var header jwtHeader
+	if json.Unmarshal(headerBytes, &header) != nil {
+		return errInvalidJWT
+	}
+
+	if jwtHashes[header.Alg] == nil {
+		return errUnsupportedJWTAlg
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errInvalidJWT
+	}
+
+	if !hmac.Equal(signature, jwtSign(header.Alg, parts[0]+"."+parts[1], secret)) {
+		return errInvalidCredential
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return errInvalidJWT
+	}
+
+	var claims jwtClaims
+	if json.Unmarshal(payload, &claims) != nil {
+		return errInvalidJWT
+	}
+
+	if claims.ExpiresAt != 0 && now.Unix() >= claims.ExpiresAt {
+		return errJWTExpired
+	}
+
+	if claims.NotBefore != 0 && now.Unix() < claims.NotBefore {
+		return errJWTNotValidYet
+	}
+
+	return nil
+}
+
+// authenticate handles the authenticate method with the params [credential], and returns
+// true if the connection is authenticated.
+func (server *Server) authenticate(ctx *callContext, params json.RawMessage) (interface{}, *Error) {
+	if ctx == nil || ctx.conn == nil {
+		return nil, errNotificationUnsupported
+	}
+
+	var credential string
+	if err := decodeParams(params, reflect.ValueOf(&credential)); err != nil {
+		return nil, newError(ErrCodeInvalidParams, "invalid params", err.Error())
+	}
+
+	if !server.auth.Enabled() {
+		credential = ""
+	} else if err := server.auth.Authenticate(credential); err != nil {
+		return nil, unauthorizedError(err)
+	}
+
+	ctx.conn.setAuthenticated(credential)
+	return true, nil
+}
+
+// Authenticate authenticates the connection with the API key or JWT, which is required
+// before calling other methods if the server enables the authentication.
+func (c *Client) Authenticate(credential string) error {
+	return c.Call(authenticateMethod, credential, nil)
+}
@@ -0,0 +1,136 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package rpc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_AuthConfig_Authenticate(t *testing.T) {
+	assert.Equal(t, (*AuthConfig)(nil).Enabled(), false)
+	assert.Equal(t, (&AuthConfig{}).Enabled(), false)
+
+	config := &AuthConfig{APIKeys: []string{"key1", "key2"}, JWTSecret: "secret"}
+	assert.Equal(t, config.Enabled(), true)
+
+	assert.Equal(t, config.Authenticate("key2"), nil)
+	assert.Equal(t, config.Authenticate("key3"), errInvalidCredential)
+	assert.Equal(t, config.Authenticate(""), errMissingCredential)
+
+	assert.Equal(t, config.Authenticate(NewJWT("secret", time.Minute)), nil)
+	assert.Equal(t, config.Authenticate(NewJWT("secret", 0)), nil)
+	assert.Equal(t, config.Authenticate(NewJWT("wrong", time.Minute)), errInvalidCredential)
+
+	// expired
+	token := NewJWT("secret", time.Minute)
+	assert.Equal(t, verifyJWT(token, []byte("secret"), time.Now().Add(2*time.Minute)), errJWTExpired)
+
+	// the algorithm "none" is not accepted
+	parts := strings.Split(token, ".")
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	assert.Equal(t, config.Authenticate(parts[0]+"."+parts[1]+"."), errUnsupportedJWTAlg)
+
+	// the JWT is not accepted without the secret
+	config.JWTSecret = ""
+	assert.Equal(t, config.Authenticate(token), errInvalidCredential)
+}
+
+func Test_HTTPServe_Auth(t *testing.T) {
+	server, _ := NewHTTPServer(nil, nil)
+	server.RegisterName("arith", new(Arith))
+	server.SetAuth(&AuthConfig{APIKeys: []string{"key"}, JWTSecret: "secret"})
+
+	serve := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://url.com", strings.NewReader(`{"jsonrpc": "2.0", "method": "arith.Add", "params": [1, 2], "id": 1}`))
+		req.Header.Set("content-type", "application/json")
+		if len(header) > 0 {
+			req.Header.Set(header, value)
+		}
+
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("", "")
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+
+	var resp struct{ Error *Error }
+	assert.Equal(t, json.Unmarshal(w.Body.Bytes(), &resp), nil)
+	assert.Equal(t, resp.Error.Code, ErrCodeUnauthorized)
+
+	assert.Equal(t, serve("Authorization", "Bearer wrong").Code, http.StatusUnauthorized)
+	assert.Equal(t, serve("Authorization", "Bearer "+NewJWT("secret", time.Minute)).Code, http.StatusOK)
+	assert.Equal(t, serve("X-API-Key", "key").Code, http.StatusOK)
+}
+
+func Test_ServeConn_Auth(t *testing.T) {
+	server := newTestServer()
+	server.SetAuth(&AuthConfig{APIKeys: []string{"key"}})
+
+	cli, srv := net.Pipe()
+	go server.ServeConn(srv)
+
+	client := NewClient(cli)
+	defer client.Close()
+
+	var reply Reply
+	err := client.Call("Arith.Add", &Args{7, 8}, &reply)
+	if rpcErr, ok := err.(*Error); !ok || rpcErr.Code != ErrCodeUnauthorized {
+		t.Fatalf("bad error %v", err)
+	}
+
+	err = client.Authenticate("wrong")
+	if rpcErr, ok := err.(*Error); !ok || rpcErr.Code != ErrCodeUnauthorized {
+		t.Fatalf("bad error %v", err)
+	}
+
+	assert.Equal(t, client.Authenticate("key"), nil)
+	assert.Equal(t, client.Call("Arith.Add", &Args{7, 8}, &reply), nil)
+	assert.Equal(t, reply.C, 15)
+}
+
+func Test_WSServer_Auth(t *testing.T) {
+	server := NewWSServer(nil)
+	server.SetAuth(&AuthConfig{APIKeys: []string{"key"}})
+
+	// the invalid credential is rejected before upgrading
+	req := httptest.NewRequest(http.MethodGet, "http://url.com/?token=wrong", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+
+	// the connection without the credential is upgraded, but not authenticated
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	conn, status := dialWS(t, httpServer.Listener.Addr().String(), "")
+	assert.Equal(t, status, http.StatusSwitchingProtocols)
+	defer conn.Close()
+
+	var resp struct{ Error *Error }
+	conn.WriteMessage([]byte(`{"jsonrpc": "2.0", "method": "subscribe", "params": ["newHeads"], "id": 1}`))
+	readWSResponse(t, conn, &resp)
+	assert.Equal(t, resp.Error.Code, ErrCodeUnauthorized)
+
+	var authResp struct {
+		Result bool
+		Error  *Error
+	}
+	conn.WriteMessage([]byte(`{"jsonrpc": "2.0", "method": "authenticate", "params": ["key"], "id": 2}`))
+	readWSResponse(t, conn, &authResp)
+	assert.Nil(t, authResp.Error)
+	assert.Equal(t, authResp.Result, true)
+}
@@ -0,0 +1,111 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+var errNoResult = errors.New("rpc: response has neither result nor error")
+
+// Client is a JSON-RPC 2.0 client on a stream connection, e.g. the TCP RPC server of a node.
+// The calls of a client are sent one by one.
+type Client struct {
+	conn io.ReadWriteCloser
+	enc  *json.Encoder
+	dec  *json.Decoder
+
+	mutex sync.Mutex // protects the connection and seq
+	seq   uint64
+}
+
+type clientResponse struct {
+	Id     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// NewClient returns a new Client on the connection.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	return &Client{
+		conn: conn,
+		enc:  json.NewEncoder(conn),
+		dec:  json.NewDecoder(conn),
+	}
+}
+
+// Dial connects to a JSON-RPC server at the specified network address.
+func Dial(network, address string) (*Client, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(conn), nil
+}
+
+// Call calls the method with the args as the only positional param, and decodes the
+// result into the reply. The args is omitted if nil. The error of the response is
+// returned as an *Error.
+func (c *Client) Call(method string, args interface{}, reply interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.seq++
+	id := strconv.FormatUint(c.seq, 10)
+
+	req := &struct {
+		Version string        `json:"jsonrpc"`
+		Method  string        `json:"method"`
+		Params  []interface{} `json:"params,omitempty"`
+		Id      string        `json:"id"`
+	}{Version: jsonrpcVersion, Method: method, Id: id}
+
+	if args != nil {
+		req.Params = []interface{}{args}
+	}
+
+	if err := c.enc.Encode(req); err != nil {
+		return err
+	}
+
+	for {
+		var resp clientResponse
+		if err := c.dec.Decode(&resp); err != nil {
+			return err
+		}
+
+		// skip the messages of other requests, e.g. the notifications from the server
+		var respID string
+		if json.Unmarshal(resp.Id, &respID) != nil || respID != id {
+			continue
+		}
+
+		if resp.Error != nil {
+			return resp.Error
+		}
+
+		if len(resp.Result) == 0 {
+			return errNoResult
+		}
+
+		if reply == nil {
+			return nil
+		}
+
+		return json.Unmarshal(resp.Result, reply)
+	}
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
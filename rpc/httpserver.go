@@ -6,11 +6,12 @@
 package rpc
 
 import (
+	"encoding/json"
 	"errors"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
-	"net/rpc"
 	"strings"
 
 	"github.com/rs/cors"
@@ -23,18 +24,18 @@ var (
 
 // HTTPServer represents a HTTP RPC server
 type HTTPServer struct {
-	rpc.Server
+	*Server
 }
 
 // NewHTTPServer returns a new HttpServer and a http handler used by cors
 func NewHTTPServer(whitehosts []string, corsList []string) (*HTTPServer, *hostFilter) {
 	server := &HTTPServer{
-		rpc.Server{},
+		NewServer(),
 	}
 	// cors
 	c := cors.New(cors.Options{
 		AllowedOrigins: corsList,
-		AllowedMethods: []string{http.MethodPost, http.MethodConnect},
+		AllowedMethods: []string{http.MethodPost},
 		AllowedHeaders: []string{"*"},
 		MaxAge:         600,
 	})
@@ -50,32 +51,62 @@ func NewHTTPServer(whitehosts []string, corsList []string) (*HTTPServer, *hostFi
 }
 
 // ServeHTTP implements an http.Handler that answers RPC requests.
-// Supports POST and CONNECT http method.
-// POST handles requests from the browser
-// CONNECT handles requests form other go rpc.Client
+// The body of the POST http method is a request or a batch of requests,
+// and the response is empty if all requests are notifications.
 func (server *HTTPServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	switch req.Method {
-	case http.MethodConnect:
-		server.Server.ServeHTTP(w, req)
-	case http.MethodPost:
-		w.Header().Set("Content-Type", "application/json")
-		conn := &httpReadWriteCloser{req.Body, w}
-		server.ServeRequest(NewJsonCodec(conn))
-	default:
+	if req.Method != http.MethodPost {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(http.StatusMethodNotAllowed)
-		io.WriteString(w, "405 must POST or CONNECT\n")
+		io.WriteString(w, "405 must POST\n")
+		return
 	}
-}
 
-// httpReadWriteCloser wraps a io.Reader and io.Writer
-type httpReadWriteCloser struct {
-	io.Reader
-	io.Writer
-}
+	// the client IP is the remote address of the connection, as the forwarding headers
+	// could be forged by the clients.
+	ctx := &callContext{ip: remoteIP(req.RemoteAddr)}
+	if server.auth.Enabled() {
+		if rpcErr := server.rateLimitIP(ctx.ip); rpcErr != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(newErrorResponse(nil, rpcErr))
+			return
+		}
+
+		ctx.ipTokenTaken = true
+		ctx.key = requestCredential(req)
+		if err := server.auth.Authenticate(ctx.key); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(newErrorResponse(nil, unauthorizedError(err)))
+			return
+		}
+	}
 
-func (t *httpReadWriteCloser) Close() error {
-	return nil
+	// read one more byte than the max body size to reject the larger bodies without
+	// reading them entirely.
+	reader := io.Reader(req.Body)
+	if server.limits != nil && server.limits.MaxBodySize > 0 {
+		reader = io.LimitReader(req.Body, server.limits.MaxBodySize+1)
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if rpcErr := server.rejectBody(int64(len(body))); rpcErr != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(newErrorResponse(nil, rpcErr))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if response := server.handleMessage(body, ctx); response != nil {
+		json.NewEncoder(w).Encode(response)
+	}
 }
 
 // hostFilter handlers the incoming requests and filters the Host-header.
@@ -58,6 +58,31 @@ func Test_HTTPServe(t *testing.T) {
 
 	w = httptest.NewRecorder()
 
+	serve.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), "-32700") {
+		t.Fatalf("HTTPServe test failed")
+	}
+
+	serve.RegisterName("arith", new(Arith))
+
+	req = httptest.NewRequest(http.MethodPost, "http://url.com", strings.NewReader(`[
+		{"jsonrpc": "2.0", "method": "arith.Add", "params": {"A": 1, "B": 2}, "id": 1},
+		{"jsonrpc": "2.0", "method": "arith.Add", "params": [3, 4], "id": 2}]`))
+	req.Header.Set("content-type", "application/json")
+
+	w = httptest.NewRecorder()
+
+	serve.ServeHTTP(w, req)
+	if body := strings.TrimSpace(w.Body.String()); body != `[{"jsonrpc":"2.0","id":1,"result":{"C":3}},{"jsonrpc":"2.0","id":2,"result":{"C":7}}]` {
+		t.Fatalf("HTTPServe test failed, %s", body)
+	}
+
+	// nothing is responded for notifications
+	req = httptest.NewRequest(http.MethodPost, "http://url.com", strings.NewReader(`{"jsonrpc": "2.0", "method": "arith.Add", "params": [3, 4]}`))
+	req.Header.Set("content-type", "application/json")
+
+	w = httptest.NewRecorder()
+
 	serve.ServeHTTP(w, req)
 	if w.Body.Len() != 0 {
 		t.Fatalf("HTTPServe test failed")
@@ -1,140 +1,333 @@
-// Copyright 2010 The Go Authors. All rights reserved.
-// Use of this source code is governed by a BSD-style
-// license that can be found in the LICENSE file.
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
 
 package rpc
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
-	"net/rpc"
+	"reflect"
 	"sync"
 )
 
-var errMissingParams = errors.New("jsonrpc: request body missing params")
+const (
+	jsonrpcVersion = "2.0"
+)
 
+// The error codes defined by JSON-RPC 2.0.
 const (
-	jsonrpcVersion = "1.0"
+	ErrCodeParse          = -32700 // invalid JSON was received by the server
+	ErrCodeInvalidRequest = -32600 // the JSON sent is not a valid request object
+	ErrCodeMethodNotFound = -32601 // the method does not exist or is not available
+	ErrCodeInvalidParams  = -32602 // invalid method parameters
+	ErrCodeInternal       = -32603 // internal JSON-RPC error
+	ErrCodeServer         = -32000 // the error returned by the method
+	ErrCodeUnauthorized   = -32001 // the credential of the client is missing or invalid
+	ErrCodeTimeout        = -32002 // the method does not return within its timeout
+	ErrCodeLimitExceeded  = -32005 // the request exceeds the rate, size or batch limits
 )
 
-type jsonCodec struct {
-	dec *json.Decoder // for reading JSON values
-	enc *json.Encoder // for writing JSON values
-	c   io.Closer
-
-	// temporary work space
-	req jsonRequest
-
-	// JSON-RPC clients can use arbitrary json values as request IDs.
-	// Package rpc expects uint64 request IDs.
-	// We assign uint64 sequence numbers to incoming requests
-	// but save the original request ID in the pending map.
-	// When rpc responds, we use the sequence number in
-	// the response to find the original request ID.
-	mutex   sync.Mutex // protects seq, pending
-	seq     uint64
-	pending map[uint64]*json.RawMessage
-}
-
-// NewJsonCodec returns a new rpc.ServerCodec using JSON-RPC on conn.
-func NewJsonCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
-	return &jsonCodec{
-		dec:     json.NewDecoder(conn),
-		enc:     json.NewEncoder(conn),
-		c:       conn,
-		pending: make(map[uint64]*json.RawMessage),
+var (
+	errTooManyParams = errors.New("too many params")
+	errInvalidParams = errors.New("params must be an array or an object")
+
+	errMessageTooLarge = errors.New("message too large")
+)
+
+// Error is the error object of the JSON-RPC 2.0 response. The methods of the services
+// could return an *Error to respond with the specified code and data, otherwise the
+// error message is responded with the code ErrCodeServer.
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func newError(code int, message string, data interface{}) *Error {
+	return &Error{code, message, data}
+}
+
+// toError converts the error returned by a method to an *Error.
+func toError(err error) *Error {
+	if rpcErr, ok := err.(*Error); ok {
+		return rpcErr
+	}
+
+	return newError(ErrCodeServer, err.Error(), nil)
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Data == nil {
+		return e.Message
 	}
+
+	return fmt.Sprintf("%s: %v", e.Message, e.Data)
 }
 
 type jsonRequest struct {
-	Version string           `json:"jsonrpc"`
-	Method  string           `json:"method"`
-	Params  *json.RawMessage `json:"params"`
-	Id      *json.RawMessage `json:"id"`
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Id      json.RawMessage `json:"id,omitempty"`
+}
+
+// isNotification returns true if the request has no id member.
+func (r *jsonRequest) isNotification() bool {
+	return len(r.Id) == 0
 }
 
-func (r *jsonRequest) reset() {
-	r.Method = ""
-	r.Params = nil
-	r.Id = nil
+// id returns the id of the request, which is null if absent.
+func (r *jsonRequest) id() json.RawMessage {
+	if r.isNotification() {
+		return null
+	}
+
+	return r.Id
+}
+
+// validate checks the members of the request object.
+func (r *jsonRequest) validate() *Error {
+	if len(r.Id) > 0 {
+		switch r.Id[0] {
+		case '{', '[', 't', 'f':
+			r.Id = nil
+			return newError(ErrCodeInvalidRequest, "invalid request", "id must be a string, number or null")
+		}
+	}
+
+	if r.Version != jsonrpcVersion {
+		return newError(ErrCodeInvalidRequest, "invalid request", `jsonrpc must be exactly "2.0"`)
+	}
+
+	if len(r.Method) == 0 {
+		return newError(ErrCodeInvalidRequest, "invalid request", "method is required")
+	}
+
+	return nil
 }
 
 type jsonResponse struct {
-	Version string           `json:"jsonrpc"`
-	Id      *json.RawMessage `json:"id"`
-	Result  interface{}      `json:"result"`
-	Error   interface{}      `json:"error"`
+	Version string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. The result member is required on success even
+// if it is null, and must not exist on error.
+func (r *jsonResponse) MarshalJSON() ([]byte, error) {
+	if r.Error != nil {
+		return json.Marshal(&struct {
+			Version string          `json:"jsonrpc"`
+			Id      json.RawMessage `json:"id"`
+			Error   *Error          `json:"error"`
+		}{r.Version, r.Id, r.Error})
+	}
+
+	return json.Marshal(&struct {
+		Version string          `json:"jsonrpc"`
+		Id      json.RawMessage `json:"id"`
+		Result  interface{}     `json:"result"`
+	}{r.Version, r.Id, r.Result})
+}
+
+var null = json.RawMessage([]byte("null"))
+
+func newErrorResponse(id json.RawMessage, err *Error) *jsonResponse {
+	if len(id) == 0 {
+		id = null
+	}
+
+	return &jsonResponse{Version: jsonrpcVersion, Id: id, Error: err}
+}
+
+// parseParams decodes the positional or named params into a new value of the arg type.
+// The absent params leave the arg zero. The positional params are decoded as the only
+// arg, or as the exported fields in order if the arg is a struct. The named params
+// are decoded as the fields of the arg.
+func parseParams(params json.RawMessage, argType reflect.Type) (reflect.Value, error) {
+	argIsValue := argType.Kind() != reflect.Ptr
+	var argv reflect.Value
+	if argIsValue {
+		argv = reflect.New(argType)
+	} else {
+		argv = reflect.New(argType.Elem())
+	}
+
+	if err := decodeParams(params, argv); err != nil {
+		return argv, err
+	}
+
+	if argIsValue {
+		return argv.Elem(), nil
+	}
+
+	return argv, nil
 }
 
-func (c *jsonCodec) ReadRequestHeader(r *rpc.Request) error {
-	c.req.reset()
-	if err := c.dec.Decode(&c.req); err != nil {
+func decodeParams(params json.RawMessage, argv reflect.Value) error {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || bytes.Equal(params, null) {
+		return nil
+	}
+
+	switch params[0] {
+	case '{':
+		dec := json.NewDecoder(bytes.NewReader(params))
+		if argv.Elem().Kind() == reflect.Struct {
+			dec.DisallowUnknownFields()
+		}
+
+		return dec.Decode(argv.Interface())
+	case '[':
+	default:
+		return errInvalidParams
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(params, &list); err != nil {
 		return err
 	}
-	r.ServiceMethod = c.req.Method
 
-	// JSON request id can be any JSON value;
-	// RPC package expects uint64.  Translate to
-	// internal uint64 and save JSON on the side.
-	c.mutex.Lock()
-	c.seq++
-	c.pending[c.seq] = c.req.Id
-	c.req.Id = nil
-	r.Seq = c.seq
-	c.mutex.Unlock()
+	arg := argv.Elem()
+	switch {
+	case len(list) == 0:
+		return nil
+	case len(list) == 1:
+		err := json.Unmarshal(list[0], argv.Interface())
+		if err != nil && (arg.Kind() == reflect.Slice || arg.Kind() == reflect.Array) {
+			// the params array is the arg itself
+			return json.Unmarshal(params, argv.Interface())
+		}
+
+		return err
+	case arg.Kind() == reflect.Slice || arg.Kind() == reflect.Array:
+		return json.Unmarshal(params, argv.Interface())
+	case arg.Kind() != reflect.Struct:
+		return errTooManyParams
+	}
+
+	// positional params of the exported struct fields
+	index := 0
+	for i := 0; i < arg.NumField(); i++ {
+		field := arg.Type().Field(i)
+		if field.PkgPath != "" || field.Tag.Get("json") == "-" {
+			continue
+		}
+
+		if index == len(list) {
+			return nil
+		}
+
+		if err := json.Unmarshal(list[index], arg.Field(i).Addr().Interface()); err != nil {
+			return err
+		}
+
+		index++
+	}
+
+	if index < len(list) {
+		return errTooManyParams
+	}
 
 	return nil
 }
 
-func (c *jsonCodec) ReadRequestBody(x interface{}) error {
-	if x == nil {
-		return nil
+// ServerCodec reads the JSON messages of requests from and writes the responses to a
+// connection. WriteMessage may be called concurrently.
+type ServerCodec interface {
+	ReadMessage() (json.RawMessage, error)
+	WriteMessage(msg interface{}) error
+	Close() error
+}
+
+type jsonCodec struct {
+	dec    *json.Decoder  // for reading JSON values
+	enc    *json.Encoder  // for writing JSON values
+	reader *messageReader // limits the bytes read for a message
+	c      io.Closer
+
+	mutex sync.Mutex // protects enc
+}
+
+// NewJsonCodec returns a new ServerCodec using JSON-RPC 2.0 on the stream connection.
+func NewJsonCodec(conn io.ReadWriteCloser) ServerCodec {
+	return newJsonCodec(conn, 0)
+}
+
+// newJsonCodec returns a new jsonCodec which fails to read the messages larger than the max
+// message size, 0 for unlimited.
+func newJsonCodec(conn io.ReadWriteCloser, maxMessageSize int64) *jsonCodec {
+	reader := &messageReader{r: conn, limit: maxMessageSize}
+	return &jsonCodec{
+		dec:    json.NewDecoder(reader),
+		enc:    json.NewEncoder(conn),
+		reader: reader,
+		c:      conn,
+	}
+}
+
+func (c *jsonCodec) ReadMessage() (json.RawMessage, error) {
+	// the bytes of the message buffered by the decoder are read already
+	buffered := 0
+	if r, ok := c.dec.Buffered().(*bytes.Reader); ok {
+		buffered = r.Len()
 	}
-	if c.req.Params == nil {
-		return errMissingParams
+
+	c.reader.reset(int64(buffered))
+
+	var msg json.RawMessage
+	if err := c.dec.Decode(&msg); err != nil {
+		return nil, err
 	}
-	// JSON params is array value.
-	// RPC params is struct.
-	// Unmarshal into array containing struct for now.
-	// Should think about making RPC more general.
-	var params [1]interface{}
-	params[0] = x
-	return json.Unmarshal(*c.req.Params, &params)
+
+	return msg, nil
 }
 
-var null = json.RawMessage([]byte("null"))
+// messageReader reads at most limit bytes for a message from the stream, which is reset
+// before reading each message.
+type messageReader struct {
+	r         io.Reader
+	limit     int64 // the max bytes of a message, 0 for unlimited
+	remaining int64
+}
 
-func (c *jsonCodec) WriteResponse(r *rpc.Response, x interface{}) error {
-	c.mutex.Lock()
-	b, ok := c.pending[r.Seq]
-	if !ok {
-		c.mutex.Unlock()
-		return errors.New("invalid sequence number in response")
+// reset starts to read a message, of which the bytes are read already.
+func (r *messageReader) reset(read int64) {
+	r.remaining = r.limit - read
+}
+
+func (r *messageReader) Read(p []byte) (int, error) {
+	if r.limit <= 0 {
+		return r.r.Read(p)
 	}
-	delete(c.pending, r.Seq)
-	c.mutex.Unlock()
 
-	if b == nil {
-		// Invalid request so no id. Use JSON null.
-		b = &null
+	if r.remaining <= 0 {
+		return 0, errMessageTooLarge
 	}
-	resp := jsonResponse{Version: jsonrpcVersion, Id: b}
-	if r.Error == "" {
-		resp.Result = x
-	} else {
-		resp.Error = r.Error
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
 	}
-	return c.enc.Encode(resp)
+
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	return n, err
 }
 
-func (c *jsonCodec) Close() error {
-	return c.c.Close()
+func (c *jsonCodec) WriteMessage(msg interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.enc.Encode(msg)
 }
 
-// ServeConn runs the JSON-RPC server on a single connection.
-// ServeConn blocks, serving the connection until the client hangs up.
-// The caller typically invokes ServeConn with go-routine.
-func ServeConn(conn io.ReadWriteCloser) {
-	rpc.ServeCodec(NewJsonCodec(conn))
+func (c *jsonCodec) Close() error {
+	return c.c.Close()
 }
@@ -12,7 +12,6 @@ import (
 	"io"
 	"io/ioutil"
 	"net"
-	"net/rpc"
 	"strings"
 	"testing"
 )
@@ -72,52 +71,95 @@ func (BuiltinTypes) Array(i int, reply *[1]int) error {
 	return nil
 }
 
-func init() {
-	rpc.Register(new(Arith))
-	rpc.Register(BuiltinTypes{})
+func newTestServer() *Server {
+	server := NewServer()
+	server.Register(new(Arith))
+	server.Register(BuiltinTypes{})
+	return server
 }
 
-func Test_ServerNoParams(t *testing.T) {
-	cli, srv := net.Pipe()
-	defer cli.Close()
-	go ServeConn(srv)
-	dec := json.NewDecoder(cli)
+type testResponse struct {
+	Version string           `json:"jsonrpc"`
+	Id      interface{}      `json:"id"`
+	Result  *json.RawMessage `json:"result"`
+	Error   *Error           `json:"error"`
+}
 
-	fmt.Fprintf(cli, `{"method": "Arith.Add", "id": "123"}`)
-	var resp ArithAddResp
-	if err := dec.Decode(&resp); err != nil {
-		t.Fatalf("Decode after no params: %s", err)
+// testCall sends the request to the server, and returns the decoded response.
+func testCall(t *testing.T, server *Server, request string) *testResponse {
+	response := server.handleMessage(json.RawMessage(request), nil)
+	if response == nil {
+		t.Fatalf("no response of %s", request)
 	}
-	if resp.Error == nil {
-		t.Fatalf("Expected error, got nil")
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp testResponse
+	if err = json.Unmarshal(data, &resp); err != nil {
+		t.Fatal(err)
 	}
+
+	return &resp
 }
 
-func Test_ServerEmptyMessage(t *testing.T) {
-	cli, srv := net.Pipe()
-	defer cli.Close()
-	go ServeConn(srv)
-	dec := json.NewDecoder(cli)
+func testCallReply(t *testing.T, server *Server, request string) *Reply {
+	resp := testCall(t, server, request)
+	if resp.Error != nil {
+		t.Fatalf("resp.Error: %s", resp.Error)
+	}
 
-	fmt.Fprintf(cli, "{}")
-	var resp ArithAddResp
-	if err := dec.Decode(&resp); err != nil {
-		t.Fatalf("Decode after empty: %s", err)
+	var reply Reply
+	if err := json.Unmarshal(*resp.Result, &reply); err != nil {
+		t.Fatal(err)
 	}
+
+	return &reply
+}
+
+func testCallError(t *testing.T, server *Server, request string, code int) *Error {
+	resp := testCall(t, server, request)
 	if resp.Error == nil {
 		t.Fatalf("Expected error, got nil")
 	}
+	if resp.Error.Code != code {
+		t.Fatalf("bad error code %d want %d", resp.Error.Code, code)
+	}
+	if resp.Result != nil {
+		t.Fatalf("Response contains both an error and result")
+	}
+
+	return resp.Error
+}
+
+func Test_ServerNoParams(t *testing.T) {
+	server := newTestServer()
+
+	reply := testCallReply(t, server, `{"jsonrpc": "2.0", "method": "Arith.Add", "id": "123"}`)
+	if reply.C != 0 {
+		t.Fatalf("bad result of no params: %d", reply.C)
+	}
+}
+
+func Test_ServerEmptyMessage(t *testing.T) {
+	server := newTestServer()
+
+	if err := testCallError(t, server, "{}", ErrCodeInvalidRequest); err.Data == nil {
+		t.Fatalf("Expected error data, got nil")
+	}
 }
 
 func Test_Server(t *testing.T) {
 	cli, srv := net.Pipe()
 	defer cli.Close()
-	go ServeConn(srv)
+	go newTestServer().ServeConn(srv)
 	dec := json.NewDecoder(cli)
 
 	// Send hand-coded requests to server, parse responses.
 	for i := 0; i < 10; i++ {
-		fmt.Fprintf(cli, `{"method": "Arith.Add", "id": "\u%04d", "params": [{"A": %d, "B": %d}]}`, i, i, i+1)
+		fmt.Fprintf(cli, `{"jsonrpc": "2.0", "method": "Arith.Add", "id": "\u%04d", "params": [{"A": %d, "B": %d}]}`, i, i, i+1)
 		var resp ArithAddResp
 		err := dec.Decode(&resp)
 		if err != nil {
@@ -126,8 +168,8 @@ func Test_Server(t *testing.T) {
 		if resp.Error != nil {
 			t.Fatalf("resp.Error: %s", resp.Error)
 		}
-		if resp.Id.(string) != string(i) {
-			t.Fatalf("resp: bad id %q want %q", resp.Id.(string), string(i))
+		if resp.Id.(string) != string(rune(i)) {
+			t.Fatalf("resp: bad id %q want %q", resp.Id.(string), string(rune(i)))
 		}
 		if resp.Result.C != 2*i+1 {
 			t.Fatalf("resp: bad result: %d+%d=%d", i, i+1, resp.Result.C)
@@ -135,10 +177,139 @@ func Test_Server(t *testing.T) {
 	}
 }
 
+func Test_Server_Params(t *testing.T) {
+	server := newTestServer()
+
+	// named params
+	if reply := testCallReply(t, server, `{"jsonrpc": "2.0", "method": "Arith.Mul", "id": 1, "params": {"A": 3, "B": 4}}`); reply.C != 12 {
+		t.Fatalf("bad result of named params: %d", reply.C)
+	}
+
+	// positional params of the struct fields
+	if reply := testCallReply(t, server, `{"jsonrpc": "2.0", "method": "Arith.Mul", "id": 1, "params": [3, 5]}`); reply.C != 15 {
+		t.Fatalf("bad result of positional params: %d", reply.C)
+	}
+
+	// positional params of builtin types
+	resp := testCall(t, server, `{"jsonrpc": "2.0", "method": "BuiltinTypes.Slice", "id": 1, "params": [7]}`)
+	if resp.Error != nil || string(*resp.Result) != "[7]" {
+		t.Fatalf("bad result of builtin type params: %+v", resp)
+	}
+
+	testCallError(t, server, `{"jsonrpc": "2.0", "method": "Arith.Mul", "id": 1, "params": [3, 5, 7]}`, ErrCodeInvalidParams)
+	testCallError(t, server, `{"jsonrpc": "2.0", "method": "Arith.Mul", "id": 1, "params": {"A": 3, "D": 4}}`, ErrCodeInvalidParams)
+	testCallError(t, server, `{"jsonrpc": "2.0", "method": "BuiltinTypes.Map", "id": 1, "params": [1, 2]}`, ErrCodeInvalidParams)
+	testCallError(t, server, `{"jsonrpc": "2.0", "method": "BuiltinTypes.Map", "id": 1, "params": ["a"]}`, ErrCodeInvalidParams)
+}
+
+func Test_Server_Errors(t *testing.T) {
+	server := newTestServer()
+
+	testCallError(t, server, `{"method": "Arith.Add", "id": 1}`, ErrCodeInvalidRequest)
+	testCallError(t, server, `{"jsonrpc": "2.0", "id": 1}`, ErrCodeInvalidRequest)
+	testCallError(t, server, `{"jsonrpc": "2.0", "method": "Arith.Add", "id": {}}`, ErrCodeInvalidRequest)
+	testCallError(t, server, `1`, ErrCodeInvalidRequest)
+	testCallError(t, server, `{"jsonrpc": "2.0", "method": "Arith.Sub", "id": 1}`, ErrCodeMethodNotFound)
+	testCallError(t, server, `{"jsonrpc": "2.0", "method": "Math.Add", "id": 1}`, ErrCodeMethodNotFound)
+	testCallError(t, server, `{"jsonrpc": "2.0", "method": "Add", "id": 1}`, ErrCodeMethodNotFound)
+	testCallError(t, server, `{"jsonrpc": "2.0", "method": "Arith.Add", "id": 1`, ErrCodeParse)
+
+	if err := testCallError(t, server, `{"jsonrpc": "2.0", "method": "Arith.Div", "id": 1, "params": [1, 0]}`, ErrCodeServer); err.Message != "divide by zero" {
+		t.Fatalf("bad error message %s", err.Message)
+	}
+
+	if err := testCallError(t, server, `{"jsonrpc": "2.0", "method": "Arith.Error", "id": 1}`, ErrCodeInternal); err.Data != "ERROR" {
+		t.Fatalf("bad error data %v", err.Data)
+	}
+}
+
+func Test_Server_Notification(t *testing.T) {
+	server := newTestServer()
+
+	if response := server.handleMessage(json.RawMessage(`{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1, 2]}`), nil); response != nil {
+		t.Fatalf("notification has response %+v", response)
+	}
+
+	// the errors of notifications are not responded
+	if response := server.handleMessage(json.RawMessage(`{"jsonrpc": "2.0", "method": "Arith.Div", "params": [1, 0]}`), nil); response != nil {
+		t.Fatalf("notification has response %+v", response)
+	}
+
+	// the request with null id is not a notification
+	resp := testCall(t, server, `{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1, 2], "id": null}`)
+	if resp.Error != nil || resp.Id != nil {
+		t.Fatalf("bad response of null id: %+v", resp)
+	}
+}
+
+func Test_Server_Batch(t *testing.T) {
+	cli, srv := net.Pipe()
+	defer cli.Close()
+	go newTestServer().ServeConn(srv)
+	dec := json.NewDecoder(cli)
+
+	fmt.Fprintf(cli, `[
+		{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1, 2], "id": 1},
+		{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1, 2]},
+		{"jsonrpc": "2.0", "method": "Arith.Mul", "params": {"A": 2, "B": 3}, "id": "2"},
+		{"foo": "boo"}
+	]`)
+
+	var responses []testResponse
+	if err := dec.Decode(&responses); err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	if len(responses) != 3 {
+		t.Fatalf("bad batch response length %d", len(responses))
+	}
+
+	if responses[0].Id != float64(1) || string(*responses[0].Result) != `{"C":3}` {
+		t.Fatalf("bad response %+v", responses[0])
+	}
+
+	if responses[1].Id != "2" || string(*responses[1].Result) != `{"C":6}` {
+		t.Fatalf("bad response %+v", responses[1])
+	}
+
+	if responses[2].Id != nil || responses[2].Error.Code != ErrCodeInvalidRequest {
+		t.Fatalf("bad response %+v", responses[2])
+	}
+
+	// nothing is responded for the batch of notifications
+	fmt.Fprintf(cli, `[{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1, 2]}]`)
+	fmt.Fprintf(cli, `[]`)
+
+	var resp testResponse
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	if resp.Error == nil || resp.Error.Code != ErrCodeInvalidRequest {
+		t.Fatalf("bad response of empty batch %+v", resp)
+	}
+}
+
 func Test_MalformedInput(t *testing.T) {
 	cli, srv := net.Pipe()
 	go cli.Write([]byte(`{id:1}`)) // invalid json
-	ServeConn(srv)                 // must return, not loop
+
+	done := make(chan struct{})
+	go func() {
+		newTestServer().ServeConn(srv) // must return, not loop
+		close(done)
+	}()
+
+	var resp testResponse
+	if err := json.NewDecoder(cli).Decode(&resp); err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	if resp.Error == nil || resp.Error.Code != ErrCodeParse {
+		t.Fatalf("bad response of malformed input %+v", resp)
+	}
+
+	<-done
 }
 
 func Test_Json_ErrorHasNullResult(t *testing.T) {
@@ -148,36 +319,61 @@ func Test_Json_ErrorHasNullResult(t *testing.T) {
 		io.Writer
 		io.Closer
 	}{
-		Reader: strings.NewReader(`{"method": "Arith.Add", "id": "123", "params": []}`),
+		Reader: strings.NewReader(""),
 		Writer: &out,
 		Closer: ioutil.NopCloser(nil),
 	})
-	r := new(rpc.Request)
-	if err := sc.ReadRequestHeader(r); err != nil {
-		t.Fatal(err)
-	}
-	const valueText = "the value we don't want to see"
 	const errorText = "some error"
-	err := sc.WriteResponse(&rpc.Response{
-		ServiceMethod: "Method",
-		Seq:           1,
-		Error:         errorText,
-	}, valueText)
+	err := sc.WriteMessage(newErrorResponse(json.RawMessage(`"123"`), newError(ErrCodeServer, errorText, nil)))
 	if err != nil {
 		t.Fatal(err)
 	}
 	if !strings.Contains(out.String(), errorText) {
 		t.Fatalf("Response didn't contain expected error %q: %s", errorText, &out)
 	}
-	if strings.Contains(out.String(), valueText) {
-		t.Errorf("Response contains both an error and value: %s", &out)
+	if strings.Contains(out.String(), "result") {
+		t.Errorf("Response contains both an error and result: %s", &out)
+	}
+
+	out.Reset()
+	if err = sc.WriteMessage(&jsonResponse{Version: jsonrpcVersion, Id: null}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"result":null`) || strings.Contains(out.String(), "error") {
+		t.Errorf("Response has no null result: %s", &out)
 	}
 }
 
 func Test_UnexpectedError(t *testing.T) {
 	cli, srv := myPipe()
 	go cli.PipeWriter.CloseWithError(errors.New("unexpected error!")) // reader will get this error
-	ServeConn(srv)                                                    // must return, not loop
+	newTestServer().ServeConn(srv)                                    // must return, not loop
+}
+
+func Test_Client(t *testing.T) {
+	cli, srv := net.Pipe()
+	go newTestServer().ServeConn(srv)
+
+	client := NewClient(cli)
+	defer client.Close()
+
+	var reply Reply
+	if err := client.Call("Arith.Add", &Args{7, 8}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.C != 15 {
+		t.Fatalf("bad result: %d", reply.C)
+	}
+
+	err := client.Call("Arith.Div", &Args{7, 0}, &reply)
+	if rpcErr, ok := err.(*Error); !ok || rpcErr.Code != ErrCodeServer || rpcErr.Message != "divide by zero" {
+		t.Fatalf("bad error %v", err)
+	}
+
+	err = client.Call("Arith.Sub", &Args{7, 8}, &reply)
+	if rpcErr, ok := err.(*Error); !ok || rpcErr.Code != ErrCodeMethodNotFound {
+		t.Fatalf("bad error %v", err)
+	}
 }
 
 // Copied from package net.
@@ -0,0 +1,320 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package rpc
+
+import (
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxLimiterBuckets is the number of token buckets of a rate limiter, beyond which the idle
+// buckets are removed.
+const maxLimiterBuckets = 10000
+
+// defaultMaxPendingRequests is the max number of the requests of a persistent connection
+// handled concurrently if not configured.
+const defaultMaxPendingRequests = 64
+
+// The default requests per second of each client IP and each API key or JWT of the public
+// rpc endpoints, see WithDefaultRates.
+const (
+	DefaultIPRate  = 100
+	DefaultKeyRate = 100
+)
+
+var (
+	errRateLimited   = newError(ErrCodeLimitExceeded, "rate limit exceeded", nil)
+	errBodyTooLarge  = newError(ErrCodeLimitExceeded, "request too large", nil)
+	errBatchTooLarge = newError(ErrCodeLimitExceeded, "batch too large", nil)
+	errCallTimeout   = newError(ErrCodeTimeout, "execution timeout", nil)
+)
+
+// LimitConfig is the limits config of an rpc endpoint, whose zero values are unlimited except
+// MaxPendingRequests. Each request of a batch consumes a token of the rate limits.
+type LimitConfig struct {
+	// the requests per second and the burst of each client IP. The burst is the rate
+	// rounded up if 0.
+	IPRate  float64
+	IPBurst int
+
+	// the requests per second and the burst of each API key or JWT, which is limited only
+	// if the endpoint requires authentication.
+	KeyRate  float64
+	KeyBurst int
+
+	// the max bytes of a HTTP request body, or a message of the persistent connections.
+	MaxBodySize int64
+
+	// the max number of the requests in a batch
+	MaxBatchLength int
+
+	// the max number of the requests of a persistent connection handled concurrently, beyond
+	// which the next message of the connection is not read until a request is done. It is
+	// defaultMaxPendingRequests if 0.
+	MaxPendingRequests int
+
+	// the execution timeouts in milliseconds of the methods by the name "namespace.Method",
+	// the namespace, or "*" for all methods. The timed out methods keep running, but their
+	// replies are discarded.
+	MethodTimeouts map[string]uint64
+}
+
+// methodTimeout returns the execution timeout of the method, 0 if unlimited.
+func (c *LimitConfig) methodTimeout(method string) time.Duration {
+	if c == nil || len(c.MethodTimeouts) == 0 {
+		return 0
+	}
+
+	ms, ok := c.MethodTimeouts[method]
+	if !ok {
+		if dot := strings.LastIndex(method, "."); dot >= 0 {
+			ms, ok = c.MethodTimeouts[method[:dot]]
+		}
+	}
+
+	if !ok {
+		ms = c.MethodTimeouts["*"]
+	}
+
+	return time.Duration(ms) * time.Millisecond
+}
+
+// WithDefaultRates returns a copy of the config whose zero rates are replaced by DefaultIPRate
+// and DefaultKeyRate, which is used by the public rpc endpoints. The negative rates are unlimited.
+func (c LimitConfig) WithDefaultRates() *LimitConfig {
+	if c.IPRate == 0 {
+		c.IPRate = DefaultIPRate
+	}
+
+	if c.KeyRate == 0 {
+		c.KeyRate = DefaultKeyRate
+	}
+
+	return &c
+}
+
+// maxPendingRequests returns the max number of the requests of a connection handled concurrently.
+func (c *LimitConfig) maxPendingRequests() int {
+	if c == nil || c.MaxPendingRequests <= 0 {
+		return defaultMaxPendingRequests
+	}
+
+	return c.MaxPendingRequests
+}
+
+// bodyTooLarge returns true if the size exceeds the max body size.
+func (c *LimitConfig) bodyTooLarge(size int64) bool {
+	return c != nil && c.MaxBodySize > 0 && size > c.MaxBodySize
+}
+
+// batchTooLarge returns true if the length exceeds the max batch length.
+func (c *LimitConfig) batchTooLarge(length int) bool {
+	return c != nil && c.MaxBatchLength > 0 && length > c.MaxBatchLength
+}
+
+// RejectionStats is the number of the requests rejected by the limits of the server.
+type RejectionStats struct {
+	RateLimited   uint64
+	BodyTooLarge  uint64
+	BatchTooLarge uint64
+	Timeout       uint64
+}
+
+// rejectionCounters counts the rejected requests, which are updated atomically.
+type rejectionCounters struct {
+	rateLimited   uint64
+	bodyTooLarge  uint64
+	batchTooLarge uint64
+	timeout       uint64
+}
+
+// tokenBucket is the token bucket of a client, which is refilled lazily when consumed.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter limits the requests of the clients with a token bucket per client.
+type rateLimiter struct {
+	rate  float64 // the tokens refilled per second
+	burst float64 // the size of the buckets
+
+	mutex   sync.Mutex // protects buckets
+	buckets map[string]*tokenBucket
+}
+
+// newRateLimiter returns a rate limiter, or nil if the rate is unlimited.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow consumes a token of the client, and returns false if there is no token.
+func (l *rateLimiter) allow(client string, now time.Time) bool {
+	if l == nil || len(client) == 0 {
+		return true
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	bucket := l.buckets[client]
+	if bucket == nil {
+		if len(l.buckets) >= maxLimiterBuckets {
+			l.removeIdle(now)
+		}
+
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = bucket
+	} else {
+		bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+		bucket.last = now
+	}
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+	return true
+}
+
+// removeIdle removes the buckets which are refilled, as they are the same as the new ones.
+func (l *rateLimiter) removeIdle(now time.Time) {
+	for client, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// remoteIP returns the IP of the remote address "host:port".
+func remoteIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	return host
+}
+
+// SetLimits sets the rate limits, the size limits and the method timeouts of the server,
+// which must be called before serving.
+func (server *Server) SetLimits(config *LimitConfig) {
+	server.limits = config
+	server.ipLimiter, server.keyLimiter = nil, nil
+
+	if config != nil {
+		server.ipLimiter = newRateLimiter(config.IPRate, config.IPBurst)
+		server.keyLimiter = newRateLimiter(config.KeyRate, config.KeyBurst)
+	}
+}
+
+// Rejections returns the number of the requests rejected by the limits.
+func (server *Server) Rejections() RejectionStats {
+	return RejectionStats{
+		RateLimited:   atomic.LoadUint64(&server.rejections.rateLimited),
+		BodyTooLarge:  atomic.LoadUint64(&server.rejections.bodyTooLarge),
+		BatchTooLarge: atomic.LoadUint64(&server.rejections.batchTooLarge),
+		Timeout:       atomic.LoadUint64(&server.rejections.timeout),
+	}
+}
+
+// rateLimit consumes the tokens of the client IP and key of the request. The IP token is
+// not consumed again if it is taken by the authentication of the message.
+func (server *Server) rateLimit(ctx *callContext) *Error {
+	if ctx == nil {
+		return nil
+	}
+
+	now := time.Now()
+	ipAllowed := ctx.ipTokenTaken || server.ipLimiter.allow(ctx.ip, now)
+	ctx.ipTokenTaken = false
+
+	if !ipAllowed || !server.keyLimiter.allow(ctx.credential(), now) {
+		atomic.AddUint64(&server.rejections.rateLimited, 1)
+		return errRateLimited
+	}
+
+	return nil
+}
+
+// rateLimitIP consumes a token of the client IP before the credential is verified, so that
+// the credentials could not be guessed without limits.
+func (server *Server) rateLimitIP(ip string) *Error {
+	if server.ipLimiter.allow(ip, time.Now()) {
+		return nil
+	}
+
+	atomic.AddUint64(&server.rejections.rateLimited, 1)
+	return errRateLimited
+}
+
+// rejectBody returns the error if the size exceeds the max body size.
+func (server *Server) rejectBody(size int64) *Error {
+	if !server.limits.bodyTooLarge(size) {
+		return nil
+	}
+
+	atomic.AddUint64(&server.rejections.bodyTooLarge, 1)
+	return errBodyTooLarge
+}
+
+// rejectBatch returns the error if the length exceeds the max batch length.
+func (server *Server) rejectBatch(length int) *Error {
+	if !server.limits.batchTooLarge(length) {
+		return nil
+	}
+
+	atomic.AddUint64(&server.rejections.batchTooLarge, 1)
+	return errBatchTooLarge
+}
+
+// callWithTimeout calls the method of the request, and returns the timeout error if the
+// method does not return within its timeout.
+func (server *Server) callWithTimeout(req *jsonRequest) (interface{}, *Error) {
+	timeout := server.limits.methodTimeout(req.Method)
+	if timeout == 0 {
+		return server.call(req)
+	}
+
+	type callResult struct {
+		result interface{}
+		err    *Error
+	}
+
+	done := make(chan callResult, 1)
+	go func() {
+		result, err := server.call(req)
+		done <- callResult{result, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.result, r.err
+	case <-timer.C:
+		atomic.AddUint64(&server.rejections.timeout, 1)
+		return nil, errCallTimeout
+	}
+}
@@ -0,0 +1,272 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Sleeper struct{}
+
+func (s *Sleeper) Sleep(ms *int, reply *bool) error {
+	time.Sleep(time.Duration(*ms) * time.Millisecond)
+	*reply = true
+	return nil
+}
+
+func Test_RateLimiter(t *testing.T) {
+	assert.Equal(t, newRateLimiter(0, 10) == nil, true)
+	assert.Equal(t, (*rateLimiter)(nil).allow("client", time.Now()), true)
+
+	limiter := newRateLimiter(2, 3)
+	now := time.Now()
+
+	// the burst is consumed at once
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, limiter.allow("client1", now), true)
+	}
+	assert.Equal(t, limiter.allow("client1", now), false)
+
+	// the buckets of the clients are separated
+	assert.Equal(t, limiter.allow("client2", now), true)
+
+	// refilled by the rate
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(t, limiter.allow("client1", now), true)
+	assert.Equal(t, limiter.allow("client1", now), false)
+
+	// the refilled buckets are removed
+	limiter.removeIdle(now.Add(2 * time.Second))
+	assert.Equal(t, len(limiter.buckets), 0)
+
+	// the burst is the rate rounded up by default
+	assert.Equal(t, newRateLimiter(1.5, 0).burst, float64(2))
+}
+
+func Test_LimitConfig_MethodTimeout(t *testing.T) {
+	assert.Equal(t, (*LimitConfig)(nil).methodTimeout("seele.GetInfo"), time.Duration(0))
+
+	config := &LimitConfig{MethodTimeouts: map[string]uint64{
+		"seele.GetBlockByHeight": 100,
+		"seele":                  200,
+		"*":                      300,
+	}}
+
+	assert.Equal(t, config.methodTimeout("seele.GetBlockByHeight"), 100*time.Millisecond)
+	assert.Equal(t, config.methodTimeout("seele.GetInfo"), 200*time.Millisecond)
+	assert.Equal(t, config.methodTimeout("debug.TraceBlock"), 300*time.Millisecond)
+}
+
+func Test_LimitConfig_WithDefaultRates(t *testing.T) {
+	config := LimitConfig{KeyRate: -1, MaxBodySize: 128}
+	defaults := config.WithDefaultRates()
+
+	assert.Equal(t, defaults.IPRate, float64(DefaultIPRate))
+	assert.Equal(t, defaults.KeyRate, float64(-1))
+	assert.Equal(t, defaults.MaxBodySize, int64(128))
+	assert.Equal(t, config.IPRate, float64(0))
+
+	// the negative rates are unlimited
+	assert.Equal(t, newRateLimiter(defaults.KeyRate, 0) == nil, true)
+}
+
+func Test_Server_Limits(t *testing.T) {
+	server := newTestServer()
+	server.RegisterName("sleeper", new(Sleeper))
+	server.SetLimits(&LimitConfig{
+		IPRate:         1,
+		IPBurst:        3,
+		MaxBodySize:    256,
+		MaxBatchLength: 2,
+		MethodTimeouts: map[string]uint64{"sleeper": 50},
+	})
+
+	call := func(ip string, request string) *testResponse {
+		data, err := json.Marshal(server.handleMessage(json.RawMessage(request), &callContext{ip: ip}))
+		assert.Nil(t, err)
+
+		var resp testResponse
+		assert.Nil(t, json.Unmarshal(data, &resp))
+		return &resp
+	}
+
+	// timeout
+	resp := call("1.1.1.1", `{"jsonrpc": "2.0", "method": "sleeper.Sleep", "params": [10], "id": 1}`)
+	assert.Nil(t, resp.Error)
+
+	resp = call("1.1.1.1", `{"jsonrpc": "2.0", "method": "sleeper.Sleep", "params": [500], "id": 1}`)
+	assert.Equal(t, resp.Error.Code, ErrCodeTimeout)
+
+	// rate limit
+	resp = call("1.1.1.1", `{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1, 2], "id": 1}`)
+	assert.Nil(t, resp.Error)
+
+	resp = call("1.1.1.1", `{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1, 2], "id": 1}`)
+	assert.Equal(t, resp.Error.Code, ErrCodeLimitExceeded)
+
+	resp = call("2.2.2.2", `{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1, 2], "id": 1}`)
+	assert.Nil(t, resp.Error)
+
+	// batch length
+	batch := `{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1, 2], "id": 1}`
+	resp = call("3.3.3.3", "["+strings.Repeat(batch+",", 2)+batch+"]")
+	assert.Equal(t, resp.Error.Code, ErrCodeLimitExceeded)
+
+	// body size
+	resp = call("3.3.3.3", `{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1, 2], "id": "`+strings.Repeat("a", 256)+`"}`)
+	assert.Equal(t, resp.Error.Code, ErrCodeLimitExceeded)
+
+	assert.Equal(t, server.Rejections(), RejectionStats{RateLimited: 1, BodyTooLarge: 1, BatchTooLarge: 1, Timeout: 1})
+}
+
+func Test_HTTPServe_Limits(t *testing.T) {
+	server, _ := NewHTTPServer(nil, nil)
+	server.RegisterName("arith", new(Arith))
+	server.SetAuth(&AuthConfig{APIKeys: []string{"key1", "key2"}})
+	server.SetLimits(&LimitConfig{KeyRate: 1, MaxBodySize: 128})
+
+	serve := func(key string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://url.com", strings.NewReader(body))
+		req.Header.Set("content-type", "application/json")
+		req.Header.Set("X-API-Key", key)
+
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	request := `{"jsonrpc": "2.0", "method": "arith.Add", "params": [1, 2], "id": 1}`
+	var resp testResponse
+
+	w := serve("key1", request)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Nil(t, resp.Error)
+
+	w = serve("key1", request)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, resp.Error.Code, ErrCodeLimitExceeded)
+
+	var keyResp testResponse
+	w = serve("key2", request)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &keyResp))
+	assert.Nil(t, keyResp.Error)
+
+	// the large body is not read entirely
+	w = serve("key2", request+strings.Repeat(" ", 1024))
+	assert.Equal(t, w.Code, http.StatusRequestEntityTooLarge)
+
+	var bodyResp testResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &bodyResp))
+	assert.Equal(t, bodyResp.Error.Code, ErrCodeLimitExceeded)
+
+	assert.Equal(t, server.Rejections(), RejectionStats{RateLimited: 1, BodyTooLarge: 1})
+}
+
+func Test_HTTPServe_AuthRateLimit(t *testing.T) {
+	server, _ := NewHTTPServer(nil, nil)
+	server.RegisterName("arith", new(Arith))
+	server.SetAuth(&AuthConfig{APIKeys: []string{"key"}})
+	server.SetLimits(&LimitConfig{IPRate: 1, IPBurst: 3})
+
+	serve := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://url.com", strings.NewReader(`{"jsonrpc": "2.0", "method": "arith.Add", "params": [1, 2], "id": 1}`))
+		req.Header.Set("content-type", "application/json")
+		req.Header.Set("X-API-Key", key)
+
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	// the request takes a token of the IP only once
+	assert.Equal(t, serve("key").Code, http.StatusOK)
+
+	// the invalid credentials take the tokens of the IP
+	assert.Equal(t, serve("wrong").Code, http.StatusUnauthorized)
+	assert.Equal(t, serve("wrong").Code, http.StatusUnauthorized)
+
+	w := serve("key")
+	assert.Equal(t, w.Code, http.StatusTooManyRequests)
+
+	var resp testResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, resp.Error.Code, ErrCodeLimitExceeded)
+
+	assert.Equal(t, server.Rejections(), RejectionStats{RateLimited: 1})
+}
+
+func Test_ServeConn_MaxMessageSize(t *testing.T) {
+	server := newTestServer()
+	server.SetLimits(&LimitConfig{MaxBodySize: 128})
+
+	cli, srv := net.Pipe()
+	defer cli.Close()
+	go server.ServeConn(srv)
+	dec := json.NewDecoder(cli)
+
+	// the messages within the limit are served, though they are more than the limit in total
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(cli, `{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1, 2], "id": %d}`, i)
+
+		var resp testResponse
+		assert.Nil(t, dec.Decode(&resp))
+		assert.Nil(t, resp.Error)
+	}
+
+	// the large message is not read entirely, and the connection is closed
+	go fmt.Fprintf(cli, `{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1, 2], "id": "%s"}`, strings.Repeat("a", 1024))
+
+	var resp testResponse
+	assert.Nil(t, dec.Decode(&resp))
+	assert.Equal(t, resp.Error.Code, ErrCodeLimitExceeded)
+
+	_, err := cli.Read(make([]byte, 1))
+	assert.NotNil(t, err)
+
+	assert.Equal(t, server.Rejections(), RejectionStats{BodyTooLarge: 1})
+}
+
+func Test_ServeConn_MaxPendingRequests(t *testing.T) {
+	server := newTestServer()
+	server.RegisterName("sleeper", new(Sleeper))
+	server.SetLimits(&LimitConfig{MaxPendingRequests: 1})
+
+	cli, srv := net.Pipe()
+	defer cli.Close()
+	go server.ServeConn(srv)
+	dec := json.NewDecoder(cli)
+
+	fmt.Fprint(cli, `{"jsonrpc": "2.0", "method": "sleeper.Sleep", "params": [300], "id": 1}`)
+
+	// the next message is not read until the pending request is done
+	written := make(chan struct{})
+	go func() {
+		fmt.Fprint(cli, `{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1, 2], "id": 2}`)
+		close(written)
+	}()
+
+	select {
+	case <-written:
+		t.Fatal("the message is read while the request is pending")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for id := 1; id <= 2; id++ {
+		var resp testResponse
+		assert.Nil(t, dec.Decode(&resp))
+		assert.Nil(t, resp.Error)
+		assert.Equal(t, resp.Id, float64(id))
+	}
+}
@@ -6,15 +6,48 @@
 package rpc
 
 import (
-	"net/rpc"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
 )
 
 // MetadataAPI is a default service for RegisterName.
 const MetadataAPI = "rpc"
 
-// Server represents a RPC server
+// Precompute the reflect type for error. Can't use error directly
+// because Typeof takes an empty interface value. This is annoying.
+var typeOfError = reflect.TypeOf((*error)(nil)).Elem()
+
+var (
+	errServiceNameEmpty   = errors.New("rpc: no service name")
+	errServiceDefined     = errors.New("rpc: service already defined")
+	errNoSuitableMethods  = errors.New("rpc: no exported methods of suitable type")
+	errServiceNotExported = errors.New("rpc: service type is not exported")
+)
+
+// Server represents a JSON-RPC 2.0 server. The methods of the registered services
+// are called by the name "namespace.Method".
 type Server struct {
-	rpc.Server
+	rejections rejectionCounters // the first field to be 64-bit aligned for the atomic operations
+
+	mutex    sync.RWMutex // protects services and topics
+	services map[string]*service
+	topics   map[string]*Topic
+
+	auth *AuthConfig // nil if the clients are not authenticated
+
+	limits     *LimitConfig // nil if unlimited
+	ipLimiter  *rateLimiter // nil if the client IPs are not rate limited
+	keyLimiter *rateLimiter // nil if the client keys are not rate limited
 }
 
 // API is a collection of methods for the RPC interface.
@@ -29,21 +62,353 @@ type API struct {
 	Public bool
 }
 
-// RPCService offers meta information of the server.
-//type RPCService struct {
-//	server *Server
-//}
+// service is a registered receiver with its suitable methods.
+type service struct {
+	name    string
+	rcvr    reflect.Value
+	methods map[string]*methodType
+}
+
+// methodType is a method of the form
+//
+//	func (t *T) MethodName(argType T1, replyType *T2) error
+//
+// which is the same as the net/rpc package.
+type methodType struct {
+	method    reflect.Method
+	ArgType   reflect.Type
+	ReplyType reflect.Type
+}
 
 // NewServer returns a new Server.
 func NewServer() *Server {
-	server := &Server{
-		rpc.Server{},
+	return &Server{
+		services: make(map[string]*service),
+		topics:   make(map[string]*Topic),
+	}
+}
+
+// SetAuth enables the authentication of the clients if the config is enabled, which must be
+// called before serving. The http requests are authenticated by the headers, and the persistent
+// connections are authenticated by the authenticate method or the handshake headers.
+func (server *Server) SetAuth(config *AuthConfig) {
+	server.auth = config
+}
+
+// Register publishes the suitable methods of the receiver in the server,
+// with the namespace of the concrete type name of the receiver.
+func (server *Server) Register(rcvr interface{}) error {
+	return server.register(rcvr, reflect.Indirect(reflect.ValueOf(rcvr)).Type().Name(), false)
+}
+
+// RegisterName is like Register but uses the provided name for the namespace.
+func (server *Server) RegisterName(name string, rcvr interface{}) error {
+	return server.register(rcvr, name, true)
+}
+
+func (server *Server) register(rcvr interface{}, name string, useName bool) error {
+	if name == "" {
+		return errServiceNameEmpty
+	}
+
+	if !useName && !isExported(name) {
+		return errServiceNotExported
+	}
+
+	s := &service{
+		name:    name,
+		rcvr:    reflect.ValueOf(rcvr),
+		methods: suitableMethods(reflect.TypeOf(rcvr)),
+	}
+
+	if len(s.methods) == 0 {
+		return errNoSuitableMethods
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if _, ok := server.services[name]; ok {
+		return errServiceDefined
+	}
+
+	server.services[name] = s
+	return nil
+}
+
+// suitableMethods returns the methods of the type that are suitable for RPC.
+func suitableMethods(typ reflect.Type) map[string]*methodType {
+	methods := make(map[string]*methodType)
+	for m := 0; m < typ.NumMethod(); m++ {
+		method := typ.Method(m)
+		mtype := method.Type
+
+		// Method must be exported.
+		if method.PkgPath != "" {
+			continue
+		}
+
+		// Method needs three ins: receiver, *args, *reply.
+		if mtype.NumIn() != 3 {
+			continue
+		}
+
+		// First arg need not be a pointer.
+		argType := mtype.In(1)
+		if !isExportedOrBuiltinType(argType) {
+			continue
+		}
+
+		// Second arg must be a pointer.
+		replyType := mtype.In(2)
+		if replyType.Kind() != reflect.Ptr || !isExportedOrBuiltinType(replyType) {
+			continue
+		}
+
+		// Method needs one out which is error.
+		if mtype.NumOut() != 1 || mtype.Out(0) != typeOfError {
+			continue
+		}
+
+		methods[method.Name] = &methodType{method: method, ArgType: argType, ReplyType: replyType}
+	}
+
+	return methods
+}
+
+func isExported(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
+
+func isExportedOrBuiltinType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// PkgPath will be non-empty even for an exported type,
+	// so we need to check the type name as well.
+	return isExported(t.Name()) || t.PkgPath() == ""
+}
+
+// lookup returns the registered service and method of the name "namespace.Method".
+func (server *Server) lookup(serviceMethod string) (*service, *methodType, *Error) {
+	dot := strings.LastIndex(serviceMethod, ".")
+	if dot < 0 {
+		return nil, nil, newError(ErrCodeMethodNotFound, "the method "+serviceMethod+" does not exist", nil)
+	}
+
+	server.mutex.RLock()
+	s := server.services[serviceMethod[:dot]]
+	server.mutex.RUnlock()
+
+	if s == nil {
+		return nil, nil, newError(ErrCodeMethodNotFound, "the method "+serviceMethod+" does not exist", nil)
+	}
+
+	mtype := s.methods[serviceMethod[dot+1:]]
+	if mtype == nil {
+		return nil, nil, newError(ErrCodeMethodNotFound, "the method "+serviceMethod+" does not exist", nil)
+	}
+
+	return s, mtype, nil
+}
+
+// ServeConn runs the server on a single stream connection of JSON values, e.g. a TCP connection.
+// ServeConn blocks, serving the connection until the client hangs up.
+// The caller typically invokes ServeConn with go-routine.
+func (server *Server) ServeConn(conn io.ReadWriteCloser) {
+	var ip string
+	if netConn, ok := conn.(net.Conn); ok {
+		ip = remoteIP(netConn.RemoteAddr().String())
 	}
 
-	// Not implemented service
-	// register a default service which will provide meta information about the RPC service.
-	//rpcService := &RPCService{server}
-	//server.RegisterName(MetadataAPI, rpcService)
+	var maxMessageSize int64
+	if server.limits != nil {
+		maxMessageSize = server.limits.MaxBodySize
+	}
+
+	server.serveCodec(newJsonCodec(conn, maxMessageSize), ip, "")
+}
+
+// ServeCodec is like ServeConn but uses the specified codec to read requests
+// and write responses. The requests are handled concurrently.
+func (server *Server) ServeCodec(codec ServerCodec) {
+	server.serveCodec(codec, "", "")
+}
+
+// serveCodec serves the codec of the client IP, which is empty if unknown. The connection is
+// authenticated if the credential is verified, e.g. by the headers of the WebSocket handshake
+// request, or the server does not require authentication.
+func (server *Server) serveCodec(codec ServerCodec, ip string, credential string) {
+	conn := newConnection(codec)
+	if !server.auth.Enabled() || len(credential) > 0 {
+		conn.setAuthenticated(credential)
+	}
+
+	// the requests handled concurrently are bounded, so that a client could not pile up
+	// goroutines by pipelining requests without reading the responses.
+	pending := make(chan struct{}, server.limits.maxPendingRequests())
+
+	var wg sync.WaitGroup
+	defer func() {
+		conn.close()
+		wg.Wait()
+		codec.Close()
+	}()
+
+	for {
+		pending <- struct{}{}
+		msg, err := codec.ReadMessage()
+		if err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				// the stream can not be recovered from the invalid JSON
+				codec.WriteMessage(newErrorResponse(nil, newError(ErrCodeParse, "parse error", err.Error())))
+			} else if err == errMessageTooLarge {
+				// the rest of the large message is not read, so the stream can not be recovered
+				codec.WriteMessage(newErrorResponse(nil, errBodyTooLarge))
+				atomic.AddUint64(&server.rejections.bodyTooLarge, 1)
+			} else if err == errWSMessageTooBig {
+				atomic.AddUint64(&server.rejections.bodyTooLarge, 1)
+			}
+
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-pending
+				wg.Done()
+			}()
+
+			ctx := &callContext{conn: conn, ip: ip}
+			if response := server.handleMessage(msg, ctx); response != nil {
+				codec.WriteMessage(response)
+			}
+
+			ctx.activate()
+		}()
+	}
+}
+
+// handleMessage handles a request or a batch of requests, and returns the response or
+// the batch of responses. It returns nil if there is nothing to respond, i.e. all requests
+// are notifications. The context is nil if the client is unknown.
+func (server *Server) handleMessage(msg json.RawMessage, ctx *callContext) interface{} {
+	if err := server.rejectBody(int64(len(msg))); err != nil {
+		return newErrorResponse(nil, err)
+	}
+
+	if !json.Valid(msg) {
+		return newErrorResponse(nil, newError(ErrCodeParse, "parse error", "invalid JSON"))
+	}
+
+	msg = bytes.TrimSpace(msg)
+	if len(msg) == 0 || msg[0] != '[' {
+		if response := server.handleRequest(msg, ctx); response != nil {
+			return response
+		}
+
+		return nil
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(msg, &batch); err != nil {
+		return newErrorResponse(nil, newError(ErrCodeParse, "parse error", err.Error()))
+	}
+
+	if len(batch) == 0 {
+		return newErrorResponse(nil, newError(ErrCodeInvalidRequest, "invalid request", "empty batch"))
+	}
+
+	if err := server.rejectBatch(len(batch)); err != nil {
+		return newErrorResponse(nil, err)
+	}
+
+	responses := make([]*jsonResponse, 0, len(batch))
+	for _, req := range batch {
+		if response := server.handleRequest(req, ctx); response != nil {
+			responses = append(responses, response)
+		}
+	}
+
+	if len(responses) == 0 {
+		return nil
+	}
+
+	return responses
+}
+
+// handleRequest calls the method of a single request, and returns nil for a valid notification.
+func (server *Server) handleRequest(msg json.RawMessage, ctx *callContext) *jsonResponse {
+	var req jsonRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return newErrorResponse(nil, newError(ErrCodeInvalidRequest, "invalid request", err.Error()))
+	}
+
+	if err := req.validate(); err != nil {
+		return newErrorResponse(req.id(), err)
+	}
+
+	var result interface{}
+	err := server.rateLimit(ctx)
+	switch {
+	case err != nil:
+		// rejected by the rate limits
+	case ctx != nil && ctx.conn != nil && req.Method != authenticateMethod && !ctx.conn.isAuthenticated():
+		err = unauthorizedError(errMissingCredential)
+	case req.Method == authenticateMethod:
+		result, err = server.authenticate(ctx, req.Params)
+	case req.Method == subscribeMethod:
+		result, err = server.subscribe(ctx, req.Params)
+	case req.Method == unsubscribeMethod:
+		result, err = server.unsubscribe(ctx, req.Params)
+	default:
+		result, err = server.callWithTimeout(&req)
+	}
+
+	if req.isNotification() {
+		return nil
+	}
+
+	if err != nil {
+		return newErrorResponse(req.id(), err)
+	}
+
+	return &jsonResponse{Version: jsonrpcVersion, Id: req.id(), Result: result}
+}
+
+// call calls the method of the request, and returns the reply of the method.
+func (server *Server) call(req *jsonRequest) (result interface{}, rpcErr *Error) {
+	s, mtype, rpcErr := server.lookup(req.Method)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	argv, err := parseParams(req.Params, mtype.ArgType)
+	if err != nil {
+		return nil, newError(ErrCodeInvalidParams, "invalid params", err.Error())
+	}
+
+	replyv := reflect.New(mtype.ReplyType.Elem())
+	switch mtype.ReplyType.Elem().Kind() {
+	case reflect.Map:
+		replyv.Elem().Set(reflect.MakeMap(mtype.ReplyType.Elem()))
+	case reflect.Slice:
+		replyv.Elem().Set(reflect.MakeSlice(mtype.ReplyType.Elem(), 0, 0))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result, rpcErr = nil, newError(ErrCodeInternal, "internal error", fmt.Sprint(r))
+		}
+	}()
+
+	returnValues := mtype.method.Func.Call([]reflect.Value{s.rcvr, argv, replyv})
+	if errInter := returnValues[0].Interface(); errInter != nil {
+		return nil, toError(errInter.(error))
+	}
 
-	return server
+	return replyv.Interface(), nil
 }
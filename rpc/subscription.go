@@ -0,0 +1,338 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package rpc
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/seeleteam/go-seele/common/hexutil"
+)
+
+// The built-in methods of the subscriptions, which are available on the persistent
+// connections, e.g. TCP and WebSocket.
+const (
+	subscribeMethod    = "subscribe"
+	unsubscribeMethod  = "unsubscribe"
+	notificationMethod = "subscription"
+)
+
+// maxQueuedNotifications is the max number of results of a subscription waiting to send,
+// the subscription of the client not keeping up is dropped.
+const maxQueuedNotifications = 1000
+
+// maxConnSubscriptions is the max number of active subscriptions of a connection.
+const maxConnSubscriptions = 100
+
+var (
+	errTopicNameEmpty          = errors.New("rpc: no topic name")
+	errTopicDefined            = errors.New("rpc: topic already defined")
+	errNotificationUnsupported = newError(ErrCodeMethodNotFound, "notifications not supported", nil)
+	errTooManySubscriptions    = newError(ErrCodeLimitExceeded, "too many subscriptions", nil)
+)
+
+// Topic is a topic of the subscribe method. Subscribe decodes the params of the topic,
+// and starts to call notify with the results until unsubscribe is called. The results
+// are sent to the client with the method "subscription".
+type Topic struct {
+	Name      string
+	Namespace string // the API namespace of the topic, which is exposed if the namespace is allowed
+	Subscribe func(params json.RawMessage, notify func(result interface{})) (unsubscribe func(), err error)
+}
+
+// RegisterTopic registers the topic of the subscribe method.
+func (server *Server) RegisterTopic(topic Topic) error {
+	if topic.Name == "" {
+		return errTopicNameEmpty
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if _, ok := server.topics[topic.Name]; ok {
+		return errTopicDefined
+	}
+
+	server.topics[topic.Name] = &topic
+	return nil
+}
+
+// connection is a persistent connection of the server, which keeps the subscriptions.
+type connection struct {
+	codec ServerCodec
+
+	mutex         sync.Mutex // protects subs, authenticated and credential
+	subs          map[string]*subscription
+	authenticated bool
+	credential    string // the verified API key or JWT, empty if not required
+	closed        chan struct{}
+}
+
+func newConnection(codec ServerCodec) *connection {
+	return &connection{
+		codec:  codec,
+		subs:   make(map[string]*subscription),
+		closed: make(chan struct{}),
+	}
+}
+
+func (c *connection) isAuthenticated() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.authenticated
+}
+
+// setAuthenticated authenticates the connection with the verified credential.
+func (c *connection) setAuthenticated(credential string) {
+	c.mutex.Lock()
+	c.authenticated = true
+	c.credential = credential
+	c.mutex.Unlock()
+}
+
+func (c *connection) getCredential() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.credential
+}
+
+// close stops all subscriptions of the connection.
+func (c *connection) close() {
+	close(c.closed)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for id, sub := range c.subs {
+		sub.stop()
+		delete(c.subs, id)
+	}
+}
+
+// drop stops the subscription and removes it from the connection.
+func (c *connection) drop(sub *subscription) {
+	c.mutex.Lock()
+	delete(c.subs, sub.id)
+	c.mutex.Unlock()
+
+	sub.stop()
+}
+
+// callContext is the context of a request message, which is a request or a batch of requests.
+type callContext struct {
+	conn *connection     // nil if the transport is not persistent, e.g. HTTP
+	subs []*subscription // the subscriptions created by the message
+
+	ip  string // the client IP, empty if unknown
+	key string // the verified credential of the HTTP request, empty if not required
+
+	ipTokenTaken bool // the IP token of the first request is taken by the authentication
+}
+
+// credential returns the verified credential of the client, which is rate limited.
+func (ctx *callContext) credential() string {
+	if ctx.conn != nil {
+		return ctx.conn.getCredential()
+	}
+
+	return ctx.key
+}
+
+// activate starts to send the notifications of the subscriptions created by the message,
+// which is called after the response is sent.
+func (ctx *callContext) activate() {
+	if ctx == nil {
+		return
+	}
+
+	for _, sub := range ctx.subs {
+		close(sub.ready)
+	}
+}
+
+type subscription struct {
+	id          string
+	conn        *connection
+	ready       chan struct{}    // closed after the subscription id is responded
+	queue       chan interface{} // the results waiting to send
+	overflow    chan struct{}    // closed when the queue is full
+	quit        chan struct{}    // closed when the subscription is stopped
+	unsubscribe func()
+
+	overflowOnce sync.Once
+	stopOnce     sync.Once
+}
+
+func newSubscription(conn *connection) *subscription {
+	return &subscription{
+		id:       newSubscriptionID(),
+		conn:     conn,
+		ready:    make(chan struct{}),
+		queue:    make(chan interface{}, maxQueuedNotifications),
+		overflow: make(chan struct{}),
+		quit:     make(chan struct{}),
+	}
+}
+
+type subscriptionResult struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+type jsonNotification struct {
+	Version string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  subscriptionResult `json:"params"`
+}
+
+// notify queues the result to send to the client, it never blocks so that the topic could
+// notify in the goroutine firing the events. The subscription is dropped if the queue is full.
+func (s *subscription) notify(result interface{}) {
+	select {
+	case s.queue <- result:
+	default:
+		s.overflowOnce.Do(func() { close(s.overflow) })
+	}
+}
+
+// send writes the queued results to the client in order after the subscription id is
+// responded, until the subscription is stopped or dropped for the overflow.
+func (s *subscription) send() {
+	select {
+	case <-s.ready:
+	case <-s.quit:
+		return
+	}
+
+	for {
+		select {
+		case result := <-s.queue:
+			s.conn.codec.WriteMessage(&jsonNotification{
+				Version: jsonrpcVersion,
+				Method:  notificationMethod,
+				Params:  subscriptionResult{s.id, result},
+			})
+		case <-s.overflow:
+			s.conn.drop(s)
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// stop unsubscribes the topic and stops sending the results.
+func (s *subscription) stop() {
+	s.stopOnce.Do(func() {
+		s.unsubscribe()
+		close(s.quit)
+	})
+}
+
+func newSubscriptionID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hexutil.BytesToHex(id[:])
+}
+
+// subscribe handles the subscribe method with the params [topic, topic params], and returns
+// the subscription id.
+func (server *Server) subscribe(ctx *callContext, params json.RawMessage) (interface{}, *Error) {
+	if ctx == nil || ctx.conn == nil {
+		return nil, errNotificationUnsupported
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(params, &list); err != nil || len(list) == 0 || len(list) > 2 {
+		return nil, newError(ErrCodeInvalidParams, "invalid params", "expected [topic] or [topic, params]")
+	}
+
+	var name string
+	if err := json.Unmarshal(list[0], &name); err != nil {
+		return nil, newError(ErrCodeInvalidParams, "invalid params", err.Error())
+	}
+
+	server.mutex.RLock()
+	topic := server.topics[name]
+	server.mutex.RUnlock()
+
+	if topic == nil {
+		return nil, newError(ErrCodeInvalidParams, "invalid params", "the topic "+name+" does not exist")
+	}
+
+	ctx.conn.mutex.Lock()
+	subs := len(ctx.conn.subs)
+	ctx.conn.mutex.Unlock()
+
+	if subs >= maxConnSubscriptions {
+		return nil, errTooManySubscriptions
+	}
+
+	var topicParams json.RawMessage
+	if len(list) == 2 {
+		topicParams = list[1]
+	}
+
+	sub := newSubscription(ctx.conn)
+	unsubscribe, err := topic.Subscribe(topicParams, sub.notify)
+	if err != nil {
+		return nil, newError(ErrCodeInvalidParams, "invalid params", err.Error())
+	}
+
+	sub.unsubscribe = unsubscribe
+
+	ctx.conn.mutex.Lock()
+	defer ctx.conn.mutex.Unlock()
+
+	select {
+	case <-ctx.conn.closed:
+		unsubscribe()
+		return nil, errNotificationUnsupported
+	default:
+	}
+
+	// checked again as the subscriptions may be created concurrently
+	if len(ctx.conn.subs) >= maxConnSubscriptions {
+		unsubscribe()
+		return nil, errTooManySubscriptions
+	}
+
+	ctx.conn.subs[sub.id] = sub
+	ctx.subs = append(ctx.subs, sub)
+	go sub.send()
+
+	return sub.id, nil
+}
+
+// unsubscribe handles the unsubscribe method with the params [subscription id], and returns
+// true if the subscription is found and stopped.
+func (server *Server) unsubscribe(ctx *callContext, params json.RawMessage) (interface{}, *Error) {
+	if ctx == nil || ctx.conn == nil {
+		return nil, errNotificationUnsupported
+	}
+
+	var id string
+	if err := decodeParams(params, reflect.ValueOf(&id)); err != nil {
+		return nil, newError(ErrCodeInvalidParams, "invalid params", err.Error())
+	}
+
+	ctx.conn.mutex.Lock()
+	sub := ctx.conn.subs[id]
+	delete(ctx.conn.subs, id)
+	ctx.conn.mutex.Unlock()
+
+	if sub == nil {
+		return false, nil
+	}
+
+	sub.stop()
+	return true, nil
+}
@@ -0,0 +1,198 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testTopic notifies the results sent to its channel.
+type testTopic struct {
+	results      chan interface{}
+	unsubscribed chan struct{}
+}
+
+func newTestTopic() *testTopic {
+	return &testTopic{make(chan interface{}), make(chan struct{}, 10)}
+}
+
+func (t *testTopic) subscribe(params json.RawMessage, notify func(interface{})) (func(), error) {
+	var prefix string
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	quit := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case result := <-t.results:
+				notify(fmt.Sprint(prefix, result))
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(quit)
+		t.unsubscribed <- struct{}{}
+	}, nil
+}
+
+func newTestTopicServer(topic *testTopic) *Server {
+	server := newTestServer()
+	server.RegisterTopic(Topic{Name: "test", Subscribe: topic.subscribe})
+	return server
+}
+
+type testNotification struct {
+	Version string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  struct {
+		Subscription string
+		Result       string
+	} `json:"params"`
+}
+
+func Test_Server_RegisterTopic(t *testing.T) {
+	server := NewServer()
+	topic := newTestTopic()
+
+	assert.Equal(t, server.RegisterTopic(Topic{Name: "test", Subscribe: topic.subscribe}), nil)
+	assert.Equal(t, server.RegisterTopic(Topic{Name: "test", Subscribe: topic.subscribe}), errTopicDefined)
+	assert.Equal(t, server.RegisterTopic(Topic{Subscribe: topic.subscribe}), errTopicNameEmpty)
+}
+
+func Test_Subscription(t *testing.T) {
+	topic := newTestTopic()
+	cli, srv := net.Pipe()
+	go newTestTopicServer(topic).ServeConn(srv)
+	dec := json.NewDecoder(cli)
+
+	fmt.Fprintf(cli, `{"jsonrpc": "2.0", "method": "subscribe", "params": ["test", "r"], "id": 1}`)
+	var resp testResponse
+	assert.Equal(t, dec.Decode(&resp), nil)
+	assert.Equal(t, resp.Error == nil, true)
+
+	var id string
+	assert.Equal(t, json.Unmarshal(*resp.Result, &id), nil)
+
+	topic.results <- 1
+	var notification testNotification
+	assert.Equal(t, dec.Decode(&notification), nil)
+	assert.Equal(t, notification.Version, jsonrpcVersion)
+	assert.Equal(t, notification.Method, "subscription")
+	assert.Equal(t, notification.Params.Subscription, id)
+	assert.Equal(t, notification.Params.Result, "r1")
+
+	// the errors of subscribe
+	fmt.Fprintf(cli, `[{"jsonrpc": "2.0", "method": "subscribe", "params": ["unknown"], "id": 2},
+		{"jsonrpc": "2.0", "method": "subscribe", "params": ["test", 1], "id": 3},
+		{"jsonrpc": "2.0", "method": "subscribe", "id": 4}]`)
+	var responses []testResponse
+	assert.Equal(t, dec.Decode(&responses), nil)
+	assert.Equal(t, len(responses), 3)
+	for _, r := range responses {
+		assert.Equal(t, r.Error.Code, ErrCodeInvalidParams)
+	}
+
+	// unsubscribe
+	fmt.Fprintf(cli, `{"jsonrpc": "2.0", "method": "unsubscribe", "params": ["%s"], "id": 5}`, id)
+	resp = testResponse{}
+	assert.Equal(t, dec.Decode(&resp), nil)
+	assert.Equal(t, string(*resp.Result), "true")
+	<-topic.unsubscribed
+
+	fmt.Fprintf(cli, `{"jsonrpc": "2.0", "method": "unsubscribe", "params": ["%s"], "id": 6}`, id)
+	resp = testResponse{}
+	assert.Equal(t, dec.Decode(&resp), nil)
+	assert.Equal(t, string(*resp.Result), "false")
+
+	// the subscriptions are stopped when the connection is closed
+	fmt.Fprintf(cli, `{"jsonrpc": "2.0", "method": "subscribe", "params": ["test"], "id": 7}`)
+	resp = testResponse{}
+	assert.Equal(t, dec.Decode(&resp), nil)
+	assert.Equal(t, resp.Error == nil, true)
+
+	cli.Close()
+	<-topic.unsubscribed
+}
+
+func Test_Subscription_NotSupported(t *testing.T) {
+	server := newTestTopicServer(newTestTopic())
+
+	testCallError(t, server, `{"jsonrpc": "2.0", "method": "subscribe", "params": ["test"], "id": 1}`, ErrCodeMethodNotFound)
+	testCallError(t, server, `{"jsonrpc": "2.0", "method": "unsubscribe", "params": ["0x01"], "id": 1}`, ErrCodeMethodNotFound)
+}
+
+func Test_Subscription_Overflow(t *testing.T) {
+	topic := newTestTopic()
+	cli, srv := net.Pipe()
+	defer cli.Close()
+	go newTestTopicServer(topic).ServeConn(srv)
+	dec := json.NewDecoder(cli)
+
+	fmt.Fprintf(cli, `{"jsonrpc": "2.0", "method": "subscribe", "params": ["test"], "id": 1}`)
+	var resp testResponse
+	assert.Equal(t, dec.Decode(&resp), nil)
+	assert.Equal(t, resp.Error == nil, true)
+
+	// the client does not read the notifications, so the queue of the subscription is full
+	for i := 0; i < maxQueuedNotifications+2; i++ {
+		topic.results <- i
+	}
+
+	// the notifications are in order, and the subscription is dropped
+	go func() {
+		for i := 0; ; i++ {
+			var notification testNotification
+			if dec.Decode(&notification) != nil {
+				return
+			}
+
+			assert.Equal(t, notification.Params.Result, fmt.Sprint(i))
+		}
+	}()
+
+	<-topic.unsubscribed
+}
+
+func Test_Subscription_MaxConnSubscriptions(t *testing.T) {
+	server := newTestServer()
+	server.RegisterTopic(Topic{Name: "test", Subscribe: func(json.RawMessage, func(interface{})) (func(), error) {
+		return func() {}, nil
+	}})
+
+	cli, srv := net.Pipe()
+	defer cli.Close()
+	go server.ServeConn(srv)
+	dec := json.NewDecoder(cli)
+
+	requests := make([]string, maxConnSubscriptions+1)
+	for i := range requests {
+		requests[i] = fmt.Sprintf(`{"jsonrpc": "2.0", "method": "subscribe", "params": ["test"], "id": %d}`, i)
+	}
+	fmt.Fprintf(cli, "[%s]", strings.Join(requests, ","))
+
+	var responses []testResponse
+	assert.Equal(t, dec.Decode(&responses), nil)
+	assert.Equal(t, len(responses), maxConnSubscriptions+1)
+	for _, r := range responses[:maxConnSubscriptions] {
+		assert.Equal(t, r.Error == nil, true)
+	}
+
+	// the subscription beyond the limit is rejected
+	assert.Equal(t, responses[maxConnSubscriptions].Error.Code, ErrCodeLimitExceeded)
+}
@@ -0,0 +1,422 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package rpc
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The WebSocket protocol defined by RFC 6455.
+const (
+	wsGUID    = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsVersion = "13"
+
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsCloseNormal       = 1000
+	wsCloseProtocolErr  = 1002
+	wsCloseTooBig       = 1009
+	wsMaxControlPayload = 125
+)
+
+const (
+	// WSMaxMessageSize is the max size of a message received from the WebSocket clients.
+	WSMaxMessageSize = 16 * 1024 * 1024
+
+	wsWriteTimeout = 10 * time.Second
+)
+
+var (
+	// ErrInvalidOrigin will be returned when the origin is not allowed by the cors settings
+	ErrInvalidOrigin = errors.New("Invalid origin.")
+
+	errWSHandshake        = errors.New("websocket: invalid handshake request")
+	errWSUnmaskedFrame    = errors.New("websocket: unmasked client frame")
+	errWSInvalidFrame     = errors.New("websocket: invalid frame")
+	errWSMessageTooBig    = errors.New("websocket: message too big")
+	errWSHijackNotSupport = errors.New("websocket: response does not support hijacking")
+)
+
+// WSServer represents a WebSocket RPC server, on which the clients could subscribe to the
+// topics of the server.
+type WSServer struct {
+	*Server
+
+	origins []string
+}
+
+// NewWSServer returns a new WebSocket server. The Origin header of the handshake requests
+// is checked with the cors list, which allows all origins if it is empty or contains "*".
+func NewWSServer(corsList []string) *WSServer {
+	origins := make([]string, len(corsList))
+	for i, origin := range corsList {
+		origins[i] = strings.ToLower(origin)
+	}
+
+	return &WSServer{NewServer(), origins}
+}
+
+// ServeHTTP implements an http.Handler that upgrades the connection to WebSocket and
+// serves the requests on it.
+func (server *WSServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !server.isValidOrigin(req.Header.Get("Origin")) {
+		http.Error(w, ErrInvalidOrigin.Error(), http.StatusForbidden)
+		return
+	}
+
+	// the browsers could not set the headers, so the credential is also accepted as the token
+	// query param. The connection is not authenticated without the credential, and could be
+	// authenticated by the authenticate method later.
+	var credential string
+	if server.auth.Enabled() {
+		if credential = requestCredential(req); len(credential) == 0 {
+			credential = req.URL.Query().Get("token")
+		}
+
+		if len(credential) > 0 {
+			if rpcErr := server.rateLimitIP(remoteIP(req.RemoteAddr)); rpcErr != nil {
+				http.Error(w, rpcErr.Error(), http.StatusTooManyRequests)
+				return
+			}
+
+			if err := server.auth.Authenticate(credential); err != nil {
+				http.Error(w, unauthorizedError(err).Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+	}
+
+	conn, err := wsUpgrade(w, req)
+	if err != nil {
+		return
+	}
+
+	if server.limits.bodyTooLarge(WSMaxMessageSize) {
+		conn.maxMessageSize = int(server.limits.MaxBodySize)
+	}
+
+	server.serveCodec(newWSCodec(conn), remoteIP(req.RemoteAddr), credential)
+}
+
+// isValidOrigin checks the origin with the cors list, in which "*" matches any characters.
+func (server *WSServer) isValidOrigin(origin string) bool {
+	if origin == "" || len(server.origins) == 0 {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	for _, allowed := range server.origins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+
+		if i := strings.IndexByte(allowed, '*'); i >= 0 {
+			prefix, suffix := allowed[:i], allowed[i+1:]
+			if len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func headerContains(header http.Header, name string, value string) bool {
+	for _, v := range header[name] {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// wsAcceptKey returns the Sec-WebSocket-Accept value of the Sec-WebSocket-Key.
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	io.WriteString(h, key+wsGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// wsUpgrade validates the handshake request and hijacks the connection after responding
+// the handshake. The errors are responded to the client with http status.
+func wsUpgrade(w http.ResponseWriter, req *http.Request) (*wsConn, error) {
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if req.Method != http.MethodGet || key == "" ||
+		!headerContains(req.Header, "Connection", "upgrade") ||
+		!headerContains(req.Header, "Upgrade", "websocket") {
+		http.Error(w, errWSHandshake.Error(), http.StatusBadRequest)
+		return nil, errWSHandshake
+	}
+
+	if req.Header.Get("Sec-WebSocket-Version") != wsVersion {
+		w.Header().Set("Sec-WebSocket-Version", wsVersion)
+		http.Error(w, errWSHandshake.Error(), http.StatusUpgradeRequired)
+		return nil, errWSHandshake
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, errWSHijackNotSupport.Error(), http.StatusInternalServerError)
+		return nil, errWSHijackNotSupport
+	}
+
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\n\r\n"
+
+	netConn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err = netConn.Write([]byte(response)); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	return newWSConn(netConn, rw.Reader, false), nil
+}
+
+// wsConn is a WebSocket connection which reads and writes the messages.
+type wsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	client bool // the client masks the frames it sends
+
+	maxMessageSize int // WSMaxMessageSize if the server does not limit the body size
+
+	writeMutex sync.Mutex // protects the writing of frames
+}
+
+func newWSConn(conn net.Conn, reader *bufio.Reader, client bool) *wsConn {
+	if reader == nil {
+		reader = bufio.NewReader(conn)
+	}
+
+	return &wsConn{conn: conn, reader: reader, client: client, maxMessageSize: WSMaxMessageSize}
+}
+
+// readFrame reads a frame and returns its fin bit, opcode and unmasked payload.
+func (c *wsConn) readFrame(maxPayload int) (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, errWSInvalidFrame // no extensions are negotiated
+	}
+
+	if masked == c.client {
+		return false, 0, nil, errWSUnmaskedFrame
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if opcode >= wsOpClose && (!fin || length > wsMaxControlPayload) {
+		return false, 0, nil, errWSInvalidFrame
+	}
+
+	if length > uint64(maxPayload) {
+		return false, 0, nil, errWSMessageTooBig
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a frame with the fin bit set.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|opcode)
+
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+
+	if c.client {
+		var mask [4]byte
+		binary.BigEndian.PutUint32(mask[:], uint32(time.Now().UnixNano()))
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// writeClose sends the close frame with the status code.
+func (c *wsConn) writeClose(code uint16) error {
+	var payload [2]byte
+	binary.BigEndian.PutUint16(payload[:], code)
+	return c.writeFrame(wsOpClose, payload[:])
+}
+
+// ReadMessage reads a text or binary message, and handles the control frames meanwhile.
+// It returns io.EOF after the close frame is received.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	started := false
+
+	for {
+		fin, opcode, payload, err := c.readFrame(c.maxMessageSize - len(message))
+		if err != nil {
+			switch err {
+			case errWSMessageTooBig:
+				c.writeClose(wsCloseTooBig)
+			case errWSInvalidFrame, errWSUnmaskedFrame:
+				c.writeClose(wsCloseProtocolErr)
+			}
+
+			return nil, err
+		}
+
+		switch opcode {
+		case wsOpPing:
+			if err = c.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			c.writeClose(wsCloseNormal)
+			return nil, io.EOF
+		case wsOpText, wsOpBinary:
+			if started {
+				c.writeClose(wsCloseProtocolErr)
+				return nil, errWSInvalidFrame
+			}
+			started = true
+		case wsOpContinuation:
+			if !started {
+				c.writeClose(wsCloseProtocolErr)
+				return nil, errWSInvalidFrame
+			}
+		default:
+			c.writeClose(wsCloseProtocolErr)
+			return nil, errWSInvalidFrame
+		}
+
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// WriteMessage writes a text message.
+func (c *wsConn) WriteMessage(data []byte) error {
+	return c.writeFrame(wsOpText, data)
+}
+
+// Close closes the underlying connection.
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
+
+// wsCodec is the ServerCodec of a WebSocket connection, each message of which is a
+// request or a batch of requests.
+type wsCodec struct {
+	conn *wsConn
+}
+
+func newWSCodec(conn *wsConn) ServerCodec {
+	return &wsCodec{conn}
+}
+
+func (c *wsCodec) ReadMessage() (json.RawMessage, error) {
+	return c.conn.ReadMessage()
+}
+
+func (c *wsCodec) WriteMessage(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return c.conn.WriteMessage(data)
+}
+
+func (c *wsCodec) Close() error {
+	return c.conn.Close()
+}
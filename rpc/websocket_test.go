@@ -0,0 +1,152 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dialWS connects to the WebSocket server with the origin, and returns the client connection
+// or the http status code if failed to upgrade.
+func dialWS(t *testing.T, addr string, origin string) (*wsConn, int) {
+	conn, err := net.Dial("tcp", addr)
+	assert.Equal(t, err, nil)
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\nOrigin: %s\r\n\r\n", addr, key, origin)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	assert.Equal(t, err, nil)
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, resp.StatusCode
+	}
+
+	assert.Equal(t, resp.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+	return newWSConn(conn, reader, true), resp.StatusCode
+}
+
+// writeTestFrame writes a masked frame with the zero mask key.
+func writeTestFrame(conn *wsConn, fin bool, opcode byte, payload string) {
+	if fin {
+		opcode |= 0x80
+	}
+
+	frame := []byte{opcode, 0x80 | byte(len(payload)), 0, 0, 0, 0}
+	conn.conn.Write(append(frame, payload...))
+}
+
+func readWSResponse(t *testing.T, conn *wsConn, v interface{}) {
+	msg, err := conn.ReadMessage()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, json.Unmarshal(msg, v), nil)
+}
+
+func Test_WSServer(t *testing.T) {
+	topic := newTestTopic()
+	server := NewWSServer([]string{"http://*.seele.com", "http://localhost"})
+	server.Register(new(Arith))
+	server.RegisterTopic(Topic{Name: "test", Subscribe: topic.subscribe})
+
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	addr := strings.TrimPrefix(httpServer.URL, "http://")
+
+	_, status := dialWS(t, addr, "http://www.test.com")
+	assert.Equal(t, status, http.StatusForbidden)
+
+	conn, status := dialWS(t, addr, "http://wallet.seele.com")
+	assert.Equal(t, status, http.StatusSwitchingProtocols)
+	defer conn.Close()
+
+	// call
+	assert.Equal(t, conn.WriteMessage([]byte(`{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1, 2], "id": 1}`)), nil)
+	var resp testResponse
+	readWSResponse(t, conn, &resp)
+	assert.Equal(t, string(*resp.Result), `{"C":3}`)
+
+	// the fragmented message
+	assert.Equal(t, conn.writeFrame(wsOpPing, []byte("ping")), nil)
+	writeTestFrame(conn, false, wsOpText, `{"jsonrpc": "2.0", "method": `)
+	writeTestFrame(conn, true, wsOpContinuation, `"Arith.Mul", "params": [2, 3], "id": 2}`)
+
+	fin, opcode, payload, err := conn.readFrame(WSMaxMessageSize)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, fin, true)
+	assert.Equal(t, int(opcode), wsOpPong)
+	assert.Equal(t, string(payload), "ping")
+
+	resp = testResponse{}
+	readWSResponse(t, conn, &resp)
+	assert.Equal(t, string(*resp.Result), `{"C":6}`)
+
+	// the invalid JSON does not close the connection
+	assert.Equal(t, conn.WriteMessage([]byte(`{"jsonrpc"`)), nil)
+	resp = testResponse{}
+	readWSResponse(t, conn, &resp)
+	assert.Equal(t, resp.Error.Code, ErrCodeParse)
+
+	// subscription
+	assert.Equal(t, conn.WriteMessage([]byte(`{"jsonrpc": "2.0", "method": "subscribe", "params": ["test"], "id": 3}`)), nil)
+	resp = testResponse{}
+	readWSResponse(t, conn, &resp)
+	assert.Equal(t, resp.Error == nil, true)
+
+	topic.results <- "result"
+	var notification testNotification
+	readWSResponse(t, conn, &notification)
+	assert.Equal(t, notification.Params.Result, "result")
+
+	// close
+	assert.Equal(t, conn.writeClose(wsCloseNormal), nil)
+	_, err = conn.ReadMessage()
+	assert.Equal(t, err, io.EOF)
+	<-topic.unsubscribed
+}
+
+func Test_WSServer_Origin(t *testing.T) {
+	assert.Equal(t, NewWSServer(nil).isValidOrigin("http://www.test.com"), true)
+	assert.Equal(t, NewWSServer([]string{"*"}).isValidOrigin("http://www.test.com"), true)
+
+	server := NewWSServer([]string{"http://www.Test.com", "https://*.seele.com"})
+	assert.Equal(t, server.isValidOrigin(""), true)
+	assert.Equal(t, server.isValidOrigin("http://www.test.com"), true)
+	assert.Equal(t, server.isValidOrigin("https://a.seele.com"), true)
+	assert.Equal(t, server.isValidOrigin("http://a.seele.com"), false)
+	assert.Equal(t, server.isValidOrigin("http://www.test.com.cn"), false)
+}
+
+func Test_WSServer_Handshake(t *testing.T) {
+	server := NewWSServer(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "8")
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, http.StatusUpgradeRequired)
+	assert.Equal(t, w.Header().Get("Sec-WebSocket-Version"), "13")
+}
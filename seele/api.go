@@ -12,6 +12,7 @@ import (
 	"github.com/seeleteam/go-seele/common/hexutil"
 	"github.com/seeleteam/go-seele/core/types"
 	"github.com/seeleteam/go-seele/p2p"
+	"github.com/seeleteam/go-seele/p2p/discovery"
 )
 
 // PublicSeeleAPI provides an API to access full node-related information.
@@ -143,10 +144,10 @@ func (api *PublicSeeleAPI) GetBlockByHash(request *GetBlockByHashRequest, result
 	return nil
 }
 
-// rpcOutputBlock converts the given block to the RPC output which depends on fullTx
-func rpcOutputBlock(b *types.Block, fullTx bool) (map[string]interface{}, error) {
+// rpcOutputHeader converts the header of the given block to the RPC output
+func rpcOutputHeader(b *types.Block) map[string]interface{} {
 	head := b.Header
-	fields := map[string]interface{}{
+	return map[string]interface{}{
 		"height":     head.Height,
 		"hash":       b.HeaderHash.ToHex(),
 		"parentHash": head.PreviousBlockHash.ToHex(),
@@ -156,7 +157,13 @@ func rpcOutputBlock(b *types.Block, fullTx bool) (map[string]interface{}, error)
 		"creator":    head.Creator.ToHex(),
 		"timestamp":  head.CreateTimestamp,
 		"difficulty": head.Difficulty,
+		"logsBloom":  hexutil.BytesToHex(head.LogsBloom[:]),
 	}
+}
+
+// rpcOutputBlock converts the given block to the RPC output which depends on fullTx
+func rpcOutputBlock(b *types.Block, fullTx bool) (map[string]interface{}, error) {
+	fields := rpcOutputHeader(b)
 
 	formatTx := func(tx *types.Transaction) interface{} {
 		return tx.Hash.ToHex()
@@ -209,3 +216,95 @@ func (n *PublicNetworkAPI) GetNetworkVersion(input interface{}, result *uint64)
 	*result = n.networkVersion
 	return nil
 }
+
+// GetTraffic returns the traffic statistics of all peers since the node started
+func (n *PublicNetworkAPI) GetTraffic(input interface{}, result *p2p.Traffic) error {
+	*result = n.p2pServer.Traffic()
+	return nil
+}
+
+// GetPeersTraffic returns the traffic statistics of the connected peers
+func (n *PublicNetworkAPI) GetPeersTraffic(input interface{}, result *[]p2p.PeerTraffic) error {
+	*result = n.p2pServer.PeersTraffic()
+	return nil
+}
+
+// PrivateAdminAPI provides an API to manage the peers of the node.
+type PrivateAdminAPI struct {
+	s *SeeleService
+}
+
+// NewPrivateAdminAPI creates a new PrivateAdminAPI object for rpc service.
+func NewPrivateAdminAPI(s *SeeleService) *PrivateAdminAPI {
+	return &PrivateAdminAPI{s}
+}
+
+// AdminPeerInfo is the information of a connected peer.
+type AdminPeerInfo struct {
+	p2p.PeerInfo
+	Seele *PeerInfo `json:"seele"` // nil if the seele protocol handshake is not done
+}
+
+// Peers returns the information of the connected peers
+func (api *PrivateAdminAPI) Peers(input interface{}, result *[]AdminPeerInfo) error {
+	peers := api.s.p2pServer.Peers()
+	infos := make([]AdminPeerInfo, 0, len(peers))
+	for _, p := range peers {
+		info := AdminPeerInfo{PeerInfo: p.Info()}
+		if sp := api.s.seeleProtocol.peerSet.Find(p.Node.ID); sp != nil {
+			info.Seele = sp.Info()
+		}
+
+		infos = append(infos, info)
+	}
+
+	*result = infos
+	return nil
+}
+
+// AddPeer connects to the node with the node url, e.g. snode://id@ip:port
+func (api *PrivateAdminAPI) AddPeer(url *string, result *bool) error {
+	node, err := discovery.NewNodeFromString(*url)
+	if err != nil {
+		return err
+	}
+
+	if err = api.s.p2pServer.AddPeer(node); err != nil {
+		return err
+	}
+
+	*result = true
+	return nil
+}
+
+// AddTrustedPeer connects to the node with the node url, and always allows it past the max peers
+func (api *PrivateAdminAPI) AddTrustedPeer(url *string, result *bool) error {
+	node, err := discovery.NewNodeFromString(*url)
+	if err != nil {
+		return err
+	}
+
+	if err = api.s.p2pServer.AddTrustedPeer(node); err != nil {
+		return err
+	}
+
+	*result = true
+	return nil
+}
+
+// RemovePeer disconnects the node with the node url, the result is true if the node is connected
+func (api *PrivateAdminAPI) RemovePeer(url *string, result *bool) error {
+	node, err := discovery.NewNodeFromString(*url)
+	if err != nil {
+		return err
+	}
+
+	*result, err = api.s.p2pServer.RemovePeer(node)
+	return err
+}
+
+// NodeInfo returns the information of the local node
+func (api *PrivateAdminAPI) NodeInfo(input interface{}, result *p2p.NodeInfo) error {
+	*result = api.s.p2pServer.NodeInfo()
+	return nil
+}
@@ -0,0 +1,208 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/common/hexutil"
+	"github.com/seeleteam/go-seele/core"
+	"github.com/seeleteam/go-seele/core/state"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/core/vm"
+	"github.com/seeleteam/go-seele/rpc"
+)
+
+// MaxCallGas is the gas limit of the contract calls which do not specify the gas.
+const MaxCallGas = 50000000
+
+var errNoContractCode = errors.New("no contract code at the address to call with payload")
+
+// CallMessage is the message of a contract call with hex strings, which is executed without
+// being sent as a transaction.
+type CallMessage struct {
+	From    string   // the sender address, empty for the zero address
+	To      string   // the contract address, empty to create a contract
+	Amount  *big.Int // the amount transferred to the contract, nil for 0
+	Payload string   // the input of the contract, empty for no input
+	Gas     uint64   // the gas limit, 0 for MaxCallGas
+}
+
+// CallRequest is the request param of Call and EstimateGas. The message is executed against
+// the state of the block of the height, and the negative height stands for the HEAD block.
+type CallRequest struct {
+	Msg    CallMessage
+	Height int64
+}
+
+// CallResult is the result of Call.
+type CallResult struct {
+	Output  string // the hex output of the contract
+	GasUsed uint64 // the gas used including the intrinsic gas
+}
+
+// toTransaction converts the message to an unsigned transaction.
+func (msg *CallMessage) toTransaction() (*types.Transaction, error) {
+	tx := &types.Transaction{
+		Data: &types.TransactionData{Amount: new(big.Int)},
+	}
+
+	if len(msg.From) > 0 {
+		from, err := common.HexToAddress(msg.From)
+		if err != nil {
+			return nil, err
+		}
+
+		tx.Data.From = from
+	}
+
+	if len(msg.To) > 0 {
+		to, err := common.HexToAddress(msg.To)
+		if err != nil {
+			return nil, err
+		}
+
+		tx.Data.To = &to
+	}
+
+	if msg.Amount != nil {
+		tx.Data.Amount.Set(msg.Amount)
+	}
+
+	if len(msg.Payload) > 0 {
+		payload, err := hexutil.HexToBytes(msg.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		tx.Data.Payload = payload
+	}
+
+	return tx, nil
+}
+
+// callHeader returns the header of the block of the height, or the HEAD block if the height
+// is negative.
+func (api *PublicSeeleAPI) callHeader(height int64) (*types.BlockHeader, error) {
+	if height < 0 {
+		block, _ := api.s.chain.CurrentBlock()
+		return block.Header, nil
+	}
+
+	bcStore := api.s.chain.GetStore()
+	hash, err := bcStore.GetBlockHash(uint64(height))
+	if err != nil {
+		return nil, err
+	}
+
+	return bcStore.GetBlockHeader(hash)
+}
+
+// call executes the tx with the gas limit against a copy of the state of the block. The
+// payload of the tx to an address without code is rejected, since it would not be executed.
+func (api *PublicSeeleAPI) call(tx *types.Transaction, gas uint64, header *types.BlockHeader) ([]byte, uint64, error) {
+	statedb, err := state.NewStatedb(header.StateHash, api.s.accountStateDB)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if tx.Data.To != nil && len(tx.Data.Payload) > 0 && statedb.GetCodeSize(*tx.Data.To) == 0 {
+		return nil, 0, errNoContractCode
+	}
+
+	return core.CallContract(tx, gas, header, statedb, api.s.chain.GetStore(), vm.Config{})
+}
+
+// revertError returns the rpc error of the reverted execution, whose data is the revert reason.
+func revertError(output []byte) error {
+	return &rpc.Error{
+		Code:    rpc.ErrCodeServer,
+		Message: "execution reverted",
+		Data:    hexutil.BytesToHex(output),
+	}
+}
+
+// Call executes the message against the state of the block without committing the changes,
+// and returns the output of the contract. If the execution is reverted, the rpc error is
+// returned with the revert reason as its data.
+func (api *PublicSeeleAPI) Call(request *CallRequest, result *CallResult) error {
+	tx, err := request.Msg.toTransaction()
+	if err != nil {
+		return err
+	}
+
+	header, err := api.callHeader(request.Height)
+	if err != nil {
+		return err
+	}
+
+	gas := request.Msg.Gas
+	if gas == 0 {
+		gas = MaxCallGas
+	}
+
+	output, gasUsed, err := api.call(tx, gas, header)
+	if err == vm.ErrExecutionReverted {
+		return revertError(output)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	result.Output = hexutil.BytesToHex(output)
+	result.GasUsed = gasUsed
+
+	return nil
+}
+
+// EstimateGas returns the minimum gas limit with which the message is executed successfully
+// against the state of the block, by binary searching between the intrinsic gas and the gas
+// limit of the message. Note the estimate is the gas of the EVM execution only, it does not
+// reflect what the chain consumes, since the blocks apply the transactions as plain value
+// transfers without charging any gas so far.
+func (api *PublicSeeleAPI) EstimateGas(request *CallRequest, result *uint64) error {
+	tx, err := request.Msg.toTransaction()
+	if err != nil {
+		return err
+	}
+
+	header, err := api.callHeader(request.Height)
+	if err != nil {
+		return err
+	}
+
+	hi := request.Msg.Gas
+	if hi == 0 {
+		hi = MaxCallGas
+	}
+
+	// the execution fails with the gas lo, and succeeds with the gas hi
+	lo := core.IntrinsicGas(tx.Data.Payload, tx.Data.To == nil) - 1
+
+	output, _, err := api.call(tx, hi, header)
+	if err == vm.ErrExecutionReverted {
+		return revertError(output)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		if _, _, err = api.call(tx, mid, header); err != nil {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	*result = hi
+	return nil
+}
@@ -0,0 +1,132 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/common/hexutil"
+	"github.com/seeleteam/go-seele/core"
+	"github.com/seeleteam/go-seele/core/state"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/core/vm"
+	"github.com/seeleteam/go-seele/crypto"
+	"github.com/seeleteam/go-seele/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PublicSeeleAPI_Call(t *testing.T) {
+	s := newTestFilterService(t)
+	defer s.Stop()
+
+	api := NewPublicSeeleAPI(s)
+	to := crypto.MustGenerateRandomAddress().ToHex()
+
+	var result CallResult
+	request := &CallRequest{Msg: CallMessage{To: to}, Height: -1}
+	assert.Nil(t, api.Call(request, &result))
+	assert.Equal(t, result.Output, "0x")
+	assert.Equal(t, result.GasUsed, params.TxGas)
+
+	// the payload is not executed without the contract code
+	request.Msg.Payload = "0x0001"
+	assert.Equal(t, api.Call(request, &result), errNoContractCode)
+	request.Msg.Payload = ""
+
+	// the state of the block of the height
+	request.Height = 0
+	assert.Nil(t, api.Call(request, &result))
+
+	request.Height = 1
+	assert.NotNil(t, api.Call(request, &result))
+
+	// the sender has no balance
+	request = &CallRequest{Msg: CallMessage{To: to, Amount: big.NewInt(1)}, Height: -1}
+	assert.Equal(t, api.Call(request, &result), vm.ErrInsufficientBalance)
+
+	request = &CallRequest{Msg: CallMessage{To: to, Gas: params.TxGas - 1}, Height: -1}
+	assert.Equal(t, api.Call(request, &result), core.ErrIntrinsicGas)
+
+	request = &CallRequest{Msg: CallMessage{To: "0xinvalid"}, Height: -1}
+	assert.NotNil(t, api.Call(request, &result))
+}
+
+func Test_PublicSeeleAPI_EstimateGas(t *testing.T) {
+	s := newTestFilterService(t)
+	defer s.Stop()
+
+	api := NewPublicSeeleAPI(s)
+	to := crypto.MustGenerateRandomAddress().ToHex()
+
+	var gas uint64
+	request := &CallRequest{Msg: CallMessage{To: to}, Height: -1}
+	assert.Nil(t, api.EstimateGas(request, &gas))
+	assert.Equal(t, gas, params.TxGas)
+
+	// the execution fails with the gas limit of the message
+	request.Msg.Gas = params.TxGas - 1
+	assert.Equal(t, api.EstimateGas(request, &gas), core.ErrIntrinsicGas)
+}
+
+// testContractCode returns the word 42 if the first byte of the input is 0, otherwise reverts
+// with the word 42 as the reason.
+var testContractCode = []byte{
+	0x60, 0x2a, 0x60, 0x00, 0x52, // MSTORE(0, 42)
+	0x60, 0x00, 0x35, 0x60, 0x00, 0x1a, // BYTE(0, CALLDATALOAD(0))
+	0x60, 0x13, 0x57, // JUMPI(19, byte)
+	0x60, 0x20, 0x60, 0x00, 0xf3, // RETURN(0, 32)
+	0x5b, 0x60, 0x20, 0x60, 0x00, 0xfd, // JUMPDEST, REVERT(0, 32)
+}
+
+// commitTestContract commits the test contract code into the state of the HEAD block, and
+// returns the header of the committed state.
+func commitTestContract(t *testing.T, s *SeeleService, contract common.Address) *types.BlockHeader {
+	head, _ := s.chain.CurrentBlock()
+	statedb, err := state.NewStatedb(head.Header.StateHash, s.accountStateDB)
+	assert.Nil(t, err)
+
+	statedb.CreateAccount(contract)
+	statedb.SetCode(contract, testContractCode)
+
+	batch := s.accountStateDB.NewBatch()
+	header := head.Header.Clone()
+	header.StateHash = statedb.Commit(batch)
+	assert.Nil(t, batch.Commit())
+
+	return header
+}
+
+func Test_PublicSeeleAPI_Call_Contract(t *testing.T) {
+	s := newTestFilterService(t)
+	defer s.Stop()
+
+	api := NewPublicSeeleAPI(s)
+	contract := *crypto.MustGenerateRandomAddress()
+	header := commitTestContract(t, s, contract)
+
+	// the code is loaded from the db by the reopened state
+	tx, err := (&CallMessage{To: contract.ToHex(), Payload: "0x00"}).toTransaction()
+	assert.Nil(t, err)
+
+	output, gasUsed, err := api.call(tx, MaxCallGas, header)
+	assert.Nil(t, err)
+	assert.Equal(t, hexutil.BytesToHex(output), "0x"+strings.Repeat("00", 31)+"2a")
+	assert.True(t, gasUsed > params.TxGas+params.TxDataZeroGas)
+
+	tx.Data.Payload = []byte{1}
+	_, _, err = api.call(tx, MaxCallGas, header)
+	assert.Equal(t, err, vm.ErrExecutionReverted)
+}
+
+func Test_RevertError(t *testing.T) {
+	err := revertError([]byte{1, 2})
+	assert.Equal(t, err.(*rpc.Error).Code, rpc.ErrCodeServer)
+	assert.Equal(t, err.(*rpc.Error).Data, "0x0102")
+}
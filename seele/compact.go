@@ -0,0 +1,208 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/crypto"
+)
+
+const (
+	// prefilledTxs is the number of leading transactions sent in full in a compact block,
+	// i.e. the miner reward transaction which is never in the transaction pool.
+	prefilledTxs = 1
+
+	// maxPartialBlocks is the max number of compact blocks waiting for the missing transactions.
+	maxPartialBlocks = 64
+
+	// partialExpiration is the time a compact block waits for the missing transactions.
+	partialExpiration = time.Minute
+)
+
+var (
+	errInvalidCompactBlock = errors.New("invalid compact block")
+	errMissingTxsMismatch  = errors.New("missing transactions mismatch")
+)
+
+// shortTxID returns the short id of the transaction in the block. It is salted with the
+// block hash, so that the collisions are different in each block.
+func shortTxID(blockHash, txHash common.Hash) uint64 {
+	return binary.BigEndian.Uint64(crypto.HashBytes(blockHash.Bytes(), txHash.Bytes()).Bytes()[:8])
+}
+
+// newCompactBlock creates the compact block of the block with its total difficulty.
+func newCompactBlock(block *types.Block, td *big.Int) *compactBlock {
+	prefilled := prefilledTxs
+	if len(block.Transactions) < prefilled {
+		prefilled = len(block.Transactions)
+	}
+
+	compact := &compactBlock{
+		Header:    block.Header,
+		TD:        td,
+		Prefilled: block.Transactions[:prefilled],
+		ShortIDs:  make([]uint64, 0, len(block.Transactions)-prefilled),
+	}
+
+	for _, tx := range block.Transactions[prefilled:] {
+		compact.ShortIDs = append(compact.ShortIDs, shortTxID(block.HeaderHash, tx.Hash))
+	}
+
+	return compact
+}
+
+// partialBlock is a block being rebuilt from a compact block.
+type partialBlock struct {
+	hash    common.Hash
+	header  *types.BlockHeader
+	txs     []*types.Transaction
+	missing []uint64  // indexes of the missing transactions
+	peer    string    // id of the peer which sends the compact block
+	added   time.Time // time the compact block is received
+}
+
+// newPartialBlock rebuilds the block of the compact block with the transactions in the pool.
+// The transactions not found or with ambiguous short ids are missing.
+func newPartialBlock(compact *compactBlock, poolTxs []*types.Transaction, peer string, now time.Time) (*partialBlock, error) {
+	if compact.Header == nil || len(compact.Prefilled) > prefilledTxs {
+		return nil, errInvalidCompactBlock
+	}
+
+	hash := compact.Header.Hash()
+	candidates := make(map[uint64]*types.Transaction)
+	for _, tx := range poolTxs {
+		id := shortTxID(hash, tx.Hash)
+		if _, ok := candidates[id]; ok {
+			candidates[id] = nil
+		} else {
+			candidates[id] = tx
+		}
+	}
+
+	pb := &partialBlock{
+		hash:   hash,
+		header: compact.Header,
+		txs:    append([]*types.Transaction{}, compact.Prefilled...),
+		peer:   peer,
+		added:  now,
+	}
+
+	for i, id := range compact.ShortIDs {
+		tx := candidates[id]
+		if tx == nil {
+			pb.missing = append(pb.missing, uint64(len(compact.Prefilled)+i))
+		}
+
+		pb.txs = append(pb.txs, tx)
+	}
+
+	return pb, nil
+}
+
+// complete returns true if no transaction is missing.
+func (pb *partialBlock) complete() bool {
+	return len(pb.missing) == 0
+}
+
+// fill fills the missing transactions in order.
+func (pb *partialBlock) fill(txs []*types.Transaction) error {
+	if len(txs) != len(pb.missing) {
+		return errMissingTxsMismatch
+	}
+
+	for i, index := range pb.missing {
+		if txs[i] == nil {
+			return errMissingTxsMismatch
+		}
+
+		pb.txs[index] = txs[i]
+	}
+
+	pb.missing = nil
+
+	return nil
+}
+
+// block returns the rebuilt block, and false if the transactions don't match the
+// TxHash of the header, e.g. a short id collides with another transaction in the pool.
+func (pb *partialBlock) block() (*types.Block, bool) {
+	if !pb.complete() || types.MerkleRootHash(pb.txs) != pb.header.TxHash {
+		return nil, false
+	}
+
+	return &types.Block{
+		HeaderHash:   pb.hash,
+		Header:       pb.header,
+		Transactions: pb.txs,
+	}, true
+}
+
+// partialPool keeps the compact blocks waiting for the missing transactions.
+type partialPool struct {
+	lock   sync.Mutex
+	blocks map[common.Hash]*partialBlock
+}
+
+func newPartialPool() *partialPool {
+	return &partialPool{blocks: make(map[common.Hash]*partialBlock)}
+}
+
+// add adds the partial block into the pool. It returns false if the block is
+// already waiting for the transactions, or the pool is full.
+func (pool *partialPool) add(pb *partialBlock) bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if _, ok := pool.blocks[pb.hash]; ok || len(pool.blocks) >= maxPartialBlocks {
+		return false
+	}
+
+	pool.blocks[pb.hash] = pb
+
+	return true
+}
+
+// take removes and returns the partial block of the hash sent by the peer.
+func (pool *partialPool) take(hash common.Hash, peer string) *partialBlock {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	pb := pool.blocks[hash]
+	if pb == nil || pb.peer != peer {
+		return nil
+	}
+
+	delete(pool.blocks, hash)
+
+	return pb
+}
+
+// remove removes the partial block of the hash, e.g. the full block is received.
+func (pool *partialPool) remove(hash common.Hash) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	delete(pool.blocks, hash)
+}
+
+// expire removes the partial blocks which have waited too long.
+func (pool *partialPool) expire(now time.Time) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	for hash, pb := range pool.blocks {
+		if now.Sub(pb.added) >= partialExpiration {
+			delete(pool.blocks, hash)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCompactTestTx(nonce uint64) *types.Transaction {
+	from, key, _ := crypto.GenerateKeyPair()
+	tx := types.NewTransaction(*from, *crypto.MustGenerateRandomAddress(), big.NewInt(1), nonce)
+	tx.Sign(key)
+
+	return tx
+}
+
+func newCompactTestBlock(txNum int) *types.Block {
+	reward := types.NewTransaction(common.Address{}, *crypto.MustGenerateRandomAddress(), big.NewInt(10), 0)
+	reward.Signature = &crypto.Signature{}
+
+	txs := []*types.Transaction{reward}
+	for i := 0; i < txNum; i++ {
+		txs = append(txs, newCompactTestTx(uint64(i)))
+	}
+
+	header := &types.BlockHeader{
+		PreviousBlockHash: common.StringToHash("parent"),
+		Height:            1,
+		Difficulty:        big.NewInt(1),
+	}
+
+	return types.NewBlock(header, txs)
+}
+
+func Test_CompactBlock_Rebuild(t *testing.T) {
+	block := newCompactTestBlock(3)
+	compact := newCompactBlock(block, big.NewInt(1))
+	assert.Equal(t, len(compact.Prefilled), prefilledTxs)
+	assert.Equal(t, len(compact.ShortIDs), 3)
+
+	// all the transactions are in the pool
+	pool := append([]*types.Transaction{newCompactTestTx(0)}, block.Transactions[1:]...)
+	pb, err := newPartialBlock(compact, pool, "p", time.Now())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, pb.complete(), true)
+
+	rebuilt, ok := pb.block()
+	assert.Equal(t, ok, true)
+	assert.Equal(t, rebuilt.HeaderHash, block.HeaderHash)
+	assert.Equal(t, rebuilt.Transactions, block.Transactions)
+}
+
+func Test_CompactBlock_Missing(t *testing.T) {
+	block := newCompactTestBlock(3)
+	compact := newCompactBlock(block, big.NewInt(1))
+
+	pb, err := newPartialBlock(compact, block.Transactions[2:3], "p", time.Now())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, pb.missing, []uint64{1, 3})
+
+	_, ok := pb.block()
+	assert.Equal(t, ok, false)
+
+	assert.Equal(t, pb.fill(block.Transactions[1:2]), errMissingTxsMismatch)
+	assert.Equal(t, pb.fill([]*types.Transaction{block.Transactions[1], block.Transactions[3]}), nil)
+
+	rebuilt, ok := pb.block()
+	assert.Equal(t, ok, true)
+	assert.Equal(t, rebuilt.Transactions, block.Transactions)
+}
+
+func Test_CompactBlock_Mismatch(t *testing.T) {
+	block := newCompactTestBlock(2)
+	compact := newCompactBlock(block, big.NewInt(1))
+
+	pb, err := newPartialBlock(compact, nil, "p", time.Now())
+	assert.Equal(t, err, nil)
+
+	// the wrong transactions don't match the TxHash of the header
+	assert.Equal(t, pb.fill([]*types.Transaction{newCompactTestTx(0), newCompactTestTx(1)}), nil)
+	_, ok := pb.block()
+	assert.Equal(t, ok, false)
+
+	// invalid compact blocks
+	_, err = newPartialBlock(&compactBlock{}, nil, "p", time.Now())
+	assert.Equal(t, err, errInvalidCompactBlock)
+
+	compact.Prefilled = block.Transactions
+	_, err = newPartialBlock(compact, nil, "p", time.Now())
+	assert.Equal(t, err, errInvalidCompactBlock)
+}
+
+func Test_PartialPool(t *testing.T) {
+	pool := newPartialPool()
+	now := time.Now()
+	block := newCompactTestBlock(1)
+
+	pb, _ := newPartialBlock(newCompactBlock(block, big.NewInt(1)), nil, "p1", now)
+	assert.Equal(t, pool.add(pb), true)
+	assert.Equal(t, pool.add(pb), false)
+
+	// only taken by the peer which sends the compact block
+	assert.Equal(t, pool.take(block.HeaderHash, "p2") == nil, true)
+	assert.Equal(t, pool.take(block.HeaderHash, "p1"), pb)
+	assert.Equal(t, pool.take(block.HeaderHash, "p1") == nil, true)
+
+	pool.add(pb)
+	pool.expire(now.Add(partialExpiration))
+	assert.Equal(t, len(pool.blocks), 0)
+}
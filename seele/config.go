@@ -15,4 +15,12 @@ type Config struct {
 	TxConf    core.TransactionPoolConfig
 	NetworkID uint64
 	Coinbase  common.Address `toml:"-"`
+
+	// SyncMode is the mode to synchronise blocks, "full" or "fast". default is "full".
+	// The node runs as a light node which keeps only the header chain if it is "light".
+	SyncMode string
+
+	// KeyStoreDir is the directory of the encrypted account keys managed by the personal API.
+	// default is the keystore folder in the data dir.
+	KeyStoreDir string
 }
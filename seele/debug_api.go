@@ -0,0 +1,248 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/common/hexutil"
+	"github.com/seeleteam/go-seele/core"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/core/vm"
+)
+
+// CallTracerName is the name of the tracer which outputs the call tree instead of the struct logs.
+const CallTracerName = "callTracer"
+
+var (
+	errTraceRewardTx         = errors.New("miner reward tx is not executed by the EVM")
+	errTraceContractCreation = errors.New("contract creation tx is not supported by the chain")
+	errUnsupportedTracer     = errors.New("unsupported tracer")
+)
+
+// TraceConfig is the config of the trace output. The struct logs are limited by Limit and
+// the disable flags, and the call tree is returned instead if Tracer is CallTracerName.
+type TraceConfig struct {
+	DisableMemory  bool   // disable the memory of the struct logs
+	DisableStack   bool   // disable the stack of the struct logs
+	DisableStorage bool   // disable the storage of the struct logs
+	Limit          int    // the maximum number of the struct logs, 0 for unlimited
+	Tracer         string // empty for the struct logs, or CallTracerName for the call tree
+}
+
+// TraceRequest is the request param of TraceTransaction and TraceBlock.
+type TraceRequest struct {
+	HashHex string // the hash of the tx or block
+	Config  TraceConfig
+}
+
+// PrivateDebugAPI provides an API to trace the execution of the transactions in the EVM.
+type PrivateDebugAPI struct {
+	s *SeeleService
+}
+
+// NewPrivateDebugAPI creates a new PrivateDebugAPI object for rpc service.
+func NewPrivateDebugAPI(s *SeeleService) *PrivateDebugAPI {
+	return &PrivateDebugAPI{s}
+}
+
+// TraceTransaction executes the tx in the EVM from the parent state of its block with the former
+// txs in the block processed, and returns the trace of the execution. Note the chain processes
+// the txs as plain value transfers without charging any gas so far, while the trace reports the
+// gas of the EVM execution with the gas limit MaxCallGas.
+func (api *PrivateDebugAPI) TraceTransaction(request *TraceRequest, result *map[string]interface{}) error {
+	hash, err := hexToHash(request.HashHex)
+	if err != nil {
+		return err
+	}
+
+	bcStore := api.s.chain.GetStore()
+	txIndex, err := bcStore.GetTxIndex(hash)
+	if err != nil {
+		return err
+	}
+
+	if txIndex.Index == 0 {
+		return errTraceRewardTx
+	}
+
+	block, err := bcStore.GetBlock(txIndex.BlockHash)
+	if err != nil {
+		return err
+	}
+
+	trace, err := api.traceTx(block, int(txIndex.Index), &request.Config)
+	if err != nil {
+		return err
+	}
+
+	*result = trace
+	return nil
+}
+
+// TraceBlock re-executes the txs of the block from the parent state, and returns the traces
+// of the txs except the miner reward tx.
+func (api *PrivateDebugAPI) TraceBlock(request *TraceRequest, result *[]map[string]interface{}) error {
+	hash, err := hexToHash(request.HashHex)
+	if err != nil {
+		return err
+	}
+
+	block, err := api.s.chain.GetStore().GetBlock(hash)
+	if err != nil {
+		return err
+	}
+
+	traces := make([]map[string]interface{}, 0, len(block.Transactions))
+	for i := 1; i < len(block.Transactions); i++ {
+		trace, err := api.traceTx(block, i, &request.Config)
+		if err != nil {
+			return fmt.Errorf("failed to trace tx %d, %s", i, err)
+		}
+
+		traces = append(traces, map[string]interface{}{
+			"txHash": block.Transactions[i].Hash.ToHex(),
+			"result": trace,
+		})
+	}
+
+	*result = traces
+	return nil
+}
+
+// traceTx executes the tx of the index in the block in the EVM with the tracer of the config.
+func (api *PrivateDebugAPI) traceTx(block *types.Block, txIndex int, config *TraceConfig) (map[string]interface{}, error) {
+	var tracer vm.Tracer
+	switch config.Tracer {
+	case "":
+		tracer = vm.NewStructLogger(&vm.LogConfig{
+			DisableMemory:  config.DisableMemory,
+			DisableStack:   config.DisableStack,
+			DisableStorage: config.DisableStorage,
+			Limit:          config.Limit,
+		})
+	case CallTracerName:
+		tracer = vm.NewCallTracer()
+	default:
+		return nil, errUnsupportedTracer
+	}
+
+	// the chain rejects the contract creation txs, which would be traced as an execution
+	// never happening on the chain.
+	tx := block.Transactions[txIndex]
+	if tx.Data.To == nil {
+		return nil, errTraceContractCreation
+	}
+
+	statedb, err := api.s.chain.StateAtTransaction(block, txIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	vmConfig := vm.Config{Debug: true, Tracer: tracer}
+	output, gasUsed, err := core.CallContract(tx, MaxCallGas, block.Header, statedb, api.s.chain.GetStore(), vmConfig)
+
+	switch tracer := tracer.(type) {
+	case *vm.StructLogger:
+		return map[string]interface{}{
+			"gas":         gasUsed,
+			"failed":      err != nil,
+			"returnValue": hexutil.BytesToHex(output),
+			"structLogs":  rpcOutputStructLogs(tracer.StructLogs()),
+		}, nil
+	default:
+		return rpcOutputCallFrame(tracer.(*vm.CallTracer).Result()), nil
+	}
+}
+
+func hexToHash(hashHex string) (common.Hash, error) {
+	hashBytes, err := hexutil.HexToBytes(hashHex)
+	if err != nil {
+		return common.EmptyHash, err
+	}
+
+	return common.BytesToHash(hashBytes), nil
+}
+
+// rpcOutputStructLogs converts the struct logs to the RPC output, where the stack, memory and
+// storage are omitted if they are disabled.
+func rpcOutputStructLogs(logs []vm.StructLog) []map[string]interface{} {
+	outputs := make([]map[string]interface{}, len(logs))
+	for i, log := range logs {
+		output := map[string]interface{}{
+			"pc":      log.Pc,
+			"op":      log.OpName(),
+			"gas":     log.Gas,
+			"gasCost": log.GasCost,
+			"depth":   log.Depth,
+		}
+
+		if log.Err != nil {
+			output["error"] = log.Err.Error()
+		}
+
+		if log.Stack != nil {
+			stack := make([]string, len(log.Stack))
+			for j, item := range log.Stack {
+				stack[j] = hexutil.BytesToHex(common.BigToHash(item).Bytes())
+			}
+
+			output["stack"] = stack
+		}
+
+		if log.Memory != nil {
+			output["memory"] = hexutil.BytesToHex(log.Memory)
+		}
+
+		if log.Storage != nil {
+			storage := make(map[string]string, len(log.Storage))
+			for key, value := range log.Storage {
+				storage[key.ToHex()] = value.ToHex()
+			}
+
+			output["storage"] = storage
+		}
+
+		outputs[i] = output
+	}
+
+	return outputs
+}
+
+// rpcOutputCallFrame converts the call frame and its nested calls to the RPC output.
+func rpcOutputCallFrame(frame *vm.CallFrame) map[string]interface{} {
+	if frame == nil {
+		return nil
+	}
+
+	output := map[string]interface{}{
+		"type":    frame.Type,
+		"from":    frame.From.ToHex(),
+		"to":      frame.To.ToHex(),
+		"value":   frame.Value,
+		"gas":     frame.Gas,
+		"gasUsed": frame.GasUsed,
+		"input":   hexutil.BytesToHex(frame.Input),
+		"output":  hexutil.BytesToHex(frame.Output),
+	}
+
+	if len(frame.Error) > 0 {
+		output["error"] = frame.Error
+	}
+
+	if len(frame.Calls) > 0 {
+		calls := make([]map[string]interface{}, len(frame.Calls))
+		for i, call := range frame.Calls {
+			calls[i] = rpcOutputCallFrame(call)
+		}
+
+		output["calls"] = calls
+	}
+
+	return output
+}
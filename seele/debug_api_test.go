@@ -0,0 +1,144 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestTraceBlock writes the block after the parent block, in which the miner transfers
+// the reward to the address with the payload.
+func writeTestTraceBlock(t *testing.T, s *SeeleService, parent *types.BlockHeader, to common.Address, payload []byte) *types.Block {
+	minerAddr, minerKey, err := crypto.GenerateKeyPair()
+	assert.Nil(t, err)
+
+	rewardTx := types.NewTransaction(common.Address{}, *minerAddr, big.NewInt(10), 0)
+	rewardTx.Sign(minerKey)
+
+	tx := types.NewTransaction(*minerAddr, to, big.NewInt(3), 0)
+	tx.Data.Payload = payload
+	tx.Sign(minerKey)
+
+	header := &types.BlockHeader{
+		PreviousBlockHash: parent.Hash(),
+		Creator:           *minerAddr,
+		Height:            parent.Height + 1,
+		Difficulty:        big.NewInt(1),
+		CreateTimestamp:   big.NewInt(1),
+	}
+
+	block := types.NewBlock(header, []*types.Transaction{rewardTx, tx})
+	assert.Nil(t, s.chain.GetStore().PutBlock(block, big.NewInt(2), true))
+
+	return block
+}
+
+func Test_PrivateDebugAPI_TraceTransaction(t *testing.T) {
+	s := newTestFilterService(t)
+	defer s.Stop()
+
+	api := NewPrivateDebugAPI(s)
+	genesis, _ := s.chain.CurrentBlock()
+	block := writeTestTraceBlock(t, s, genesis.Header, *crypto.MustGenerateRandomAddress(), nil)
+	tx := block.Transactions[1]
+
+	// struct logs of the value transfer
+	var result map[string]interface{}
+	assert.Nil(t, api.TraceTransaction(&TraceRequest{HashHex: tx.Hash.ToHex()}, &result))
+	assert.Equal(t, result["gas"], params.TxGas)
+	assert.Equal(t, result["failed"], false)
+	assert.Equal(t, result["returnValue"], "0x")
+	assert.Equal(t, len(result["structLogs"].([]map[string]interface{})), 0)
+
+	// call tree
+	request := &TraceRequest{HashHex: tx.Hash.ToHex(), Config: TraceConfig{Tracer: CallTracerName}}
+	assert.Nil(t, api.TraceTransaction(request, &result))
+	assert.Equal(t, result["type"], "CALL")
+	assert.Equal(t, result["from"], tx.Data.From.ToHex())
+	assert.Equal(t, result["to"], tx.Data.To.ToHex())
+	assert.Equal(t, result["value"], big.NewInt(3))
+	assert.Equal(t, result["gasUsed"], uint64(0))
+	assert.Equal(t, result["calls"], nil)
+
+	request.Config.Tracer = "unknown"
+	assert.Equal(t, api.TraceTransaction(request, &result), errUnsupportedTracer)
+
+	// the miner reward tx
+	request = &TraceRequest{HashHex: block.Transactions[0].Hash.ToHex()}
+	assert.Equal(t, api.TraceTransaction(request, &result), errTraceRewardTx)
+
+	request = &TraceRequest{HashHex: common.StringToHash("unknown").ToHex()}
+	assert.NotNil(t, api.TraceTransaction(request, &result))
+}
+
+func Test_PrivateDebugAPI_TraceTransaction_Contract(t *testing.T) {
+	s := newTestFilterService(t)
+	defer s.Stop()
+
+	// the parent block of the traced block has the contract in its state
+	contract := *crypto.MustGenerateRandomAddress()
+	parent := commitTestContract(t, s, contract)
+	assert.Nil(t, s.chain.GetStore().PutBlock(types.NewBlock(parent, nil), big.NewInt(1), false))
+
+	api := NewPrivateDebugAPI(s)
+	block := writeTestTraceBlock(t, s, parent, contract, []byte{0})
+	tx := block.Transactions[1]
+
+	var result map[string]interface{}
+	assert.Nil(t, api.TraceTransaction(&TraceRequest{HashHex: tx.Hash.ToHex()}, &result))
+	assert.Equal(t, result["failed"], false)
+	assert.Equal(t, result["returnValue"], "0x"+strings.Repeat("00", 31)+"2a")
+	assert.True(t, result["gas"].(uint64) > params.TxGas+params.TxDataZeroGas)
+
+	logs := result["structLogs"].([]map[string]interface{})
+	assert.True(t, len(logs) > 0)
+	assert.Equal(t, logs[0]["op"], "PUSH1")
+	assert.Equal(t, logs[len(logs)-1]["op"], "RETURN")
+
+	// the call tree
+	request := &TraceRequest{HashHex: tx.Hash.ToHex(), Config: TraceConfig{Tracer: CallTracerName}}
+	assert.Nil(t, api.TraceTransaction(request, &result))
+	assert.Equal(t, result["to"], contract.ToHex())
+	assert.True(t, result["gasUsed"].(uint64) > 0)
+}
+
+func Test_PrivateDebugAPI_TraceTransaction_Untraceable(t *testing.T) {
+	s := newTestFilterService(t)
+	defer s.Stop()
+
+	genesis, _ := s.chain.CurrentBlock()
+	block := writeTestTraceBlock(t, s, genesis.Header, *crypto.MustGenerateRandomAddress(), nil)
+	block.Transactions[1].Data.To = nil
+
+	// the chain does not support contract creation
+	_, err := NewPrivateDebugAPI(s).traceTx(block, 1, &TraceConfig{})
+	assert.Equal(t, err, errTraceContractCreation)
+}
+
+func Test_PrivateDebugAPI_TraceBlock(t *testing.T) {
+	s := newTestFilterService(t)
+	defer s.Stop()
+
+	api := NewPrivateDebugAPI(s)
+	genesis, _ := s.chain.CurrentBlock()
+	block := writeTestTraceBlock(t, s, genesis.Header, *crypto.MustGenerateRandomAddress(), nil)
+
+	var result []map[string]interface{}
+	assert.Nil(t, api.TraceBlock(&TraceRequest{HashHex: block.HeaderHash.ToHex()}, &result))
+	assert.Equal(t, len(result), 1)
+	assert.Equal(t, result[0]["txHash"], block.Transactions[1].Hash.ToHex())
+	assert.Equal(t, result[0]["result"].(map[string]interface{})["failed"], false)
+
+	assert.NotNil(t, api.TraceBlock(&TraceRequest{HashHex: "0xinvalid"}, &result))
+}
@@ -10,14 +10,17 @@ import (
 	"time"
 
 	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/types"
 )
 
 const (
 	// SeeleProtoName protoName of Seele service
 	SeeleProtoName = "seele"
 
-	// SeeleVersion Version number of Seele protocol
-	SeeleVersion uint = 1
+	// SeeleVersion Version number of Seele protocol. Version 2 adds the block announcements,
+	// orphan ancestor requests, compact blocks, batched transaction announcements, node data
+	// messages and the skip of header queries.
+	SeeleVersion uint = 2
 
 	// BlockChainDir blockchain data directory based on config.DataRoot
 	BlockChainDir = "/db/blockchain"
@@ -26,8 +29,13 @@ const (
 
 	txsyncPackSize = 100 * 1024
 
+	txBroadcastInterval = 100 * time.Millisecond // interval time of broadcasting the queued transactions
+
 	// AccountStateDir account state info directory based on config.DataRoot
 	AccountStateDir = "/db/accountState"
+
+	// KeyStoreDir default directory of the account keys based on config.DataRoot
+	KeyStoreDir = "/keystore"
 )
 
 // statusData the structure for peers to exchange status
@@ -44,6 +52,7 @@ type blockHeadersQuery struct {
 	Hash    common.Hash // Block hash from which to retrieve headers (excludes Number)
 	Number  uint64      // Block hash from which to retrieve headers (excludes Hash)
 	Amount  uint64      // Maximum number of headers to retrieve
+	Skip    uint64      // Blocks to skip between consecutive headers
 	Reverse bool        // Query direction (false = rising towards latest, true = falling towards genesis)
 }
 
@@ -57,6 +66,34 @@ type blocksQuery struct {
 type newBlockHash struct {
 	Hash   common.Hash
 	Number uint64
+	TD     *big.Int // total difficulty of the block
+}
+
+// newBlock is the network packet for the block propagation.
+type newBlock struct {
+	Block *types.Block
+	TD    *big.Int // total difficulty of the block
+}
+
+// compactBlock is the network packet for the block propagation, which contains the header
+// and the short ids of the transactions instead of the full transactions.
+type compactBlock struct {
+	Header    *types.BlockHeader
+	TD        *big.Int             // total difficulty of the block
+	Prefilled []*types.Transaction // leading transactions sent in full
+	ShortIDs  []uint64             // short ids of the rest transactions
+}
+
+// blockTxsRequest requests the transactions of the block by indexes.
+type blockTxsRequest struct {
+	Hash    common.Hash
+	Indexes []uint64
+}
+
+// blockTxs is the network packet for the requested transactions of the block.
+type blockTxs struct {
+	Hash common.Hash
+	Txs  []*types.Transaction
 }
 
 // chainHeadStatus sends this message when local head changes.
@@ -17,6 +17,7 @@ import (
 	"github.com/seeleteam/go-seele/core/types"
 	"github.com/seeleteam/go-seele/event"
 	"github.com/seeleteam/go-seele/log"
+	"github.com/seeleteam/go-seele/metrics"
 	"github.com/seeleteam/go-seele/p2p"
 )
 
@@ -26,15 +27,40 @@ const (
 	GetBlocksMsg       uint16 = 10
 	BlocksPreMsg       uint16 = 11 // is sent before BlockMsg, containing block numbers of BlockMsg.
 	BlocksMsg          uint16 = 12
+	GetNodeDataMsg     uint16 = 16 // requests the state trie nodes by hashes in fast sync
+	NodeDataMsg        uint16 = 17
+)
+
+// SyncMode is the mode of the downloader to synchronise the blocks
+type SyncMode int
+
+const (
+	// FullSync downloads and processes all the blocks
+	FullSync SyncMode = iota
+
+	// FastSync downloads the blocks without processing them before a pivot block near the
+	// head, and downloads the state of the pivot block instead. It is only used when the
+	// local chain is empty, and switches to full sync after the first successful session.
+	FastSync
 )
 
 var (
-	MaxBlockFetch  = 128 // Amount of blocks to be fetched per retrieval request
-	MaxHeaderFetch = 256 // Amount of block headers to be fetched per retrieval request
+	MaxBlockFetch   = 128 // Amount of blocks to be fetched per retrieval request
+	MaxHeaderFetch  = 256 // Amount of block headers to be fetched per retrieval request
+	MaxSkeletonSize = 128 // Amount of skeleton headers to be fetched per retrieval request
 
 	MaxForkAncestry = 90000       // Maximum chain reorganisation
+	MaxStateFetch   = 384         // Amount of state trie nodes to be fetched per retrieval request
+	PivotDistance   = 64          // Distance of the fast sync pivot block from the head
 	peerIdleTime    = time.Second // peer's wait time for next turn if no task now
 
+	initialBlockFetch = 16               // Amount of blocks to be fetched from a peer without throughput estimation
+	targetRequestTime = time.Second      // Expected time of a block request by the peer throughput
+	throughputImpact  = 0.1              // Impact of a new measurement on the peer throughput estimation
+	minRequestTimeout = 2 * time.Second  // Min timeout of a request by the peer round trip time
+	maxRequestTimeout = 10 * time.Second // Max timeout of a request, and the timeout without round trip time
+	maxPeerTimeouts   = 3                // Max successive timeouts before a peer stops downloading in the session
+
 	MaxMessageLength = 8 * 1024 * 1024
 	statusNone       = 1 // no sync session
 	statusPreparing  = 2 // sync session is preparing
@@ -50,8 +76,32 @@ var (
 	errMaxForkAncestor     = errors.New("Can not find ancestor when reached MaxForkAncestry")
 	errPeerNotFound        = errors.New("Peer not found")
 	errSyncErr             = errors.New("Err occurs when syncing")
+	errInvalidSyncMode     = errors.New("Invalid sync mode")
+)
+
+var (
+	syncCounter        = metrics.GetOrRegisterCounter("downloader_syncs_total", "the sync sessions")
+	syncFailureCounter = metrics.GetOrRegisterCounter("downloader_sync_failures_total", "the failed sync sessions")
+	syncTimer          = metrics.GetOrRegisterTimer("downloader_sync_seconds", "the time of the sync sessions")
+	blockRequestTimer  = metrics.GetOrRegisterTimer("downloader_block_request_seconds", "the round trip time of the block requests")
+	blockMeter         = metrics.GetOrRegisterMeter("downloader_blocks", "the downloaded blocks written to the chain")
 )
 
+// ParseSyncMode parses the sync mode of "full" or "fast", the empty string is full sync.
+func ParseSyncMode(mode string) (SyncMode, error) {
+	switch mode {
+	case "", "full":
+		return FullSync, nil
+	case "fast":
+		return FastSync, nil
+	default:
+		return FullSync, errInvalidSyncMode
+	}
+}
+
+// peerDropFn disconnects the peer which delivers invalid data.
+type peerDropFn func(id string)
+
 // Downloader sync block chain with remote peer
 type Downloader struct {
 	cancelCh   chan struct{}        // Cancel current synchronising session
@@ -60,19 +110,24 @@ type Downloader struct {
 
 	syncStatus int
 	tm         *taskMgr
+	mode       SyncMode
+	stateSync  *stateSync // state sync of the pivot block in the fast sync session
 
 	chain     *core.Blockchain
+	dropPeer  peerDropFn
 	sessionWG sync.WaitGroup
 	log       *log.SeeleLog
 	lock      sync.RWMutex
 }
 
 // NewDownloader create Downloader
-func NewDownloader(chain *core.Blockchain) *Downloader {
+func NewDownloader(chain *core.Blockchain, mode SyncMode, dropPeer peerDropFn) *Downloader {
 	d := &Downloader{
 		peers:      make(map[string]*peerConn),
 		chain:      chain,
+		dropPeer:   dropPeer,
 		syncStatus: statusNone,
+		mode:       mode,
 	}
 	d.log = log.GetLogger("download", common.PrintLog)
 	return d
@@ -140,8 +195,12 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, local
 
 func (d *Downloader) doSynchronise(conn *peerConn, head common.Hash, td *big.Int, localTD *big.Int) (err error) {
 	event.BlockDownloaderEventManager.Fire(event.DownloaderStartEvent)
+	syncCounter.Inc(1)
+	start := time.Now()
 	defer func() {
+		syncTimer.UpdateSince(start)
 		if err != nil {
+			syncFailureCounter.Inc(1)
 			event.BlockDownloaderEventManager.Fire(event.DownloaderFailedEvent)
 		} else {
 			event.BlockDownloaderEventManager.Fire(event.DownloaderDoneEvent)
@@ -159,17 +218,42 @@ func (d *Downloader) doSynchronise(conn *peerConn, head common.Hash, td *big.Int
 		return err
 	}
 	d.log.Debug("Downloader.findCommonAncestorHeight start, ancestor=%d", ancestor)
-	tm := newTaskMgr(d, d.masterPeer, ancestor+1, height)
-	d.tm = tm
+	if ancestor == height {
+		return nil
+	}
+
+	parent, err := d.chain.GetStore().GetBlockHash(ancestor)
+	if err != nil {
+		return err
+	}
+
+	skeleton, err := d.fetchSkeleton(conn, ancestor+1, latest)
+	if err == errInvalidSkeleton {
+		d.dropPeer(conn.peerID)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if d.stateSync, err = d.startStateSync(conn, height); err != nil {
+		return err
+	}
+
+	// the blocks are downloaded from all the peers with higher TD, including the peers
+	// connected during the session, so that the session goes on if any peer quits.
+	tm := newTaskMgr(d, ancestor+1, height, parent, skeleton)
 	d.lock.Lock()
+	d.tm = tm
 	d.syncStatus = statusFetching
 	for _, c := range d.peers {
 		_, peerTD := c.peer.Head()
 		if localTD.Cmp(peerTD) >= 0 {
 			continue
 		}
-		d.sessionWG.Add(1)
 
+		tm.addPeer()
+		d.sessionWG.Add(1)
 		go d.peerDownload(c, tm)
 	}
 	d.lock.Unlock()
@@ -180,6 +264,7 @@ func (d *Downloader) doSynchronise(conn *peerConn, head common.Hash, td *big.Int
 	d.lock.Unlock()
 	tm.close()
 	d.tm = nil
+	d.stateSync = nil
 	d.log.Info("downloader.doSynchronise quit!")
 
 	if tm.isDone() {
@@ -192,8 +277,9 @@ func (d *Downloader) doSynchronise(conn *peerConn, head common.Hash, td *big.Int
 // fetchHeight gets the latest head of peer
 func (d *Downloader) fetchHeight(conn *peerConn) (*types.BlockHeader, error) {
 	head, _ := conn.peer.Head()
-	go conn.peer.RequestHeadersByHashOrNumber(head, 0, 1, false)
-	msg, err := conn.waitMsg(BlockHeadersMsg, d.cancelCh)
+	conn.expectMsg(BlockHeadersMsg)
+	go conn.peer.RequestHeadersByHashOrNumber(head, 0, 1, 0, false)
+	msg, err := conn.waitMsg(BlockHeadersMsg, d.cancelCh, maxRequestTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -210,6 +296,85 @@ func (d *Downloader) fetchHeight(conn *peerConn) (*types.BlockHeader, error) {
 	return &headers[0], nil
 }
 
+// fetchHeader gets the header of the height from peer
+func (d *Downloader) fetchHeader(conn *peerConn, height uint64) (*types.BlockHeader, error) {
+	conn.expectMsg(BlockHeadersMsg)
+	go conn.peer.RequestHeadersByHashOrNumber(common.EmptyHash, height, 1, 0, false)
+	msg, err := conn.waitMsg(BlockHeadersMsg, d.cancelCh, maxRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	var headers []types.BlockHeader
+	if err := common.Deserialize(msg.Payload, &headers); err != nil {
+		return nil, err
+	}
+	if len(headers) != 1 || headers[0].Height != height {
+		return nil, errInvalidPacketRecved
+	}
+	return &headers[0], nil
+}
+
+// fetchSkeleton gets the headers of every MaxHeaderFetch blocks from the height from, which split
+// the blocks into segments to download from all the peers. The latest header ends the skeleton.
+func (d *Downloader) fetchSkeleton(conn *peerConn, from uint64, latest *types.BlockHeader) ([]*types.BlockHeader, error) {
+	var skeleton []*types.BlockHeader
+	interval := uint64(MaxHeaderFetch)
+
+	for next := from + interval - 1; next < latest.Height; {
+		count := (latest.Height-1-next)/interval + 1
+		if count > uint64(MaxSkeletonSize) {
+			count = uint64(MaxSkeletonSize)
+		}
+
+		conn.expectMsg(BlockHeadersMsg)
+		go conn.peer.RequestHeadersByHashOrNumber(common.EmptyHash, next, int(count), MaxHeaderFetch-1, false)
+		msg, err := conn.waitMsg(BlockHeadersMsg, d.cancelCh, maxRequestTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		var headers []*types.BlockHeader
+		if err = common.Deserialize(msg.Payload, &headers); err != nil {
+			return nil, err
+		}
+
+		if uint64(len(headers)) != count {
+			return nil, errInvalidSkeleton
+		}
+
+		for i, h := range headers {
+			if h.Height != next+uint64(i)*interval {
+				return nil, errInvalidSkeleton
+			}
+		}
+
+		if err = d.validateSkeleton(headers); err != nil {
+			return nil, err
+		}
+
+		skeleton = append(skeleton, headers...)
+		next += count * interval
+	}
+
+	if err := d.validateSkeleton([]*types.BlockHeader{latest}); err != nil {
+		return nil, err
+	}
+
+	return append(skeleton, latest), nil
+}
+
+// validateSkeleton validates the consensus of the skeleton headers, which are not linked.
+func (d *Downloader) validateSkeleton(headers []*types.BlockHeader) error {
+	for _, h := range headers {
+		if err := d.chain.HeaderChain().ValidateHeaderChain([]*types.BlockHeader{h}); err != nil {
+			d.log.Info("invalid skeleton header %d, %s", h.Height, err)
+			return errInvalidSkeleton
+		}
+	}
+
+	return nil
+}
+
 // findCommonAncestorHeight finds the common ancestor height
 func (d *Downloader) findCommonAncestorHeight(conn *peerConn, height uint64) (uint64, error) {
 	// Get the top height
@@ -249,8 +414,9 @@ func (d *Downloader) findCommonAncestorHeight(conn *peerConn, height uint64) (ui
 		}
 
 		// Get peer block headers
-		go conn.peer.RequestHeadersByHashOrNumber(common.EmptyHash, localTop, fetchCount, true)
-		msg, err := conn.waitMsg(BlockHeadersMsg, d.cancelCh)
+		conn.expectMsg(BlockHeadersMsg)
+		go conn.peer.RequestHeadersByHashOrNumber(common.EmptyHash, localTop, fetchCount, 0, true)
+		msg, err := conn.waitMsg(BlockHeadersMsg, d.cancelCh, maxRequestTimeout)
 		if err != nil {
 			return 0, err
 		}
@@ -287,6 +453,7 @@ func (d *Downloader) RegisterPeer(peerID string, peer Peer) {
 	d.peers[peerID] = newConn
 
 	if d.syncStatus == statusFetching {
+		d.tm.addPeer()
 		d.sessionWG.Add(1)
 		go d.peerDownload(newConn, d.tm)
 	}
@@ -334,113 +501,173 @@ func (d *Downloader) Terminate() {
 	// TODO release variables if needed
 }
 
-// peerDownload peer download routine
+// peerDownload peer download routine, which downloads the header segments and blocks
+// scheduled to the peer until all the blocks are processed or the peer has no more blocks.
 func (d *Downloader) peerDownload(conn *peerConn, tm *taskMgr) {
 	defer d.sessionWG.Done()
 	d.log.Debug("Downloader.peerDownload start")
-	bMaster := (conn.peerID == d.masterPeer)
 	peerID := conn.peerID
 	var err error
 outLoop:
-	for !tm.isDone() {
+	for !tm.isDone() && !tm.isExhausted(peerID) {
 		hasReqData := false
-		if startNo, amount := tm.getReqHeaderInfo(conn); amount > 0 {
-			d.log.Debug("tm.getReqHeaderInfo. %d %d", startNo, amount)
+		if seg := tm.reserveHeaders(peerID); seg != nil {
 			hasReqData = true
-			if err = conn.peer.RequestHeadersByHashOrNumber(common.Hash{}, startNo, amount, false); err != nil {
-				d.log.Info("RequestHeadersByHashOrNumber err!")
-				break
-			}
-			msg, err := conn.waitMsg(BlockHeadersMsg, d.cancelCh)
-			if err != nil {
-				d.log.Info("peerDownload waitMsg BlockHeadersMsg err! %s", err)
-				break
-			}
-			var headers []*types.BlockHeader
-			if err = common.Deserialize(msg.Payload, &headers); err != nil {
-				d.log.Info("peerDownload Deserialize err! %s", err)
-				break
-			}
-
-			if err = tm.deliverHeaderMsg(peerID, headers); err != nil {
-				d.log.Info("peerDownload deliverHeaderMsg err! %s", err)
-				break
-			}
+			err = d.fetchHeaders(conn, tm, seg)
 		}
 
-		if startNo, amount := tm.getReqBlocks(conn); amount > 0 {
-			d.log.Debug("download.peerdown getReqBlocks startNo=%d amount=%d", startNo, amount)
-			hasReqData = true
-			if err = conn.peer.RequestBlocksByHashOrNumber(common.Hash{}, startNo, amount); err != nil {
-				d.log.Info("RequestBlocksByHashOrNumber err!")
-				break
-			}
-
-			msg, err := conn.waitMsg(BlocksPreMsg, d.cancelCh)
-			if err != nil {
-				d.log.Info("peerDownload waitMsg BlocksPreMsg err! %s", err)
-				break
-			}
-
-			var blockNums []uint64
-			if err = common.Deserialize(msg.Payload, &blockNums); err != nil {
-				d.log.Info("peerDownload Deserialize err! %s", err)
-				break
-			}
-			tm.deliverBlockPreMsg(peerID, blockNums)
-
-			msg, err = conn.waitMsg(BlocksMsg, d.cancelCh)
-			if err != nil {
-				d.log.Info("peerDownload waitMsg BlocksMsg err! %s", err)
-				break
+		if err == nil || err == errHeadersNotMatch {
+			if tasks := tm.reserveBlocks(peerID, conn.blockCapacity()); len(tasks) > 0 {
+				hasReqData = true
+				err = d.fetchBlocks(conn, tm, tasks)
 			}
+		}
 
-			var blocks []*types.Block
-			if err = common.Deserialize(msg.Payload, &blocks); err != nil {
-				d.log.Info("peerDownload Deserialize err! %s", err)
-				break
+		switch err {
+		case nil, errHeadersNotMatch:
+		case errRequestTimeout:
+			if conn.onTimeout() >= maxPeerTimeouts {
+				d.log.Info("peerDownload peer %s timeout too many times", peerID)
+				break outLoop
 			}
-			tm.deliverBlockMsg(peerID, blocks)
+		case errInvalidHeaders, errInvalidBlocks:
+			d.log.Warn("peerDownload peer %s delivers invalid data, %s", peerID, err)
+			d.dropPeer(peerID)
+			break outLoop
+		case errInvalidSkeleton:
+			d.log.Warn("peerDownload master peer %s delivers invalid skeleton", d.masterPeer)
+			d.dropPeer(d.masterPeer)
+			d.Cancel()
+			break outLoop
+		default:
+			d.log.Info("peerDownload peer %s err! %s", peerID, err)
+			break outLoop
 		}
+
+		err = nil
 		if hasReqData {
 			continue
 		}
 
-	outFor:
-		for {
-			select {
-			case <-d.cancelCh:
-				break outLoop
-			case <-conn.quitCh:
-				break outLoop
-			case <-time.After(peerIdleTime):
-				d.log.Debug("peerDownload peerIdleTime timeout")
-				break outFor
-			}
+		select {
+		case <-d.cancelCh:
+			break outLoop
+		case <-conn.quitCh:
+			break outLoop
+		case <-time.After(peerIdleTime):
+			d.log.Debug("peerDownload peerIdleTime timeout")
 		}
 	}
 
-	tm.onPeerQuit(peerID)
-	if bMaster {
+	// the session fails if no peer is able to download the remaining blocks
+	if tm.onPeerQuit(peerID) == 0 && !tm.isDone() {
 		d.Cancel()
 	}
 	d.log.Debug("Downloader.peerDownload end")
 }
 
-// processBlocks writes blocks to the blockchain.
-func (d *Downloader) processBlocks(headInfos []*masterHeadInfo) {
+// fetchHeaders downloads the headers of the segment from the peer.
+func (d *Downloader) fetchHeaders(conn *peerConn, tm *taskMgr, seg *headerSegment) error {
+	amount := int(seg.anchor.Height - seg.from + 1)
+	d.log.Debug("download.peerDownload fetchHeaders from=%d amount=%d", seg.from, amount)
+
+	conn.expectMsg(BlockHeadersMsg)
+	if err := conn.peer.RequestHeadersByHashOrNumber(common.EmptyHash, seg.from, amount, 0, false); err != nil {
+		tm.release(conn.peerID)
+		return err
+	}
 
-	for _, h := range headInfos {
-		d.log.Debug("%d %s <- %s ", h.block.Header.Height, h.block.HeaderHash.ToHex(), h.block.Header.PreviousBlockHash.ToHex())
+	msg, err := conn.waitMsg(BlockHeadersMsg, d.cancelCh, conn.requestTimeout())
+	if err != nil {
+		tm.release(conn.peerID)
+		return err
 	}
 
-	for _, h := range headInfos {
-		d.log.Debug("d.processBlock %d", h.block.Header.Height)
-		if err := d.chain.WriteBlock(h.block); err != nil && err != core.ErrBlockAlreadyExists {
+	var headers []*types.BlockHeader
+	if err = common.Deserialize(msg.Payload, &headers); err != nil {
+		tm.release(conn.peerID)
+		return errInvalidHeaders
+	}
+
+	return tm.deliverHeaders(conn.peerID, seg, headers)
+}
+
+// fetchBlocks downloads the reserved blocks from the peer, and updates the peer throughput.
+func (d *Downloader) fetchBlocks(conn *peerConn, tm *taskMgr, tasks []*blockTask) error {
+	startNo, amount := tasks[0].header.Height, len(tasks)
+	d.log.Debug("download.peerDownload fetchBlocks startNo=%d amount=%d", startNo, amount)
+
+	// the blocks are sent right after the block numbers, expect both before request
+	conn.expectMsg(BlocksPreMsg)
+	conn.expectMsg(BlocksMsg)
+	if err := conn.peer.RequestBlocksByHashOrNumber(common.EmptyHash, startNo, amount); err != nil {
+		tm.release(conn.peerID)
+		return err
+	}
+
+	start := time.Now()
+	timeout := conn.requestTimeout()
+	if _, err := conn.waitMsg(BlocksPreMsg, d.cancelCh, timeout); err != nil {
+		tm.release(conn.peerID)
+		return err
+	}
+
+	msg, err := conn.waitMsg(BlocksMsg, d.cancelCh, timeout-time.Since(start))
+	if err != nil {
+		tm.release(conn.peerID)
+		return err
+	}
+
+	var blocks []*types.Block
+	if err = common.Deserialize(msg.Payload, &blocks); err != nil {
+		tm.release(conn.peerID)
+		return errInvalidBlocks
+	}
+
+	blockRequestTimer.UpdateSince(start)
+	accepted, err := tm.deliverBlocks(conn.peerID, tasks, blocks)
+	if accepted > 0 {
+		conn.updateStats(accepted, time.Since(start))
+	}
+
+	return err
+}
+
+// processBlocks writes blocks to the blockchain, and returns the number of blocks written.
+// In fast sync, the blocks before the pivot block are written without processing the txs.
+func (d *Downloader) processBlocks(tasks []*blockTask) int {
+
+	for _, t := range tasks {
+		d.log.Debug("%d %s <- %s ", t.block.Header.Height, t.block.HeaderHash.ToHex(), t.block.Header.PreviousBlockHash.ToHex())
+	}
+
+	for i, t := range tasks {
+		d.log.Debug("d.processBlock %d", t.block.Header.Height)
+
+		var err error
+		switch s := d.stateSync; {
+		case s == nil || t.block.Header.Height > s.pivot.Height:
+			err = d.chain.WriteBlock(t.block)
+		case t.block.Header.Height < s.pivot.Height:
+			err = d.chain.WriteBlockWithoutState(t.block)
+		default:
+			err = d.commitPivot(t.block)
+		}
+
+		if err != nil && err != core.ErrBlockAlreadyExists {
 			d.log.Error("downloader processBlocks err. %s", err)
+
+			// the block is invalid unless the state sync of the pivot block fails
+			if err != errStateSyncFailed && err != errRecvedQuitMsg {
+				d.dropPeer(t.peerID)
+			}
+
 			d.Cancel()
-			break
+			blockMeter.Mark(int64(i))
+			return i
 		}
-		h.status = taskStatusProcessed
 	}
+
+	blockMeter.Mark(int64(len(tasks)))
+	return len(tasks)
 }
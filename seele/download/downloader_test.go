@@ -120,7 +120,7 @@ func newTestBlockchain(db database.Database) *core.Blockchain {
 
 func newTestDownloader(db database.Database) *Downloader {
 	bc := newTestBlockchain(db)
-	return NewDownloader(bc)
+	return NewDownloader(bc, FullSync, func(id string) {})
 }
 
 type TestPeer struct {
@@ -134,7 +134,7 @@ func (p TestPeer) Head() (hash common.Hash, td *big.Int) {
 }
 
 // RequestHeadersByHashOrNumber fetches a batch of blocks' headers
-func (p TestPeer) RequestHeadersByHashOrNumber(origin common.Hash, num uint64, amount int, reverse bool) error {
+func (p TestPeer) RequestHeadersByHashOrNumber(origin common.Hash, num uint64, amount int, skip int, reverse bool) error {
 	return nil
 }
 
@@ -143,6 +143,11 @@ func (p TestPeer) RequestBlocksByHashOrNumber(origin common.Hash, num uint64, am
 	return nil
 }
 
+// RequestNodeData fetches the state trie nodes
+func (p TestPeer) RequestNodeData(hashes []common.Hash) error {
+	return nil
+}
+
 func Test_findCommonAncestorHeight_localHeightIsZero(t *testing.T) {
 	db, dispose := newTestDatabase()
 	defer dispose()
@@ -154,3 +159,29 @@ func Test_findCommonAncestorHeight_localHeightIsZero(t *testing.T) {
 	assert.Equal(t, nil, err)
 	assert.Equal(t, uint64(0), ancestorHeight)
 }
+
+func Test_Downloader_ProcessBlocks_DropPeer(t *testing.T) {
+	db, dispose := newTestDatabase()
+	defer dispose()
+
+	var dropped []string
+	d := NewDownloader(newTestBlockchain(db), FullSync, func(id string) { dropped = append(dropped, id) })
+	d.cancelCh = make(chan struct{})
+
+	// the existing block is skipped, and the peer supplying the invalid block is dropped
+	genesis, _ := d.chain.CurrentBlock()
+	invalid := newTestChain(genesis.HeaderHash, 1, 1)[0]
+	tasks := []*blockTask{
+		{header: genesis.Header, block: genesis, peerID: "p1", status: taskStatusWaitProcessing},
+		{header: invalid.Header, block: invalid, peerID: "p2", status: taskStatusWaitProcessing},
+	}
+
+	assert.Equal(t, d.processBlocks(tasks), 1)
+	assert.Equal(t, dropped, []string{"p2"})
+
+	select {
+	case <-d.cancelCh:
+	default:
+		t.Fatal("the session is not cancelled")
+	}
+}
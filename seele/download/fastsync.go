@@ -0,0 +1,246 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package downloader
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core"
+	"github.com/seeleteam/go-seele/core/state"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/trie"
+)
+
+var (
+	errNoNodeData      = errors.New("No state trie node data received")
+	errPivotNotMatch   = errors.New("Pivot block not match")
+	errStateSyncFailed = errors.New("State sync failed")
+)
+
+// stateSync downloads the state of the pivot block in the fast sync session.
+type stateSync struct {
+	pivot *types.BlockHeader
+	done  chan struct{} // closed when the state sync is finished
+	err   error         // result of the state sync, only valid after done is closed
+}
+
+// startStateSync starts to download the state of the pivot block from the session peers if in
+// fast sync mode, and returns nil if the blocks should be processed in full.
+func (d *Downloader) startStateSync(conn *peerConn, height uint64) (*stateSync, error) {
+	if d.getMode() != FastSync || height <= uint64(PivotDistance) {
+		return nil, nil
+	}
+
+	if current, _ := d.chain.CurrentBlock(); current.Header.Height > 0 {
+		// the state of the local HEAD block is available, so process the blocks in full.
+		d.setMode(FullSync)
+		return nil, nil
+	}
+
+	pivot, err := d.fetchHeader(conn, height-uint64(PivotDistance))
+	if err != nil {
+		return nil, err
+	}
+
+	d.log.Info("fast sync the state of pivot block %d %s", pivot.Height, pivot.Hash().ToHex())
+
+	s := &stateSync{
+		pivot: pivot,
+		done:  make(chan struct{}),
+	}
+
+	d.sessionWG.Add(1)
+	go func() {
+		defer d.sessionWG.Done()
+		defer close(s.done)
+
+		if s.err = d.syncState(d.statePeers(conn), pivot.StateHash); s.err != nil {
+			d.log.Error("downloader sync state err. %s", s.err)
+			d.Cancel()
+		}
+	}()
+
+	return s, nil
+}
+
+// getMode returns the sync mode of the downloader.
+func (d *Downloader) getMode() SyncMode {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return d.mode
+}
+
+// setMode changes the sync mode of the downloader.
+func (d *Downloader) setMode(mode SyncMode) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.mode = mode
+}
+
+// statePeers returns the peers to download the state from, the master peer is the first one.
+// The peers connected after the state sync starts are not used.
+func (d *Downloader) statePeers(master *peerConn) []*peerConn {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	peers := []*peerConn{master}
+	for _, c := range d.peers {
+		if c != master {
+			peers = append(peers, c)
+		}
+	}
+
+	return peers
+}
+
+// nodeDataResult is the response of the trie nodes requested from a peer.
+type nodeDataResult struct {
+	conn  *peerConn
+	nodes [][]byte
+	err   error
+}
+
+// syncState downloads the state trie of the root until complete. The missing nodes are requested
+// from the peers in parallel, and the peer failing to respond is not requested anymore.
+func (d *Downloader) syncState(peers []*peerConn, root common.Hash) error {
+	db := d.chain.AccountStateDB()
+	sync := state.NewStateSync(root, db)
+
+	for hashes := sync.Missing(MaxStateFetch * len(peers)); len(hashes) > 0; hashes = sync.Missing(MaxStateFetch * len(peers)) {
+		var fetchErr error
+		var alive []*peerConn
+		processed := 0
+		for _, r := range d.fetchNodeData(peers, hashes) {
+			if r.err != nil {
+				d.log.Debug("downloader fetches node data from peer %s failed, %s", r.conn.peerID, r.err)
+				fetchErr = r.err
+				continue
+			}
+
+			alive = append(alive, r.conn)
+			processed += d.processNodeData(sync, r.nodes)
+		}
+
+		// the nodes not delivered are retrieved again.
+		sync.Retry(hashes)
+
+		batch := db.NewBatch()
+		sync.Commit(batch)
+		if err := batch.Commit(); err != nil {
+			return err
+		}
+
+		if peers = alive; len(peers) == 0 {
+			return fetchErr
+		}
+
+		if processed == 0 {
+			return errNoNodeData
+		}
+	}
+
+	return sync.Complete()
+}
+
+// fetchNodeData splits the hashes to request the trie nodes from the peers in parallel, at most
+// MaxStateFetch nodes from each peer. The peers without hashes to request respond nothing.
+func (d *Downloader) fetchNodeData(peers []*peerConn, hashes []common.Hash) []*nodeDataResult {
+	results := make([]*nodeDataResult, len(peers))
+	var wg sync.WaitGroup
+	for i, conn := range peers {
+		results[i] = &nodeDataResult{conn: conn}
+		if len(hashes) == 0 {
+			continue
+		}
+
+		n := MaxStateFetch
+		if n > len(hashes) {
+			n = len(hashes)
+		}
+
+		wg.Add(1)
+		go func(r *nodeDataResult, hashes []common.Hash) {
+			defer wg.Done()
+			r.nodes, r.err = d.requestNodeData(r.conn, hashes)
+		}(results[i], hashes[:n])
+
+		hashes = hashes[n:]
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// requestNodeData requests the trie nodes of hashes from the peer and waits for the response.
+func (d *Downloader) requestNodeData(conn *peerConn, hashes []common.Hash) ([][]byte, error) {
+	conn.expectMsg(NodeDataMsg)
+	if err := conn.peer.RequestNodeData(hashes); err != nil {
+		return nil, err
+	}
+
+	msg, err := conn.waitMsg(NodeDataMsg, d.cancelCh, maxRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes [][]byte
+	if err = common.Deserialize(msg.Payload, &nodes); err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+// processNodeData processes the trie nodes, and returns the number of nodes processed.
+func (d *Downloader) processNodeData(sync *trie.Sync, nodes [][]byte) int {
+	processed := 0
+	for _, data := range nodes {
+		if _, err := sync.Process(data); err != nil {
+			d.log.Debug("downloader drops invalid trie node. %s", err)
+			continue
+		}
+
+		processed++
+	}
+
+	return processed
+}
+
+// commitPivot writes the pivot block after its state is downloaded, and switches to full sync.
+func (d *Downloader) commitPivot(block *types.Block) error {
+	s := d.stateSync
+	if !block.HeaderHash.Equal(s.pivot.Hash()) {
+		return errPivotNotMatch
+	}
+
+	if err := d.chain.WriteBlockWithoutState(block); err != nil && err != core.ErrBlockAlreadyExists {
+		return err
+	}
+
+	select {
+	case <-s.done:
+	case <-d.cancelCh:
+		return errRecvedQuitMsg
+	}
+
+	if s.err != nil {
+		return errStateSyncFailed
+	}
+
+	if err := d.chain.CommitFastSyncBlock(block.HeaderHash); err != nil {
+		return err
+	}
+
+	d.log.Info("fast sync done at pivot block %d, switch to full sync", block.Header.Height)
+	d.setMode(FullSync)
+
+	return nil
+}
@@ -0,0 +1,94 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+package downloader
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/state"
+	"github.com/seeleteam/go-seele/crypto"
+	"github.com/seeleteam/go-seele/database"
+	"github.com/seeleteam/go-seele/p2p"
+	"github.com/stretchr/testify/assert"
+)
+
+// nodeDataPeer serves the state trie nodes in the database.
+type nodeDataPeer struct {
+	TestPeer
+	db       database.Database
+	conn     *peerConn
+	requests int
+	err      error // the error of the requests, e.g. the peer quits
+}
+
+func (p *nodeDataPeer) RequestNodeData(hashes []common.Hash) error {
+	p.requests++
+	if p.err != nil {
+		return p.err
+	}
+
+	var nodes [][]byte
+	for _, hash := range hashes {
+		if data, err := state.GetTrieNode(p.db, hash); err == nil {
+			nodes = append(nodes, data)
+		}
+	}
+
+	p.conn.deliverMsg(NodeDataMsg, &p2p.Message{Code: NodeDataMsg, Payload: common.SerializePanic(nodes)})
+	return nil
+}
+
+func newNodeDataPeer(db database.Database, id string) *nodeDataPeer {
+	p := &nodeDataPeer{db: db}
+	p.conn = newPeerConn(p, id)
+	return p
+}
+
+func Test_Downloader_SyncState(t *testing.T) {
+	srcDB, disposeSrc := newTestDatabase()
+	defer disposeSrc()
+
+	// the accounts are committed in rounds, the state objects cached in a statedb are limited
+	root := common.EmptyHash
+	var addrs []common.Address
+	for round := 0; round < 4; round++ {
+		statedb, err := state.NewStatedb(root, srcDB)
+		assert.Nil(t, err)
+
+		for i := 0; i < 500; i++ {
+			addr := *crypto.MustGenerateRandomAddress()
+			addrs = append(addrs, addr)
+			statedb.GetOrNewStateObject(addr).SetAmount(big.NewInt(int64(len(addrs))))
+		}
+
+		batch := srcDB.NewBatch()
+		root = statedb.Commit(batch)
+		assert.Nil(t, batch.Commit())
+	}
+
+	db, dispose := newTestDatabase()
+	defer dispose()
+	d := newTestDownloader(db)
+
+	// the quit peer is not requested anymore, and the nodes are requested from both other peers
+	master, other, quit := newNodeDataPeer(srcDB, "master"), newNodeDataPeer(srcDB, "other"), newNodeDataPeer(srcDB, "quit")
+	quit.err = errPeerNotFound
+	assert.Nil(t, d.syncState([]*peerConn{master.conn, quit.conn, other.conn}, root))
+	assert.Equal(t, quit.requests, 1)
+	assert.True(t, master.requests > 1)
+	assert.True(t, other.requests > 0)
+
+	synced, err := state.NewStatedb(root, d.chain.AccountStateDB())
+	assert.Nil(t, err)
+	for i, addr := range addrs {
+		assert.Equal(t, synced.GetBalance(addr), big.NewInt(int64(i+1)))
+	}
+
+	// fails if all peers quit
+	master.err = errPeerNotFound
+	assert.NotNil(t, d.syncState([]*peerConn{master.conn}, common.StringToHash("root")))
+}
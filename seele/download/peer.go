@@ -9,20 +9,23 @@ import (
 	"errors"
 	"math/big"
 	"sync"
+	"time"
 
 	"github.com/seeleteam/go-seele/common"
 	"github.com/seeleteam/go-seele/p2p"
 )
 
 var (
-	errRecvedQuitMsg = errors.New("Recved quit msg")
-	errPeerQuit      = errors.New("Peer quit")
+	errRecvedQuitMsg  = errors.New("Recved quit msg")
+	errPeerQuit       = errors.New("Peer quit")
+	errRequestTimeout = errors.New("Request timeout")
 )
 
 type Peer interface {
 	Head() (common.Hash, *big.Int)
-	RequestHeadersByHashOrNumber(origin common.Hash, num uint64, amount int, reverse bool) error
+	RequestHeadersByHashOrNumber(origin common.Hash, num uint64, amount int, skip int, reverse bool) error
 	RequestBlocksByHashOrNumber(origin common.Hash, num uint64, amount int) error
+	RequestNodeData(hashes []common.Hash) error
 }
 
 type peerConn struct {
@@ -31,6 +34,11 @@ type peerConn struct {
 	waitingMsgMap  map[uint16]chan *p2p.Message //
 	lockForWaiting sync.RWMutex                 //
 
+	// download statistics of the peer, only accessed by the download routine of the peer
+	throughput float64       // estimated blocks per second
+	rtt        time.Duration // estimated round trip time of requests
+	timeouts   int           // number of successive timeout requests
+
 	quitCh chan struct{}
 }
 
@@ -47,34 +55,114 @@ func (p *peerConn) close() {
 	close(p.quitCh)
 }
 
-func (p *peerConn) waitMsg(msgCode uint16, cancelCh chan struct{}) (*p2p.Message, error) {
-	rcvCh := make(chan *p2p.Message)
+// expectMsg registers the message code before the request is sent, so that the response
+// is kept until waitMsg is called even if it arrives earlier.
+func (p *peerConn) expectMsg(msgCode uint16) chan *p2p.Message {
 	p.lockForWaiting.Lock()
-	p.waitingMsgMap[msgCode] = rcvCh
-	p.lockForWaiting.Unlock()
+	defer p.lockForWaiting.Unlock()
+
+	rcvCh, ok := p.waitingMsgMap[msgCode]
+	if !ok {
+		rcvCh = make(chan *p2p.Message, 1)
+		p.waitingMsgMap[msgCode] = rcvCh
+	}
+
+	return rcvCh
+}
+
+// waitMsg waits for the message until it arrives, or the timeout expires.
+func (p *peerConn) waitMsg(msgCode uint16, cancelCh chan struct{}, timeout time.Duration) (*p2p.Message, error) {
+	rcvCh := p.expectMsg(msgCode)
+	defer func() {
+		p.lockForWaiting.Lock()
+		delete(p.waitingMsgMap, msgCode)
+		p.lockForWaiting.Unlock()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
 	select {
 	case <-p.quitCh:
 		return nil, errPeerQuit
 	case <-cancelCh:
-		p.lockForWaiting.Lock()
-		delete(p.waitingMsgMap, msgCode)
-		p.lockForWaiting.Unlock()
 		return nil, errRecvedQuitMsg
+	case <-timer.C:
+		return nil, errRequestTimeout
 	case msg := <-rcvCh:
-		p.lockForWaiting.Lock()
-		delete(p.waitingMsgMap, msgCode)
-		p.lockForWaiting.Unlock()
-		close(rcvCh)
 		return msg, nil
 	}
 }
 
+// blockCapacity returns the number of blocks to request from the peer, so that the
+// response is expected in targetRequestTime by the estimated throughput.
+func (p *peerConn) blockCapacity() int {
+	if p.throughput == 0 {
+		return initialBlockFetch
+	}
+
+	capacity := int(p.throughput * targetRequestTime.Seconds())
+	if capacity < 1 {
+		return 1
+	}
+
+	if capacity > MaxBlockFetch {
+		return MaxBlockFetch
+	}
+
+	return capacity
+}
+
+// requestTimeout returns the timeout of requests by the estimated round trip time.
+func (p *peerConn) requestTimeout() time.Duration {
+	timeout := 3 * p.rtt
+	if p.rtt == 0 || timeout > maxRequestTimeout {
+		return maxRequestTimeout
+	}
+
+	if timeout < minRequestTimeout {
+		return minRequestTimeout
+	}
+
+	return timeout
+}
+
+// updateStats updates the estimated throughput and round trip time by the delivered request.
+func (p *peerConn) updateStats(delivered int, elapsed time.Duration) {
+	if elapsed <= 0 {
+		elapsed = time.Millisecond
+	}
+
+	throughput := float64(delivered) / elapsed.Seconds()
+	if p.rtt == 0 {
+		p.throughput, p.rtt = throughput, elapsed
+	} else {
+		p.throughput = (1-throughputImpact)*p.throughput + throughputImpact*throughput
+		p.rtt = time.Duration((1-throughputImpact)*float64(p.rtt) + throughputImpact*float64(elapsed))
+	}
+
+	p.timeouts = 0
+}
+
+// onTimeout halves the estimated throughput, and returns the number of successive timeouts.
+func (p *peerConn) onTimeout() int {
+	p.throughput /= 2
+	p.timeouts++
+	return p.timeouts
+}
+
+// deliverMsg delivers the message to the waiting routine, it is dropped if not expected.
 func (p *peerConn) deliverMsg(msgCode uint16, msg *p2p.Message) {
 	p.lockForWaiting.Lock()
+	defer p.lockForWaiting.Unlock()
+
 	ch, ok := p.waitingMsgMap[msgCode]
-	p.lockForWaiting.Unlock()
 	if !ok {
 		return
 	}
-	ch <- msg
+
+	select {
+	case ch <- msg:
+	default:
+	}
 }
@@ -0,0 +1,60 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+package downloader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seeleteam/go-seele/p2p"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_peerConn_Stats(t *testing.T) {
+	var testPeer TestPeer
+	conn := newPeerConn(testPeer, "test")
+
+	// no estimation before any delivery
+	assert.Equal(t, conn.blockCapacity(), initialBlockFetch)
+	assert.Equal(t, conn.requestTimeout(), maxRequestTimeout)
+
+	// 100 blocks per second
+	conn.updateStats(10, 100*time.Millisecond)
+	assert.Equal(t, conn.blockCapacity(), 100)
+	assert.Equal(t, conn.requestTimeout(), minRequestTimeout)
+
+	// the capacity is limited by MaxBlockFetch
+	conn.updateStats(1000, 100*time.Millisecond)
+	assert.Equal(t, conn.blockCapacity(), MaxBlockFetch)
+
+	// the throughput is halved by timeouts
+	throughput := conn.throughput
+	assert.Equal(t, conn.onTimeout(), 1)
+	assert.Equal(t, conn.onTimeout(), 2)
+	assert.Equal(t, conn.throughput, throughput/4)
+
+	conn.updateStats(10, 100*time.Millisecond)
+	assert.Equal(t, conn.timeouts, 0)
+}
+
+func Test_peerConn_WaitMsg(t *testing.T) {
+	var testPeer TestPeer
+	conn := newPeerConn(testPeer, "test")
+	cancelCh := make(chan struct{})
+
+	_, err := conn.waitMsg(BlocksMsg, cancelCh, 10*time.Millisecond)
+	assert.Equal(t, err, errRequestTimeout)
+
+	// the message delivered before waiting is kept
+	conn.expectMsg(BlocksMsg)
+	conn.deliverMsg(BlocksMsg, &p2p.Message{Code: BlocksMsg})
+	msg, err := conn.waitMsg(BlocksMsg, cancelCh, 10*time.Millisecond)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, msg.Code, BlocksMsg)
+
+	close(cancelCh)
+	_, err = conn.waitMsg(BlocksMsg, cancelCh, time.Second)
+	assert.Equal(t, err, errRecvedQuitMsg)
+}
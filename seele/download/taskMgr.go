@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/seeleteam/go-seele/common"
 	"github.com/seeleteam/go-seele/core/types"
 	"github.com/seeleteam/go-seele/log"
 )
@@ -20,87 +21,127 @@ const (
 	taskStatusWaitProcessing = 2    // block is downloaded, needs to process
 	taskStatusProcessed      = 3    // block is written to chain
 	maxBlocksWaiting         = 1024 // max blocks waiting to download
+	maxSegmentFailures       = 3    // max peers whose headers not match a skeleton segment before the skeleton is rejected
 )
 
 var (
-	errMasterHeadersNotMatch = errors.New("Master headers not match")
-	errHeadInfoNotFound      = errors.New("Header info not found")
+	errInvalidHeaders  = errors.New("Invalid headers")
+	errInvalidBlocks   = errors.New("Invalid blocks")
+	errInvalidSkeleton = errors.New("Invalid header skeleton")
+	errHeadersNotMatch = errors.New("Headers not match the skeleton")
 )
 
-// masterHeadInfo header info for master peer
-type masterHeadInfo struct {
+// blockTask is the download task of a block, its header is known after the header segment is downloaded.
+type blockTask struct {
 	header *types.BlockHeader
 	block  *types.Block
 	peerID string
 	status int // block download status
 }
 
-// peerHeadInfo header info for ordinary peer
-type peerHeadInfo struct {
-	headers map[uint64]*types.BlockHeader // block no=> block header
-	maxNo   uint64                        //min max blockno in headers
-}
-
-func newPeerHeadInfo() *peerHeadInfo {
-	return &peerHeadInfo{
-		headers: make(map[uint64]*types.BlockHeader),
-	}
+// headerSegment is the download task of the headers between two skeleton headers. The headers
+// are downloaded from any peer, and verified by linking them to the skeleton headers.
+type headerSegment struct {
+	from   uint64             // height of the first header
+	parent common.Hash        // hash of the header before the segment
+	anchor *types.BlockHeader // skeleton header, which is the last header of the segment
+	peerID string
+	status int
+	failed map[string]bool // peers whose headers not match the skeleton
 }
 
 type taskMgr struct {
-	downloader       *Downloader
-	fromNo, toNo     uint64 // block number range [from, to]
-	curNo            uint64 // the smallest block number need to recv
-	downloadedNum    uint64
-	peersHeaderMap   map[string]*peerHeadInfo // peer's header information
-	masterHeaderList []*masterHeadInfo        // headers for master peer
-
-	masterPeer string
-	lock       sync.RWMutex
-	quitCh     chan struct{}
-	wg         sync.WaitGroup
-	log        *log.SeeleLog
-	startTime  time.Time
+	downloader    *Downloader
+	fromNo, toNo  uint64 // block number range [from, to]
+	curNo         uint64 // the smallest block number need to process
+	downloadedNum uint64
+	segments      []*headerSegment
+	tasks         []*blockTask      // block no - fromNo => task
+	limits        map[string]uint64 // peer id => block no from which the peer can not provide the blocks
+	active        int               // number of peer download routines
+
+	lock      sync.RWMutex
+	processCh chan struct{} // notifies the downloaded blocks to process
+	quitCh    chan struct{}
+	wg        sync.WaitGroup
+	log       *log.SeeleLog
+	startTime time.Time
 }
 
-func newTaskMgr(d *Downloader, masterPeer string, from uint64, to uint64) *taskMgr {
+// newTaskMgr creates the tasks to download the blocks [from, to] after the parent block. The
+// skeleton are the headers which split the range into segments, and the last one is the header of to.
+func newTaskMgr(d *Downloader, from uint64, to uint64, parent common.Hash, skeleton []*types.BlockHeader) *taskMgr {
 	t := &taskMgr{
-		log:              d.log,
-		downloader:       d,
-		fromNo:           from,
-		toNo:             to,
-		curNo:            from,
-		downloadedNum:    0,
-		masterPeer:       masterPeer,
-		startTime:        time.Now(),
-		peersHeaderMap:   make(map[string]*peerHeadInfo),
-		masterHeaderList: make([]*masterHeadInfo, 0, to-from+1),
-		quitCh:           make(chan struct{}),
+		log:        d.log,
+		downloader: d,
+		fromNo:     from,
+		toNo:       to,
+		curNo:      from,
+		startTime:  time.Now(),
+		tasks:      make([]*blockTask, to-from+1),
+		limits:     make(map[string]uint64),
+		processCh:  make(chan struct{}, 1),
+		quitCh:     make(chan struct{}),
 	}
+
+	for i := range t.tasks {
+		t.tasks[i] = &blockTask{}
+	}
+
+	for _, anchor := range skeleton {
+		t.segments = append(t.segments, &headerSegment{
+			from:   from,
+			parent: parent,
+			anchor: anchor,
+			failed: make(map[string]bool),
+		})
+
+		from, parent = anchor.Height+1, anchor.Hash()
+	}
+
 	t.wg.Add(1)
 	go t.run()
 	return t
 }
 
+// run processes the downloaded blocks in order while the following blocks are downloading.
 func (t *taskMgr) run() {
 	defer t.wg.Done()
-loopOut:
+
 	for {
-		t.lock.Lock()
-		startPos, num := int(t.curNo-t.fromNo), 0
-		for (startPos+num < len(t.masterHeaderList)) && (t.masterHeaderList[startPos+num].status == taskStatusWaitProcessing) {
-			num = num + 1
+		select {
+		case <-t.processCh:
+		case <-t.quitCh:
+			return
 		}
 
-		results := t.masterHeaderList[startPos : startPos+num]
-		t.curNo = t.curNo + uint64(num)
-		t.lock.Unlock()
-		t.downloader.processBlocks(results)
+		for {
+			t.lock.Lock()
+			startPos, num := int(t.curNo-t.fromNo), 0
+			for startPos+num < len(t.tasks) && t.tasks[startPos+num].status == taskStatusWaitProcessing {
+				num = num + 1
+			}
 
-		select {
-		case <-time.After(time.Second):
-		case <-t.quitCh:
-			break loopOut
+			results := t.tasks[startPos : startPos+num]
+			t.lock.Unlock()
+
+			if num == 0 {
+				break
+			}
+
+			// the blocks are done after written to the chain
+			processed := t.downloader.processBlocks(results)
+			t.lock.Lock()
+			for _, task := range results[:processed] {
+				task.status = taskStatusProcessed
+				task.block = nil
+			}
+			t.curNo = t.curNo + uint64(processed)
+			t.lock.Unlock()
+
+			if processed < num {
+				break
+			}
 		}
 	}
 }
@@ -114,194 +155,225 @@ func (t *taskMgr) close() {
 	t.wg.Wait()
 }
 
-// getReqHeaderInfo gets header request information, returns the start block number and amount of headers.
-func (t *taskMgr) getReqHeaderInfo(conn *peerConn) (uint64, int) {
+// isDone returns if all blocks are downloaded
+func (t *taskMgr) isDone() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.curNo == t.toNo+1
+}
+
+// addPeer records a running peer download routine.
+func (t *taskMgr) addPeer() {
 	t.lock.Lock()
 	defer t.lock.Unlock()
-	headInfo, ok := t.peersHeaderMap[conn.peerID]
-	if !ok {
-		headInfo = newPeerHeadInfo()
-		t.peersHeaderMap[conn.peerID] = headInfo
-		t.log.Debug("getReqHeaderInfo. create headInfo for peer: %s", conn.peerID)
-	}
+	t.active++
+}
+
+// onPeerQuit needs to remove tasks assigned to peer, and returns the number of peers still downloading.
+func (t *taskMgr) onPeerQuit(peerID string) int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.releaseLocked(peerID)
+	delete(t.limits, peerID)
+	t.active--
+
+	return t.active
+}
 
-	// try remove headers that already downloaded
-	for no := range headInfo.headers {
-		if no < t.curNo {
-			delete(headInfo.headers, no)
+// release makes the tasks assigned to the peer available to other peers.
+func (t *taskMgr) release(peerID string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.releaseLocked(peerID)
+}
+
+// releaseLocked makes the tasks assigned to the peer available to other peers.
+func (t *taskMgr) releaseLocked(peerID string) {
+	for _, seg := range t.segments {
+		if seg.status == taskStatusDownloading && seg.peerID == peerID {
+			seg.peerID = ""
+			seg.status = taskStatusIdle
 		}
 	}
 
-	var startNo uint64
-	if conn.peerID == t.masterPeer {
-		startNo = t.fromNo + uint64(len(t.masterHeaderList))
-		if startNo-t.curNo > maxBlocksWaiting {
-			return 0, 0
-		}
-	} else {
-		startNo = headInfo.maxNo + 1
-		if len(headInfo.headers) == 0 {
-			headInfo.maxNo = 0
-			startNo = t.curNo
+	for _, task := range t.tasks[t.curNo-t.fromNo:] {
+		if task.status == taskStatusDownloading && task.peerID == peerID {
+			task.peerID = ""
+			task.status = taskStatusIdle
 		}
 	}
+}
 
-	if startNo == t.toNo+1 || startNo-t.curNo >= uint64(MaxHeaderFetch) {
-		// do not need to recv headers now.
-		return 0, 0
+// limitLocked records that the peer can not provide the blocks from the block number.
+func (t *taskMgr) limitLocked(peerID string, no uint64) {
+	if limit, ok := t.limits[peerID]; !ok || no < limit {
+		t.limits[peerID] = no
 	}
+}
 
-	amount := MaxHeaderFetch
-	if uint64(MaxHeaderFetch) > (t.toNo + 1 - startNo) {
-		amount = int(t.toNo - startNo + 1)
-	}
-	return startNo, amount
+// canProvide returns true if the block number is not beyond the limit of the peer.
+func (t *taskMgr) canProvide(peerID string, no uint64) bool {
+	limit, ok := t.limits[peerID]
+	return !ok || no < limit
 }
 
-// getReqBlocks get block request information, returns the start block number and amount of blocks.
-// should set masterHead.isDownloading = false, if send request msg error or download finished.
-func (t *taskMgr) getReqBlocks(conn *peerConn) (uint64, int) {
+// isExhausted returns true if all the blocks the peer can provide are downloaded.
+func (t *taskMgr) isExhausted(peerID string) bool {
 	t.lock.Lock()
 	defer t.lock.Unlock()
-	headInfo, ok := t.peersHeaderMap[conn.peerID]
-	if !ok || len(headInfo.headers) == 0 {
-		return 0, 0
-	}
-	var startNo uint64
-	var amount int
-	// find the first block that not requested yet and exists in conn
-	for _, masterHead := range t.masterHeaderList[t.curNo-t.fromNo:] {
-		if masterHead.status != taskStatusIdle {
-			continue
-		}
-		curHeight := masterHead.header.Height
-		peerHead, ok := headInfo.headers[curHeight]
-		if !ok || peerHead.Hash() != masterHead.header.Hash() {
-			continue
-		}
 
-		startNo = masterHead.header.Height
-		masterHead.status = taskStatusDownloading
-		masterHead.peerID = conn.peerID
-		amount = 1
-		break
-	}
-	if amount == 0 {
-		return 0, 0
+	limit, ok := t.limits[peerID]
+	if !ok {
+		return false
 	}
 
-	for _, masterHead := range t.masterHeaderList[startNo+1-t.fromNo:] {
-		if masterHead.status == taskStatusIdle {
-			peerHead, ok := headInfo.headers[startNo+uint64(amount)]
-			// if block is not found in headers or hash not match, then breaks the loop
-			if !ok || peerHead.Hash() != masterHead.header.Hash() {
-				break
-			}
-			if amount < MaxBlockFetch {
-				amount++
-				masterHead.status = taskStatusDownloading
-				masterHead.peerID = conn.peerID
-			}
-			continue
+	for no := t.curNo; no < limit && no <= t.toNo; no++ {
+		if task := t.tasks[no-t.fromNo]; task.status == taskStatusIdle || task.status == taskStatusDownloading {
+			return false
 		}
-		break
 	}
 
-	return startNo, amount
+	return true
 }
 
-// isDone returns if all blocks are downloaded
-func (t *taskMgr) isDone() bool {
+// reserveHeaders assigns the first header segment the peer can provide to the peer.
+func (t *taskMgr) reserveHeaders(peerID string) *headerSegment {
 	t.lock.Lock()
 	defer t.lock.Unlock()
-	return t.curNo == t.toNo+1
-}
 
-// onPeerQuit needs to remove tasks assigned to peer
-func (t *taskMgr) onPeerQuit(peerID string) {
-	t.lock.Lock()
-	defer t.lock.Unlock()
-	for _, masterHead := range t.masterHeaderList[t.curNo-t.fromNo:] {
-		if masterHead.status != taskStatusDownloading || masterHead.peerID != peerID {
+	for _, seg := range t.segments {
+		if seg.status != taskStatusIdle || seg.failed[peerID] || !t.canProvide(peerID, seg.from) {
 			continue
 		}
-		masterHead.peerID = ""
-		masterHead.status = taskStatusIdle
+
+		seg.peerID = peerID
+		seg.status = taskStatusDownloading
+		return seg
 	}
+
+	return nil
 }
 
-// deliverHeaderMsg recved header msg from peer.
-func (t *taskMgr) deliverHeaderMsg(peerID string, headers []*types.BlockHeader) error {
+// deliverHeaders verifies the headers of the segment from the peer. The headers are
+// rejected if they are not linked to the skeleton, and errInvalidSkeleton is returned
+// if the headers of too many peers are rejected.
+func (t *taskMgr) deliverHeaders(peerID string, seg *headerSegment, headers []*types.BlockHeader) error {
 	t.lock.Lock()
 	defer t.lock.Unlock()
-	if peerID == t.masterPeer {
-		lastNo := t.fromNo + uint64(len(t.masterHeaderList))
-		t.log.Debug("masterPeer deliverHeaderMsg. lastNo=%d fromNo:%d header.height:%d", lastNo, t.fromNo, headers[0].Height)
-		if lastNo != headers[0].Height {
-			return errMasterHeadersNotMatch
-		}
-		for _, h := range headers {
-			t.masterHeaderList = append(t.masterHeaderList, &masterHeadInfo{
-				header: h,
-				status: taskStatusIdle,
-			})
-		}
+
+	if seg.status != taskStatusDownloading || seg.peerID != peerID {
+		return nil
 	}
 
-	headInfo, ok := t.peersHeaderMap[peerID]
-	if !ok {
-		return errHeadInfoNotFound
+	seg.peerID = ""
+	seg.status = taskStatusIdle
+
+	if uint64(len(headers)) != seg.anchor.Height-seg.from+1 {
+		// the peer does not have the headers
+		t.limitLocked(peerID, seg.from+uint64(len(headers)))
+		return nil
 	}
 
-	for _, h := range headers {
-		headInfo.headers[h.Height] = h
-		if headInfo.maxNo < h.Height {
-			headInfo.maxNo = h.Height
+	// the bodies are only downloaded for the headers linked one by one with valid consensus
+	if headers[0].Height != seg.from {
+		return errInvalidHeaders
+	}
+
+	if err := t.downloader.chain.HeaderChain().ValidateHeaderChain(headers); err != nil {
+		t.log.Info("peer %s delivers invalid headers from %d, %s", peerID, seg.from, err)
+		return errInvalidHeaders
+	}
+
+	if headers[0].PreviousBlockHash != seg.parent || headers[len(headers)-1].Hash() != seg.anchor.Hash() {
+		// the peer is on another chain
+		t.limitLocked(peerID, seg.from)
+		if seg.failed[peerID] = true; len(seg.failed) >= maxSegmentFailures {
+			return errInvalidSkeleton
 		}
+
+		return errHeadersNotMatch
+	}
+
+	for _, h := range headers {
+		t.tasks[h.Height-t.fromNo].header = h
 	}
 
+	seg.status = taskStatusProcessed
+
 	return nil
 }
 
-// deliverBlockPreMsg recved blocks-pre msg from peer.
-func (t *taskMgr) deliverBlockPreMsg(peerID string, blockNums []uint64) {
+// reserveBlocks assigns at most max sequential blocks with downloaded headers to the peer.
+func (t *taskMgr) reserveBlocks(peerID string, max int) []*blockTask {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
-	for _, masterHead := range t.masterHeaderList[t.curNo-t.fromNo:] {
-		if masterHead.status != taskStatusDownloading || masterHead.peerID != peerID {
-			continue
-		}
-
-		curNo := masterHead.header.Height
-		bFind := false
-		for _, n := range blockNums {
-			if n == curNo {
-				bFind = true
+	var reserved []*blockTask
+	for no := t.curNo; no <= t.toNo && no < t.curNo+maxBlocksWaiting && len(reserved) < max; no++ {
+		task := t.tasks[no-t.fromNo]
+		if task.header == nil || task.status != taskStatusIdle || !t.canProvide(peerID, no) {
+			if len(reserved) > 0 {
 				break
 			}
-		}
 
-		if !bFind {
-			masterHead.peerID = ""
-			masterHead.status = taskStatusIdle
+			continue
 		}
+
+		task.peerID = peerID
+		task.status = taskStatusDownloading
+		reserved = append(reserved, task)
 	}
+
+	return reserved
 }
 
-// deliverBlockMsg recved blocks msg from peer.
-func (t *taskMgr) deliverBlockMsg(peerID string, blocks []*types.Block) {
+// deliverBlocks verifies the blocks from the peer against the downloaded headers, the reserved
+// blocks not delivered are available to other peers. It returns the number of blocks accepted.
+func (t *taskMgr) deliverBlocks(peerID string, reserved []*blockTask, blocks []*types.Block) (int, error) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
+	defer t.releaseLocked(peerID)
+
+	from := reserved[0].header.Height
+	accepted := 0
 	for _, b := range blocks {
-		headInfo := t.masterHeaderList[int(b.Header.Height-t.fromNo)]
-		if headInfo.peerID != peerID {
-			t.log.Info("Recved block from different peer, discard this block. peerID=%s", peerID)
+		if b.Header == nil || b.Header.Height < from || b.Header.Height >= from+uint64(len(reserved)) {
+			return accepted, errInvalidBlocks
+		}
+
+		task := reserved[b.Header.Height-from]
+		if task.status != taskStatusDownloading || task.peerID != peerID {
 			continue
 		}
 
-		headInfo.block = b
-		headInfo.status = taskStatusWaitProcessing
+		if b.Header.Hash() != task.header.Hash() {
+			// the peer is on another chain
+			t.limitLocked(peerID, b.Header.Height)
+			break
+		}
+
+		if b.HeaderHash != task.header.Hash() || types.MerkleRootHash(b.Transactions) != task.header.TxHash {
+			return accepted, errInvalidBlocks
+		}
+
+		task.block = b
+		task.status = taskStatusWaitProcessing
 		t.downloadedNum++
+		accepted++
 	}
+
+	if len(blocks) == 0 {
+		// the peer does not have the blocks
+		t.limitLocked(peerID, from)
+	}
+
+	if accepted > 0 {
+		select {
+		case t.processCh <- struct{}{}:
+		default:
+		}
+	}
+
+	return accepted, nil
 }
@@ -0,0 +1,193 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+package downloader
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestChain creates n linked blocks without txs after the parent.
+func newTestChain(parent common.Hash, from uint64, n int) []*types.Block {
+	var blocks []*types.Block
+	for i := 0; i < n; i++ {
+		header := &types.BlockHeader{
+			PreviousBlockHash: parent,
+			Height:            from + uint64(i),
+			Difficulty:        big.NewInt(1),
+			CreateTimestamp:   big.NewInt(int64(i)),
+		}
+
+		block := types.NewBlock(header, nil)
+		blocks = append(blocks, block)
+		parent = block.HeaderHash
+	}
+
+	return blocks
+}
+
+func headersOf(blocks []*types.Block) []*types.BlockHeader {
+	var headers []*types.BlockHeader
+	for _, b := range blocks {
+		headers = append(headers, b.Header)
+	}
+
+	return headers
+}
+
+// newTestTaskMgr creates the tasks of 10 blocks in the segments [1, 4], [5, 8] and [9, 10].
+func newTestTaskMgr(t *testing.T) (*taskMgr, []*types.Block, func()) {
+	db, dispose := newTestDatabase()
+	d := newTestDownloader(db)
+	genesis, _ := d.chain.CurrentBlock()
+
+	blocks := newTestChain(genesis.HeaderHash, 1, 10)
+	skeleton := []*types.BlockHeader{blocks[3].Header, blocks[7].Header, blocks[9].Header}
+	tm := newTaskMgr(d, 1, 10, genesis.HeaderHash, skeleton)
+
+	return tm, blocks, func() {
+		tm.close()
+		dispose()
+	}
+}
+
+func Test_taskMgr_Headers(t *testing.T) {
+	tm, blocks, dispose := newTestTaskMgr(t)
+	defer dispose()
+
+	// the segments are assigned to different peers
+	seg1 := tm.reserveHeaders("p1")
+	seg2 := tm.reserveHeaders("p2")
+	assert.Equal(t, seg1.from, uint64(1))
+	assert.Equal(t, seg2.from, uint64(5))
+
+	// no blocks to download before the headers
+	assert.Equal(t, len(tm.reserveBlocks("p1", 10)), 0)
+
+	assert.Equal(t, tm.deliverHeaders("p2", seg2, headersOf(blocks[4:8])), nil)
+	assert.Equal(t, tm.deliverHeaders("p1", seg1, headersOf(blocks[0:4])), nil)
+
+	// the blocks are assigned by the capacity of the peers
+	reserved := tm.reserveBlocks("p1", 3)
+	assert.Equal(t, len(reserved), 3)
+	assert.Equal(t, reserved[0].header.Height, uint64(1))
+
+	reserved = tm.reserveBlocks("p2", 10)
+	assert.Equal(t, len(reserved), 5)
+	assert.Equal(t, reserved[0].header.Height, uint64(4))
+}
+
+func Test_taskMgr_InvalidHeaders(t *testing.T) {
+	tm, blocks, dispose := newTestTaskMgr(t)
+	defer dispose()
+
+	// the headers are not linked
+	seg := tm.reserveHeaders("p1")
+	headers := headersOf(blocks[0:4])
+	headers[1], headers[2] = headers[2], headers[1]
+	assert.Equal(t, tm.deliverHeaders("p1", seg, headers), errInvalidHeaders)
+
+	// the segment is assigned to other peer
+	assert.Equal(t, tm.reserveHeaders("p2"), seg)
+
+	// the peer without headers can not provide the segment
+	assert.Equal(t, tm.deliverHeaders("p2", seg, nil), nil)
+	assert.Equal(t, tm.reserveHeaders("p2"), (*headerSegment)(nil))
+	assert.Equal(t, tm.reserveHeaders("p3"), seg)
+}
+
+func Test_taskMgr_InvalidConsensus(t *testing.T) {
+	tm, blocks, dispose := newTestTaskMgr(t)
+	defer dispose()
+
+	// the nonce is not mined for the difficulty
+	seg := tm.reserveHeaders("p1")
+	headers := headersOf(blocks[0:4])
+	invalid := *headers[3]
+	invalid.Difficulty = new(big.Int).Lsh(big.NewInt(1), 255)
+	headers[3] = &invalid
+	assert.Equal(t, tm.deliverHeaders("p1", seg, headers), errInvalidHeaders)
+
+	// no bodies are downloaded for the invalid headers
+	assert.Equal(t, len(tm.reserveBlocks("p1", 4)), 0)
+}
+
+func Test_taskMgr_HeadersNotMatch(t *testing.T) {
+	tm, blocks, dispose := newTestTaskMgr(t)
+	defer dispose()
+
+	// the first segment is downloading
+	assert.Equal(t, tm.reserveHeaders("p0").from, uint64(1))
+
+	// the headers of another chain after block 4
+	fork := headersOf(newTestChain(blocks[3].HeaderHash, 5, 4))
+	for _, peer := range []string{"p1", "p2", "p3"} {
+		seg := tm.reserveHeaders(peer)
+		assert.Equal(t, seg.from, uint64(5))
+
+		// the skeleton is rejected if the headers of too many peers not match
+		err := tm.deliverHeaders(peer, seg, fork)
+		if peer == "p3" {
+			assert.Equal(t, err, errInvalidSkeleton)
+		} else {
+			assert.Equal(t, err, errHeadersNotMatch)
+		}
+	}
+
+	// the peer on another chain can not provide the following segments
+	assert.Equal(t, tm.reserveHeaders("p1"), (*headerSegment)(nil))
+	assert.Equal(t, tm.reserveHeaders("p4").from, uint64(5))
+}
+
+func Test_taskMgr_Blocks(t *testing.T) {
+	tm, blocks, dispose := newTestTaskMgr(t)
+	defer dispose()
+
+	seg := tm.reserveHeaders("p1")
+	assert.Equal(t, tm.deliverHeaders("p1", seg, headersOf(blocks[0:4])), nil)
+
+	// the blocks not delivered are assigned to other peer
+	reserved := tm.reserveBlocks("p1", 4)
+	accepted, err := tm.deliverBlocks("p1", reserved, blocks[0:2])
+	assert.Equal(t, accepted, 2)
+	assert.Equal(t, err, nil)
+
+	reserved = tm.reserveBlocks("p2", 4)
+	assert.Equal(t, len(reserved), 2)
+	assert.Equal(t, reserved[0].header.Height, uint64(3))
+
+	// the block body not match the header
+	invalid := *blocks[2]
+	invalid.Transactions = []*types.Transaction{newTestTx(t, 1, 1)}
+	accepted, err = tm.deliverBlocks("p2", reserved, []*types.Block{&invalid})
+	assert.Equal(t, accepted, 0)
+	assert.Equal(t, err, errInvalidBlocks)
+
+	// the peer without blocks has no more blocks to provide
+	reserved = tm.reserveBlocks("p3", 4)
+	accepted, err = tm.deliverBlocks("p3", reserved, nil)
+	assert.Equal(t, accepted, 0)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(tm.reserveBlocks("p3", 4)), 0)
+	assert.Equal(t, tm.isExhausted("p3"), true)
+	assert.Equal(t, tm.isExhausted("p1"), false)
+}
+
+func Test_taskMgr_OnPeerQuit(t *testing.T) {
+	tm, _, dispose := newTestTaskMgr(t)
+	defer dispose()
+
+	tm.addPeer()
+	tm.addPeer()
+
+	seg := tm.reserveHeaders("p1")
+	assert.Equal(t, tm.onPeerQuit("p1"), 1)
+	assert.Equal(t, tm.reserveHeaders("p2"), seg)
+	assert.Equal(t, tm.onPeerQuit("p2"), 0)
+}
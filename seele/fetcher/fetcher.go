@@ -0,0 +1,245 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package fetcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/log"
+)
+
+const (
+	// arriveTimeout is the time allowed for an announced block to be pushed by other peers,
+	// before it is requested explicitly.
+	arriveTimeout = 500 * time.Millisecond
+
+	// fetchTimeout is the timeout of a block request, the block is requested from another
+	// announcing peer after timeout.
+	fetchTimeout = 5 * time.Second
+
+	// gatherSlack is the interval to schedule the block requests.
+	gatherSlack = 100 * time.Millisecond
+
+	// hashLimit is the max number of announced blocks waiting to be fetched from a peer.
+	hashLimit = 256
+
+	// maxHeightDist is the max distance of an announced block from the local chain height,
+	// the blocks farther away are left to the downloader.
+	maxHeightDist = 32
+)
+
+// BlockRequesterFn requests the block of hash from the peer.
+type BlockRequesterFn func(hash common.Hash) error
+
+// announce is the hash notification of a block from a peer.
+type announce struct {
+	hash   common.Hash
+	height uint64
+	origin string           // id of the peer which announces the block
+	time   time.Time        // announce time, or request time once requested
+	fetch  BlockRequesterFn // requests the block from the origin peer
+}
+
+// Fetcher fetches the announced blocks which are not pushed by peers. A block is only
+// requested from one peer at a time, and requested from another announcing peer after timeout.
+type Fetcher struct {
+	hasBlock    func(hash common.Hash) bool // returns true if the block is in the local chain
+	chainHeight func() uint64               // returns the height of the local chain
+
+	lock      sync.Mutex
+	announces map[common.Hash][]*announce // block hash => announcements waiting to be requested
+	fetching  map[common.Hash]*announce   // block hash => the announcement requested
+	counts    map[string]int              // peer id => number of its announcements
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+	log  *log.SeeleLog
+}
+
+// NewFetcher creates a fetcher with the local chain accessors.
+func NewFetcher(hasBlock func(hash common.Hash) bool, chainHeight func() uint64) *Fetcher {
+	return &Fetcher{
+		hasBlock:    hasBlock,
+		chainHeight: chainHeight,
+		announces:   make(map[common.Hash][]*announce),
+		fetching:    make(map[common.Hash]*announce),
+		counts:      make(map[string]int),
+		quit:        make(chan struct{}),
+		log:         log.GetLogger("fetcher", common.PrintLog),
+	}
+}
+
+// Start starts the routine to schedule block requests.
+func (f *Fetcher) Start() {
+	f.wg.Add(1)
+	go f.loop()
+}
+
+// Stop stops the fetcher.
+func (f *Fetcher) Stop() {
+	close(f.quit)
+	f.wg.Wait()
+}
+
+func (f *Fetcher) loop() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(gatherSlack)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			f.tick(now)
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// Notify records that the peer announces the block. It returns false if the announcement is
+// dropped, e.g. the block is known, too far away, or the peer announces too many blocks.
+func (f *Fetcher) Notify(peer string, hash common.Hash, height uint64, t time.Time, fetch BlockRequesterFn) bool {
+	if f.hasBlock(hash) {
+		return false
+	}
+
+	if dist := int64(height) - int64(f.chainHeight()); dist < -maxHeightDist || dist > maxHeightDist {
+		f.log.Debug("drop announced block %s of height %d from peer %s", hash.ToHex(), height, peer)
+		return false
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.counts[peer] >= hashLimit {
+		f.log.Debug("peer %s announces too many blocks", peer)
+		return false
+	}
+
+	if a := f.fetching[hash]; a != nil && a.origin == peer {
+		return false
+	}
+
+	for _, a := range f.announces[hash] {
+		if a.origin == peer {
+			return false
+		}
+	}
+
+	f.announces[hash] = append(f.announces[hash], &announce{hash, height, peer, t, fetch})
+	f.counts[peer]++
+
+	return true
+}
+
+// Delivered is called when the block is received, so that it is not requested any more.
+func (f *Fetcher) Delivered(hash common.Hash) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.forget(hash)
+}
+
+// RemovePeer drops the announcements of the peer, the block requested from the
+// peer is requested from another announcing peer.
+func (f *Fetcher) RemovePeer(peer string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for hash, a := range f.fetching {
+		if a.origin == peer {
+			delete(f.fetching, hash)
+		}
+	}
+
+	for hash, list := range f.announces {
+		f.announces[hash] = removeOrigin(list, peer)
+		if len(f.announces[hash]) == 0 {
+			delete(f.announces, hash)
+		}
+	}
+
+	delete(f.counts, peer)
+}
+
+// forget removes all the announcements of the block.
+func (f *Fetcher) forget(hash common.Hash) {
+	if a, ok := f.fetching[hash]; ok {
+		f.release(a)
+		delete(f.fetching, hash)
+	}
+
+	for _, a := range f.announces[hash] {
+		f.release(a)
+	}
+
+	delete(f.announces, hash)
+}
+
+func (f *Fetcher) release(a *announce) {
+	if f.counts[a.origin] <= 1 {
+		delete(f.counts, a.origin)
+	} else {
+		f.counts[a.origin]--
+	}
+}
+
+// tick drops the timed out requests, and requests the announced blocks not arrived in time.
+func (f *Fetcher) tick(now time.Time) {
+	var requests []*announce
+
+	f.lock.Lock()
+	for hash, a := range f.fetching {
+		if now.Sub(a.time) >= fetchTimeout {
+			f.log.Debug("request block %s from peer %s timeout", hash.ToHex(), a.origin)
+			f.release(a)
+			delete(f.fetching, hash)
+		}
+	}
+
+	for hash, list := range f.announces {
+		if _, ok := f.fetching[hash]; ok {
+			continue
+		}
+
+		if f.hasBlock(hash) {
+			f.forget(hash)
+			continue
+		}
+
+		// request from the first peer announcing the block
+		if a := list[0]; now.Sub(a.time) >= arriveTimeout {
+			a.time = now
+			f.fetching[hash] = a
+			requests = append(requests, a)
+
+			if f.announces[hash] = list[1:]; len(list) == 1 {
+				delete(f.announces, hash)
+			}
+		}
+	}
+	f.lock.Unlock()
+
+	for _, a := range requests {
+		if err := a.fetch(a.hash); err != nil {
+			f.log.Debug("request block %s from peer %s failed, %s", a.hash.ToHex(), a.origin, err)
+		}
+	}
+}
+
+func removeOrigin(list []*announce, peer string) []*announce {
+	result := list[:0]
+	for _, a := range list {
+		if a.origin != peer {
+			result = append(result, a)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,138 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package fetcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type testChain struct {
+	blocks map[common.Hash]bool
+	height uint64
+}
+
+func newTestFetcher() (*Fetcher, *testChain) {
+	chain := &testChain{blocks: make(map[common.Hash]bool)}
+	f := NewFetcher(func(hash common.Hash) bool { return chain.blocks[hash] }, func() uint64 { return chain.height })
+	return f, chain
+}
+
+// testPeer records the requested blocks.
+type testPeer struct {
+	requests []common.Hash
+}
+
+func (p *testPeer) fetch(hash common.Hash) error {
+	p.requests = append(p.requests, hash)
+	return nil
+}
+
+func Test_Fetcher_Dedup(t *testing.T) {
+	f, _ := newTestFetcher()
+	p1, p2 := &testPeer{}, &testPeer{}
+	hash := common.StringToHash("block")
+	now := time.Now()
+
+	assert.Equal(t, f.Notify("p1", hash, 1, now, p1.fetch), true)
+	assert.Equal(t, f.Notify("p1", hash, 1, now, p1.fetch), false)
+	assert.Equal(t, f.Notify("p2", hash, 1, now, p2.fetch), true)
+
+	// wait for the block pushed by other peers
+	f.tick(now.Add(arriveTimeout / 2))
+	assert.Equal(t, len(p1.requests)+len(p2.requests), 0)
+
+	// requested from only one peer
+	f.tick(now.Add(arriveTimeout))
+	f.tick(now.Add(arriveTimeout * 2))
+	assert.Equal(t, len(p1.requests), 1)
+	assert.Equal(t, len(p2.requests), 0)
+}
+
+func Test_Fetcher_Timeout(t *testing.T) {
+	f, _ := newTestFetcher()
+	p1, p2 := &testPeer{}, &testPeer{}
+	hash := common.StringToHash("block")
+	now := time.Now()
+
+	f.Notify("p1", hash, 1, now, p1.fetch)
+	f.Notify("p2", hash, 1, now, p2.fetch)
+	f.tick(now.Add(arriveTimeout))
+
+	// requested from the other peer after timeout
+	now = now.Add(arriveTimeout + fetchTimeout)
+	f.tick(now)
+	assert.Equal(t, len(p1.requests), 1)
+	assert.Equal(t, len(p2.requests), 1)
+
+	// all the peers time out
+	f.tick(now.Add(fetchTimeout))
+	assert.Equal(t, len(f.fetching), 0)
+	assert.Equal(t, len(f.announces), 0)
+	assert.Equal(t, len(f.counts), 0)
+}
+
+func Test_Fetcher_Delivered(t *testing.T) {
+	f, chain := newTestFetcher()
+	p := &testPeer{}
+	h1, h2 := common.StringToHash("block1"), common.StringToHash("block2")
+	now := time.Now()
+
+	// the pushed block is not requested
+	f.Notify("p", h1, 1, now, p.fetch)
+	f.Delivered(h1)
+
+	// the block inserted into the chain is not requested
+	f.Notify("p", h2, 1, now, p.fetch)
+	chain.blocks[h2] = true
+
+	f.tick(now.Add(arriveTimeout))
+	assert.Equal(t, len(p.requests), 0)
+	assert.Equal(t, len(f.counts), 0)
+
+	// the known block is dropped
+	assert.Equal(t, f.Notify("p", h2, 1, now, p.fetch), false)
+}
+
+func Test_Fetcher_Limits(t *testing.T) {
+	f, chain := newTestFetcher()
+	p := &testPeer{}
+	now := time.Now()
+	chain.height = 100
+
+	assert.Equal(t, f.Notify("p", common.StringToHash("far"), 100+maxHeightDist+1, now, p.fetch), false)
+	assert.Equal(t, f.Notify("p", common.StringToHash("old"), 100-maxHeightDist-1, now, p.fetch), false)
+
+	for i := 0; i < hashLimit; i++ {
+		var hash common.Hash
+		hash[0], hash[1] = byte(i), byte(i>>8)
+		assert.Equal(t, f.Notify("p", hash, 100, now, p.fetch), true)
+	}
+
+	assert.Equal(t, f.Notify("p", common.StringToHash("more"), 100, now, p.fetch), false)
+}
+
+func Test_Fetcher_RemovePeer(t *testing.T) {
+	f, _ := newTestFetcher()
+	p1, p2 := &testPeer{}, &testPeer{}
+	hash := common.StringToHash("block")
+	now := time.Now()
+
+	f.Notify("p1", hash, 1, now, p1.fetch)
+	f.Notify("p2", hash, 1, now, p2.fetch)
+	f.tick(now.Add(arriveTimeout))
+
+	// the block is requested from the other peer at once
+	f.RemovePeer("p1")
+	f.tick(now.Add(arriveTimeout + gatherSlack))
+	assert.Equal(t, len(p2.requests), 1)
+
+	f.RemovePeer("p2")
+	assert.Equal(t, len(f.fetching)+len(f.announces)+len(f.counts), 0)
+}
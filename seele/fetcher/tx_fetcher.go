@@ -0,0 +1,246 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package fetcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/log"
+)
+
+const (
+	// txFetchTimeout is the timeout of a transaction request, the transaction is requested
+	// from another announcing peer after timeout.
+	txFetchTimeout = 5 * time.Second
+
+	// txGatherSlack is the interval to gather the announced transactions into batched requests.
+	txGatherSlack = 100 * time.Millisecond
+
+	// txHashLimit is the max number of announced transactions waiting to be fetched from a peer.
+	txHashLimit = 4096
+
+	// maxTxFetch is the max number of transactions in a request.
+	maxTxFetch = 256
+)
+
+// TxRequesterFn requests the transactions of hashes from the peer.
+type TxRequesterFn func(hashes []common.Hash) error
+
+// txAnnounce is the hash notification of a transaction from a peer.
+type txAnnounce struct {
+	hash   common.Hash
+	origin string        // id of the peer which announces the transaction
+	time   time.Time     // request time once requested
+	fetch  TxRequesterFn // requests the transactions from the origin peer
+}
+
+// TxFetcher fetches the announced transactions in batched requests. A transaction is only
+// requested from one peer at a time, and requested from another announcing peer after timeout.
+type TxFetcher struct {
+	hasTx func(hash common.Hash) bool // returns true if the transaction is in the local pool
+
+	lock      sync.Mutex
+	announces map[common.Hash][]*txAnnounce // tx hash => announcements waiting to be requested
+	fetching  map[common.Hash]*txAnnounce   // tx hash => the announcement requested
+	counts    map[string]int                // peer id => number of its announcements
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+	log  *log.SeeleLog
+}
+
+// NewTxFetcher creates a transaction fetcher with the local pool accessor.
+func NewTxFetcher(hasTx func(hash common.Hash) bool) *TxFetcher {
+	return &TxFetcher{
+		hasTx:     hasTx,
+		announces: make(map[common.Hash][]*txAnnounce),
+		fetching:  make(map[common.Hash]*txAnnounce),
+		counts:    make(map[string]int),
+		quit:      make(chan struct{}),
+		log:       log.GetLogger("txfetcher", common.PrintLog),
+	}
+}
+
+// Start starts the routine to schedule transaction requests.
+func (f *TxFetcher) Start() {
+	f.wg.Add(1)
+	go f.loop()
+}
+
+// Stop stops the fetcher.
+func (f *TxFetcher) Stop() {
+	close(f.quit)
+	f.wg.Wait()
+}
+
+func (f *TxFetcher) loop() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(txGatherSlack)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			f.tick(now)
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// Notify records that the peer announces the transactions. It returns the number of
+// announcements accepted, the known transactions and duplicated announcements are dropped.
+func (f *TxFetcher) Notify(peer string, hashes []common.Hash, fetch TxRequesterFn) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	accepted := 0
+	for _, hash := range hashes {
+		if f.counts[peer] >= txHashLimit {
+			f.log.Debug("peer %s announces too many transactions", peer)
+			break
+		}
+
+		if f.hasTx(hash) || f.announced(hash, peer) {
+			continue
+		}
+
+		f.announces[hash] = append(f.announces[hash], &txAnnounce{hash: hash, origin: peer, fetch: fetch})
+		f.counts[peer]++
+		accepted++
+	}
+
+	return accepted
+}
+
+func (f *TxFetcher) announced(hash common.Hash, peer string) bool {
+	if a := f.fetching[hash]; a != nil && a.origin == peer {
+		return true
+	}
+
+	for _, a := range f.announces[hash] {
+		if a.origin == peer {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delivered is called when the transactions are received, so that they are not requested any more.
+func (f *TxFetcher) Delivered(hashes []common.Hash) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, hash := range hashes {
+		if a, ok := f.fetching[hash]; ok {
+			f.release(a)
+			delete(f.fetching, hash)
+		}
+
+		for _, a := range f.announces[hash] {
+			f.release(a)
+		}
+
+		delete(f.announces, hash)
+	}
+}
+
+// RemovePeer drops the announcements of the peer, the transactions requested from the
+// peer are requested from other announcing peers.
+func (f *TxFetcher) RemovePeer(peer string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for hash, a := range f.fetching {
+		if a.origin == peer {
+			delete(f.fetching, hash)
+		}
+	}
+
+	for hash, list := range f.announces {
+		result := list[:0]
+		for _, a := range list {
+			if a.origin != peer {
+				result = append(result, a)
+			}
+		}
+
+		if len(result) == 0 {
+			delete(f.announces, hash)
+		} else {
+			f.announces[hash] = result
+		}
+	}
+
+	delete(f.counts, peer)
+}
+
+func (f *TxFetcher) release(a *txAnnounce) {
+	if f.counts[a.origin] <= 1 {
+		delete(f.counts, a.origin)
+	} else {
+		f.counts[a.origin]--
+	}
+}
+
+// tick drops the timed out requests, and requests the announced transactions in batches
+// from the first announcing peers.
+func (f *TxFetcher) tick(now time.Time) {
+	batches := make(map[string][]*txAnnounce)
+
+	f.lock.Lock()
+	for hash, a := range f.fetching {
+		if now.Sub(a.time) >= txFetchTimeout {
+			f.log.Debug("request tx %s from peer %s timeout", hash.ToHex(), a.origin)
+			f.release(a)
+			delete(f.fetching, hash)
+		}
+	}
+
+	for hash, list := range f.announces {
+		if _, ok := f.fetching[hash]; ok {
+			continue
+		}
+
+		if f.hasTx(hash) {
+			for _, a := range list {
+				f.release(a)
+			}
+
+			delete(f.announces, hash)
+			continue
+		}
+
+		a := list[0]
+		if len(batches[a.origin]) >= maxTxFetch {
+			continue
+		}
+
+		a.time = now
+		f.fetching[hash] = a
+		batches[a.origin] = append(batches[a.origin], a)
+
+		if f.announces[hash] = list[1:]; len(list) == 1 {
+			delete(f.announces, hash)
+		}
+	}
+	f.lock.Unlock()
+
+	for peer, batch := range batches {
+		hashes := make([]common.Hash, len(batch))
+		for i, a := range batch {
+			hashes[i] = a.hash
+		}
+
+		if err := batch[0].fetch(hashes); err != nil {
+			f.log.Debug("request %d txs from peer %s failed, %s", len(hashes), peer, err)
+		}
+	}
+}
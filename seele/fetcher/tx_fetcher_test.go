@@ -0,0 +1,110 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package fetcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// testTxPeer records the requested transaction batches.
+type testTxPeer struct {
+	requests [][]common.Hash
+}
+
+func (p *testTxPeer) fetch(hashes []common.Hash) error {
+	p.requests = append(p.requests, hashes)
+	return nil
+}
+
+func newTestTxFetcher() (*TxFetcher, map[common.Hash]bool) {
+	pool := make(map[common.Hash]bool)
+	return NewTxFetcher(func(hash common.Hash) bool { return pool[hash] }), pool
+}
+
+func testTxHashes(n int) []common.Hash {
+	hashes := make([]common.Hash, n)
+	for i := range hashes {
+		hashes[i][0], hashes[i][1] = byte(i), byte(i>>8)
+	}
+
+	return hashes
+}
+
+func Test_TxFetcher_Batch(t *testing.T) {
+	f, pool := newTestTxFetcher()
+	p1, p2 := &testTxPeer{}, &testTxPeer{}
+	hashes := testTxHashes(maxTxFetch + 10)
+	pool[hashes[0]] = true
+
+	assert.Equal(t, f.Notify("p1", hashes, p1.fetch), len(hashes)-1)
+	assert.Equal(t, f.Notify("p1", hashes, p1.fetch), 0)
+	assert.Equal(t, f.Notify("p2", hashes[1:3], p2.fetch), 2)
+
+	// requested from the first announcing peer in batches
+	now := time.Now()
+	f.tick(now)
+	assert.Equal(t, len(p1.requests), 1)
+	assert.Equal(t, len(p1.requests[0]), maxTxFetch)
+	assert.Equal(t, len(p2.requests), 0)
+
+	f.tick(now)
+	assert.Equal(t, len(p1.requests), 2)
+	assert.Equal(t, len(p1.requests[1]), len(hashes)-1-maxTxFetch)
+}
+
+func Test_TxFetcher_Timeout(t *testing.T) {
+	f, _ := newTestTxFetcher()
+	p1, p2 := &testTxPeer{}, &testTxPeer{}
+	hashes := testTxHashes(2)
+
+	f.Notify("p1", hashes, p1.fetch)
+	f.Notify("p2", hashes, p2.fetch)
+
+	now := time.Now()
+	f.tick(now)
+	assert.Equal(t, len(p1.requests), 1)
+
+	// the delivered transaction is not requested again
+	f.Delivered(hashes[:1])
+
+	// requested from the other peer after timeout
+	f.tick(now.Add(txFetchTimeout))
+	assert.Equal(t, p2.requests, [][]common.Hash{hashes[1:]})
+
+	// all the peers time out
+	f.tick(now.Add(txFetchTimeout * 2))
+	assert.Equal(t, len(f.fetching)+len(f.announces)+len(f.counts), 0)
+}
+
+func Test_TxFetcher_RemovePeer(t *testing.T) {
+	f, _ := newTestTxFetcher()
+	p1, p2 := &testTxPeer{}, &testTxPeer{}
+	hashes := testTxHashes(3)
+
+	f.Notify("p1", hashes, p1.fetch)
+	f.Notify("p2", hashes, p2.fetch)
+	f.tick(time.Now())
+
+	// the transactions are requested from the other peer at once
+	f.RemovePeer("p1")
+	f.tick(time.Now())
+	assert.Equal(t, len(p2.requests), 1)
+	assert.Equal(t, len(p2.requests[0]), 3)
+
+	f.RemovePeer("p2")
+	assert.Equal(t, len(f.fetching)+len(f.announces)+len(f.counts), 0)
+}
+
+func Test_TxFetcher_Limit(t *testing.T) {
+	f, _ := newTestTxFetcher()
+	p := &testTxPeer{}
+
+	assert.Equal(t, f.Notify("p", testTxHashes(txHashLimit+1), p.fetch), txHashLimit)
+}
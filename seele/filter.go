@@ -0,0 +1,149 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"errors"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core"
+	"github.com/seeleteam/go-seele/core/bloombits"
+	"github.com/seeleteam/go-seele/core/types"
+)
+
+var errInvalidLogRange = errors.New("invalid block range, the from height is larger than the to height")
+
+// bloomGroups returns the values of the filter for the bloom, a bloom matches the filter if it
+// contains any value of each non-empty group.
+func (f *LogFilter) bloomGroups() [][][]byte {
+	groups := make([][][]byte, 0, len(f.Topics)+1)
+
+	addresses := make([][]byte, len(f.Addresses))
+	for i, addr := range f.Addresses {
+		addresses[i] = addr.Bytes()
+	}
+	groups = append(groups, addresses)
+
+	for _, topics := range f.Topics {
+		group := make([][]byte, len(topics))
+		for i, topic := range topics {
+			group[i] = topic.Bytes()
+		}
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+// MatchBloom returns false if the bloom definitely contains no logs matching the filter.
+func (f *LogFilter) MatchBloom(bloom *types.Bloom) bool {
+	if len(f.Addresses) > 0 {
+		matched := false
+		for _, addr := range f.Addresses {
+			if bloom.TestAddress(addr) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	for _, topics := range f.Topics {
+		if len(topics) == 0 {
+			continue
+		}
+
+		matched := false
+		for _, topic := range topics {
+			if bloom.TestTopic(topic) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getLogs returns the logs of the canonical blocks in the height range [from, to] which match
+// the filter. The candidate blocks are found by the bloom index for the indexed sections, and
+// by the blooms in the headers for the others.
+func getLogs(chain *core.Blockchain, indexer *bloombits.Indexer, from, to uint64, filter *LogFilter) ([]*types.Log, error) {
+	if from > to {
+		return nil, errInvalidLogRange
+	}
+
+	bcStore := chain.GetStore()
+	groups := filter.bloomGroups()
+	sectionSize := indexer.SectionSize()
+	logs := make([]*types.Log, 0)
+
+	for height := from; height <= to; {
+		section := height / sectionSize
+		if candidates, err := indexer.Candidates(section, groups); err == nil {
+			for _, candidate := range candidates {
+				if candidate < height || candidate > to {
+					continue
+				}
+
+				hash, err := bcStore.GetBlockHash(candidate)
+				if err != nil {
+					return nil, err
+				}
+
+				logs = append(logs, blockLogs(chain, hash, filter)...)
+			}
+
+			height = (section + 1) * sectionSize
+			continue
+		}
+
+		hash, err := bcStore.GetBlockHash(height)
+		if err != nil {
+			return nil, err
+		}
+
+		header, err := bcStore.GetBlockHeader(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if filter.MatchBloom(&header.LogsBloom) {
+			logs = append(logs, blockLogs(chain, hash, filter)...)
+		}
+
+		height++
+	}
+
+	return logs, nil
+}
+
+// blockLogs returns the logs in the receipts of the block which match the filter. The blocks
+// synchronized without state, e.g. by fast sync, have no receipts.
+func blockLogs(chain *core.Blockchain, hash common.Hash, filter *LogFilter) []*types.Log {
+	receipts, err := chain.GetStore().GetReceiptsByBlockHash(hash)
+	if err != nil {
+		return nil
+	}
+
+	var logs []*types.Log
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			if filter.Match(log) {
+				logs = append(logs, log)
+			}
+		}
+	}
+
+	return logs
+}
@@ -0,0 +1,287 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/seeleteam/go-seele/common/hexutil"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/event"
+)
+
+// DefaultFilterTimeout is the idle duration after which the filters not polled are uninstalled.
+const DefaultFilterTimeout = 5 * time.Minute
+
+const (
+	maxFilters        = 1000  // max number of the filters installed in the node
+	maxFilterChanges  = 10000 // max number of the changes kept by a filter, the oldest are dropped
+	maxLogsBlockRange = 10000 // max number of the blocks queried by GetLogs
+)
+
+var (
+	errFilterNotFound   = errors.New("filter not found")
+	errTooManyFilters   = errors.New("too many filters installed")
+	errLogRangeTooLarge = fmt.Errorf("block range too large, the max number of blocks is %d", maxLogsBlockRange)
+)
+
+// filter is an installed filter which collects the changes until they are polled.
+type filter struct {
+	lastUsed time.Time // protected by the mutex of PublicFilterAPI
+	sub      *event.Subscription
+
+	mutex   sync.Mutex // protects changes, which are added in the goroutine firing the events
+	changes []interface{}
+}
+
+// add appends the changes, and drops the oldest ones if the filter is not polled in time.
+func (f *filter) add(changes []interface{}) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.changes = append(f.changes, changes...)
+	if n := len(f.changes) - maxFilterChanges; n > 0 {
+		f.changes = f.changes[n:]
+	}
+}
+
+// take returns the changes and clears them.
+func (f *filter) take() []interface{} {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	changes := f.changes
+	f.changes = nil
+
+	return changes
+}
+
+// PublicFilterAPI provides an API to query the logs, and to poll the changes of the
+// installed filters.
+type PublicFilterAPI struct {
+	s       *SeeleService
+	timeout time.Duration
+
+	mutex   sync.Mutex // protects filters
+	filters map[string]*filter
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewPublicFilterAPI creates a new PublicFilterAPI object for rpc service.
+func NewPublicFilterAPI(s *SeeleService) *PublicFilterAPI {
+	return &PublicFilterAPI{
+		s:       s,
+		timeout: DefaultFilterTimeout,
+		filters: make(map[string]*filter),
+		quit:    make(chan struct{}),
+	}
+}
+
+// start starts to uninstall the idle filters periodically.
+func (api *PublicFilterAPI) start() {
+	api.wg.Add(1)
+	go func() {
+		defer api.wg.Done()
+
+		ticker := time.NewTicker(api.timeout / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				api.expire(now)
+			case <-api.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop uninstalls all filters.
+func (api *PublicFilterAPI) stop() {
+	close(api.quit)
+	api.wg.Wait()
+
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	for id, f := range api.filters {
+		f.sub.Unsubscribe()
+		delete(api.filters, id)
+	}
+}
+
+// expire uninstalls the filters which are not polled within the timeout.
+func (api *PublicFilterAPI) expire(now time.Time) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	for id, f := range api.filters {
+		if now.Sub(f.lastUsed) > api.timeout {
+			f.sub.Unsubscribe()
+			delete(api.filters, id)
+		}
+	}
+}
+
+func newFilterID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hexutil.BytesToHex(id[:])
+}
+
+// install installs the filter whose changes are collected by the event subscription. The
+// changes are added in the goroutine firing the events, so that they are in order, which
+// must not take the mutex of the api as the filters are unsubscribed with it held.
+func (api *PublicFilterAPI) install(manager *event.EventManager, changes func(e event.Event) []interface{}) (string, error) {
+	id := newFilterID()
+	f := &filter{lastUsed: time.Now()}
+
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	if len(api.filters) >= maxFilters {
+		return "", errTooManyFilters
+	}
+
+	f.sub = manager.SubscribeSync(func(e event.Event) {
+		if results := changes(e); len(results) > 0 {
+			f.add(results)
+		}
+	})
+
+	api.filters[id] = f
+
+	return id, nil
+}
+
+// LogQueryRequest is the request param of GetLogs with hex strings. The negative heights
+// stand for the HEAD block.
+type LogQueryRequest struct {
+	FromHeight int64
+	ToHeight   int64
+	Addresses  []string   // the contract addresses, empty matches any address
+	Topics     [][]string // the alternative topics at each position, empty matches any topic
+}
+
+// GetLogs returns the logs of the canonical blocks in the height range which match the
+// addresses and topics. The range is at most maxLogsBlockRange blocks.
+func (api *PublicFilterAPI) GetLogs(request *LogQueryRequest, result *[]map[string]interface{}) error {
+	filter, err := NewLogFilter(&LogFilterRequest{request.Addresses, request.Topics})
+	if err != nil {
+		return err
+	}
+
+	head, _ := api.s.chain.CurrentBlock()
+	from, to := head.Header.Height, head.Header.Height
+	if request.FromHeight >= 0 {
+		from = uint64(request.FromHeight)
+	}
+
+	if request.ToHeight >= 0 {
+		to = uint64(request.ToHeight)
+	}
+
+	if to >= from && to-from >= maxLogsBlockRange {
+		return errLogRangeTooLarge
+	}
+
+	if head.Header.Height < to {
+		to = head.Header.Height
+	}
+
+	logs, err := getLogs(api.s.chain, api.s.bloomIndexer, from, to, filter)
+	if err != nil {
+		return err
+	}
+
+	for _, log := range logs {
+		*result = append(*result, rpcOutputLog(log))
+	}
+
+	return nil
+}
+
+// NewFilter installs a filter of the logs of the new HEAD blocks which match the addresses
+// and topics, and returns the filter id.
+func (api *PublicFilterAPI) NewFilter(request *LogFilterRequest, result *string) error {
+	filter, err := NewLogFilter(request)
+	if err != nil {
+		return err
+	}
+
+	*result, err = api.install(event.ChainLogsEventManager, func(e event.Event) []interface{} {
+		var logs []interface{}
+		for _, log := range e.([]*types.Log) {
+			if filter.Match(log) {
+				logs = append(logs, rpcOutputLog(log))
+			}
+		}
+
+		return logs
+	})
+
+	return err
+}
+
+// NewBlockFilter installs a filter of the hashes of the new HEAD blocks, and returns the filter id.
+func (api *PublicFilterAPI) NewBlockFilter(input interface{}, result *string) (err error) {
+	*result, err = api.install(event.ChainHeaderChangedEventManager, func(e event.Event) []interface{} {
+		return []interface{}{e.(*types.Block).HeaderHash.ToHex()}
+	})
+
+	return err
+}
+
+// NewPendingTransactionFilter installs a filter of the hashes of the transactions added to
+// the tx pool, and returns the filter id.
+func (api *PublicFilterAPI) NewPendingTransactionFilter(input interface{}, result *string) (err error) {
+	*result, err = api.install(event.TransactionInsertedEventManager, func(e event.Event) []interface{} {
+		return []interface{}{e.(*types.Transaction).Hash.ToHex()}
+	})
+
+	return err
+}
+
+// GetFilterChanges returns the changes of the filter since the last poll, which are the logs
+// for the logs filter, and the hashes for the block and pending transaction filters.
+func (api *PublicFilterAPI) GetFilterChanges(id *string, result *[]interface{}) error {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	f := api.filters[*id]
+	if f == nil {
+		return errFilterNotFound
+	}
+
+	f.lastUsed = time.Now()
+	if changes := f.take(); len(changes) > 0 {
+		*result = changes
+	}
+
+	return nil
+}
+
+// UninstallFilter uninstalls the filter, and returns true if the filter is found.
+func (api *PublicFilterAPI) UninstallFilter(id *string, result *bool) error {
+	api.mutex.Lock()
+	f := api.filters[*id]
+	delete(api.filters, *id)
+	api.mutex.Unlock()
+
+	if f != nil {
+		f.sub.Unsubscribe()
+	}
+
+	*result = f != nil
+	return nil
+}
@@ -0,0 +1,230 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/bloombits"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/crypto"
+	"github.com/seeleteam/go-seele/database/leveldb"
+	"github.com/seeleteam/go-seele/event"
+	"github.com/seeleteam/go-seele/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFilterService(t *testing.T) *SeeleService {
+	chainDB, err := leveldb.NewMemDatabase()
+	assert.Nil(t, err)
+
+	stateDB, err := leveldb.NewMemDatabase()
+	assert.Nil(t, err)
+
+	s, err := NewSeeleServiceWithDB(getTmpConfig(), log.GetLogger("seele", common.PrintLog), chainDB, stateDB)
+	assert.Nil(t, err)
+
+	return s
+}
+
+// writeTestLogs writes the canonical blocks of the heights [1, count] after the genesis block,
+// and the block of each height in logs has a receipt with the log.
+func writeTestLogs(t *testing.T, s *SeeleService, count uint64, logs map[uint64]*types.Log) {
+	bcStore := s.chain.GetStore()
+	for height := uint64(1); height <= count; height++ {
+		var receipts []*types.Receipt
+		if log := logs[height]; log != nil {
+			log.BlockNumber = height
+			receipts = append(receipts, &types.Receipt{Logs: []*types.Log{log}})
+		}
+
+		header := &types.BlockHeader{
+			Height:          height,
+			Difficulty:      big.NewInt(1),
+			CreateTimestamp: big.NewInt(1),
+			LogsBloom:       types.ReceiptsBloom(receipts),
+		}
+
+		block := types.NewBlock(header, nil)
+		assert.Nil(t, bcStore.PutBlock(block, big.NewInt(1), true))
+		assert.Nil(t, bcStore.PutReceipts(block.HeaderHash, receipts))
+	}
+}
+
+func Test_LogFilter_MatchBloom(t *testing.T) {
+	addr1, addr2 := *crypto.MustGenerateRandomAddress(), *crypto.MustGenerateRandomAddress()
+	topic1, topic2 := common.StringToHash("topic1"), common.StringToHash("topic2")
+	bloom := types.LogsBloom([]*types.Log{{Address: addr1, Topics: []common.Hash{topic1}}})
+
+	match := func(addresses []common.Address, topics [][]common.Hash) bool {
+		filter := &LogFilter{addresses, topics}
+		return filter.MatchBloom(&bloom)
+	}
+
+	assert.Equal(t, match(nil, nil), true)
+	assert.Equal(t, match([]common.Address{addr2, addr1}, nil), true)
+	assert.Equal(t, match([]common.Address{addr2}, nil), false)
+	assert.Equal(t, match(nil, [][]common.Hash{nil, {topic2, topic1}}), true)
+	assert.Equal(t, match([]common.Address{addr1}, [][]common.Hash{{topic2}}), false)
+}
+
+func Test_GetLogs(t *testing.T) {
+	s := newTestFilterService(t)
+	defer s.Stop()
+
+	addr1, addr2 := *crypto.MustGenerateRandomAddress(), *crypto.MustGenerateRandomAddress()
+	topic := common.StringToHash("topic")
+	writeTestLogs(t, s, 40, map[uint64]*types.Log{
+		5:  {Address: addr1, Topics: []common.Hash{topic}},
+		17: {Address: addr2},
+		20: {Address: addr1},
+		35: {Address: addr1, Topics: []common.Hash{topic}},
+	})
+
+	// sections [0, 16) and [16, 32) are indexed, and the blocks after are checked with the header blooms
+	indexer := bloombits.NewIndexer(s.chainDB, s.chain.GetStore(), 16, 4)
+	indexer.Process(40)
+	assert.Equal(t, indexer.Sections(), uint64(2))
+
+	heights := func(from, to uint64, addresses []common.Address, topics [][]common.Hash) []uint64 {
+		logs, err := getLogs(s.chain, indexer, from, to, &LogFilter{addresses, topics})
+		assert.Nil(t, err)
+
+		result := make([]uint64, 0)
+		for _, log := range logs {
+			result = append(result, log.BlockNumber)
+		}
+
+		return result
+	}
+
+	assert.Equal(t, heights(0, 40, nil, nil), []uint64{5, 17, 20, 35})
+	assert.Equal(t, heights(0, 40, []common.Address{addr1}, nil), []uint64{5, 20, 35})
+	assert.Equal(t, heights(0, 40, []common.Address{addr1}, [][]common.Hash{{topic}}), []uint64{5, 35})
+	assert.Equal(t, heights(6, 34, []common.Address{addr1}, nil), []uint64{20})
+	assert.Equal(t, heights(18, 40, []common.Address{addr2}, nil), []uint64{})
+
+	_, err := getLogs(s.chain, indexer, 10, 9, &LogFilter{})
+	assert.Equal(t, err, errInvalidLogRange)
+}
+
+// waitFilterChanges polls the filter until the changes are collected by the async event listener.
+// filterChanges returns the changes of the filter, which are added when the events are fired.
+func filterChanges(t *testing.T, api *PublicFilterAPI, id string) []interface{} {
+	var changes []interface{}
+	assert.Nil(t, api.GetFilterChanges(&id, &changes))
+	return changes
+}
+
+func Test_PublicFilterAPI_Filters(t *testing.T) {
+	s := newTestFilterService(t)
+	defer s.Stop()
+
+	api := NewPublicFilterAPI(s)
+
+	// block filter
+	var blockFilterID string
+	assert.Nil(t, api.NewBlockFilter(nil, &blockFilterID))
+
+	block := types.NewBlock(&types.BlockHeader{Height: 1, Difficulty: big.NewInt(1), CreateTimestamp: big.NewInt(1)}, nil)
+	event.ChainHeaderChangedEventManager.Fire(block)
+	assert.Equal(t, filterChanges(t, api, blockFilterID), []interface{}{block.HeaderHash.ToHex()})
+
+	// pending transaction filter
+	var txFilterID string
+	assert.Nil(t, api.NewPendingTransactionFilter(nil, &txFilterID))
+
+	tx := &types.Transaction{Hash: common.StringToHash("tx")}
+	event.TransactionInsertedEventManager.Fire(tx)
+	assert.Equal(t, filterChanges(t, api, txFilterID), []interface{}{tx.Hash.ToHex()})
+
+	// logs filter
+	addr := *crypto.MustGenerateRandomAddress()
+	var logsFilterID string
+	assert.Nil(t, api.NewFilter(&LogFilterRequest{Addresses: []string{addr.ToHex()}}, &logsFilterID))
+
+	matched := &types.Log{Address: addr, BlockNumber: 3}
+	event.ChainLogsEventManager.Fire([]*types.Log{{Address: *crypto.MustGenerateRandomAddress()}, matched})
+	assert.Equal(t, filterChanges(t, api, logsFilterID), []interface{}{rpcOutputLog(matched)})
+
+	// the changes are cleared after polled
+	var changes []interface{}
+	assert.Nil(t, api.GetFilterChanges(&blockFilterID, &changes))
+	assert.Equal(t, len(changes), 0)
+
+	// uninstall
+	var uninstalled bool
+	assert.Nil(t, api.UninstallFilter(&blockFilterID, &uninstalled))
+	assert.Equal(t, uninstalled, true)
+	assert.Nil(t, api.UninstallFilter(&blockFilterID, &uninstalled))
+	assert.Equal(t, uninstalled, false)
+	assert.Equal(t, api.GetFilterChanges(&blockFilterID, &changes), errFilterNotFound)
+
+	// the idle filters expire
+	api.expire(time.Now().Add(api.timeout + time.Second))
+	assert.Equal(t, api.GetFilterChanges(&txFilterID, &changes), errFilterNotFound)
+	assert.Equal(t, api.GetFilterChanges(&logsFilterID, &changes), errFilterNotFound)
+
+	api.stop()
+}
+
+func Test_PublicFilterAPI_Limits(t *testing.T) {
+	s := newTestFilterService(t)
+	defer s.Stop()
+
+	api := NewPublicFilterAPI(s)
+	defer api.stop()
+
+	// the oldest changes are dropped
+	f := &filter{}
+	for i := 0; i < maxFilterChanges+2; i++ {
+		f.add([]interface{}{i})
+	}
+
+	assert.Equal(t, len(f.changes), maxFilterChanges)
+	assert.Equal(t, f.changes[0], 2)
+
+	// the number of filters is limited
+	var id string
+	for i := 0; i < maxFilters; i++ {
+		assert.Nil(t, api.NewBlockFilter(nil, &id))
+	}
+
+	var txFilterID string
+	assert.Equal(t, api.NewPendingTransactionFilter(nil, &txFilterID), errTooManyFilters)
+
+	var uninstalled bool
+	assert.Nil(t, api.UninstallFilter(&id, &uninstalled))
+	assert.Nil(t, api.NewPendingTransactionFilter(nil, &txFilterID))
+}
+
+func Test_PublicFilterAPI_GetLogs(t *testing.T) {
+	s := newTestFilterService(t)
+	defer s.Stop()
+
+	addr := *crypto.MustGenerateRandomAddress()
+	writeTestLogs(t, s, 3, map[uint64]*types.Log{2: {Address: addr}})
+
+	var result []map[string]interface{}
+	request := &LogQueryRequest{FromHeight: 0, ToHeight: 3, Addresses: []string{addr.ToHex()}}
+	assert.Nil(t, s.filterAPI.GetLogs(request, &result))
+
+	// the range is capped by the current block which is still the genesis block
+	assert.Equal(t, len(result), 0)
+
+	request.Addresses = []string{"0xinvalid"}
+	assert.NotNil(t, s.filterAPI.GetLogs(request, &result))
+
+	// the range is limited
+	request = &LogQueryRequest{FromHeight: 0, ToHeight: maxLogsBlockRange - 1}
+	assert.Nil(t, s.filterAPI.GetLogs(request, &result))
+
+	request.ToHeight++
+	assert.Equal(t, s.filterAPI.GetLogs(request, &result), errLogRangeTooLarge)
+}
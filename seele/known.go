@@ -0,0 +1,66 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/seeleteam/go-seele/common"
+)
+
+// knownCache is a bounded set of hashes known by a peer, the least recently
+// added hash is dropped when the cache is full.
+type knownCache struct {
+	lock     sync.Mutex
+	capacity int
+	order    *list.List                    // hashes, the most recently added first
+	items    map[common.Hash]*list.Element // hash => element in order
+}
+
+func newKnownCache(capacity int) *knownCache {
+	return &knownCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[common.Hash]*list.Element),
+	}
+}
+
+// add adds the hash into the cache, or marks it as the most recently added if known.
+func (c *knownCache) add(hash common.Hash) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if e, ok := c.items[hash]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.items[hash] = c.order.PushFront(hash)
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(common.Hash))
+	}
+}
+
+// has returns true if the hash is in the cache.
+func (c *knownCache) has(hash common.Hash) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_, ok := c.items[hash]
+	return ok
+}
+
+// size returns the number of hashes in the cache.
+func (c *knownCache) size() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.order.Len()
+}
@@ -0,0 +1,31 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"testing"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_KnownCache(t *testing.T) {
+	c := newKnownCache(2)
+	h1, h2, h3 := common.StringToHash("1"), common.StringToHash("2"), common.StringToHash("3")
+
+	c.add(h1)
+	c.add(h2)
+	assert.Equal(t, c.has(h1), true)
+	assert.Equal(t, c.has(h2), true)
+
+	// h1 is added again and becomes the most recently added, h2 is dropped
+	c.add(h1)
+	c.add(h3)
+	assert.Equal(t, c.size(), 2)
+	assert.Equal(t, c.has(h1), true)
+	assert.Equal(t, c.has(h2), false)
+	assert.Equal(t, c.has(h3), true)
+}
@@ -0,0 +1,153 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"sync"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/types"
+)
+
+const (
+	// maxOrphanBlocks is the max number of orphan blocks kept in the pool.
+	maxOrphanBlocks = 256
+
+	// orphanExpiration is the time an orphan block is kept waiting for its parent.
+	orphanExpiration = 5 * time.Minute
+
+	// maxOrphanDistance is the max distance of an orphan ancestor from the local chain height
+	// to request its parent explicitly, the farther ones are left to the downloader.
+	maxOrphanDistance = 16
+)
+
+// orphanBlock is a block received before its parent.
+type orphanBlock struct {
+	block *types.Block
+	peer  string    // id of the peer which sends the block
+	added time.Time // time the block is added into the pool
+}
+
+// orphanPool buffers the blocks whose parents are unknown, they are imported
+// once the parents are inserted into the chain.
+type orphanPool struct {
+	lock     sync.Mutex
+	orphans  map[common.Hash]*orphanBlock   // block hash => orphan
+	children map[common.Hash][]*orphanBlock // parent hash => orphans
+}
+
+func newOrphanPool() *orphanPool {
+	return &orphanPool{
+		orphans:  make(map[common.Hash]*orphanBlock),
+		children: make(map[common.Hash][]*orphanBlock),
+	}
+}
+
+// add adds the block from the peer into the pool. It returns false if the block is already
+// in the pool. The oldest orphan is evicted when the pool is full.
+func (pool *orphanPool) add(block *types.Block, peer string, now time.Time) bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if _, ok := pool.orphans[block.HeaderHash]; ok {
+		return false
+	}
+
+	pool.expireLocked(now)
+
+	for len(pool.orphans) >= maxOrphanBlocks {
+		var oldest *orphanBlock
+		for _, o := range pool.orphans {
+			if oldest == nil || o.added.Before(oldest.added) {
+				oldest = o
+			}
+		}
+
+		pool.remove(oldest)
+	}
+
+	orphan := &orphanBlock{block, peer, now}
+	parent := block.Header.PreviousBlockHash
+	pool.orphans[block.HeaderHash] = orphan
+	pool.children[parent] = append(pool.children[parent], orphan)
+
+	return true
+}
+
+// has returns true if the block is in the pool.
+func (pool *orphanPool) has(hash common.Hash) bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	_, ok := pool.orphans[hash]
+	return ok
+}
+
+// take removes and returns the orphans whose parent is the block of the hash.
+func (pool *orphanPool) take(parent common.Hash) []*types.Block {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	var blocks []*types.Block
+	for _, o := range pool.children[parent] {
+		delete(pool.orphans, o.block.HeaderHash)
+		blocks = append(blocks, o.block)
+	}
+
+	delete(pool.children, parent)
+
+	return blocks
+}
+
+// ancestor returns the earliest orphan on the chain of the block, whose parent is missing.
+func (pool *orphanPool) ancestor(block *types.Block) *types.Block {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	for {
+		o, ok := pool.orphans[block.Header.PreviousBlockHash]
+		if !ok {
+			return block
+		}
+
+		block = o.block
+	}
+}
+
+// expire removes the orphans which have waited too long for the parents.
+func (pool *orphanPool) expire(now time.Time) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	pool.expireLocked(now)
+}
+
+func (pool *orphanPool) expireLocked(now time.Time) {
+	for _, o := range pool.orphans {
+		if now.Sub(o.added) >= orphanExpiration {
+			pool.remove(o)
+		}
+	}
+}
+
+func (pool *orphanPool) remove(orphan *orphanBlock) {
+	hash, parent := orphan.block.HeaderHash, orphan.block.Header.PreviousBlockHash
+	delete(pool.orphans, hash)
+
+	siblings := pool.children[parent][:0]
+	for _, o := range pool.children[parent] {
+		if o != orphan {
+			siblings = append(siblings, o)
+		}
+	}
+
+	if len(siblings) == 0 {
+		delete(pool.children, parent)
+	} else {
+		pool.children[parent] = siblings
+	}
+}
@@ -0,0 +1,99 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newOrphanTestBlock(parent common.Hash, height uint64) *types.Block {
+	header := &types.BlockHeader{
+		PreviousBlockHash: parent,
+		Height:            height,
+	}
+
+	return &types.Block{
+		HeaderHash: header.Hash(),
+		Header:     header,
+	}
+}
+
+func Test_OrphanPool_AddTake(t *testing.T) {
+	pool := newOrphanPool()
+	now := time.Now()
+	parent := common.StringToHash("parent")
+
+	b1 := newOrphanTestBlock(parent, 1)
+	b2 := newOrphanTestBlock(b1.HeaderHash, 2)
+	fork := newOrphanTestBlock(b1.HeaderHash, 3)
+
+	assert.Equal(t, pool.add(b1, "p", now), true)
+	assert.Equal(t, pool.add(b1, "p", now), false)
+	assert.Equal(t, pool.add(b2, "p", now), true)
+	assert.Equal(t, pool.add(fork, "p", now), true)
+	assert.Equal(t, pool.has(b2.HeaderHash), true)
+
+	assert.Equal(t, pool.take(parent), []*types.Block{b1})
+	assert.Equal(t, len(pool.take(b1.HeaderHash)), 2)
+	assert.Equal(t, len(pool.orphans), 0)
+	assert.Equal(t, len(pool.children), 0)
+}
+
+func Test_OrphanPool_Ancestor(t *testing.T) {
+	pool := newOrphanPool()
+	now := time.Now()
+
+	b1 := newOrphanTestBlock(common.StringToHash("parent"), 1)
+	b2 := newOrphanTestBlock(b1.HeaderHash, 2)
+	b3 := newOrphanTestBlock(b2.HeaderHash, 3)
+
+	pool.add(b3, "p", now)
+	assert.Equal(t, pool.ancestor(b3), b3)
+
+	pool.add(b1, "p", now)
+	pool.add(b2, "p", now)
+	assert.Equal(t, pool.ancestor(b3), b1)
+}
+
+func Test_OrphanPool_Expire(t *testing.T) {
+	pool := newOrphanPool()
+	now := time.Now()
+	parent := common.StringToHash("parent")
+
+	old := newOrphanTestBlock(parent, 1)
+	pool.add(old, "p", now)
+
+	recent := newOrphanTestBlock(parent, 2)
+	pool.add(recent, "p", now.Add(orphanExpiration/2))
+
+	pool.expire(now.Add(orphanExpiration))
+	assert.Equal(t, pool.has(old.HeaderHash), false)
+	assert.Equal(t, pool.has(recent.HeaderHash), true)
+	assert.Equal(t, pool.take(parent), []*types.Block{recent})
+}
+
+func Test_OrphanPool_Limit(t *testing.T) {
+	pool := newOrphanPool()
+	now := time.Now()
+	parent := common.StringToHash("parent")
+
+	first := newOrphanTestBlock(parent, 0)
+	pool.add(first, "p", now)
+
+	for i := 1; i <= maxOrphanBlocks; i++ {
+		pool.add(newOrphanTestBlock(parent, uint64(i)), "p", now.Add(time.Duration(i)))
+	}
+
+	// the oldest orphan is evicted
+	assert.Equal(t, len(pool.orphans), maxOrphanBlocks)
+	assert.Equal(t, len(pool.children[parent]), maxOrphanBlocks)
+	assert.Equal(t, pool.has(first.HeaderHash), false)
+}
@@ -16,20 +16,27 @@ import (
 	"github.com/seeleteam/go-seele/core/types"
 	"github.com/seeleteam/go-seele/p2p"
 	"github.com/seeleteam/go-seele/seele/download"
-	set "gopkg.in/fatih/set.v0"
 )
 
 const (
 	// DiscHandShakeErr peer handshake error
 	DiscHandShakeErr = 100
 
+	// DiscBadBlock peer sends a block with invalid header
+	DiscBadBlock = 101
+
 	maxKnownTxs    = 32768 // Maximum transactions hashes to keep in the known list
 	maxKnownBlocks = 1024  // Maximum block hashes to keep in the known list
+
+	maxQueuedTxs       = 4096 // Maximum transactions or hashes queued to broadcast to a peer
+	maxTxHashesPerMsg  = 1024 // Maximum transaction hashes in an announcement message
+	maxTxsPerBroadcast = 256  // Maximum transactions in a broadcast message
 )
 
 var (
 	errMsgNotMatch     = errors.New("Message not match")
 	errNetworkNotMatch = errors.New("NetworkID not match")
+	errVersionNotMatch = errors.New("Protocol version not match")
 )
 
 // PeerInfo represents a short summary of a connected peer.
@@ -50,8 +57,12 @@ type peer struct {
 
 	rw p2p.MsgReadWriter // the read write method for this peer
 
-	knownTxs    *set.Set // Set of transaction hashes known by this peer
-	knownBlocks *set.Set // Set of block hashes known by this peer
+	knownTxs    *knownCache // Set of transaction hashes known by this peer
+	knownBlocks *knownCache // Set of block hashes known by this peer
+
+	txLock     sync.Mutex
+	queuedTxs  []*types.Transaction // transactions queued to send in full
+	queuedHash []common.Hash        // transaction hashes queued to announce
 }
 
 func newPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
@@ -61,8 +72,8 @@ func newPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 		td:          big.NewInt(0),
 		peerID:      p.Node.ID,
 		peerStrID:   fmt.Sprintf("%x", p.Node.ID[:8]),
-		knownTxs:    set.New(),
-		knownBlocks: set.New(),
+		knownTxs:    newKnownCache(maxKnownTxs),
+		knownBlocks: newKnownCache(maxKnownBlocks),
 		rw:          rw,
 	}
 }
@@ -80,42 +91,102 @@ func (p *peer) Info() *PeerInfo {
 
 // markTransaction marks hash in knownTxs set
 func (p *peer) markTransaction(hash common.Hash) {
-	// If we reached the memory allowance, drop a previously known transaction hash
-	for p.knownTxs.Size() >= maxKnownTxs {
-		p.knownTxs.Pop()
+	p.knownTxs.add(hash)
+}
+
+// queueTransaction queues the transaction to send in full in the next broadcast,
+// it returns false if the transaction is known by the peer or the queue is full.
+func (p *peer) queueTransaction(tx *types.Transaction) bool {
+	p.txLock.Lock()
+	defer p.txLock.Unlock()
+
+	if p.knownTxs.has(tx.Hash) || len(p.queuedTxs) >= maxQueuedTxs {
+		return false
 	}
-	p.knownTxs.Add(hash)
+
+	p.markTransaction(tx.Hash)
+	p.queuedTxs = append(p.queuedTxs, tx)
+
+	return true
 }
 
-func (p *peer) sendTransactionHash(txHash common.Hash) error {
-	if p.knownTxs.Has(txHash) {
-		return nil
+// queueTransactionHash queues the transaction hash to announce in the next broadcast,
+// it returns false if the transaction is known by the peer or the queue is full.
+func (p *peer) queueTransactionHash(hash common.Hash) bool {
+	p.txLock.Lock()
+	defer p.txLock.Unlock()
+
+	if p.knownTxs.has(hash) || len(p.queuedHash) >= maxQueuedTxs {
+		return false
 	}
 
-	err := p2p.SendMessage(p.rw, transactionHashMsgCode, common.SerializePanic(txHash))
-	if err == nil {
-		p.markTransaction(txHash)
+	p.markTransaction(hash)
+	p.queuedHash = append(p.queuedHash, hash)
+
+	return true
+}
+
+// broadcastTransactions sends the queued transactions and announces the queued hashes in batches.
+func (p *peer) broadcastTransactions() error {
+	p.txLock.Lock()
+	txs, hashes := p.queuedTxs, p.queuedHash
+	p.queuedTxs, p.queuedHash = nil, nil
+	p.txLock.Unlock()
+
+	for len(txs) > 0 {
+		n := len(txs)
+		if n > maxTxsPerBroadcast {
+			n = maxTxsPerBroadcast
+		}
+
+		if err := p.sendTransactions(txs[:n]); err != nil {
+			return err
+		}
+
+		txs = txs[n:]
 	}
 
-	return err
+	for len(hashes) > 0 {
+		n := len(hashes)
+		if n > maxTxHashesPerMsg {
+			n = maxTxHashesPerMsg
+		}
+
+		if err := p2p.SendMessage(p.rw, transactionHashMsgCode, common.SerializePanic(hashes[:n])); err != nil {
+			return err
+		}
+
+		hashes = hashes[n:]
+	}
+
+	return nil
 }
 
-func (p *peer) sendTransactionRequest(txHash common.Hash) error {
-	return p2p.SendMessage(p.rw, transactionRequestMsgCode, common.SerializePanic(txHash))
+// RequestTransactions requests the transactions of hashes from the peer
+func (p *peer) RequestTransactions(hashes []common.Hash) error {
+	return p2p.SendMessage(p.rw, transactionRequestMsgCode, common.SerializePanic(hashes))
 }
 
-func (p *peer) sendTransaction(tx *types.Transaction) error {
-	return p2p.SendMessage(p.rw, transactionsMsgCode, common.SerializePanic([]*types.Transaction{tx}))
+// markBlock marks hash in knownBlocks set
+func (p *peer) markBlock(hash common.Hash) {
+	p.knownBlocks.add(hash)
 }
 
-func (p *peer) SendBlockHash(blockHash common.Hash) error {
-	if p.knownBlocks.Has(blockHash) {
+// SendBlockHash announces the block hash with its height and total difficulty
+func (p *peer) SendBlockHash(block *types.Block, td *big.Int) error {
+	if p.knownBlocks.has(block.HeaderHash) {
 		return nil
 	}
 
-	err := p2p.SendMessage(p.rw, blockHashMsgCode, common.SerializePanic(blockHash))
+	announce := &newBlockHash{
+		Hash:   block.HeaderHash,
+		Number: block.Header.Height,
+		TD:     td,
+	}
+
+	err := p2p.SendMessage(p.rw, blockHashMsgCode, common.SerializePanic(announce))
 	if err == nil {
-		p.knownBlocks.Add(blockHash)
+		p.markBlock(block.HeaderHash)
 	}
 
 	return err
@@ -129,8 +200,32 @@ func (p *peer) sendTransactions(txs []*types.Transaction) error {
 	return p2p.SendMessage(p.rw, transactionsMsgCode, common.SerializePanic(txs))
 }
 
-func (p *peer) SendBlock(block *types.Block) error {
-	return p2p.SendMessage(p.rw, blockMsgCode, common.SerializePanic(block))
+// SendBlock sends the block with its total difficulty
+func (p *peer) SendBlock(block *types.Block, td *big.Int) error {
+	err := p2p.SendMessage(p.rw, blockMsgCode, common.SerializePanic(&newBlock{block, td}))
+	if err == nil {
+		p.markBlock(block.HeaderHash)
+	}
+
+	return err
+}
+
+// SendCompactBlock sends the block with its total difficulty in the compact form
+func (p *peer) SendCompactBlock(block *types.Block, td *big.Int) error {
+	err := p2p.SendMessage(p.rw, compactBlockMsgCode, common.SerializePanic(newCompactBlock(block, td)))
+	if err == nil {
+		p.markBlock(block.HeaderHash)
+	}
+
+	return err
+}
+
+func (p *peer) sendBlockTxsRequest(hash common.Hash, indexes []uint64) error {
+	return p2p.SendMessage(p.rw, blockTxsRequestMsgCode, common.SerializePanic(&blockTxsRequest{hash, indexes}))
+}
+
+func (p *peer) sendBlockTxs(hash common.Hash, txs []*types.Transaction) error {
+	return p2p.SendMessage(p.rw, blockTxsMsgCode, common.SerializePanic(&blockTxs{hash, txs}))
 }
 
 // Head retrieves a copy of the current head hash and total difficulty.
@@ -153,11 +248,12 @@ func (p *peer) SetHead(hash common.Hash, td *big.Int) {
 
 // RequestHeadersByHashOrNumber fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the hash of an origin block.
-func (p *peer) RequestHeadersByHashOrNumber(origin common.Hash, num uint64, amount int, reverse bool) error {
+func (p *peer) RequestHeadersByHashOrNumber(origin common.Hash, num uint64, amount int, skip int, reverse bool) error {
 	query := &blockHeadersQuery{
 		Hash:    origin,
 		Number:  num,
 		Amount:  uint64(amount),
+		Skip:    uint64(skip),
 		Reverse: reverse,
 	}
 	return p2p.SendMessage(p.rw, downloader.GetBlockHeadersMsg, common.SerializePanic(query))
@@ -186,6 +282,15 @@ func (p *peer) sendBlocks(blocks []*types.Block) error {
 	return p2p.SendMessage(p.rw, downloader.BlocksMsg, common.SerializePanic(blocks))
 }
 
+// RequestNodeData fetches the state trie nodes of hashes.
+func (p *peer) RequestNodeData(hashes []common.Hash) error {
+	return p2p.SendMessage(p.rw, downloader.GetNodeDataMsg, common.SerializePanic(hashes))
+}
+
+func (p *peer) sendNodeData(nodes [][]byte) error {
+	return p2p.SendMessage(p.rw, downloader.NodeDataMsg, common.SerializePanic(nodes))
+}
+
 func (p *peer) sendHeadStatus(msg *chainHeadStatus) error {
 	return p2p.SendMessage(p.rw, statusChainHeadMsgCode, common.SerializePanic(msg))
 }
@@ -221,6 +326,10 @@ func (p *peer) handShake(networkID uint64, td *big.Int, head common.Hash, genesi
 		return errNetworkNotMatch
 	}
 
+	if retStatusMsg.ProtocolVersion != uint32(SeeleVersion) {
+		return errVersionNotMatch
+	}
+
 	p.head = retStatusMsg.CurrentBlock
 	p.td = retStatusMsg.TD
 	return nil
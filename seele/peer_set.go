@@ -74,6 +74,13 @@ func (p *peerSet) Add(pe *peer) {
 	p.peers[pe.Node.ID] = pe
 }
 
+func (p *peerSet) count() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return len(p.peers)
+}
+
 func (p *peerSet) ForEach(handle func(*peer) bool) {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
@@ -13,7 +13,9 @@ import (
 	"github.com/seeleteam/go-seele/p2p/discovery"
 
 	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/crypto"
 	"github.com/seeleteam/go-seele/p2p"
+	"github.com/stretchr/testify/assert"
 )
 
 func Test_peer_Info(t *testing.T) {
@@ -26,7 +28,7 @@ func Test_peer_Info(t *testing.T) {
 	var myHash common.Hash
 	copy(myHash[0:], myAddr[0:common.HashLength])
 	bigInt := big.NewInt(100)
-	okStr := "{\"version\":1,\"difficulty\":100,\"head\":\"0548d0b1a3297fea072284f86b9fd39a9f1273c46fba8951b62de5b95cd3dd84\"}"
+	okStr := "{\"version\":2,\"difficulty\":100,\"head\":\"0548d0b1a3297fea072284f86b9fd39a9f1273c46fba8951b62de5b95cd3dd84\"}"
 
 	// Create peer for test
 	peer := newPeer(SeeleVersion, p2pPeer, nil)
@@ -39,3 +41,79 @@ func Test_peer_Info(t *testing.T) {
 		t.Fail()
 	}
 }
+
+// testMsgRW records the messages written.
+type testMsgRW struct {
+	msgs []p2p.Message
+}
+
+func (rw *testMsgRW) ReadMsg() (p2p.Message, error) { return p2p.Message{}, nil }
+
+func (rw *testMsgRW) WriteMsg(msg p2p.Message) error {
+	rw.msgs = append(rw.msgs, msg)
+	return nil
+}
+
+func Test_peer_BroadcastTransactions(t *testing.T) {
+	rw := &testMsgRW{}
+	node := discovery.NewNode(*crypto.MustGenerateRandomAddress(), nil, 0)
+	peer := newPeer(SeeleVersion, &p2p.Peer{Node: node}, rw)
+
+	tx := newCompactTestTx(0)
+	assert.Equal(t, peer.queueTransaction(tx), true)
+	assert.Equal(t, peer.queueTransaction(tx), false)
+	assert.Equal(t, peer.queueTransactionHash(tx.Hash), false)
+
+	for i := 0; i < maxTxHashesPerMsg+1; i++ {
+		var hash common.Hash
+		hash[0], hash[1] = byte(i), byte(i>>8)
+		assert.Equal(t, peer.queueTransactionHash(hash), true)
+	}
+
+	// one message of transactions, and the hashes in two messages
+	assert.Equal(t, peer.broadcastTransactions(), nil)
+	assert.Equal(t, len(rw.msgs), 3)
+	assert.Equal(t, rw.msgs[0].Code, transactionsMsgCode)
+
+	var hashes []common.Hash
+	assert.Equal(t, common.Deserialize(rw.msgs[1].Payload, &hashes), nil)
+	assert.Equal(t, len(hashes), maxTxHashesPerMsg)
+	assert.Equal(t, common.Deserialize(rw.msgs[2].Payload, &hashes), nil)
+	assert.Equal(t, len(hashes), 1)
+
+	// nothing is sent without queued transactions
+	assert.Equal(t, peer.broadcastTransactions(), nil)
+	assert.Equal(t, len(rw.msgs), 3)
+}
+
+// statusMsgRW replies the status message to the handshake.
+type statusMsgRW struct {
+	testMsgRW
+	status *statusData
+}
+
+func (rw *statusMsgRW) ReadMsg() (p2p.Message, error) {
+	return p2p.Message{Code: statusDataMsgCode, Payload: common.SerializePanic(rw.status)}, nil
+}
+
+func Test_peer_HandShake(t *testing.T) {
+	node := discovery.NewNode(*crypto.MustGenerateRandomAddress(), nil, 0)
+	genesis := common.StringToHash("genesis")
+	head := common.StringToHash("head")
+	status := &statusData{
+		ProtocolVersion: uint32(SeeleVersion),
+		NetworkID:       1,
+		TD:              big.NewInt(10),
+		CurrentBlock:    head,
+		GenesisBlock:    genesis,
+	}
+
+	peer := newPeer(SeeleVersion, &p2p.Peer{Node: node}, &statusMsgRW{status: status})
+	assert.Equal(t, peer.handShake(1, big.NewInt(1), common.EmptyHash, genesis), nil)
+	assert.Equal(t, peer.head, head)
+
+	// the peer of the old protocol version is rejected
+	status.ProtocolVersion = 1
+	peer = newPeer(SeeleVersion, &p2p.Peer{Node: node}, &statusMsgRW{status: status})
+	assert.Equal(t, peer.handShake(1, big.NewInt(1), common.EmptyHash, genesis), errVersionNotMatch)
+}
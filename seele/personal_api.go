@@ -0,0 +1,229 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/common/hexutil"
+	"github.com/seeleteam/go-seele/common/keystore"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/crypto"
+)
+
+// PrivatePersonalAPI provides an API to manage the accounts in the keystore of the node, and
+// to send the transactions signed by them.
+type PrivatePersonalAPI struct {
+	s       *SeeleService
+	manager *keystore.Manager
+
+	nonceLock sync.Mutex // keeps the nonces of the sent txs unique
+}
+
+// NewPrivatePersonalAPI creates a new PrivatePersonalAPI object for rpc service.
+func NewPrivatePersonalAPI(s *SeeleService) *PrivatePersonalAPI {
+	return &PrivatePersonalAPI{s: s, manager: s.accountManager}
+}
+
+// NewAccount creates a new account whose key is encrypted with the passphrase, and returns
+// the account address.
+func (api *PrivatePersonalAPI) NewAccount(passphrase *string, result *string) error {
+	address, err := api.manager.NewAccount(*passphrase)
+	if err != nil {
+		return err
+	}
+
+	*result = address.ToHex()
+	return nil
+}
+
+// ListAccounts returns the addresses of the accounts in the keystore.
+func (api *PrivatePersonalAPI) ListAccounts(input interface{}, result *[]string) error {
+	accounts, err := api.manager.Accounts()
+	if err != nil {
+		return err
+	}
+
+	addresses := make([]string, len(accounts))
+	for i := range accounts {
+		addresses[i] = accounts[i].ToHex()
+	}
+
+	*result = addresses
+	return nil
+}
+
+// ImportRawKeyRequest is the request param of ImportRawKey.
+type ImportRawKeyRequest struct {
+	KeyHex     string // the hex private key
+	Passphrase string
+}
+
+// ImportRawKey stores the private key encrypted with the passphrase, and returns the account address.
+func (api *PrivatePersonalAPI) ImportRawKey(request *ImportRawKeyRequest, result *string) error {
+	privKey, err := crypto.LoadECDSAFromString(request.KeyHex)
+	if err != nil {
+		return err
+	}
+
+	address, err := api.manager.Import(privKey, request.Passphrase)
+	if err != nil {
+		return err
+	}
+
+	*result = address.ToHex()
+	return nil
+}
+
+// UnlockAccountRequest is the request param of UnlockAccount.
+type UnlockAccountRequest struct {
+	Address    string
+	Passphrase string
+	Duration   uint64 // the seconds to keep the account unlocked, 0 until it is locked
+}
+
+// UnlockAccount unlocks the account for the duration, so that the txs and data are signed
+// without the passphrase.
+func (api *PrivatePersonalAPI) UnlockAccount(request *UnlockAccountRequest, result *bool) error {
+	address, err := common.HexToAddress(request.Address)
+	if err != nil {
+		return err
+	}
+
+	timeout := time.Duration(request.Duration) * time.Second
+	if err = api.manager.Unlock(address, request.Passphrase, timeout); err != nil {
+		return err
+	}
+
+	*result = true
+	return nil
+}
+
+// LockAccount locks the account, and returns false if it is not unlocked.
+func (api *PrivatePersonalAPI) LockAccount(address *string, result *bool) error {
+	addr, err := common.HexToAddress(*address)
+	if err != nil {
+		return err
+	}
+
+	*result = api.manager.Lock(addr)
+	return nil
+}
+
+// signHash signs the hash with the key of the account, which is decrypted with the passphrase,
+// or unlocked if the passphrase is empty.
+func (api *PrivatePersonalAPI) signHash(address common.Address, passphrase string, hash []byte) (*crypto.Signature, error) {
+	if len(passphrase) == 0 {
+		return api.manager.SignHash(address, hash)
+	}
+
+	return api.manager.SignHashWithPassphrase(address, passphrase, hash)
+}
+
+// SendTxRequest is the request param of SendTransaction with hex strings.
+type SendTxRequest struct {
+	From       string
+	To         string   // empty to create a contract
+	Amount     *big.Int // nil for 0
+	Payload    string   // the hex payload, empty for no payload
+	Passphrase string   // empty to sign with the unlocked account
+}
+
+// SendTransaction fills the nonce of the tx, signs it with the key of the sender, and adds
+// it to the tx pool. It returns the tx hash.
+func (api *PrivatePersonalAPI) SendTransaction(request *SendTxRequest, result *string) error {
+	from, err := common.HexToAddress(request.From)
+	if err != nil {
+		return err
+	}
+
+	amount := request.Amount
+	if amount == nil {
+		amount = new(big.Int)
+	}
+
+	var to common.Address
+	if len(request.To) > 0 {
+		if to, err = common.HexToAddress(request.To); err != nil {
+			return err
+		}
+	}
+
+	var payload []byte
+	if len(request.Payload) > 0 {
+		if payload, err = hexutil.HexToBytes(request.Payload); err != nil {
+			return err
+		}
+	}
+
+	api.nonceLock.Lock()
+	defer api.nonceLock.Unlock()
+
+	nonce := api.s.txPool.GetPendingNonce(from)
+
+	var tx *types.Transaction
+	if len(request.To) == 0 {
+		tx, err = types.NewContractTransaction(from, amount, nonce, payload)
+	} else {
+		tx, err = types.NewMessageTransaction(from, to, amount, nonce, payload)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if tx.Signature, err = api.signHash(from, request.Passphrase, tx.Hash.Bytes()); err != nil {
+		return err
+	}
+
+	if err = api.s.txPool.AddTransaction(tx); err != nil {
+		return err
+	}
+
+	*result = tx.Hash.ToHex()
+	return nil
+}
+
+// SignRequest is the request param of Sign with hex strings.
+type SignRequest struct {
+	Address    string
+	DataHex    string
+	Passphrase string // empty to sign with the unlocked account
+}
+
+// signDataHash returns the hash of the prefixed data to sign, so that the signature of the
+// data could not be used as the signature of a tx.
+func signDataHash(data []byte) []byte {
+	prefix := fmt.Sprintf("\x19Seele Signed Message:\n%d", len(data))
+	return crypto.HashBytes([]byte(prefix), data).Bytes()
+}
+
+// Sign signs the hash of the prefixed data with the key of the account, and returns the hex
+// signature of the 32-byte R followed by the 32-byte S.
+func (api *PrivatePersonalAPI) Sign(request *SignRequest, result *string) error {
+	address, err := common.HexToAddress(request.Address)
+	if err != nil {
+		return err
+	}
+
+	data, err := hexutil.HexToBytes(request.DataHex)
+	if err != nil {
+		return err
+	}
+
+	sig, err := api.signHash(address, request.Passphrase, signDataHash(data))
+	if err != nil {
+		return err
+	}
+
+	*result = hexutil.BytesToHex(append(math.PaddedBigBytes(sig.R, 32), math.PaddedBigBytes(sig.S, 32)...))
+	return nil
+}
@@ -0,0 +1,117 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/common/hexutil"
+	"github.com/seeleteam/go-seele/common/keystore"
+	"github.com/seeleteam/go-seele/crypto"
+	"github.com/seeleteam/go-seele/database/leveldb"
+	"github.com/seeleteam/go-seele/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPersonalService(t *testing.T, keyStoreDir string) *SeeleService {
+	chainDB, err := leveldb.NewMemDatabase()
+	assert.Nil(t, err)
+
+	stateDB, err := leveldb.NewMemDatabase()
+	assert.Nil(t, err)
+
+	conf := getTmpConfig()
+	conf.KeyStoreDir = keyStoreDir
+
+	s, err := NewSeeleServiceWithDB(conf, log.GetLogger("seele", common.PrintLog), chainDB, stateDB)
+	assert.Nil(t, err)
+
+	return s
+}
+
+func Test_PrivatePersonalAPI(t *testing.T) {
+	dir := filepath.Join(common.GetTempFolder(), "personal")
+	defer os.RemoveAll(dir)
+
+	s := newTestPersonalService(t, dir)
+	defer s.Stop()
+
+	api := NewPrivatePersonalAPI(s)
+
+	// import
+	_, privKey, err := crypto.GenerateKeyPair()
+	assert.Nil(t, err)
+
+	var address string
+	keyHex := hexutil.BytesToHex(crypto.FromECDSA(privKey))
+	assert.Nil(t, api.ImportRawKey(&ImportRawKeyRequest{keyHex, "password"}, &address))
+
+	var accounts []string
+	assert.Nil(t, api.ListAccounts(nil, &accounts))
+	assert.Equal(t, accounts, []string{address})
+
+	// the locked account signs with the passphrase only
+	to := crypto.MustGenerateRandomAddress().ToHex()
+	var txHash string
+	assert.Equal(t, api.SendTransaction(&SendTxRequest{From: address, To: to}, &txHash), keystore.ErrLocked)
+
+	var unlocked bool
+	assert.Nil(t, api.UnlockAccount(&UnlockAccountRequest{Address: address, Passphrase: "password"}, &unlocked))
+	assert.Equal(t, unlocked, true)
+
+	// the nonces follow the txs in the pool
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		assert.Nil(t, api.SendTransaction(&SendTxRequest{From: address, To: to, Amount: big.NewInt(0)}, &txHash))
+
+		hash, err := common.HexToHash(txHash)
+		assert.Nil(t, err)
+
+		tx := s.txPool.GetTransaction(hash)
+		assert.Equal(t, tx.Data.AccountNonce, nonce)
+		assert.Nil(t, tx.Validate(s.chain.CurrentState()))
+	}
+
+	// sign
+	var sigHex string
+	assert.Nil(t, api.Sign(&SignRequest{Address: address, DataHex: "0x0102"}, &sigHex))
+
+	sigBytes, err := hexutil.HexToBytes(sigHex)
+	assert.Nil(t, err)
+	assert.Equal(t, len(sigBytes), 64)
+
+	sig := &crypto.Signature{R: new(big.Int).SetBytes(sigBytes[:32]), S: new(big.Int).SetBytes(sigBytes[32:])}
+	addr := common.HexMustToAddres(address)
+	assert.Equal(t, sig.Verify(&addr, signDataHash([]byte{1, 2})), true)
+
+	// lock
+	assert.Nil(t, api.LockAccount(&address, &unlocked))
+	assert.Equal(t, unlocked, true)
+	assert.Equal(t, api.Sign(&SignRequest{Address: address, DataHex: "0x01"}, &sigHex), keystore.ErrLocked)
+
+	assert.Equal(t, api.UnlockAccount(&UnlockAccountRequest{Address: to, Passphrase: "password"}, &unlocked), keystore.ErrAccountNotFound)
+}
+
+func Test_SeeleService_PersonalAPI(t *testing.T) {
+	hasPersonal := func(s *SeeleService) bool {
+		defer s.Stop()
+
+		for _, api := range s.APIs() {
+			if api.Namespace == "personal" {
+				assert.Equal(t, api.Public, false)
+				return true
+			}
+		}
+
+		return false
+	}
+
+	assert.Equal(t, hasPersonal(newTestFilterService(t)), false)
+	assert.Equal(t, hasPersonal(newTestPersonalService(t, common.GetTempFolder())), true)
+}
@@ -7,16 +7,20 @@ package seele
 
 import (
 	"errors"
+	"math"
+	"math/big"
 	"sync"
 	"time"
 
 	"github.com/seeleteam/go-seele/common"
 	"github.com/seeleteam/go-seele/core"
+	"github.com/seeleteam/go-seele/core/state"
 	"github.com/seeleteam/go-seele/core/types"
 	"github.com/seeleteam/go-seele/event"
 	"github.com/seeleteam/go-seele/log"
 	"github.com/seeleteam/go-seele/p2p"
 	"github.com/seeleteam/go-seele/seele/download"
+	"github.com/seeleteam/go-seele/seele/fetcher"
 )
 
 var (
@@ -34,7 +38,11 @@ var (
 	statusDataMsgCode      uint16 = 6
 	statusChainHeadMsgCode uint16 = 7
 
-	protocolMsgCodeLength uint16 = 13
+	compactBlockMsgCode    uint16 = 13
+	blockTxsRequestMsgCode uint16 = 14
+	blockTxsMsgCode        uint16 = 15
+
+	protocolMsgCodeLength uint16 = 18
 )
 
 // SeeleProtocol service implementation of seele
@@ -44,9 +52,18 @@ type SeeleProtocol struct {
 
 	networkID  uint64
 	downloader *downloader.Downloader
+	fetcher    *fetcher.Fetcher
+	txFetcher  *fetcher.TxFetcher
+	orphans    *orphanPool
+	partials   *partialPool
 	txPool     *core.TransactionPool
 	chain      *core.Blockchain
 
+	// subscriptions of the events shared by all protocols in the process
+	txSub    *event.Subscription
+	blockSub *event.Subscription
+	headSub  *event.Subscription
+
 	wg     sync.WaitGroup
 	quitCh chan struct{}
 	syncCh chan struct{}
@@ -64,44 +81,82 @@ func NewSeeleProtocol(seele *SeeleService, log *log.SeeleLog) (s *SeeleProtocol,
 			Version: SeeleVersion,
 			Length:  protocolMsgCodeLength,
 		},
-		networkID:  seele.networkID,
-		txPool:     seele.TxPool(),
-		chain:      seele.BlockChain(),
-		downloader: downloader.NewDownloader(seele.BlockChain()),
-		log:        log,
-		quitCh:     make(chan struct{}),
-		syncCh:     make(chan struct{}),
+		networkID: seele.networkID,
+		txPool:    seele.TxPool(),
+		chain:     seele.BlockChain(),
+		log:       log,
+		quitCh:    make(chan struct{}),
+		syncCh:    make(chan struct{}),
+
+		peerSet:  newPeerSet(),
+		orphans:  newOrphanPool(),
+		partials: newPartialPool(),
+	}
 
-		peerSet: newPeerSet(),
+	hasBlock := func(hash common.Hash) bool {
+		has, err := s.chain.GetStore().HasBlock(hash)
+		return err == nil && has
+	}
+	chainHeight := func() uint64 {
+		block, _ := s.chain.CurrentBlock()
+		return block.Header.Height
 	}
+	s.downloader = downloader.NewDownloader(s.chain, seele.syncMode, s.disconnectPeer)
+	s.fetcher = fetcher.NewFetcher(hasBlock, chainHeight)
+	s.txFetcher = fetcher.NewTxFetcher(func(hash common.Hash) bool { return s.txPool.GetTransaction(hash) != nil })
 
 	s.Protocol.AddPeer = s.handleAddPeer
 	s.Protocol.DeletePeer = s.handleDelPeer
 
-	event.TransactionInsertedEventManager.AddAsyncListener(s.handleNewTx)
-	event.BlockMinedEventManager.AddAsyncListener(s.handleNewMinedBlock)
+	s.txSub = event.TransactionInsertedEventManager.Subscribe(s.handleNewTx)
+	s.blockSub = event.BlockMinedEventManager.Subscribe(s.handleNewMinedBlock)
+	s.headSub = event.ChainHeaderChangedEventManager.Subscribe(s.handleChainHeadChanged)
 	return s, nil
 }
 
 func (sp *SeeleProtocol) Start() {
 	sp.log.Info("SeeleProtocol.Start called!")
+	sp.wg.Add(1)
 	go sp.syncer()
+	sp.wg.Add(1)
+	go sp.txBroadcaster()
+	sp.fetcher.Start()
+	sp.txFetcher.Start()
 }
 
 // Stop stops protocol, called when seeleService quits.
 func (sp *SeeleProtocol) Stop() {
-	event.BlockMinedEventManager.RemoveListener(sp.handleNewMinedBlock)
-	event.TransactionInsertedEventManager.RemoveListener(sp.handleNewTx)
+	sp.headSub.Unsubscribe()
+	sp.blockSub.Unsubscribe()
+	sp.txSub.Unsubscribe()
+	sp.fetcher.Stop()
+	sp.txFetcher.Stop()
 	close(sp.quitCh)
 	close(sp.syncCh)
 	sp.wg.Wait()
 }
 
+// disconnectPeer disconnects the peer which sends invalid blocks to the downloader.
+func (sp *SeeleProtocol) disconnectPeer(peerID string) {
+	var bad *peer
+	sp.peerSet.ForEach(func(p *peer) bool {
+		if p.peerStrID == peerID {
+			bad = p
+			return false
+		}
+
+		return true
+	})
+
+	if bad != nil {
+		bad.Disconnect(DiscBadBlock)
+	}
+}
+
 // syncer try to synchronise with remote peer
 func (sp *SeeleProtocol) syncer() {
 	defer sp.downloader.Terminate()
 	defer sp.wg.Done()
-	sp.wg.Add(1)
 
 	forceSync := time.NewTicker(forceSyncInterval)
 	for {
@@ -109,6 +164,8 @@ func (sp *SeeleProtocol) syncer() {
 		case <-sp.syncCh:
 			go sp.synchronise(sp.peerSet.bestPeer())
 		case <-forceSync.C:
+			sp.orphans.expire(time.Now())
+			sp.partials.expire(time.Now())
 			go sp.synchronise(sp.peerSet.bestPeer())
 		case <-sp.quitCh:
 			return
@@ -169,7 +226,6 @@ func (sp *SeeleProtocol) broadcastChainHead() {
 // syncTransactions sends pending transactions to remote peer.
 func (sp *SeeleProtocol) syncTransactions(p *peer) {
 	defer sp.wg.Done()
-	sp.wg.Add(1)
 	txs := sp.txPool.GetProcessableTransactions()
 	pending := make([]*types.Transaction, 0)
 	for _, value := range txs {
@@ -180,6 +236,11 @@ func (sp *SeeleProtocol) syncTransactions(p *peer) {
 	if len(pending) == 0 {
 		return
 	}
+
+	for _, tx := range pending {
+		p.markTransaction(tx.Hash)
+	}
+
 	var (
 		resultCh = make(chan error, 1)
 		curPos   = 0
@@ -216,35 +277,261 @@ loopOut:
 	close(resultCh)
 }
 
+// handleNewTx queues the new transaction to the peers which don't know it, the transaction is
+// sent in full to a square root subset of the peers and announced to the rest.
 func (p *SeeleProtocol) handleNewTx(e event.Event) {
-	p.log.Debug("find new tx")
 	tx := e.(*types.Transaction)
 
-	p.peerSet.ForEach(func(peer *peer) bool {
+	// the event is shared by all nodes in the process, e.g. in simulations,
+	// only broadcast the transactions inserted into the own pool.
+	if p.txPool.GetTransaction(tx.Hash) != tx {
+		return
+	}
 
-		if err := peer.sendTransactionHash(tx.Hash); err != nil {
-			p.log.Warn("send transaction failed %s", err.Error())
+	p.log.Debug("find new tx")
+
+	var peers []*peer
+	p.peerSet.ForEach(func(peer *peer) bool {
+		if !peer.knownTxs.has(tx.Hash) {
+			peers = append(peers, peer)
 		}
 		return true
 	})
+
+	// the peers are in random order of map iteration
+	pushed := int(math.Sqrt(float64(len(peers))))
+	for i, peer := range peers {
+		if i < pushed {
+			peer.queueTransaction(tx)
+		} else {
+			peer.queueTransactionHash(tx.Hash)
+		}
+	}
+}
+
+// txBroadcaster sends the queued transactions to the peers periodically.
+func (sp *SeeleProtocol) txBroadcaster() {
+	defer sp.wg.Done()
+
+	ticker := time.NewTicker(txBroadcastInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sp.peerSet.ForEach(func(peer *peer) bool {
+				if err := peer.broadcastTransactions(); err != nil {
+					sp.log.Warn("broadcast transactions to peer %s failed %s", peer.peerStrID, err.Error())
+				}
+				return true
+			})
+		case <-sp.quitCh:
+			return
+		}
+	}
+}
+
+// handleChainHeadChanged imports the orphans waiting for the new HEAD block, so that the
+// orphans are drained whoever writes their parent, e.g. the downloader or the miner.
+func (p *SeeleProtocol) handleChainHeadChanged(e event.Event) {
+	block := e.(*types.Block)
+
+	// the event is shared by all nodes in the process, only import the orphans of the own chain.
+	if has, err := p.chain.GetStore().HasBlock(block.HeaderHash); err != nil || !has {
+		return
+	}
+
+	for _, child := range p.orphans.take(block.HeaderHash) {
+		p.insertBlocks(child)
+	}
 }
 
 func (p *SeeleProtocol) handleNewMinedBlock(e event.Event) {
-	p.log.Debug("find new mined block")
 	block := e.(*types.Block)
 
+	// the event is shared by all nodes in the process, only broadcast the blocks in the own chain.
+	if has, err := p.chain.GetStore().HasBlock(block.HeaderHash); err != nil || !has {
+		return
+	}
+
+	p.log.Debug("find new mined block")
+
+	td, err := p.chain.GetStore().GetBlockTotalDifficulty(block.HeaderHash)
+	if err != nil {
+		p.log.Error("handleNewMinedBlock GetBlockTotalDifficulty err. %s", err)
+		return
+	}
+
+	p.propagateBlock(block, td)
+
+	p.log.Debug("handleNewMinedBlock broadcast chainhead changed")
+	p.log.Debug("new block: %d %s <- %s ", block.Header.Height, block.HeaderHash.ToHex(), block.Header.PreviousBlockHash.ToHex())
+
+	p.broadcastChainHead()
+}
+
+// propagateBlock sends the compact block to a square root subset of the peers which don't
+// know it, and announces the block hash with its height and total difficulty to the rest.
+func (p *SeeleProtocol) propagateBlock(block *types.Block, td *big.Int) {
+	var peers []*peer
 	p.peerSet.ForEach(func(peer *peer) bool {
-		err := peer.SendBlockHash(block.HeaderHash)
-		if err != nil {
-			p.log.Warn("send mined block hash failed %s", err.Error())
+		if !peer.knownBlocks.has(block.HeaderHash) {
+			peers = append(peers, peer)
 		}
 		return true
 	})
 
-	p.log.Debug("handleNewMinedBlock broadcast chainhead changed")
-	p.log.Debug("new block: %d %s <- %s ", block.Header.Height, block.HeaderHash.ToHex(), block.Header.PreviousBlockHash.ToHex())
+	// the peers are in random order of map iteration
+	pushed := int(math.Sqrt(float64(len(peers))))
+	for i, peer := range peers {
+		var err error
+		if i < pushed {
+			err = peer.SendCompactBlock(block, td)
+		} else {
+			err = peer.SendBlockHash(block, td)
+		}
 
-	p.broadcastChainHead()
+		if err != nil {
+			p.log.Warn("propagate block to peer %s failed %s", peer.peerStrID, err.Error())
+		}
+	}
+}
+
+// importBlock validates the header of the block received from the peer, writes it into the
+// chain and relays it to the other peers. The block whose parent is unknown is kept
+// in the orphan pool, and its missing ancestor is requested from the peer.
+func (p *SeeleProtocol) importBlock(peer *peer, block *types.Block) {
+	if has, err := p.chain.GetStore().HasBlock(block.HeaderHash); err != nil || has || p.orphans.has(block.HeaderHash) {
+		p.fetcher.Delivered(block.HeaderHash)
+		return
+	}
+
+	if err := p.chain.ValidateBlockHeader(block); err != nil {
+		p.log.Warn("invalid block %s from peer %s, %s", block.HeaderHash.ToHex(), peer.peerStrID, err)
+		peer.Disconnect(DiscBadBlock)
+		return
+	}
+
+	p.fetcher.Delivered(block.HeaderHash)
+
+	if has, err := p.chain.GetStore().HasBlock(block.Header.PreviousBlockHash); err != nil || !has {
+		p.handleOrphan(peer, block)
+		return
+	}
+
+	p.insertBlocks(block)
+}
+
+// handleOrphan buffers the orphan block, and requests the parent of its earliest orphan
+// ancestor from the peer if it is close to the local chain, otherwise synchronises with the peer.
+func (p *SeeleProtocol) handleOrphan(peer *peer, block *types.Block) {
+	if !p.orphans.add(block, peer.peerStrID, time.Now()) {
+		return
+	}
+
+	ancestor := p.orphans.ancestor(block)
+	current, _ := p.chain.CurrentBlock()
+	p.log.Debug("orphan block %d %s, missing ancestor %s", block.Header.Height, block.HeaderHash.ToHex(), ancestor.Header.PreviousBlockHash.ToHex())
+
+	if ancestor.Header.Height <= current.Header.Height+maxOrphanDistance {
+		p.fetcher.Notify(peer.peerStrID, ancestor.Header.PreviousBlockHash, ancestor.Header.Height-1, time.Now(), peer.SendBlockRequest)
+	} else {
+		go p.synchronise(peer)
+	}
+}
+
+// insertBlocks writes the block into the chain and relays it, followed by the orphans
+// waiting for it. The orphans of the block failed to write are dropped.
+func (p *SeeleProtocol) insertBlocks(block *types.Block) {
+	for queue := []*types.Block{block}; len(queue) > 0; queue = queue[1:] {
+		block = queue[0]
+		children := p.orphans.take(block.HeaderHash)
+
+		parentTD, err := p.chain.GetStore().GetBlockTotalDifficulty(block.Header.PreviousBlockHash)
+		if err != nil {
+			p.log.Warn("get total difficulty of block %s failed, %s", block.Header.PreviousBlockHash.ToHex(), err)
+			continue
+		}
+
+		// the block is written before relayed, so that the peers rebuilding the compact block
+		// could request its transactions from the chain.
+		if err = p.chain.WriteBlock(block); err != nil && err != core.ErrBlockAlreadyExists {
+			p.log.Warn("write block %s failed, %s", block.HeaderHash.ToHex(), err)
+			continue
+		}
+
+		p.propagateBlock(block, new(big.Int).Add(parentTD, block.Header.Difficulty))
+
+		p.log.Debug("imported block: %d %s <- %s", block.Header.Height, block.HeaderHash.ToHex(), block.Header.PreviousBlockHash.ToHex())
+		queue = append(queue, children...)
+	}
+}
+
+// handleCompactBlock rebuilds the block of the compact block with the transactions in the pool,
+// and requests the missing transactions from the peer. The full block is requested by the fetcher
+// if the missing transactions don't arrive in time.
+func (p *SeeleProtocol) handleCompactBlock(peer *peer, compact *compactBlock) {
+	pb, err := newPartialBlock(compact, p.poolTransactions(), peer.peerStrID, time.Now())
+	if err != nil {
+		p.log.Warn("invalid compact block from peer %s, %s", peer.peerStrID, err)
+		peer.Disconnect(DiscBadBlock)
+		return
+	}
+
+	p.log.Debug("got compact block msg %s, %d txs missing", pb.hash.ToHex(), len(pb.missing))
+
+	peer.markBlock(pb.hash)
+	p.updatePeerHead(peer, pb.hash, compact.TD)
+
+	if has, err := p.chain.GetStore().HasBlock(pb.hash); err != nil || has || p.orphans.has(pb.hash) {
+		p.fetcher.Delivered(pb.hash)
+		return
+	}
+
+	if pb.complete() {
+		p.importPartialBlock(peer, pb)
+		return
+	}
+
+	if !p.partials.add(pb) {
+		return
+	}
+
+	if err = peer.sendBlockTxsRequest(pb.hash, pb.missing); err != nil {
+		p.log.Warn("send block txs request msg failed %s", err.Error())
+	}
+
+	p.fetcher.Notify(peer.peerStrID, pb.hash, pb.header.Height, time.Now(), peer.SendBlockRequest)
+}
+
+// importPartialBlock imports the rebuilt block, or requests the full block from the
+// peer if the transactions don't match the header.
+func (p *SeeleProtocol) importPartialBlock(peer *peer, pb *partialBlock) {
+	block, ok := pb.block()
+	if !ok {
+		p.log.Debug("rebuild block %s failed, request the full block", pb.hash.ToHex())
+		p.requestFullBlock(peer, pb.hash)
+		return
+	}
+
+	p.importBlock(peer, block)
+}
+
+// requestFullBlock requests the full block as the fallback of the compact block.
+func (p *SeeleProtocol) requestFullBlock(peer *peer, hash common.Hash) {
+	if err := peer.SendBlockRequest(hash); err != nil {
+		p.log.Warn("send block request msg failed %s", err.Error())
+	}
+}
+
+// poolTransactions returns all the transactions in the pool.
+func (p *SeeleProtocol) poolTransactions() []*types.Transaction {
+	var txs []*types.Transaction
+	for _, pending := range p.txPool.GetProcessableTransactions() {
+		txs = append(txs, pending...)
+	}
+
+	return txs
 }
 
 func (p *SeeleProtocol) handleAddPeer(p2pPeer *p2p.Peer, rw p2p.MsgReadWriter) {
@@ -265,6 +552,7 @@ func (p *SeeleProtocol) handleAddPeer(p2pPeer *p2p.Peer, rw p2p.MsgReadWriter) {
 	p.log.Info("newPeer.HandShake ok")
 	p.peerSet.Add(newPeer)
 	p.downloader.RegisterPeer(newPeer.peerStrID, newPeer)
+	p.wg.Add(1)
 	go p.syncTransactions(newPeer)
 	go p.handleMsg(newPeer)
 }
@@ -272,6 +560,17 @@ func (p *SeeleProtocol) handleAddPeer(p2pPeer *p2p.Peer, rw p2p.MsgReadWriter) {
 func (p *SeeleProtocol) handleDelPeer(p2pPeer *p2p.Peer) {
 }
 
+// updatePeerHead updates the head of the peer if the block has larger total difficulty
+func (p *SeeleProtocol) updatePeerHead(peer *peer, hash common.Hash, td *big.Int) {
+	if td == nil {
+		return
+	}
+
+	if _, peerTD := peer.Head(); td.Cmp(peerTD) > 0 {
+		peer.SetHead(hash, td)
+	}
+}
+
 func (p *SeeleProtocol) handleMsg(peer *peer) {
 handler:
 	for {
@@ -283,38 +582,43 @@ handler:
 
 		switch msg.Code {
 		case transactionHashMsgCode:
-			var txHash common.Hash
-			err := common.Deserialize(msg.Payload, &txHash)
+			var hashes []common.Hash
+			err := common.Deserialize(msg.Payload, &hashes)
 			if err != nil {
 				p.log.Warn("deserialize transaction hash msg failed %s", err.Error())
 				continue
 			}
 
-			p.log.Debug("got tx hash %s", txHash.ToHex())
+			p.log.Debug("got %d tx hashes", len(hashes))
 
-			if !peer.knownTxs.Has(txHash) {
-				peer.knownTxs.Add(txHash) //update peer known transaction
-				err := peer.sendTransactionRequest(txHash)
-				if err != nil {
-					p.log.Warn("send transaction request msg failed %s", err.Error())
-					break handler
-				}
-			} else {
-				p.log.Debug("already have this tx %s", txHash.ToHex())
+			for _, hash := range hashes {
+				peer.markTransaction(hash)
 			}
 
+			p.txFetcher.Notify(peer.peerStrID, hashes, peer.RequestTransactions)
+
 		case transactionRequestMsgCode:
-			var txHash common.Hash
-			err := common.Deserialize(msg.Payload, &txHash)
+			var hashes []common.Hash
+			err := common.Deserialize(msg.Payload, &hashes)
 			if err != nil {
 				p.log.Warn("deserialize transaction request msg failed %s", err.Error())
 				continue
 			}
 
-			p.log.Debug("got tx request %s", txHash.ToHex())
+			p.log.Debug("got %d tx requests", len(hashes))
+
+			var txs []*types.Transaction
+			for _, hash := range hashes {
+				if tx := p.txPool.GetTransaction(hash); tx != nil {
+					txs = append(txs, tx)
+				}
+			}
+
+			if len(txs) == 0 {
+				continue
+			}
 
-			tx := p.txPool.GetTransaction(txHash)
-			err = peer.sendTransaction(tx)
+			err = peer.sendTransactions(txs)
 			if err != nil {
 				p.log.Warn("send transaction msg failed %s", err.Error())
 				break handler
@@ -329,29 +633,28 @@ handler:
 			}
 
 			p.log.Debug("received %d transactions", len(txs))
+			hashes := make([]common.Hash, 0, len(txs))
 			for _, tx := range txs {
-				p.txPool.AddTransaction(tx)
 				peer.markTransaction(tx.Hash)
+				p.txPool.AddTransaction(tx)
+				hashes = append(hashes, tx.Hash)
 			}
 
+			p.txFetcher.Delivered(hashes)
+
 		case blockHashMsgCode:
-			var blockHash common.Hash
-			err := common.Deserialize(msg.Payload, &blockHash)
+			var announce newBlockHash
+			err := common.Deserialize(msg.Payload, &announce)
 			if err != nil {
 				p.log.Warn("deserialize block hash msg failed %s", err.Error())
 				continue
 			}
 
-			p.log.Debug("got block hash msg %s", blockHash.ToHex())
+			p.log.Debug("got block hash msg %s", announce.Hash.ToHex())
 
-			if !peer.knownBlocks.Has(blockHash) {
-				peer.knownBlocks.Add(blockHash)
-				err := peer.SendBlockRequest(blockHash)
-				if err != nil {
-					p.log.Warn("send block request msg failed %s", err.Error())
-					break handler
-				}
-			}
+			peer.markBlock(announce.Hash)
+			p.updatePeerHead(peer, announce.Hash, announce.TD)
+			p.fetcher.Notify(peer.peerStrID, announce.Hash, announce.Number, time.Now(), peer.SendBlockRequest)
 
 		case blockRequestMsgCode:
 			var blockHash common.Hash
@@ -368,22 +671,91 @@ handler:
 				continue
 			}
 
-			err = peer.SendBlock(block)
+			td, err := p.chain.GetStore().GetBlockTotalDifficulty(blockHash)
+			if err != nil {
+				p.log.Warn("not found total difficulty of request block %s", err.Error())
+				continue
+			}
+
+			err = peer.SendBlock(block, td)
 			if err != nil {
 				p.log.Warn("send block msg failed %s", err.Error())
 			}
 
 		case blockMsgCode:
-			var block types.Block
+			var block newBlock
 			err := common.Deserialize(msg.Payload, &block)
+			if err != nil || block.Block == nil || block.Block.Header == nil {
+				p.log.Warn("deserialize block msg failed %v", err)
+				continue
+			}
+
+			p.log.Debug("got block msg %s", block.Block.HeaderHash.ToHex())
+
+			peer.markBlock(block.Block.HeaderHash)
+			p.updatePeerHead(peer, block.Block.HeaderHash, block.TD)
+			p.partials.remove(block.Block.HeaderHash)
+			p.importBlock(peer, block.Block)
+
+		case compactBlockMsgCode:
+			var compact compactBlock
+			err := common.Deserialize(msg.Payload, &compact)
+			if err != nil {
+				p.log.Warn("deserialize compact block msg failed %s", err.Error())
+				continue
+			}
+
+			p.handleCompactBlock(peer, &compact)
+
+		case blockTxsRequestMsgCode:
+			var request blockTxsRequest
+			err := common.Deserialize(msg.Payload, &request)
+			if err != nil {
+				p.log.Warn("deserialize block txs request msg failed %s", err.Error())
+				continue
+			}
+
+			p.log.Debug("got block txs request msg %s", request.Hash.ToHex())
+			block, err := p.chain.GetStore().GetBlock(request.Hash)
+			if err != nil {
+				p.log.Warn("not found request block %s", err.Error())
+				continue
+			}
+
+			txs := make([]*types.Transaction, 0, len(request.Indexes))
+			for _, index := range request.Indexes {
+				if index >= uint64(len(block.Transactions)) {
+					break
+				}
+
+				txs = append(txs, block.Transactions[index])
+			}
+
+			if err = peer.sendBlockTxs(request.Hash, txs); err != nil {
+				p.log.Warn("send block txs msg failed %s", err.Error())
+			}
+
+		case blockTxsMsgCode:
+			var response blockTxs
+			err := common.Deserialize(msg.Payload, &response)
 			if err != nil {
-				p.log.Warn("deserialize block msg failed %s", err.Error())
+				p.log.Warn("deserialize block txs msg failed %s", err.Error())
 				continue
 			}
 
-			p.log.Debug("got block msg %s", block.HeaderHash.ToHex())
-			// @todo need to make sure WriteBlock handle block fork
-			p.chain.WriteBlock(&block)
+			p.log.Debug("got %d txs of block %s", len(response.Txs), response.Hash.ToHex())
+			pb := p.partials.take(response.Hash, peer.peerStrID)
+			if pb == nil {
+				continue
+			}
+
+			if err = pb.fill(response.Txs); err != nil {
+				p.log.Warn("fill txs of block %s failed, %s", response.Hash.ToHex(), err)
+				p.requestFullBlock(peer, response.Hash)
+				continue
+			}
+
+			p.importPartialBlock(peer, pb)
 
 		case downloader.GetBlockHeadersMsg:
 			var query blockHeadersQuery
@@ -405,18 +777,23 @@ handler:
 				orgNum = head.Height
 			}
 
+			if query.Amount > uint64(downloader.MaxHeaderFetch) {
+				query.Amount = uint64(downloader.MaxHeaderFetch)
+			}
+
+			// the headers not found are not sent, so that the requester knows they are missing.
 			for cnt := uint64(0); cnt < query.Amount; cnt++ {
 				var curNum uint64
 				if query.Reverse {
-					curNum = orgNum - cnt
+					curNum = orgNum - cnt*(query.Skip+1)
 				} else {
-					curNum = orgNum + cnt
+					curNum = orgNum + cnt*(query.Skip+1)
 				}
 
 				hash, _ := p.chain.GetStore().GetBlockHash(curNum)
 				if head, err = p.chain.GetStore().GetBlockHeader(hash); err != nil {
-					p.log.Error("HandleMsg GetBlockHeader err. %s", err)
-					break handler
+					p.log.Debug("HandleMsg GetBlockHeader err. %s", err)
+					break
 				}
 				headL = append(headL, head)
 			}
@@ -454,8 +831,8 @@ handler:
 				curNum := orgNum + cnt
 				hash, _ := p.chain.GetStore().GetBlockHash(curNum)
 				if block, err = p.chain.GetStore().GetBlock(hash); err != nil {
-					p.log.Error("HandleMsg GetBlocksMsg p.chain.GetStore().GetBlock err. %s", err)
-					break handler
+					p.log.Debug("HandleMsg GetBlocksMsg p.chain.GetStore().GetBlock err. %s", err)
+					break
 				}
 
 				curLen := len(common.SerializePanic(block))
@@ -478,7 +855,31 @@ handler:
 			}
 			p.log.Debug("send downloader.sendBlockHeaders")
 
-		case downloader.BlockHeadersMsg, downloader.BlocksPreMsg, downloader.BlocksMsg:
+		case downloader.GetNodeDataMsg:
+			var hashes []common.Hash
+			if err := common.Deserialize(msg.Payload, &hashes); err != nil {
+				p.log.Error("deserialize downloader.GetNodeDataMsg failed, quit! %s", err.Error())
+				break handler
+			}
+
+			if len(hashes) > downloader.MaxStateFetch {
+				hashes = hashes[:downloader.MaxStateFetch]
+			}
+
+			var nodes [][]byte
+			for _, hash := range hashes {
+				// the nodes not found are not delivered, and retrieved from other peers.
+				if data, err := state.GetTrieNode(p.chain.AccountStateDB(), hash); err == nil {
+					nodes = append(nodes, data)
+				}
+			}
+
+			if err := peer.sendNodeData(nodes); err != nil {
+				p.log.Error("HandleMsg GetNodeDataMsg sendNodeData err. %s", err)
+				break handler
+			}
+
+		case downloader.BlockHeadersMsg, downloader.BlocksPreMsg, downloader.BlocksMsg, downloader.NodeDataMsg:
 			p.log.Debug("Recved downloader Msg. %d", msg.Code)
 			p.downloader.DeliverMsg(peer.peerStrID, &msg)
 
@@ -501,5 +902,7 @@ handler:
 
 	p.peerSet.Remove(peer.peerID)
 	p.downloader.UnRegisterPeer(peer.peerStrID)
+	p.fetcher.RemovePeer(peer.peerStrID)
+	p.txFetcher.RemovePeer(peer.peerStrID)
 	p.log.Debug("seele.peer.run out!")
 }
@@ -10,12 +10,16 @@ import (
 	"path/filepath"
 
 	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/common/keystore"
 	"github.com/seeleteam/go-seele/core"
+	"github.com/seeleteam/go-seele/core/bloombits"
 	"github.com/seeleteam/go-seele/core/store"
 	"github.com/seeleteam/go-seele/core/types"
 	"github.com/seeleteam/go-seele/database"
 	"github.com/seeleteam/go-seele/database/leveldb"
+	"github.com/seeleteam/go-seele/light"
 	"github.com/seeleteam/go-seele/log"
+	"github.com/seeleteam/go-seele/metrics"
 	"github.com/seeleteam/go-seele/p2p"
 	"github.com/seeleteam/go-seele/rpc"
 	"github.com/seeleteam/go-seele/seele/download"
@@ -24,8 +28,10 @@ import (
 // SeeleService implements full node service.
 type SeeleService struct {
 	networkID     uint64
+	syncMode      downloader.SyncMode
 	p2pServer     *p2p.Server
 	seeleProtocol *SeeleProtocol
+	lightProtocol *light.ServerProtocol // serves the light nodes
 	log           *log.SeeleLog
 	Coinbase      common.Address // account address that mining rewards will be send to.
 
@@ -33,6 +39,11 @@ type SeeleService struct {
 	chain          *core.Blockchain
 	chainDB        database.Database // database used to store blocks.
 	accountStateDB database.Database // database used to store account state info.
+
+	bloomIndexer *bloombits.Indexer // indexes the logs bloom of the blocks in chainDB
+	filterAPI    *PublicFilterAPI
+
+	accountManager *keystore.Manager // nil if the keystore dir is not configured
 }
 
 // ServiceContext is a collection of service configuration inherited from node
@@ -43,6 +54,10 @@ type ServiceContext struct {
 func (s *SeeleService) TxPool() *core.TransactionPool { return s.txPool }
 func (s *SeeleService) BlockChain() *core.Blockchain  { return s.chain }
 func (s *SeeleService) NetVersion() uint64            { return s.networkID }
+
+// PeerCount returns the number of peers which finished the seele protocol handshake.
+func (s *SeeleService) PeerCount() int { return s.seeleProtocol.peerSet.count() }
+
 func (s *SeeleService) Downloader() *downloader.Downloader {
 	return s.seeleProtocol.Downloader()
 }
@@ -56,17 +71,12 @@ func (s *SeeleService) ApplyTransaction(coinbase common.Address, tx *types.Trans
 
 // NewSeeleService create SeeleService
 func NewSeeleService(ctx context.Context, conf *Config, log *log.SeeleLog) (s *SeeleService, err error) {
-	s = &SeeleService{
-		networkID: conf.NetworkID,
-		log:       log,
-	}
-	s.Coinbase = conf.Coinbase
 	serviceContext := ctx.Value("ServiceContext").(ServiceContext)
 
 	// Initialize blockchain DB.
 	chainDBPath := filepath.Join(serviceContext.DataDir, BlockChainDir)
 	log.Info("NewSeeleService BlockChain datadir is %s", chainDBPath)
-	s.chainDB, err = leveldb.NewLevelDB(chainDBPath)
+	chainDB, err := leveldb.NewLevelDB(chainDBPath)
 	if err != nil {
 		log.Error("NewSeeleService Create BlockChain err. %s", err)
 		return nil, err
@@ -75,13 +85,44 @@ func NewSeeleService(ctx context.Context, conf *Config, log *log.SeeleLog) (s *S
 	// Initialize account state info DB.
 	accountStateDBPath := filepath.Join(serviceContext.DataDir, AccountStateDir)
 	log.Info("NewSeeleService account state datadir is %s", accountStateDBPath)
-	s.accountStateDB, err = leveldb.NewLevelDB(accountStateDBPath)
+	accountStateDB, err := leveldb.NewLevelDB(accountStateDBPath)
 	if err != nil {
-		s.chainDB.Close()
+		chainDB.Close()
 		log.Error("NewSeeleService Create BlockChain err: failed to create account state DB, %s", err)
 		return nil, err
 	}
 
+	serviceConf := *conf
+	if len(serviceConf.KeyStoreDir) == 0 {
+		serviceConf.KeyStoreDir = filepath.Join(serviceContext.DataDir, KeyStoreDir)
+	}
+
+	return NewSeeleServiceWithDB(&serviceConf, log, chainDB, accountStateDB)
+}
+
+// NewSeeleServiceWithDB create SeeleService with the opened databases, e.g. memory databases
+// in simulations. The databases are closed when the service stops, or it fails to create.
+// The personal API is not provided if the keystore dir is not configured.
+func NewSeeleServiceWithDB(conf *Config, log *log.SeeleLog, chainDB, accountStateDB database.Database) (s *SeeleService, err error) {
+	s = &SeeleService{
+		networkID:      conf.NetworkID,
+		log:            log,
+		chainDB:        chainDB,
+		accountStateDB: accountStateDB,
+	}
+	s.Coinbase = conf.Coinbase
+
+	if len(conf.KeyStoreDir) > 0 {
+		s.accountManager = keystore.NewManager(conf.KeyStoreDir)
+	}
+
+	if s.syncMode, err = downloader.ParseSyncMode(conf.SyncMode); err != nil {
+		s.chainDB.Close()
+		s.accountStateDB.Close()
+		log.Error("NewSeeleService parse sync mode %s err. %s", conf.SyncMode, err)
+		return nil, err
+	}
+
 	bcStore := store.NewBlockchainDatabase(s.chainDB)
 	genesis := core.DefaultGenesis(bcStore)
 	err = genesis.Initialize(s.accountStateDB)
@@ -100,6 +141,9 @@ func NewSeeleService(ctx context.Context, conf *Config, log *log.SeeleLog) (s *S
 		return nil, err
 	}
 
+	s.bloomIndexer = bloombits.NewIndexer(s.chainDB, bcStore, bloombits.DefaultSectionSize, bloombits.DefaultConfirms)
+	s.filterAPI = NewPublicFilterAPI(s)
+
 	s.txPool = core.NewTransactionPool(conf.TxConf, s.chain)
 	s.seeleProtocol, err = NewSeeleProtocol(s, log)
 	if err != nil {
@@ -109,13 +153,15 @@ func NewSeeleService(ctx context.Context, conf *Config, log *log.SeeleLog) (s *S
 		return nil, err
 	}
 
+	s.lightProtocol = light.NewServerProtocol(s.networkID, s.chain, s.txPool, log)
+
 	return s, nil
 }
 
 // Protocols implements node.Service, returning all the currently configured
 // network protocols to start.
 func (s *SeeleService) Protocols() (protos []p2p.Protocol) {
-	protos = append(protos, s.seeleProtocol.Protocol)
+	protos = append(protos, s.seeleProtocol.Protocol, s.lightProtocol.Protocol)
 	return
 }
 
@@ -124,12 +170,20 @@ func (s *SeeleService) Start(srvr *p2p.Server) error {
 	s.p2pServer = srvr
 
 	s.seeleProtocol.Start()
+	s.lightProtocol.Start()
+	s.bloomIndexer.Start()
+	s.filterAPI.start()
+	s.registerMetrics()
 	return nil
 }
 
 // Stop implements node.Service, terminating all internal goroutines.
 func (s *SeeleService) Stop() error {
+	s.unregisterMetrics()
 	s.seeleProtocol.Stop()
+	s.lightProtocol.Stop()
+	s.filterAPI.stop()
+	s.bloomIndexer.Stop()
 
 	//TODO
 	// s.txPool.Stop() s.chain.Stop()
@@ -139,8 +193,42 @@ func (s *SeeleService) Stop() error {
 	return nil
 }
 
+// The metrics of the tx pool, which are read from the tx pool of the running service.
+const (
+	txPoolPendingMetric = "txpool_pending"
+	txPoolQueuedMetric  = "txpool_queued"
+)
+
+// registerMetrics registers the metrics read from the components of the service.
+func (s *SeeleService) registerMetrics() {
+	metrics.RegisterGaugeFunc(txPoolPendingMetric, "the txs processable in the nonce order", func() float64 {
+		pending, _ := s.txPool.Stats()
+		return float64(pending)
+	})
+
+	metrics.RegisterGaugeFunc(txPoolQueuedMetric, "the txs waiting for the txs of the smaller nonces", func() float64 {
+		_, queued := s.txPool.Stats()
+		return float64(queued)
+	})
+}
+
+// unregisterMetrics unregisters the metrics, so that the stopped service is not referenced.
+func (s *SeeleService) unregisterMetrics() {
+	metrics.Unregister(txPoolPendingMetric)
+	metrics.Unregister(txPoolQueuedMetric)
+}
+
 // APIs implements node.Service, returning the collection of RPC services the seele package offers.
 func (s *SeeleService) APIs() (apis []rpc.API) {
+	if s.accountManager != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "personal",
+			Version:   "1.0",
+			Service:   NewPrivatePersonalAPI(s),
+			Public:    false,
+		})
+	}
+
 	return append(apis, []rpc.API{
 		{
 			Namespace: "seele",
@@ -160,5 +248,23 @@ func (s *SeeleService) APIs() (apis []rpc.API) {
 			Service:   NewPublicNetworkAPI(s.p2pServer, s.NetVersion()),
 			Public:    true,
 		},
+		{
+			Namespace: "filter",
+			Version:   "1.0",
+			Service:   s.filterAPI,
+			Public:    true,
+		},
+		{
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   NewPrivateAdminAPI(s),
+			Public:    false,
+		},
+		{
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   NewPrivateDebugAPI(s),
+			Public:    false,
+		},
 	}...)
 }
@@ -0,0 +1,35 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/seeleteam/go-seele/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SeeleService_Metrics(t *testing.T) {
+	s := newTestFilterService(t)
+	defer s.Stop()
+
+	exported := func() string {
+		var buf bytes.Buffer
+		assert.Nil(t, metrics.WritePrometheus(&buf, metrics.DefaultRegistry))
+		return buf.String()
+	}
+
+	s.registerMetrics()
+	assert.True(t, strings.Contains(exported(), txPoolPendingMetric+" 0\n"))
+	assert.True(t, strings.Contains(exported(), txPoolQueuedMetric+" 0\n"))
+
+	// the tx pool is not referenced after the service stops
+	s.unregisterMetrics()
+	assert.False(t, strings.Contains(exported(), txPoolPendingMetric))
+	assert.False(t, strings.Contains(exported(), txPoolQueuedMetric))
+}
@@ -0,0 +1,184 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"encoding/json"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/common/hexutil"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/event"
+	"github.com/seeleteam/go-seele/rpc"
+)
+
+// The topics of the rpc subscriptions the seele service offers.
+const (
+	NewHeadsTopic               = "newHeads"
+	NewPendingTransactionsTopic = "newPendingTransactions"
+	LogsTopic                   = "logs"
+	SyncingTopic                = "syncing"
+)
+
+// LogFilterRequest is the request param of the logs filter with hex strings.
+type LogFilterRequest struct {
+	Addresses []string   // the contract addresses, empty matches any address
+	Topics    [][]string // the alternative topics at each position, empty matches any topic
+}
+
+// LogFilter filters the logs with the contract addresses and topics.
+type LogFilter struct {
+	Addresses []common.Address
+	Topics    [][]common.Hash
+}
+
+// NewLogFilter parses the hex strings of the request.
+func NewLogFilter(request *LogFilterRequest) (*LogFilter, error) {
+	filter := &LogFilter{
+		Topics: make([][]common.Hash, len(request.Topics)),
+	}
+
+	for _, hex := range request.Addresses {
+		addr, err := common.HexToAddress(hex)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.Addresses = append(filter.Addresses, addr)
+	}
+
+	for i, topics := range request.Topics {
+		for _, hex := range topics {
+			topic, err := common.HexToHash(hex)
+			if err != nil {
+				return nil, err
+			}
+
+			filter.Topics[i] = append(filter.Topics[i], topic)
+		}
+	}
+
+	return filter, nil
+}
+
+// Match returns true if the log is generated by one of the addresses, and the topic at each
+// position is one of the filter topics.
+func (f *LogFilter) Match(log *types.Log) bool {
+	if len(f.Addresses) > 0 && !containsAddress(f.Addresses, log.Address) {
+		return false
+	}
+
+	if len(f.Topics) > len(log.Topics) {
+		return false
+	}
+
+	for i, topics := range f.Topics {
+		if len(topics) > 0 && !containsHash(topics, log.Topics[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsAddress(addresses []common.Address, addr common.Address) bool {
+	for _, a := range addresses {
+		if a == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsHash(hashes []common.Hash, hash common.Hash) bool {
+	for _, h := range hashes {
+		if h == hash {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rpcOutputLog converts the log to the RPC output
+func rpcOutputLog(log *types.Log) map[string]interface{} {
+	topics := make([]string, len(log.Topics))
+	for i, topic := range log.Topics {
+		topics[i] = topic.ToHex()
+	}
+
+	return map[string]interface{}{
+		"address":          log.Address.ToHex(),
+		"topics":           topics,
+		"data":             hexutil.BytesToHex(log.Data),
+		"blockNumber":      log.BlockNumber,
+		"transactionIndex": log.TxIndex,
+	}
+}
+
+// Topics returns the topics of the rpc subscriptions, which are fed by the event managers.
+// The results are notified in the goroutines firing the events, so that they are in order.
+func (s *SeeleService) Topics() []rpc.Topic {
+	return []rpc.Topic{
+		{Name: NewHeadsTopic, Namespace: "seele", Subscribe: subscribeNewHeads},
+		{Name: NewPendingTransactionsTopic, Namespace: "seele", Subscribe: subscribeNewPendingTransactions},
+		{Name: LogsTopic, Namespace: "seele", Subscribe: subscribeLogs},
+		{Name: SyncingTopic, Namespace: "seele", Subscribe: subscribeSyncing},
+	}
+}
+
+// subscribeNewHeads notifies the header of the new HEAD block.
+func subscribeNewHeads(params json.RawMessage, notify func(interface{})) (func(), error) {
+	sub := event.ChainHeaderChangedEventManager.SubscribeSync(func(e event.Event) {
+		notify(rpcOutputHeader(e.(*types.Block)))
+	})
+
+	return sub.Unsubscribe, nil
+}
+
+// subscribeNewPendingTransactions notifies the hash of the transaction added to the tx pool.
+func subscribeNewPendingTransactions(params json.RawMessage, notify func(interface{})) (func(), error) {
+	sub := event.TransactionInsertedEventManager.SubscribeSync(func(e event.Event) {
+		notify(e.(*types.Transaction).Hash.ToHex())
+	})
+
+	return sub.Unsubscribe, nil
+}
+
+// subscribeLogs notifies the logs of the new HEAD block which match the filter of the params.
+func subscribeLogs(params json.RawMessage, notify func(interface{})) (func(), error) {
+	var request LogFilterRequest
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &request); err != nil {
+			return nil, err
+		}
+	}
+
+	filter, err := NewLogFilter(&request)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := event.ChainLogsEventManager.SubscribeSync(func(e event.Event) {
+		for _, log := range e.([]*types.Log) {
+			if filter.Match(log) {
+				notify(rpcOutputLog(log))
+			}
+		}
+	})
+
+	return sub.Unsubscribe, nil
+}
+
+// subscribeSyncing notifies true when the block downloader starts, and false when it stops.
+func subscribeSyncing(params json.RawMessage, notify func(interface{})) (func(), error) {
+	sub := event.BlockDownloaderEventManager.SubscribeSync(func(e event.Event) {
+		notify(e == event.DownloaderStartEvent)
+	})
+
+	return sub.Unsubscribe, nil
+}
@@ -0,0 +1,86 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package seele
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/core/types"
+	"github.com/seeleteam/go-seele/crypto"
+	"github.com/seeleteam/go-seele/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LogFilter_Match(t *testing.T) {
+	addr1, addr2 := *crypto.MustGenerateRandomAddress(), *crypto.MustGenerateRandomAddress()
+	topic1, topic2 := common.StringToHash("topic1"), common.StringToHash("topic2")
+	log := &types.Log{Address: addr1, Topics: []common.Hash{topic1, topic2}}
+
+	match := func(addresses []string, topics [][]string) bool {
+		filter, err := NewLogFilter(&LogFilterRequest{addresses, topics})
+		assert.Equal(t, err, nil)
+		return filter.Match(log)
+	}
+
+	assert.Equal(t, match(nil, nil), true)
+	assert.Equal(t, match([]string{addr2.ToHex(), addr1.ToHex()}, nil), true)
+	assert.Equal(t, match([]string{addr2.ToHex()}, nil), false)
+	assert.Equal(t, match(nil, [][]string{{topic1.ToHex()}}), true)
+	assert.Equal(t, match(nil, [][]string{nil, {topic1.ToHex(), topic2.ToHex()}}), true)
+	assert.Equal(t, match(nil, [][]string{{topic2.ToHex()}}), false)
+	assert.Equal(t, match(nil, [][]string{nil, nil, nil}), false)
+
+	_, err := NewLogFilter(&LogFilterRequest{Addresses: []string{"0xinvalid"}})
+	assert.Equal(t, err != nil, true)
+}
+
+func Test_Subscription_Topics(t *testing.T) {
+	results := make(chan interface{}, 1)
+	notify := func(result interface{}) { results <- result }
+
+	// newHeads
+	unsubscribe, err := subscribeNewHeads(nil, notify)
+	assert.Equal(t, err, nil)
+
+	header := &types.BlockHeader{Height: 5, Difficulty: big.NewInt(1), CreateTimestamp: big.NewInt(1)}
+	block := types.NewBlock(header, nil)
+	event.ChainHeaderChangedEventManager.Fire(block)
+	assert.Equal(t, (<-results).(map[string]interface{})["hash"], block.HeaderHash.ToHex())
+	unsubscribe()
+
+	// logs
+	addr := *crypto.MustGenerateRandomAddress()
+	params, _ := json.Marshal(&LogFilterRequest{Addresses: []string{addr.ToHex()}})
+	unsubscribe, err = subscribeLogs(params, notify)
+	assert.Equal(t, err, nil)
+
+	event.ChainLogsEventManager.Fire([]*types.Log{{Address: *crypto.MustGenerateRandomAddress()}, {Address: addr, BlockNumber: 5}})
+	assert.Equal(t, (<-results).(map[string]interface{})["blockNumber"], uint64(5))
+	unsubscribe()
+
+	_, err = subscribeLogs(json.RawMessage(`{"Addresses": ["0xinvalid"]}`), notify)
+	assert.Equal(t, err != nil, true)
+
+	// syncing
+	unsubscribe, err = subscribeSyncing(nil, notify)
+	assert.Equal(t, err, nil)
+
+	event.BlockDownloaderEventManager.Fire(event.DownloaderStartEvent)
+	assert.Equal(t, <-results, true)
+	event.BlockDownloaderEventManager.Fire(event.DownloaderDoneEvent)
+	assert.Equal(t, <-results, false)
+	unsubscribe()
+
+	select {
+	case result := <-results:
+		t.Fatalf("unexpected notification %v", result)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
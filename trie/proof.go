@@ -0,0 +1,122 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package trie
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/crypto"
+)
+
+var (
+	errProofNodeMissing = errors.New("proof node is missing")
+	errInvalidProofNode = errors.New("proof node is invalid")
+)
+
+// GetProof returns the data of the trie nodes on the path of the key from the root, which
+// proves the value of the key or the absence of the key. The trie must be committed.
+func (t *Trie) GetProof(key []byte) ([][]byte, error) {
+	if t.root == nil {
+		return nil, nil
+	}
+
+	key = keybytesToHex(key)
+	hash, pos := t.root.Hash(), 0
+
+	var proof [][]byte
+	for hash != nil {
+		data, err := t.db.Get(append(append([]byte{}, t.dbprefix...), hash...))
+		if err != nil || len(data) == 0 {
+			return nil, errNodeNotExist
+		}
+
+		proof = append(proof, data)
+		if hash, pos, err = t.nextProofNode(hash, data, key, pos); err != nil {
+			return nil, err
+		}
+	}
+
+	return proof, nil
+}
+
+// VerifyProof verifies the proof of the key against the root hash. It returns the value
+// of the key, or nil if the proof shows the key does not exist in the trie.
+func VerifyProof(root common.Hash, key []byte, proof [][]byte) ([]byte, error) {
+	if root == common.EmptyHash {
+		return nil, nil
+	}
+
+	nodes := make(map[common.Hash][]byte)
+	for _, data := range proof {
+		nodes[crypto.HashBytes(data)] = data
+	}
+
+	t := &Trie{}
+	key = keybytesToHex(key)
+	hash, pos := root.Bytes(), 0
+	for {
+		data, ok := nodes[common.BytesToHash(hash)]
+		if !ok {
+			return nil, errProofNodeMissing
+		}
+
+		node, err := t.decodeNode(hash, data)
+		if err != nil {
+			return nil, err
+		}
+
+		// the leaf is of the key only if the rest of the key matches exactly
+		if leaf, ok := node.(*LeafNode); ok {
+			if !bytes.Equal(leaf.Key, key[pos:]) {
+				return nil, nil
+			}
+
+			return leaf.Value, nil
+		}
+
+		if hash, pos, err = t.nextProofNode(hash, data, key, pos); err != nil {
+			return nil, err
+		}
+
+		if hash == nil {
+			return nil, nil
+		}
+	}
+}
+
+// nextProofNode decodes the node data and returns the hash of the child node on the
+// path of the key and its key position, or nil if the path ends at the node.
+func (t *Trie) nextProofNode(hash, data []byte, key []byte, pos int) ([]byte, int, error) {
+	node, err := t.decodeNode(hash, data)
+	if err != nil {
+		return nil, pos, err
+	}
+
+	switch n := node.(type) {
+	case *LeafNode:
+		return nil, pos, nil
+	case *ExtendNode:
+		if len(key)-pos < len(n.Key) || !bytes.Equal(n.Key, key[pos:pos+len(n.Key)]) {
+			return nil, pos, nil
+		}
+
+		return n.Nextnode.Hash(), pos + len(n.Key), nil
+	case *BranchNode:
+		if pos >= len(key) {
+			return nil, pos, errInvalidProofNode
+		}
+
+		if child := n.Children[key[pos]]; child != nil {
+			return child.Hash(), pos + 1, nil
+		}
+
+		return nil, pos, nil
+	default:
+		return nil, pos, errInvalidProofNode
+	}
+}
@@ -0,0 +1,83 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+package trie
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+	"github.com/seeleteam/go-seele/common"
+)
+
+func Test_Proof(t *testing.T) {
+	db, root, remove := newTestSyncSource(100)
+	defer remove()
+
+	trie, err := NewTrie(root, testSyncPrefix, db)
+	assert.Equal(t, err, nil)
+
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key%d", i))
+		proof, err := trie.GetProof(key)
+		assert.Equal(t, err, nil)
+
+		value, err := VerifyProof(root, key, proof)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, string(value), fmt.Sprintf("value%d", i))
+	}
+
+	// the proof of absence
+	proof, err := trie.GetProof([]byte("key100"))
+	assert.Equal(t, err, nil)
+	value, err := VerifyProof(root, []byte("key100"), proof)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, value == nil, true)
+}
+
+func Test_Proof_Invalid(t *testing.T) {
+	db, root, remove := newTestSyncSource(100)
+	defer remove()
+
+	trie, err := NewTrie(root, testSyncPrefix, db)
+	assert.Equal(t, err, nil)
+
+	key := []byte("key1")
+	proof, err := trie.GetProof(key)
+	assert.Equal(t, err, nil)
+
+	// the proof of another root
+	_, err = VerifyProof(common.StringToHash("root"), key, proof)
+	assert.Equal(t, err, errProofNodeMissing)
+
+	// the node on the path is missing
+	_, err = VerifyProof(root, key, proof[:len(proof)-1])
+	assert.Equal(t, err, errProofNodeMissing)
+
+	// the value of the leaf node is modified
+	modified := append([][]byte{}, proof...)
+	last := append([]byte{}, modified[len(modified)-1]...)
+	last[len(last)-1]++
+	modified[len(modified)-1] = last
+	_, err = VerifyProof(root, key, modified)
+	assert.Equal(t, err, errProofNodeMissing)
+}
+
+func Test_Proof_PrefixKey(t *testing.T) {
+	db, root, remove := newTestSyncSource(100)
+	defer remove()
+
+	trie, err := NewTrie(root, testSyncPrefix, db)
+	assert.Equal(t, err, nil)
+
+	proof, err := trie.GetProof([]byte("key1"))
+	assert.Equal(t, err, nil)
+
+	// the proof of the key does not prove the keys sharing the prefix
+	for _, key := range []string{"key", "ke", "key1\x00", "key1a"} {
+		value, err := VerifyProof(root, []byte(key), proof)
+		assert.Equal(t, value == nil || err != nil, true)
+	}
+}
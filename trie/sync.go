@@ -0,0 +1,191 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+
+package trie
+
+import (
+	"errors"
+
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/crypto"
+	"github.com/seeleteam/go-seele/database"
+)
+
+var (
+	errUnrequestedNode = errors.New("node is not requested")
+	errSyncNotComplete = errors.New("trie sync is not complete")
+)
+
+// syncRequest is a trie node waiting to be retrieved or waiting for its children.
+type syncRequest struct {
+	hash    common.Hash
+	data    []byte         // node data, nil if not retrieved yet
+	parents []*syncRequest // parent nodes waiting for this node
+	deps    int            // number of children not stored yet
+}
+
+// Sync downloads a trie node by node from the root. Each node is verified against its
+// hash when delivered, and only written into the database after all its children, so
+// that a node in the database always has a complete subtree and the sync can resume.
+type Sync struct {
+	trie     *Trie
+	requests map[common.Hash]*syncRequest // hash => nodes retrieving or waiting for children
+	queue    []common.Hash                // hashes of the nodes to retrieve
+	done     map[common.Hash][]byte       // completed nodes to write into database
+}
+
+// NewSync creates a trie sync of the root with the db prefix of the trie nodes.
+func NewSync(root common.Hash, dbprefix []byte, db database.Database) *Sync {
+	s := &Sync{
+		trie:     &Trie{db: db, dbprefix: dbprefix},
+		requests: make(map[common.Hash]*syncRequest),
+		done:     make(map[common.Hash][]byte),
+	}
+
+	if root != common.EmptyHash {
+		s.schedule(root, nil)
+	}
+
+	return s
+}
+
+// schedule requests the node if it is not in database, and returns true if requested.
+func (s *Sync) schedule(hash common.Hash, parent *syncRequest) bool {
+	if _, ok := s.done[hash]; ok || s.hasNode(hash) {
+		return false
+	}
+
+	if req, ok := s.requests[hash]; ok {
+		// the node is shared by several parents
+		if parent != nil {
+			req.parents = append(req.parents, parent)
+		}
+
+		return true
+	}
+
+	req := &syncRequest{hash: hash}
+	if parent != nil {
+		req.parents = []*syncRequest{parent}
+	}
+
+	s.requests[hash] = req
+	s.queue = append(s.queue, hash)
+
+	return true
+}
+
+func (s *Sync) hasNode(hash common.Hash) bool {
+	data, err := s.trie.db.Get(s.nodeKey(hash))
+	return err == nil && len(data) > 0
+}
+
+func (s *Sync) nodeKey(hash common.Hash) []byte {
+	return append(append([]byte{}, s.trie.dbprefix...), hash.Bytes()...)
+}
+
+// Missing returns at most max hashes of the nodes to retrieve, the returned hashes are
+// removed from the queue. Call Retry to retrieve the nodes not delivered again.
+func (s *Sync) Missing(max int) []common.Hash {
+	if max > len(s.queue) {
+		max = len(s.queue)
+	}
+
+	hashes := s.queue[:max]
+	s.queue = s.queue[max:]
+
+	return hashes
+}
+
+// Retry queues the hashes which are requested but not delivered.
+func (s *Sync) Retry(hashes []common.Hash) {
+	for _, hash := range hashes {
+		if req, ok := s.requests[hash]; ok && req.data == nil {
+			s.queue = append(s.queue, hash)
+		}
+	}
+}
+
+// Process verifies and processes the node data, the children not in database are
+// scheduled to retrieve. It returns the hash of the node.
+func (s *Sync) Process(data []byte) (common.Hash, error) {
+	hash := crypto.HashBytes(data)
+	req, ok := s.requests[hash]
+	if !ok || req.data != nil {
+		return hash, errUnrequestedNode
+	}
+
+	node, err := s.trie.decodeNode(hash.Bytes(), data)
+	if err != nil {
+		return hash, err
+	}
+
+	var children []common.Hash
+	switch n := node.(type) {
+	case *LeafNode:
+	case *ExtendNode:
+		children = append(children, common.BytesToHash(n.Nextnode.Hash()))
+	case *BranchNode:
+		for _, child := range n.Children {
+			if child != nil {
+				children = append(children, common.BytesToHash(child.Hash()))
+			}
+		}
+	default:
+		return hash, errNodeFormat
+	}
+
+	req.data = data
+	for _, child := range children {
+		if s.schedule(child, req) {
+			req.deps++
+		}
+	}
+
+	if req.deps == 0 {
+		s.complete(req)
+	}
+
+	return hash, nil
+}
+
+// complete moves the node to the completed nodes, and completes the parents
+// which are not waiting for other children.
+func (s *Sync) complete(req *syncRequest) {
+	s.done[req.hash] = req.data
+	delete(s.requests, req.hash)
+
+	for _, parent := range req.parents {
+		if parent.deps--; parent.deps == 0 {
+			s.complete(parent)
+		}
+	}
+}
+
+// Pending returns the number of nodes being retrieved or waiting for children.
+func (s *Sync) Pending() int {
+	return len(s.requests)
+}
+
+// Commit writes the completed nodes into the batch, and returns the number of nodes written.
+func (s *Sync) Commit(batch database.Batch) int {
+	count := len(s.done)
+	for hash, data := range s.done {
+		batch.Put(s.nodeKey(hash), data)
+	}
+
+	s.done = make(map[common.Hash][]byte)
+
+	return count
+}
+
+// Complete returns nil if all the nodes are retrieved and committed.
+func (s *Sync) Complete() error {
+	if len(s.requests) > 0 || len(s.done) > 0 {
+		return errSyncNotComplete
+	}
+
+	return nil
+}
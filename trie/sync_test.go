@@ -0,0 +1,137 @@
+/**
+*  @file
+*  @copyright defined in go-seele/LICENSE
+ */
+package trie
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+	"github.com/seeleteam/go-seele/common"
+	"github.com/seeleteam/go-seele/database"
+)
+
+var testSyncPrefix = []byte("S")
+
+// newTestSyncSource creates a committed trie of n keys.
+func newTestSyncSource(n int) (database.Database, common.Hash, func()) {
+	db, remove := newTestTrieDB()
+	trie, err := NewTrie(common.EmptyHash, testSyncPrefix, db)
+	if err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < n; i++ {
+		trie.Put([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i)))
+	}
+
+	batch := db.NewBatch()
+	root := trie.Commit(batch)
+	if err = batch.Commit(); err != nil {
+		panic(err)
+	}
+
+	return db, root, remove
+}
+
+// syncTrie retrieves at most limit nodes from the source, and returns the number of nodes retrieved.
+func syncTrie(t *testing.T, s *Sync, source, db database.Database, limit int) int {
+	count := 0
+	for hashes := s.Missing(16); len(hashes) > 0 && count < limit; hashes = s.Missing(16) {
+		for _, hash := range hashes {
+			data, err := source.Get(append(append([]byte{}, testSyncPrefix...), hash.Bytes()...))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = s.Process(data); err != nil {
+				t.Fatal(err)
+			}
+
+			count++
+		}
+
+		batch := db.NewBatch()
+		s.Commit(batch)
+		if err := batch.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return count
+}
+
+func Test_Sync(t *testing.T) {
+	source, root, removeSource := newTestSyncSource(100)
+	defer removeSource()
+
+	db, remove := newTestTrieDB()
+	defer remove()
+
+	s := NewSync(root, testSyncPrefix, db)
+	syncTrie(t, s, source, db, 1<<20)
+	assert.Equal(t, s.Complete(), nil)
+
+	trie, err := NewTrie(root, testSyncPrefix, db)
+	assert.Equal(t, err, nil)
+	for i := 0; i < 100; i++ {
+		value, ok := trie.Get([]byte(fmt.Sprintf("key%d", i)))
+		assert.Equal(t, ok, true)
+		assert.Equal(t, string(value), fmt.Sprintf("value%d", i))
+	}
+
+	// nothing to retrieve as the trie is complete
+	s = NewSync(root, testSyncPrefix, db)
+	assert.Equal(t, len(s.Missing(16)), 0)
+	assert.Equal(t, s.Complete(), nil)
+}
+
+func Test_Sync_Resume(t *testing.T) {
+	source, root, removeSource := newTestSyncSource(100)
+	defer removeSource()
+
+	fullDB, removeFull := newTestTrieDB()
+	defer removeFull()
+	full := syncTrie(t, NewSync(root, testSyncPrefix, fullDB), source, fullDB, 1<<20)
+
+	db, remove := newTestTrieDB()
+	defer remove()
+
+	// the interrupted sync writes nothing until a subtree is complete
+	s := NewSync(root, testSyncPrefix, db)
+	syncTrie(t, s, source, db, 20)
+	_, err := db.Get(append(append([]byte{}, testSyncPrefix...), root.Bytes()...))
+	assert.Equal(t, err != nil, true)
+
+	// the complete subtrees are not retrieved again
+	s = NewSync(root, testSyncPrefix, db)
+	resumed := syncTrie(t, s, source, db, 1<<20)
+	assert.Equal(t, s.Complete(), nil)
+	assert.Equal(t, resumed < full, true)
+
+	_, err = NewTrie(root, testSyncPrefix, db)
+	assert.Equal(t, err, nil)
+}
+
+func Test_Sync_InvalidNode(t *testing.T) {
+	_, root, removeSource := newTestSyncSource(10)
+	defer removeSource()
+
+	db, remove := newTestTrieDB()
+	defer remove()
+
+	s := NewSync(root, testSyncPrefix, db)
+	hashes := s.Missing(16)
+	assert.Equal(t, hashes, []common.Hash{root})
+
+	// the data not matching the hash is rejected
+	_, err := s.Process([]byte("invalid node"))
+	assert.Equal(t, err, errUnrequestedNode)
+	assert.Equal(t, s.Pending(), 1)
+
+	// the hash not delivered is retrieved again
+	s.Retry(hashes)
+	assert.Equal(t, s.Missing(16), []common.Hash{root})
+}